@@ -0,0 +1,440 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/thellimist/clihub/runtime/auth"
+)
+
+var (
+	flagAuthCmdProfile     string
+	flagAuthCmdAll         bool
+	flagAuthShowSecrets    bool
+	flagAuthRediscover     bool
+	flagAuthPassphraseFile string
+	flagAuthServers        []string
+	flagAuthOverwrite      bool
+	flagAuthCmdTimeout     int
+	flagAuthCmdProxy       string
+	flagAuthCmdClientCert  string
+	flagAuthCmdClientKey   string
+	flagAuthCmdCAFile      string
+)
+
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Inspect and manage stored credentials",
+	Long: `Inspect and manage the credentials clihub and generated CLIs store in
+~/.clihub/credentials.json (or $CLIHUB_CREDENTIALS_FILE).
+
+Secrets are redacted unless --show-secrets is given. Bundles written by
+` + "`clihub auth export`" + ` are encrypted with a passphrase, read from
+--passphrase-file, $CLIHUB_BUNDLE_PASSPHRASE or stdin.`,
+	Example: `  clihub auth list
+  clihub auth show https://mcp.linear.app/mcp --profile work
+  clihub auth refresh https://mcp.linear.app/mcp
+  clihub auth export creds.bundle && clihub auth import creds.bundle`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+func init() {
+	listCmd := &cobra.Command{
+		Use:           "list",
+		Short:         "List stored credentials",
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runAuthList,
+	}
+
+	showCmd := &cobra.Command{
+		Use:           "show <server-url>",
+		Short:         "Show a stored credential",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runAuthShow,
+	}
+	showCmd.Flags().StringVar(&flagAuthCmdProfile, "profile", "", "credential profile (default: $CLIHUB_PROFILE or the server's default profile)")
+	showCmd.Flags().BoolVar(&flagAuthShowSecrets, "show-secrets", false, "print tokens, passwords and client secrets")
+
+	removeCmd := &cobra.Command{
+		Use:           "remove <server-url>",
+		Short:         "Remove a stored credential",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runAuthRemove,
+	}
+	removeCmd.Flags().StringVar(&flagAuthCmdProfile, "profile", "", "credential profile (default: $CLIHUB_PROFILE or the server's default profile)")
+	removeCmd.Flags().BoolVar(&flagAuthCmdAll, "all", false, "remove the credentials of every profile for the server")
+
+	refreshCmd := &cobra.Command{
+		Use:           "refresh <server-url>",
+		Short:         "Obtain a fresh token for a stored credential",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runAuthRefresh,
+	}
+	refreshCmd.Flags().StringVar(&flagAuthCmdProfile, "profile", "", "credential profile (default: $CLIHUB_PROFILE or the server's default profile)")
+	refreshCmd.Flags().BoolVar(&flagAuthRediscover, "rediscover", false, "discover the OAuth token endpoint again instead of using the stored one")
+	refreshCmd.Flags().IntVar(&flagAuthCmdTimeout, "timeout", 30000, "timeout in milliseconds")
+	refreshCmd.Flags().StringVar(&flagAuthCmdProxy, "proxy", "", "HTTP(S) proxy URL (default $HTTPS_PROXY/$HTTP_PROXY, honouring $NO_PROXY)")
+	refreshCmd.Flags().StringVar(&flagAuthCmdClientCert, "client-cert", "", "client certificate PEM file for mTLS (default $CLIHUB_CLIENT_CERT)")
+	refreshCmd.Flags().StringVar(&flagAuthCmdClientKey, "client-key", "", "client key PEM file for mTLS (default $CLIHUB_CLIENT_KEY)")
+	refreshCmd.Flags().StringVar(&flagAuthCmdCAFile, "ca-file", "", "extra CA bundle to trust (default $CLIHUB_CA_FILE)")
+
+	exportCmd := &cobra.Command{
+		Use:           "export <file>",
+		Short:         "Write credentials to an encrypted bundle",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runAuthExport,
+	}
+	exportCmd.Flags().StringArrayVar(&flagAuthServers, "server", nil, "only export this server URL (repeatable)")
+	exportCmd.Flags().StringVar(&flagAuthPassphraseFile, "passphrase-file", "", "file holding the bundle passphrase")
+
+	importCmd := &cobra.Command{
+		Use:           "import <file>",
+		Short:         "Add credentials from an encrypted bundle",
+		Args:          cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE:          runAuthImport,
+	}
+	importCmd.Flags().StringVar(&flagAuthPassphraseFile, "passphrase-file", "", "file holding the bundle passphrase")
+	importCmd.Flags().BoolVar(&flagAuthOverwrite, "overwrite", false, "replace credentials that already exist")
+
+	authCmd.AddCommand(listCmd, showCmd, removeCmd, refreshCmd, exportCmd, importCmd)
+}
+
+func runAuthList(cmd *cobra.Command, args []string) error {
+	creds, err := auth.LoadCredentials(auth.DefaultCredentialsPath())
+	if err != nil {
+		return err
+	}
+	entries := creds.Entries()
+	out := cmd.OutOrStdout()
+	if len(entries) == 0 {
+		fmt.Fprintf(out, "No stored credentials in %s\n", auth.DefaultCredentialsPath())
+		return nil
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SERVER\tPROFILE\tTYPE\tEXPIRES\tSCOPES")
+	for _, e := range entries {
+		profile := e.Profile
+		if creds.ResolveProfile(e.ServerURL, "") == e.Profile {
+			profile += " *"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.ServerURL, profile, e.Credential.ResolveAuthType(),
+			describeExpiry(e.Credential), orDash(credentialScopes(e.Credential)))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "\n* active profile")
+	return nil
+}
+
+func runAuthShow(cmd *cobra.Command, args []string) error {
+	serverURL := args[0]
+	creds, err := auth.LoadServerCredentials(auth.DefaultCredentialsPath(), serverURL)
+	if err != nil {
+		return err
+	}
+	profile, sc, err := storedCredential(creds, serverURL)
+	if err != nil {
+		return err
+	}
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintf(out, "Server:    %s\n", serverURL)
+	fmt.Fprintf(out, "Profile:   %s\n", profile)
+	fmt.Fprintf(out, "Auth type: %s\n", sc.ResolveAuthType())
+	if sc.SecretStore != "" {
+		fmt.Fprintf(out, "Secrets:   %s store\n", sc.SecretStore)
+	}
+	fmt.Fprintf(out, "Expires:   %s\n", describeExpiry(sc))
+	fmt.Fprintf(out, "Scopes:    %s\n", orDash(credentialScopes(sc)))
+
+	if !flagAuthShowSecrets {
+		sc = sc.Redacted()
+	}
+	sc.SecretStore = ""
+	data, err := json.MarshalIndent(sc, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "\n%s\n", data)
+	return nil
+}
+
+func runAuthRemove(cmd *cobra.Command, args []string) error {
+	serverURL := args[0]
+	credPath := auth.DefaultCredentialsPath()
+	var removed []string
+	err := auth.UpdateCredentials(credPath, func(creds *auth.CredentialsFile) error {
+		profiles := creds.ServerProfiles(serverURL)
+		if !flagAuthCmdAll {
+			profile, _, err := storedCredential(creds, serverURL)
+			if err != nil {
+				return err
+			}
+			profiles = []string{profile}
+		}
+		if len(profiles) == 0 {
+			return fmt.Errorf("no credentials stored for %s", serverURL)
+		}
+		for _, profile := range profiles {
+			creds.DeleteCredential(serverURL, profile)
+		}
+		removed = profiles
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Removed credentials for %s (profile %s)\n", serverURL, strings.Join(removed, ", "))
+	return nil
+}
+
+func runAuthRefresh(cmd *cobra.Command, args []string) error {
+	serverURL := args[0]
+	credPath := auth.DefaultCredentialsPath()
+	if err := configureAuthCmdTransport(); err != nil {
+		return err
+	}
+
+	var profile string
+	var sc auth.ServerCredential
+	err := auth.UpdateCredentials(credPath, func(creds *auth.CredentialsFile) error {
+		var err error
+		if profile, sc, err = storedCredential(creds, serverURL); err != nil {
+			return err
+		}
+		if flagAuthRediscover && sc.TokenEndpoint != "" && sc.ResolveAuthType() == "oauth2" {
+			// The refresh discovers the endpoint again and stores it
+			sc.TokenEndpoint = ""
+			creds.SetCredential(serverURL, profile, sc)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(flagAuthCmdTimeout)*time.Millisecond)
+	defer cancel()
+	provider, err := storedAuthProvider(serverURL, credPath, profile, sc, nil)
+	if err != nil {
+		return err
+	}
+	out := cmd.OutOrStdout()
+	switch p := provider.(type) {
+	case *auth.OAuth2Provider:
+		if err := p.Refresh(ctx); err != nil {
+			return fmt.Errorf("refresh %s: %w\n\nSign in again with `clihub generate --url %s --oauth`", serverURL, err, serverURL)
+		}
+		creds, err := auth.LoadServerCredentials(credPath, serverURL)
+		if err != nil {
+			return err
+		}
+		sc, _ = creds.Credential(serverURL, profile)
+		fmt.Fprintf(out, "Refreshed token for %s (profile %s), expires %s\n", serverURL, profile, describeExpiry(sc))
+	case *auth.S2SOAuth2Provider:
+		if _, err := p.Authenticate(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Obtained a client credentials token for %s (profile %s)\n", serverURL, profile)
+	case *auth.GoogleSAProvider, *auth.TokenExchangeProvider, *auth.ExecCredentialProvider:
+		if _, err := provider.GetHeaders(ctx); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Obtained a %s token for %s (profile %s)\n", sc.ResolveAuthType(), serverURL, profile)
+	default:
+		fmt.Fprintf(out, "%s credentials for %s do not expire; nothing to refresh\n", sc.ResolveAuthType(), serverURL)
+	}
+	return nil
+}
+
+func runAuthExport(cmd *cobra.Command, args []string) error {
+	creds, err := auth.LoadCredentials(auth.DefaultCredentialsPath())
+	if err != nil {
+		return err
+	}
+	entries := creds.Entries()
+	if len(flagAuthServers) > 0 {
+		wanted := map[string]bool{}
+		for _, s := range flagAuthServers {
+			wanted[s] = true
+		}
+		var filtered []auth.CredentialEntry
+		for _, e := range entries {
+			if wanted[e.ServerURL] {
+				filtered = append(filtered, e)
+				delete(wanted, e.ServerURL)
+			}
+		}
+		for _, s := range flagAuthServers {
+			if wanted[s] {
+				return fmt.Errorf("no credentials stored for %s", s)
+			}
+		}
+		entries = filtered
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no stored credentials to export")
+	}
+
+	passphrase, err := bundlePassphrase(cmd)
+	if err != nil {
+		return err
+	}
+	data, err := auth.ExportBundle(entries, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(args[0], data, 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Exported %d credential(s) to %s\n", len(entries), args[0])
+	return nil
+}
+
+func runAuthImport(cmd *cobra.Command, args []string) error {
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	passphrase, err := bundlePassphrase(cmd)
+	if err != nil {
+		return err
+	}
+	entries, err := auth.ImportBundle(data, passphrase)
+	if err != nil {
+		return err
+	}
+
+	var imported, skipped int
+	err = auth.UpdateCredentials(auth.DefaultCredentialsPath(), func(creds *auth.CredentialsFile) error {
+		imported, skipped = 0, 0
+		for _, e := range entries {
+			if _, exists := creds.Credential(e.ServerURL, e.Profile); exists && !flagAuthOverwrite {
+				skipped++
+				continue
+			}
+			creds.SetCredential(e.ServerURL, e.Profile, e.Credential)
+			imported++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "Imported %d credential(s) from %s\n", imported, args[0])
+	if skipped > 0 {
+		fmt.Fprintf(cmd.OutOrStdout(), "Skipped %d that already exist; use --overwrite to replace them\n", skipped)
+	}
+	return nil
+}
+
+// storedCredential returns the credential for serverURL in the profile
+// selected by --profile.
+func storedCredential(creds *auth.CredentialsFile, serverURL string) (string, auth.ServerCredential, error) {
+	profile := creds.ResolveProfile(serverURL, flagAuthCmdProfile)
+	sc, ok := creds.Credential(serverURL, profile)
+	if !ok {
+		if profiles := creds.ServerProfiles(serverURL); len(profiles) > 0 {
+			return "", sc, fmt.Errorf("no credentials for %s in profile %s (stored profiles: %s)", serverURL, profile, strings.Join(profiles, ", "))
+		}
+		return "", sc, fmt.Errorf("no credentials stored for %s", serverURL)
+	}
+	return profile, sc, nil
+}
+
+// bundlePassphrase reads the bundle passphrase from --passphrase-file,
+// $CLIHUB_BUNDLE_PASSPHRASE or the first line of stdin.
+func bundlePassphrase(cmd *cobra.Command) (string, error) {
+	if flagAuthPassphraseFile != "" {
+		data, err := os.ReadFile(flagAuthPassphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("read passphrase file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	if p := os.Getenv("CLIHUB_BUNDLE_PASSPHRASE"); p != "" {
+		return p, nil
+	}
+	fmt.Fprint(cmd.ErrOrStderr(), "Bundle passphrase: ")
+	line, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("no bundle passphrase given")
+	}
+	return line, nil
+}
+
+// configureAuthCmdTransport applies --proxy and the TLS flags (falling back
+// to their environment variables) to auth requests made by
+// `clihub auth refresh`.
+func configureAuthCmdTransport() error {
+	tlsOpts := tlsOptions(flagAuthCmdClientCert, flagAuthCmdClientKey, flagAuthCmdCAFile)
+	transport, err := auth.TransportOptions{TLS: tlsOpts, Proxy: flagAuthCmdProxy, UserAgent: "clihub/" + appVersion}.RoundTripper()
+	if err != nil {
+		return err
+	}
+	auth.Transport = transport
+	return nil
+}
+
+// describeExpiry reports when a credential's access token expires.
+func describeExpiry(sc auth.ServerCredential) string {
+	if sc.ExpiresAt == nil {
+		return "-"
+	}
+	left := time.Until(*sc.ExpiresAt).Round(time.Minute)
+	when := sc.ExpiresAt.Local().Format(time.RFC3339)
+	if left <= 0 {
+		return when + " (expired)"
+	}
+	return fmt.Sprintf("%s (in %s)", when, humanDuration(left))
+}
+
+func humanDuration(d time.Duration) string {
+	if d >= 48*time.Hour {
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	}
+	return strings.TrimSuffix(d.String(), "0s")
+}
+
+func credentialScopes(sc auth.ServerCredential) string {
+	if len(sc.Scopes) > 0 {
+		return strings.Join(sc.Scopes, " ")
+	}
+	return sc.Scope
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -1,12 +1,15 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
@@ -15,13 +18,16 @@ import (
 	"github.com/mark3labs/mcp-go/client/transport"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/spf13/cobra"
-	"github.com/thellimist/clihub/internal/auth"
 	"github.com/thellimist/clihub/internal/codegen"
 	"github.com/thellimist/clihub/internal/compile"
 	"github.com/thellimist/clihub/internal/gocheck"
+	"github.com/thellimist/clihub/internal/manifest"
 	"github.com/thellimist/clihub/internal/nameutil"
+	"github.com/thellimist/clihub/internal/release"
 	"github.com/thellimist/clihub/internal/schema"
 	"github.com/thellimist/clihub/internal/toolfilter"
+	"github.com/thellimist/clihub/runtime/auth"
+	"github.com/thellimist/clihub/runtime/update"
 )
 
 var (
@@ -30,21 +36,47 @@ var (
 	flagName            string
 	flagOutput          string
 	flagPlatform        string
-	flagIncludeTools    string
-	flagExcludeTools    string
+	flagIncludeTools    []string
+	flagExcludeTools    []string
 	flagAuthToken       string
 	flagAuthType        string
 	flagAuthHeaderName  string
 	flagAuthKeyFile     string
+	flagAuthExec        string
+	flagTokenEndpoint   string
+	flagSubjectFile     string
+	flagSubjectEnv      string
+	flagSubjectType     string
+	flagAudience        string
 	flagTimeout         int
+	flagClientCert      string
+	flagClientKey       string
+	flagCAFile          string
+	flagHeaders         []string
+	flagProxy           string
+	flagCLIVersion      string
 	flagEnv             []string
 	flagSaveCredentials bool
 	flagOAuth           bool
+	flagDevice          bool
 	flagClientID        string
 	flagClientSecret    string
+	flagProfile         string
 	flagHelpAuth        bool
 	flagVerbose         bool
 	flagQuiet           bool
+	flagOnlyReadOnly    bool
+	flagExcludeDestruct bool
+	flagDryRun          bool
+	flagGroupCommands   bool
+	flagManifest        string
+	flagSignKey         string
+	flagUpdateURL       string
+	flagUpdateKey       string
+	flagJobs            int
+	flagDocs            bool
+	flagAgentGuide      bool
+	flagCacheDir        string
 )
 
 var generateCmd = &cobra.Command{
@@ -74,11 +106,17 @@ Examples:
   # Filter tools
   clihub generate --url https://mcp.example.com/mcp --include-tools create_issue,list_issues
 
+  # Filter tools by pattern and annotations, and preview the result
+  clihub generate --url https://mcp.example.com/mcp --include-tools 'list_*' --exclude-destructive --dry-run
+
+  # Group commands by prefix and apply curated names
+  clihub generate --url https://mcp.example.com/mcp --group-commands --manifest clihub.json
+
   # Pass environment variables to stdio server
   clihub generate --stdio "npx server" --env GITHUB_TOKEN=$TOKEN --env DEBUG=true`,
 	SilenceUsage:  true,
 	SilenceErrors: true,
-	RunE:          runGenerate,
+	RunE:          runGenerateWithStepUp,
 }
 
 func init() {
@@ -87,19 +125,45 @@ func init() {
 	f.StringVar(&flagStdio, "stdio", "", "shell command that spawns a local MCP server via stdin/stdout")
 	f.StringVar(&flagName, "name", "", "override the auto-inferred name for the generated CLI")
 	f.StringVar(&flagOutput, "output", "./out/", "directory where compiled binaries are written")
-	f.StringVar(&flagPlatform, "platform", runtime.GOOS+"/"+runtime.GOARCH, "comma-separated GOOS/GOARCH pairs or 'all'")
-	f.StringVar(&flagIncludeTools, "include-tools", "", "only include these tools (comma-separated)")
-	f.StringVar(&flagExcludeTools, "exclude-tools", "", "exclude these tools (comma-separated)")
+	f.StringVar(&flagCLIVersion, "cli-version", "1.0.0", "version reported by the generated CLI (--version, User-Agent, MCP client info)")
+	f.StringVar(&flagPlatform, "platform", runtime.GOOS+"/"+runtime.GOARCH, "comma-separated GOOS/GOARCH targets (arm accepts /v5, /v6, /v7; see 'go tool dist list') or 'all'")
+	f.IntVar(&flagJobs, "jobs", 0, "maximum number of platforms built at once (default: number of CPUs)")
+	f.StringVar(&flagCacheDir, "cache-dir", "", "directory for the Go build and module caches, reused across runs (default $CLIHUB_CACHE_DIR, else Go's caches)")
+	f.BoolVar(&flagDocs, "docs", false, "also write Markdown reference docs to <output>/docs and man pages to <output>/man")
+	f.BoolVar(&flagAgentGuide, "agent-guide", false, "also write a guide for LLM agents to <output>/agent: an AGENTS.md section, a skill folder and a JSON tool manifest")
+	f.StringVar(&flagSignKey, "sign-key", "", "minisign or cosign private key used to sign SHA256SUMS (password from $CLIHUB_SIGN_PASSWORD)")
+	f.StringVar(&flagUpdateURL, "update-url", "", "add a self-update command fetching releases from this directory or http(s) base URL, where the output directory is published")
+	f.StringVar(&flagUpdateKey, "update-key", "", "minisign or cosign public key self-update requires SHA256SUMS to be signed with")
+	f.StringArrayVar(&flagIncludeTools, "include-tools", nil, "only include these tools (comma-separated names, globs, or re:<regex>; repeatable, a regex runs to the end of its value)")
+	f.StringArrayVar(&flagExcludeTools, "exclude-tools", nil, "exclude these tools, applied after --include-tools (comma-separated names, globs, or re:<regex>; repeatable)")
+	f.BoolVar(&flagOnlyReadOnly, "only-read-only", false, "only include tools annotated read-only")
+	f.BoolVar(&flagExcludeDestruct, "exclude-destructive", false, "exclude destructive tools (any tool not annotated read-only, unless destructiveHint is false)")
+	f.BoolVar(&flagDryRun, "dry-run", false, "print which tools would be generated and exit")
+	f.BoolVar(&flagGroupCommands, "group-commands", false, "group tools that share a name prefix under parent commands (github_issue_create → issue create)")
+	f.StringVar(&flagManifest, "manifest", "", "JSON manifest with per-tool command names and aliases")
 	f.StringVar(&flagAuthToken, "auth-token", "", "bearer token for authenticated MCP servers")
 	f.StringVar(&flagAuthType, "auth-type", "", "authentication type: bearer, api_key, basic, none")
 	f.StringVar(&flagAuthHeaderName, "auth-header-name", "", "custom header name for api_key auth (default X-API-Key)")
 	f.StringVar(&flagAuthKeyFile, "auth-key-file", "", "path to Google service account JSON key file")
+	f.StringVar(&flagAuthExec, "auth-exec", "", "credential helper command that prints {\"token\", \"expires_at\"} JSON")
+	f.StringVar(&flagTokenEndpoint, "token-endpoint", "", "token endpoint for --auth-type token_exchange")
+	f.StringVar(&flagSubjectFile, "subject-token-file", "", "file holding the subject token to exchange (workload identity)")
+	f.StringVar(&flagSubjectEnv, "subject-token-env", "", "environment variable holding the subject token to exchange")
+	f.StringVar(&flagSubjectType, "subject-token-type", "", "subject token type URN (default urn:ietf:params:oauth:token-type:jwt)")
+	f.StringVar(&flagAudience, "audience", "", "audience to request in the token exchange")
 	f.IntVar(&flagTimeout, "timeout", 30000, "timeout in milliseconds for MCP connection")
+	f.StringVar(&flagClientCert, "client-cert", "", "PEM client certificate for mTLS (or set CLIHUB_CLIENT_CERT)")
+	f.StringVar(&flagClientKey, "client-key", "", "PEM private key for --client-cert (or set CLIHUB_CLIENT_KEY)")
+	f.StringVar(&flagCAFile, "ca-file", "", "PEM CA bundle to trust in addition to system roots; embedded in the generated CLI (or set CLIHUB_CA_FILE)")
+	f.StringArrayVar(&flagHeaders, "header", nil, "extra HTTP header for MCP requests, also embedded as a default in the generated CLI (KEY=VALUE, repeatable)")
+	f.StringVar(&flagProxy, "proxy", "", "HTTP(S) proxy URL, may include user:password (default: $HTTPS_PROXY/$HTTP_PROXY, honouring $NO_PROXY)")
 	f.StringSliceVar(&flagEnv, "env", nil, "environment variables for stdio servers (KEY=VALUE, repeatable)")
 	f.BoolVar(&flagSaveCredentials, "save-credentials", false, "persist auth token to ~/.clihub/credentials.json")
 	f.BoolVar(&flagOAuth, "oauth", false, "use OAuth for authentication (interactive browser flow)")
+	f.BoolVar(&flagDevice, "device", false, "authorize OAuth on another device (RFC 8628 device flow, or paste the redirect URL) instead of opening a local browser")
 	f.StringVar(&flagClientID, "client-id", "", "pre-registered OAuth client ID (use with --oauth)")
 	f.StringVar(&flagClientSecret, "client-secret", "", "pre-registered OAuth client secret (use with --oauth)")
+	f.StringVar(&flagProfile, "profile", "", "credential profile to read and save (default: $CLIHUB_PROFILE or the server's default profile)")
 	f.BoolVar(&flagHelpAuth, "help-auth", false, "show authentication flags and exit")
 	f.BoolVar(&flagVerbose, "verbose", false, "show detailed progress during generation")
 	f.BoolVar(&flagQuiet, "quiet", false, "suppress all output except errors")
@@ -107,6 +171,34 @@ func init() {
 	hideGenerateAuthFlags()
 }
 
+// runGenerateWithStepUp runs runGenerate and, when the server answers 403
+// insufficient_scope for an OAuth credential, re-authorizes with the broader
+// scope and runs it once more. The step-up may wait minutes for the user,
+// so it runs outside --timeout, which bounds each run.
+func runGenerateWithStepUp(cmd *cobra.Command, args []string) error {
+	err := runGenerate(cmd, args)
+	var scopeErr *auth.InsufficientScopeError
+	if !errors.As(err, &scopeErr) {
+		return err
+	}
+	provider, perr := resolveAuthProvider(flagURL)
+	if perr != nil {
+		return err
+	}
+	p := scopeStepUpProvider(provider)
+	if p == nil {
+		return err
+	}
+	stepped, serr := p.StepUp(context.Background(), scopeErr.Scope, confirmScopeStepUp)
+	if serr != nil {
+		return fmt.Errorf("server requires scope %q: %w", scopeErr.Scope, serr)
+	}
+	if !stepped {
+		return err
+	}
+	return runGenerate(cmd, args)
+}
+
 func runGenerate(cmd *cobra.Command, args []string) error {
 	if flagHelpAuth {
 		printGenerateAuthHelp(cmd.OutOrStdout())
@@ -117,13 +209,29 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	// REQ-01, REQ-66: Check Go toolchain
-	verbose("Checking Go toolchain...")
-	goVersion, err := gocheck.Check()
+	// REQ-01, REQ-66: Check Go toolchain (not needed to preview tools)
+	if !flagDryRun {
+		verbose("Checking Go toolchain...")
+		goVersion, err := gocheck.Check()
+		if err != nil {
+			return err
+		}
+		verbose("Found %s", goVersion)
+	}
+
+	var cmdManifest *manifest.Manifest
+	if flagManifest != "" {
+		m, err := manifest.Load(flagManifest)
+		if err != nil {
+			return err
+		}
+		cmdManifest = m
+	}
+
+	tlsOpts, err := configureTransport()
 	if err != nil {
 		return err
 	}
-	verbose("Found %s", goVersion)
 
 	// REQ-24: Warn if --auth-token used with --stdio
 	if flagAuthToken != "" && flagStdio != "" {
@@ -180,6 +288,8 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 			oauthProvider := &auth.OAuth2Provider{
 				ServerURL:    flagURL,
 				CredPath:     auth.DefaultCredentialsPath(),
+				Profile:      credentialProfile(flagURL),
+				Device:       flagDevice,
 				ClientID:     flagClientID,
 				ClientSecret: flagClientSecret,
 				Verbose: func(format string, args ...interface{}) {
@@ -218,18 +328,16 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 				return fmt.Errorf("S2S OAuth2 authentication failed: %w", s2sErr)
 			}
 			// Save S2S credentials
-			credPath := auth.DefaultCredentialsPath()
-			creds, loadErr := auth.LoadCredentials(credPath)
-			if loadErr == nil {
-				creds.Servers[flagURL] = auth.ServerCredential{
+			_ = auth.UpdateCredentials(auth.DefaultCredentialsPath(), func(creds *auth.CredentialsFile) error {
+				creds.SetCredential(flagURL, creds.ResolveProfile(flagURL, flagProfile), auth.ServerCredential{
 					AuthType:      "s2s_oauth2",
 					Type:          "oauth",
 					ClientID:      flagClientID,
 					ClientSecret:  flagClientSecret,
 					TokenEndpoint: s2sProvider.TokenEndpoint,
-				}
-				_ = auth.SaveCredentials(credPath, creds)
-			}
+				})
+				return nil
+			})
 			// Recreate client with bearer provider for the new token
 			mcpClient.Close()
 			bearerProvider := &auth.BearerTokenProvider{Token: token}
@@ -251,6 +359,8 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 			oauthProvider := &auth.OAuth2Provider{
 				ServerURL: flagURL,
 				CredPath:  auth.DefaultCredentialsPath(),
+				Profile:   credentialProfile(flagURL),
+				Device:    flagDevice,
 				Verbose: func(format string, args ...interface{}) {
 					verbose(format, args...)
 				},
@@ -275,11 +385,10 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 				return fmt.Errorf("MCP server at %s did not complete initialization handshake\n  %s", target, err)
 			}
 		} else {
-			errMsg := fmt.Sprintf("MCP server at %s did not complete initialization handshake\n  %s", target, err)
 			if stderr := captureStderr(mcpClient); stderr != "" {
-				errMsg += fmt.Sprintf("\n\nServer stderr:\n  %s", strings.ReplaceAll(stderr, "\n", "\n  "))
+				return fmt.Errorf("MCP server at %s did not complete initialization handshake\n  %s\n\nServer stderr:\n  %s", target, err, strings.ReplaceAll(stderr, "\n", "\n  "))
 			}
-			return fmt.Errorf("%s", errMsg)
+			return fmt.Errorf("MCP server at %s did not complete initialization handshake\n  %w", target, err)
 		}
 	}
 	verbose("Handshake complete")
@@ -291,7 +400,7 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		if ctx.Err() != nil {
 			return fmt.Errorf("MCP server did not respond within %dms", flagTimeout)
 		}
-		return fmt.Errorf("failed to connect to MCP server at %s: %s", target, err)
+		return fmt.Errorf("failed to connect to MCP server at %s: %w", target, err)
 	}
 
 	tools := toolsResult.Tools
@@ -302,19 +411,43 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	}
 	verbose("Discovered %d tools", len(tools))
 
+	if cmdManifest != nil {
+		names := make([]string, len(tools))
+		for i, t := range tools {
+			names[i] = t.Name
+		}
+		if err := cmdManifest.CheckTools(names); err != nil {
+			return err
+		}
+	}
+
 	// REQ-33-35: Apply tool filtering
-	include := toolfilter.ParseToolList(flagIncludeTools)
-	exclude := toolfilter.ParseToolList(flagExcludeTools)
+	include := toolfilter.ParseToolList(flagIncludeTools...)
+	exclude := toolfilter.ParseToolList(flagExcludeTools...)
+
+	annotationFilter := toolfilter.AnnotationFilter{
+		OnlyReadOnly:       flagOnlyReadOnly,
+		ExcludeDestructive: flagExcludeDestruct,
+	}
 
 	filterTools := make([]toolfilter.Tool, len(tools))
 	for i, t := range tools {
-		filterTools[i] = toolfilter.Tool{Name: t.Name, Description: t.Description}
+		filterTools[i] = toolfilter.Tool{
+			Name:        t.Name,
+			Description: t.Description,
+			ReadOnly:    hintSet(t.Annotations.ReadOnlyHint),
+			Destructive: isDestructive(t.Annotations),
+		}
 	}
 
 	filtered, err := toolfilter.FilterTools(filterTools, include, exclude)
 	if err != nil {
 		return err
 	}
+	filtered, err = toolfilter.FilterByAnnotations(filtered, annotationFilter)
+	if err != nil {
+		return err
+	}
 
 	filteredSet := make(map[string]bool, len(filtered))
 	for _, ft := range filtered {
@@ -327,10 +460,15 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
-	if len(include) > 0 || len(exclude) > 0 {
+	if len(include) > 0 || len(exclude) > 0 || annotationFilter.Active() {
 		verbose("After filtering: %d tools", len(finalTools))
 	}
 
+	if flagDryRun {
+		printDryRun(cmd.OutOrStdout(), tools, filteredSet)
+		return nil
+	}
+
 	// REQ-30-32: Infer name
 	cliName := flagName
 	if cliName == "" {
@@ -349,10 +487,6 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	// REQ-13a: Save credentials if requested
 	if flagSaveCredentials && flagURL != "" && flagAuthToken != "" {
 		credPath := auth.DefaultCredentialsPath()
-		creds, err := auth.LoadCredentials(credPath)
-		if err != nil {
-			return fmt.Errorf("load credentials: %w", err)
-		}
 		// Determine auth type: explicit --auth-type or infer bearer
 		saveAuthType := flagAuthType
 		if saveAuthType == "" {
@@ -363,11 +497,33 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 			Token:      flagAuthToken,
 			HeaderName: flagAuthHeaderName,
 		}
-		creds.Servers[flagURL] = sc
-		if err := auth.SaveCredentials(credPath, creds); err != nil {
+		var profile string
+		err := auth.UpdateCredentials(credPath, func(creds *auth.CredentialsFile) error {
+			profile = creds.ResolveProfile(flagURL, flagProfile)
+			creds.SetCredential(flagURL, profile, sc)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("save credentials: %w", err)
+		}
+		info("Saved credentials to %s (profile %s)", credPath, profile)
+	} else if flagSaveCredentials && flagURL != "" && (flagAuthType == "token_exchange" || flagAuthType == "exec") {
+		// These store how to obtain a token, not the token itself
+		credPath := auth.DefaultCredentialsPath()
+		sc := flagTokenExchangeProvider().Credential()
+		if flagAuthType == "exec" {
+			sc = (&auth.ExecCredentialProvider{Command: flagAuthExec}).Credential()
+		}
+		var profile string
+		err := auth.UpdateCredentials(credPath, func(creds *auth.CredentialsFile) error {
+			profile = creds.ResolveProfile(flagURL, flagProfile)
+			creds.SetCredential(flagURL, profile, sc)
+			return nil
+		})
+		if err != nil {
 			return fmt.Errorf("save credentials: %w", err)
 		}
-		info("Saved credentials to %s", credPath)
+		info("Saved %s configuration to %s (profile %s)", flagAuthType, credPath, profile)
 	}
 
 	// Process tool schemas
@@ -376,17 +532,38 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	if err != nil {
 		return err
 	}
+	if err := layoutCommands(toolDefs, cmdManifest); err != nil {
+		return err
+	}
 
 	// Build codegen context
 	genCtx := codegen.GenerateContext{
 		CLIName:       cliName,
 		Tools:         toolDefs,
 		ClihubVersion: appVersion,
+		Version:       flagCLIVersion,
 		IsHTTP:        flagURL != "",
 	}
+	if flagUpdateURL != "" {
+		key, err := readUpdateKey()
+		if err != nil {
+			return err
+		}
+		genCtx.Update = codegen.UpdateConfig{URL: flagUpdateURL, PublicKey: key}
+	}
 
 	if flagURL != "" {
 		genCtx.ServerURL = flagURL
+		genCtx.Headers, _ = auth.ParseHeaders(flagHeaders)
+		genCtx.Auth = authConfigFor(flagURL)
+		if tlsOpts.CAFile != "" {
+			// The generated CLI talks to the same server, so it trusts the same CA
+			caBundle, err := os.ReadFile(tlsOpts.CAFile)
+			if err != nil {
+				return fmt.Errorf("read CA file: %w", err)
+			}
+			genCtx.CABundle = string(caBundle)
+		}
 	} else {
 		parts, _ := nameutil.SplitCommand(flagStdio)
 		if len(parts) > 0 {
@@ -403,9 +580,19 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// go mod tidy and go build both get the cache location in their environment.
+	cacheDir := flagCacheDir
+	if cacheDir == "" {
+		cacheDir = os.Getenv("CLIHUB_CACHE_DIR")
+	}
+	cacheEnv, err := compile.CacheEnv(cacheDir)
+	if err != nil {
+		return err
+	}
+
 	// Generate Go project
 	verbose("Generating Go project...")
-	projectDir, err := codegen.Generate(genCtx, "")
+	projectDir, err := codegen.Generate(genCtx, "", cacheEnv)
 	if err != nil {
 		return fmt.Errorf("code generation failed: %w", err)
 	}
@@ -427,37 +614,36 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 	}
 	multiPlatform := len(platforms) > 1
 
-	// Compile for each platform
-	var binaries []string
-	for _, p := range platforms {
-		if flagVerbose {
-			fmt.Printf("Compiling %s...", p)
-		}
-
-		start := time.Now()
-		binaryPath, err := compile.Compile(projectDir, flagOutput, cliName, p, multiPlatform)
-		elapsed := time.Since(start)
-
-		if err != nil {
-			if flagVerbose {
-				fmt.Println(" failed")
+	// Compile platforms concurrently, reporting each as it finishes
+	showProgress := flagVerbose || (multiPlatform && !flagQuiet)
+	built := 0
+	binaries, err := compile.CompileAll(projectDir, flagOutput, cliName, platforms, flagJobs, cacheEnv, func(p compile.Progress) {
+		switch {
+		case !p.Done:
+			verbose("Compiling %s...", p.Platform)
+		case p.Err != nil:
+			if showProgress {
+				fmt.Printf("Compiling %s failed (%.1fs)\n", p.Platform, p.Elapsed.Seconds())
+			}
+		default:
+			built++
+			if showProgress {
+				fmt.Printf("Compiled %s in %.1fs [%d/%d]\n", p.Platform, p.Elapsed.Seconds(), built, len(platforms))
 			}
-			cleanupDir = ""
-			return fmt.Errorf("%s\nGenerated source preserved at: %s", err, projectDir)
-		}
-
-		if flagVerbose {
-			fmt.Printf(" done (%.1fs)\n", elapsed.Seconds())
 		}
-		binaries = append(binaries, binaryPath)
+	})
+	if err != nil {
+		cleanupDir = ""
+		return fmt.Errorf("%s\nGenerated source preserved at: %s", err, projectDir)
 	}
 
-	// Smart smoke test: only run if host platform is in the target list
+	// Smart smoke test: only run if host platform is in the target list.
+	// Foreign targets (other OSes, architectures, wasip1) can't be executed here.
 	hostGOOS, hostGOARCH := compile.CurrentPlatform()
 	hostPlatform := hostGOOS + "/" + hostGOARCH
 	var hostBinary string
 	for i, p := range platforms {
-		if p.String() == hostPlatform {
+		if p.IsHost() {
 			hostBinary = binaries[i]
 			break
 		}
@@ -474,6 +660,28 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		verbose("Warning: smoke test skipped — no binary for host platform (%s)", hostPlatform)
 	}
 
+	verbose("Writing SBOMs and checksums...")
+	releaseFiles, err := writeReleaseFiles(binaries, cliName)
+	if err != nil {
+		return err
+	}
+
+	var docFiles []string
+	if flagDocs {
+		verbose("Writing reference docs...")
+		if docFiles, err = codegen.WriteDocs(genCtx, flagOutput); err != nil {
+			return err
+		}
+	}
+
+	var agentFiles []string
+	if flagAgentGuide {
+		verbose("Writing agent guide...")
+		if agentFiles, err = codegen.WriteAgentGuide(genCtx, flagOutput); err != nil {
+			return err
+		}
+	}
+
 	// Print summary
 	if !flagQuiet {
 		fmt.Printf("Generated %s from %s (%d tools, %d platform", cliName, target, len(finalTools), len(platforms))
@@ -485,21 +693,105 @@ func runGenerate(cmd *cobra.Command, args []string) error {
 		for _, b := range binaries {
 			fmt.Printf("  %s\n", b)
 		}
+		fmt.Println("Release files:")
+		for _, f := range releaseFiles {
+			fmt.Printf("  %s\n", f)
+		}
+		if len(docFiles) > 0 {
+			fmt.Printf("Docs: %d pages in %s and %s\n", len(docFiles), filepath.Join(flagOutput, "docs"), filepath.Join(flagOutput, "man"))
+		}
+		if len(agentFiles) > 0 {
+			fmt.Println("Agent guide:")
+			for _, f := range agentFiles {
+				fmt.Printf("  %s\n", f)
+			}
+		}
 	}
 
 	return nil
 }
 
+// writeReleaseFiles writes an SBOM per binary, adds the binaries and SBOMs
+// to SHA256SUMS, and signs SHA256SUMS when --sign-key is set. Signatures of
+// the previous SHA256SUMS are removed first, since they no longer match.
+func writeReleaseFiles(binaries []string, cliName string) ([]string, error) {
+	var files []string
+	for _, b := range binaries {
+		sbom, err := release.WriteSBOM(b, cliName, flagCLIVersion)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sbom)
+	}
+
+	sums, err := release.WriteChecksums(filepath.Dir(binaries[0]), append(append([]string(nil), binaries...), files...))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, sums)
+
+	if err := release.RemoveSignatures(sums); err != nil {
+		return nil, err
+	}
+	if flagSignKey != "" {
+		verbose("Signing %s...", release.ChecksumsFile)
+		sig, err := release.Sign(sums, flagSignKey)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sig)
+	}
+	return files, nil
+}
+
+// printDryRun lists which discovered tools would be kept or skipped.
+func printDryRun(out io.Writer, tools []mcp.Tool, kept map[string]bool) {
+	fmt.Fprintf(out, "Would generate %d of %d tools:\n", len(kept), len(tools))
+	var skipped []string
+	for _, t := range tools {
+		if !kept[t.Name] {
+			skipped = append(skipped, t.Name)
+			continue
+		}
+		var hints []string
+		if hintSet(t.Annotations.ReadOnlyHint) {
+			hints = append(hints, "read-only")
+		}
+		if isDestructive(t.Annotations) {
+			hints = append(hints, "destructive")
+		}
+		if len(hints) > 0 {
+			fmt.Fprintf(out, "  %s (%s)\n", t.Name, strings.Join(hints, ", "))
+		} else {
+			fmt.Fprintf(out, "  %s\n", t.Name)
+		}
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(out, "Skipped %d tools:\n", len(skipped))
+		for _, name := range skipped {
+			fmt.Fprintf(out, "  %s\n", name)
+		}
+	}
+}
+
 func hideGenerateAuthFlags() {
 	for _, name := range []string{
 		"auth-token",
 		"auth-type",
 		"auth-header-name",
 		"auth-key-file",
+		"auth-exec",
+		"token-endpoint",
+		"subject-token-file",
+		"subject-token-env",
+		"subject-token-type",
+		"audience",
 		"oauth",
+		"device",
 		"client-id",
 		"client-secret",
 		"save-credentials",
+		"profile",
 	} {
 		_ = generateCmd.Flags().MarkHidden(name)
 	}
@@ -508,13 +800,21 @@ func hideGenerateAuthFlags() {
 func printGenerateAuthHelp(out io.Writer) {
 	fmt.Fprintln(out, "Authentication flags for clihub generate:")
 	fmt.Fprintln(out, "  --oauth                     use OAuth for authentication (interactive browser flow)")
+	fmt.Fprintln(out, "  --device                    authorize OAuth on another device (device flow or pasted redirect URL)")
 	fmt.Fprintln(out, "  --auth-token string         bearer token for authenticated MCP servers")
-	fmt.Fprintln(out, "  --auth-type string          authentication type: bearer, api_key, basic, oauth2, s2s_oauth2, dcr_oauth, google_sa, none")
+	fmt.Fprintln(out, "  --auth-type string          authentication type: bearer, api_key, basic, oauth2, s2s_oauth2, dcr_oauth, google_sa, token_exchange, exec, none")
 	fmt.Fprintln(out, "  --auth-header-name string   custom header name for api_key auth (default X-API-Key)")
 	fmt.Fprintln(out, "  --auth-key-file string      path to Google service account JSON key file")
+	fmt.Fprintln(out, "  --auth-exec string          credential helper command that prints {\"token\", \"expires_at\"} JSON")
+	fmt.Fprintln(out, "  --token-endpoint string     token endpoint for --auth-type token_exchange")
+	fmt.Fprintln(out, "  --subject-token-file string file holding the subject token to exchange (workload identity)")
+	fmt.Fprintln(out, "  --subject-token-env string  environment variable holding the subject token to exchange")
+	fmt.Fprintln(out, "  --subject-token-type string subject token type URN (default urn:ietf:params:oauth:token-type:jwt)")
+	fmt.Fprintln(out, "  --audience string           audience to request in the token exchange")
 	fmt.Fprintln(out, "  --client-id string          pre-registered OAuth client ID (use with --oauth)")
 	fmt.Fprintln(out, "  --client-secret string      pre-registered OAuth client secret (use with --oauth)")
 	fmt.Fprintln(out, "  --save-credentials          persist auth token to ~/.clihub/credentials.json")
+	fmt.Fprintln(out, "  --profile string            credential profile to read and save (default: $CLIHUB_PROFILE or the server's default profile)")
 }
 
 // processToolSchemas converts mcp-go tools to codegen tool definitions.
@@ -542,11 +842,81 @@ func processToolSchemas(tools []mcp.Tool) ([]codegen.ToolDef, error) {
 			CommandName: commandName,
 			Description: t.Description,
 			Options:     options,
+			Positional:  schema.PrimaryArgs(options),
+			ReadOnly:    hintSet(t.Annotations.ReadOnlyHint),
+			Destructive: isDestructive(t.Annotations),
+			Idempotent:  hintSet(t.Annotations.IdempotentHint),
+			OpenWorld:   hintSet(t.Annotations.OpenWorldHint),
 		})
 	}
 	return defs, nil
 }
 
+// layoutCommands applies automatic grouping and manifest overrides to the
+// generated command names and positional arguments, then checks the result
+// for collisions.
+func layoutCommands(defs []codegen.ToolDef, m *manifest.Manifest) error {
+	if flagGroupCommands {
+		names := make([]string, len(defs))
+		for i, d := range defs {
+			names[i] = d.CommandName
+		}
+		paths := nameutil.GroupCommands(names)
+		for i := range defs {
+			p := paths[defs[i].CommandName]
+			defs[i].Group, defs[i].CommandName = p.Group, p.Name
+		}
+	}
+
+	if m != nil {
+		for i := range defs {
+			o, ok := m.Tools[defs[i].Name]
+			if !ok {
+				continue
+			}
+			if o.Command != "" {
+				p, err := o.Path()
+				if err != nil {
+					return fmt.Errorf("manifest: tool %q: %w", defs[i].Name, err)
+				}
+				defs[i].Group, defs[i].CommandName = p.Group, p.Name
+			}
+			defs[i].Aliases = o.Aliases
+			if o.Args != nil {
+				args, err := schema.ResolveArgs(defs[i].Options, o.Args)
+				if err != nil {
+					return fmt.Errorf("manifest: tool %q: args: %w", defs[i].Name, err)
+				}
+				defs[i].Positional = args
+			}
+		}
+	}
+
+	if err := codegen.CheckCommandNames(defs); err != nil {
+		return fmt.Errorf("command layout: %w", err)
+	}
+	return nil
+}
+
+// hintSet reports whether an optional MCP tool annotation is explicitly true.
+// Unset hints are treated as false so only servers that opt in get
+// read-only, idempotent or open-world allowances.
+func hintSet(hint *bool) bool {
+	return hint != nil && *hint
+}
+
+// isDestructive reports whether a tool is destructive. Per the MCP spec,
+// destructiveHint defaults to true for tools that are not read-only, so
+// unannotated write tools still ask for confirmation.
+func isDestructive(a mcp.ToolAnnotation) bool {
+	return toolfilter.IsDestructive(a.ReadOnlyHint, a.DestructiveHint)
+}
+
+// warnExecCache reports a credential helper token that could not be cached.
+func warnExecCache(err error) {
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+}
+
 // resolveAuthProvider builds an AuthProvider from flags and credential store.
 // Priority: --auth-type + flags → --auth-token (infer bearer) → env → credential file → no auth.
 func resolveAuthProvider(serverURL string) (auth.AuthProvider, error) {
@@ -565,6 +935,8 @@ func resolveAuthProvider(serverURL string) (auth.AuthProvider, error) {
 			return &auth.OAuth2Provider{
 				ServerURL:    serverURL,
 				CredPath:     credPath,
+				Profile:      credentialProfile(serverURL),
+				Device:       flagDevice,
 				ClientID:     flagClientID,
 				ClientSecret: flagClientSecret,
 				Verbose:      verboseFn,
@@ -579,6 +951,10 @@ func resolveAuthProvider(serverURL string) (auth.AuthProvider, error) {
 			return &auth.GoogleSAProvider{
 				KeyFile: flagAuthKeyFile,
 			}, nil
+		case "token_exchange":
+			return flagTokenExchangeProvider(), nil
+		case "exec":
+			return &auth.ExecCredentialProvider{Command: flagAuthExec, ServerURL: serverURL, CredPath: credPath, OnCacheError: warnExecCache}, nil
 		default:
 			cred := auth.ServerCredential{
 				Token:      flagAuthToken,
@@ -600,35 +976,13 @@ func resolveAuthProvider(serverURL string) (auth.AuthProvider, error) {
 
 	// Check credential store for this server URL
 	if serverURL != "" && credPath != "" {
-		creds, err := auth.LoadCredentials(credPath)
+		creds, err := auth.LoadServerCredentials(credPath, serverURL)
 		if err == nil {
-			sc, ok := creds.Servers[serverURL]
+			profile := creds.ResolveProfile(serverURL, flagProfile)
+			sc, ok := creds.Credential(serverURL, profile)
 			if ok {
-				authType := sc.ResolveAuthType()
-				switch authType {
-				case "oauth2":
-					return &auth.OAuth2Provider{
-						ServerURL:    serverURL,
-						CredPath:     credPath,
-						ClientID:     sc.ClientID,
-						ClientSecret: sc.ClientSecret,
-						Verbose:      verboseFn,
-					}, nil
-				case "s2s_oauth2":
-					return &auth.S2SOAuth2Provider{
-						ClientID:      sc.ClientID,
-						ClientSecret:  sc.ClientSecret,
-						TokenEndpoint: sc.TokenEndpoint,
-						ServerURL:     serverURL,
-					}, nil
-				case "google_sa":
-					return &auth.GoogleSAProvider{
-						KeyFile: sc.KeyFile,
-						Scopes:  sc.Scopes,
-					}, nil
-				default:
-					return auth.NewProvider(authType, sc)
-				}
+				verbose("Using stored credentials (profile %s)", profile)
+				return storedAuthProvider(serverURL, credPath, profile, sc, verboseFn)
 			}
 		}
 	}
@@ -637,13 +991,177 @@ func resolveAuthProvider(serverURL string) (auth.AuthProvider, error) {
 	return &auth.NoAuthProvider{}, nil
 }
 
+// storedAuthProvider returns the provider for a stored credential.
+func storedAuthProvider(serverURL, credPath, profile string, sc auth.ServerCredential, verboseFn func(string, ...interface{})) (auth.AuthProvider, error) {
+	authType := sc.ResolveAuthType()
+	switch authType {
+	case "oauth2":
+		return &auth.OAuth2Provider{
+			ServerURL:    serverURL,
+			CredPath:     credPath,
+			Profile:      profile,
+			Device:       flagDevice,
+			ClientID:     sc.ClientID,
+			ClientSecret: sc.ClientSecret,
+			Verbose:      verboseFn,
+		}, nil
+	case "s2s_oauth2":
+		return &auth.S2SOAuth2Provider{
+			ClientID:      sc.ClientID,
+			ClientSecret:  sc.ClientSecret,
+			TokenEndpoint: sc.TokenEndpoint,
+			ServerURL:     serverURL,
+		}, nil
+	case "google_sa":
+		return &auth.GoogleSAProvider{
+			KeyFile: sc.KeyFile,
+			Scopes:  sc.Scopes,
+		}, nil
+	case "token_exchange":
+		return &auth.TokenExchangeProvider{
+			TokenEndpoint:    sc.TokenEndpoint,
+			SubjectTokenFile: sc.SubjectTokenFile,
+			SubjectTokenEnv:  sc.SubjectTokenEnv,
+			SubjectTokenType: sc.SubjectTokenType,
+			Audience:         sc.Audience,
+			Resource:         sc.Resource,
+			Scope:            sc.Scope,
+			ClientID:         sc.ClientID,
+			ClientSecret:     sc.ClientSecret,
+		}, nil
+	case "exec":
+		return &auth.ExecCredentialProvider{Command: sc.Command, ServerURL: serverURL, CredPath: credPath, OnCacheError: warnExecCache}, nil
+	default:
+		return auth.NewProvider(authType, sc)
+	}
+}
+
+// flagTokenExchangeProvider builds a token-exchange provider from flags.
+func flagTokenExchangeProvider() *auth.TokenExchangeProvider {
+	return &auth.TokenExchangeProvider{
+		TokenEndpoint:    flagTokenEndpoint,
+		SubjectTokenFile: flagSubjectFile,
+		SubjectTokenEnv:  flagSubjectEnv,
+		SubjectTokenType: flagSubjectType,
+		Audience:         flagAudience,
+		ClientID:         flagClientID,
+		ClientSecret:     flagClientSecret,
+	}
+}
+
+// credentialProfile returns the credential profile for serverURL: --profile,
+// then CLIHUB_PROFILE, then the server's default profile.
+func credentialProfile(serverURL string) string {
+	creds, err := auth.LoadServerCredentials(auth.DefaultCredentialsPath(), serverURL)
+	if err != nil {
+		creds = &auth.CredentialsFile{}
+	}
+	return creds.ResolveProfile(serverURL, flagProfile)
+}
+
+// detectedAuth records what probeServerAuth learned about the server.
+var detectedAuth codegen.AuthConfig
+
+// authConfigFor describes how serverURL authenticates, for embedding in the
+// generated CLI: explicit flags first, then stored credentials, then what the
+// probe detected. Secrets are never included.
+func authConfigFor(serverURL string) codegen.AuthConfig {
+	cfg := detectedAuth
+	var sc auth.ServerCredential
+	stored := false
+	if creds, err := auth.LoadServerCredentials(auth.DefaultCredentialsPath(), serverURL); err == nil {
+		sc, stored = creds.Credential(serverURL, creds.ResolveProfile(serverURL, flagProfile))
+	}
+
+	switch {
+	case flagAuthType != "":
+		cfg = codegen.AuthConfig{Type: canonicalAuthType(flagAuthType), Scope: cfg.Scope, ResourceMetadataURL: cfg.ResourceMetadataURL, AuthServerURL: cfg.AuthServerURL}
+	case flagAuthToken != "" || os.Getenv("CLIHUB_AUTH_TOKEN") != "":
+		cfg = codegen.AuthConfig{Type: "bearer"}
+	case stored:
+		cfg.Type = canonicalAuthType(sc.ResolveAuthType())
+	}
+	if stored && canonicalAuthType(sc.ResolveAuthType()) != cfg.Type {
+		stored = false // describes a different setup; don't mix fields
+	}
+
+	switch cfg.Type {
+	case "api_key":
+		cfg.HeaderName = flagAuthHeaderName
+		if cfg.HeaderName == "" && stored {
+			cfg.HeaderName = sc.HeaderName
+		}
+	case "oauth2":
+		// Dynamically registered clients belong to this machine, so only a
+		// pre-registered client ID is embedded.
+		cfg.ClientID = flagClientID
+		if cfg.Scope == "" && stored {
+			cfg.Scope = sc.Scope
+		}
+	case "s2s_oauth2":
+		cfg.ClientID = flagClientID
+		if stored {
+			cfg.ClientID = firstNonEmpty(cfg.ClientID, sc.ClientID)
+			cfg.TokenEndpoint = sc.TokenEndpoint
+			cfg.Scope = sc.Scope
+		}
+	case "token_exchange":
+		p := flagTokenExchangeProvider()
+		if flagAuthType == "" && stored {
+			p = &auth.TokenExchangeProvider{
+				TokenEndpoint: sc.TokenEndpoint, SubjectTokenFile: sc.SubjectTokenFile,
+				SubjectTokenEnv: sc.SubjectTokenEnv, SubjectTokenType: sc.SubjectTokenType,
+				Audience: sc.Audience, Scope: sc.Scope, ClientID: sc.ClientID,
+			}
+		}
+		cfg.TokenEndpoint = p.TokenEndpoint
+		cfg.SubjectTokenFile = p.SubjectTokenFile
+		cfg.SubjectTokenEnv = p.SubjectTokenEnv
+		cfg.SubjectTokenType = p.SubjectTokenType
+		cfg.Audience = p.Audience
+		cfg.Scope = p.Scope
+		cfg.ClientID = p.ClientID
+	case "exec":
+		cfg.Command = flagAuthExec
+		if cfg.Command == "" && stored {
+			cfg.Command = sc.Command
+		}
+	}
+	return cfg
+}
+
+// canonicalAuthType maps auth type aliases to the names generated CLIs use.
+func canonicalAuthType(t string) string {
+	switch t {
+	case "bearer_token":
+		return "bearer"
+	case "basic_auth":
+		return "basic"
+	case "dcr_oauth":
+		return "oauth2"
+	case "no_auth":
+		return "none"
+	}
+	return t
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
 // probeServerAuth probes an HTTP URL for auth requirements by making a GET
 // request and inspecting the response. Returns a detected AuthProvider if
 // auto-detection succeeds, nil if no auth is needed.
 func probeServerAuth(ctx context.Context, serverURL string) (auth.AuthProvider, error) {
 	verbose("Attempting unauthenticated connection...")
 	httpClient := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: httpTransport,
 		// Don't follow redirects — we want to see the 401 directly
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse
@@ -657,6 +1175,10 @@ func probeServerAuth(ctx context.Context, serverURL string) (auth.AuthProvider,
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json, text/event-stream")
+	extra, _ := auth.ParseHeaders(flagHeaders)
+	for k, v := range extra {
+		req.Header.Set(k, v)
+	}
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
@@ -665,6 +1187,9 @@ func probeServerAuth(ctx context.Context, serverURL string) (auth.AuthProvider,
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusUnauthorized {
+		if resp.StatusCode < 400 {
+			detectedAuth = codegen.AuthConfig{Type: "none"}
+		}
 		verbose("Server does not require authentication")
 		return nil, nil // No auth needed (or non-401 error; mcp-go will handle)
 	}
@@ -686,32 +1211,46 @@ func probeServerAuth(ctx context.Context, serverURL string) (auth.AuthProvider,
 	// Bearer challenge → try OAuth2 auto-detection
 	// If resource_metadata URL is present, use it directly.
 	// Otherwise, try standard RFC 9728 discovery from the server URL.
+	// Either way the discovered metadata URL and authorization server are
+	// embedded in the generated CLI.
 	resourceMetadataURL := bearer.ResourceMetadata
+	if resourceMetadataURL == "" {
+		verbose("No resource_metadata in challenge, trying OAuth discovery...")
+	}
+	httpDiscovery := &http.Client{Timeout: 10 * time.Second, Transport: httpTransport}
+	discovery, discoveryErr := auth.DiscoverOAuth(ctx, httpDiscovery, serverURL, resourceMetadataURL)
 	if resourceMetadataURL != "" {
 		verbose("Detected auth type: oauth2 (from WWW-Authenticate resource_metadata)")
-	} else {
-		// No resource_metadata hint — try standard well-known discovery
-		verbose("No resource_metadata in challenge, trying OAuth discovery...")
-		httpDiscovery := &http.Client{Timeout: 10 * time.Second}
-		_, discoveryErr := auth.FetchProtectedResourceMetadata(ctx, httpDiscovery, serverURL)
-		if discoveryErr != nil {
-			// Discovery failed — this isn't an OAuth server we can auto-detect
-			hint := "server requires Bearer token authentication"
-			if bearer.Scope != "" {
-				hint += fmt.Sprintf(" (scope: %s)", bearer.Scope)
-			}
-			if bearer.Realm != "" {
-				hint += fmt.Sprintf(" (realm: %s)", bearer.Realm)
-			}
-			return nil, fmt.Errorf("%s\n\nProvide a token with --auth-token, or use --oauth for OAuth-enabled servers", hint)
+	} else if discoveryErr != nil {
+		// Discovery failed — this isn't an OAuth server we can auto-detect
+		hint := "server requires Bearer token authentication"
+		if bearer.Scope != "" {
+			hint += fmt.Sprintf(" (scope: %s)", bearer.Scope)
+		}
+		if bearer.Realm != "" {
+			hint += fmt.Sprintf(" (realm: %s)", bearer.Realm)
 		}
+		return nil, fmt.Errorf("%s\n\nProvide a token with --auth-token, or use --oauth for OAuth-enabled servers", hint)
+	} else {
 		verbose("Detected auth type: oauth2 (from server metadata discovery)")
 	}
 
+	detectedAuth = codegen.AuthConfig{
+		Type:                "oauth2",
+		Scope:               bearer.Scope,
+		ResourceMetadataURL: resourceMetadataURL,
+	}
+	if discoveryErr == nil {
+		detectedAuth.ResourceMetadataURL = discovery.ResourceMetadataURL
+		detectedAuth.AuthServerURL = discovery.AuthServerURL
+	}
+
 	credPath := auth.DefaultCredentialsPath()
 	oauthProvider := &auth.OAuth2Provider{
 		ServerURL:           serverURL,
 		CredPath:            credPath,
+		Profile:             credentialProfile(serverURL),
+		Device:              flagDevice,
 		ResourceMetadataURL: resourceMetadataURL,
 		Scope:               bearer.Scope,
 		Verbose: func(format string, args ...interface{}) {
@@ -789,13 +1328,66 @@ func captureStderr(c *mcpclient.Client) string {
 	return strings.TrimSpace(string(buf[:n]))
 }
 
+// httpTransport carries --proxy, the --client-cert/--ca-file TLS settings
+// and the clihub User-Agent; nil means http.DefaultTransport.
+var httpTransport http.RoundTripper
+
+// tlsOptions returns the given TLS flag values, falling back to their
+// environment variables.
+func tlsOptions(clientCert, clientKey, caFile string) auth.TLSOptions {
+	opts := auth.TLSOptionsFromEnv()
+	if clientCert != "" {
+		opts.ClientCert = clientCert
+	}
+	if clientKey != "" {
+		opts.ClientKey = clientKey
+	}
+	if caFile != "" {
+		opts.CAFile = caFile
+	}
+	return opts
+}
+
+// configureTransport applies --proxy, the TLS flags and the User-Agent to
+// MCP requests and to the auth package's OAuth requests.
+func configureTransport() (auth.TLSOptions, error) {
+	opts := tlsOptions(flagClientCert, flagClientKey, flagCAFile)
+	if flagURL == "" {
+		return auth.TLSOptions{}, nil
+	}
+	if !opts.IsZero() {
+		verbose("Using custom TLS settings")
+	}
+	t, err := auth.TransportOptions{TLS: opts, Proxy: flagProxy, UserAgent: "clihub/" + appVersion}.RoundTripper()
+	if err != nil {
+		return opts, err
+	}
+	httpTransport = t
+	auth.Transport = httpTransport
+	return opts, nil
+}
+
 // createHTTPClient creates an HTTP-based mcp-go client with the given AuthProvider.
 func createHTTPClient(provider auth.AuthProvider) (*mcpclient.Client, error) {
 	var opts []transport.StreamableHTTPCOption
+	if scopeStepUpProvider(provider) != nil {
+		// runGenerate steps up when the server asks for a broader scope.
+		opts = append(opts, transport.WithHTTPBasicClient(&http.Client{Transport: &auth.ScopeStepUpTransport{Base: httpTransport}}))
+	} else if httpTransport != nil {
+		opts = append(opts, transport.WithHTTPBasicClient(&http.Client{Transport: httpTransport}))
+	}
+	extra, _ := auth.ParseHeaders(flagHeaders)
 	// Use WithHTTPHeaderFunc for dynamic per-request header injection
-	if _, isNoAuth := provider.(*auth.NoAuthProvider); !isNoAuth {
+	if _, isNoAuth := provider.(*auth.NoAuthProvider); !isNoAuth || len(extra) > 0 {
 		opts = append(opts, transport.WithHTTPHeaderFunc(func(ctx context.Context) map[string]string {
-			headers, _ := provider.GetHeaders(ctx)
+			headers := make(map[string]string, len(extra))
+			for k, v := range extra {
+				headers[k] = v
+			}
+			authHeaders, _ := provider.GetHeaders(ctx)
+			for k, v := range authHeaders {
+				headers[k] = v
+			}
 			return headers
 		}))
 	}
@@ -806,6 +1398,51 @@ func createHTTPClient(provider auth.AuthProvider) (*mcpclient.Client, error) {
 	return c, nil
 }
 
+// scopeStepUpProvider returns the OAuth provider that can re-authorize with
+// a broader scope, or nil when the server's credential is not OAuth.
+func scopeStepUpProvider(provider auth.AuthProvider) *auth.OAuth2Provider {
+	if p, ok := provider.(*auth.OAuth2Provider); ok {
+		return p
+	}
+	creds, err := auth.LoadServerCredentials(auth.DefaultCredentialsPath(), flagURL)
+	if err != nil {
+		return nil
+	}
+	// The flows in runGenerate save their tokens and then send them with a
+	// BearerTokenProvider.
+	profile := creds.ResolveProfile(flagURL, flagProfile)
+	if _, isBearer := provider.(*auth.BearerTokenProvider); !isBearer || auth.GetOAuthCredential(creds, flagURL, profile) == nil {
+		return nil
+	}
+	return &auth.OAuth2Provider{
+		ServerURL:    flagURL,
+		CredPath:     auth.DefaultCredentialsPath(),
+		Profile:      profile,
+		Device:       flagDevice,
+		ClientID:     flagClientID,
+		ClientSecret: flagClientSecret,
+		Verbose: func(format string, args ...interface{}) {
+			verbose(format, args...)
+		},
+	}
+}
+
+// confirmScopeStepUp asks before re-authorizing with a broader scope. It
+// declines when stdin is not a terminal.
+func confirmScopeStepUp(scope string) bool {
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		fmt.Fprintf(os.Stderr, "The server requires more permissions; run clihub interactively to re-authorize with scope %q\n", scope)
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "The server requires more permissions. Re-authorize with scope %q? [y/N] ", scope)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
+
 // isAuthError checks if an error indicates an authentication failure.
 func isAuthError(err error) bool {
 	if err == nil {
@@ -831,6 +1468,22 @@ func info(format string, args ...interface{}) {
 	}
 }
 
+// readUpdateKey reads and checks the --update-key public key. Returns "" when
+// the flag is unset.
+func readUpdateKey() (string, error) {
+	if flagUpdateKey == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(flagUpdateKey)
+	if err != nil {
+		return "", fmt.Errorf("--update-key: %w", err)
+	}
+	if err := update.CheckPublicKey(string(data)); err != nil {
+		return "", fmt.Errorf("--update-key %s: %w", flagUpdateKey, err)
+	}
+	return string(data), nil
+}
+
 func validateFlags() error {
 	if flagURL == "" && flagStdio == "" {
 		return fmt.Errorf("provide --url or --stdio to specify the MCP server")
@@ -840,14 +1493,41 @@ func validateFlags() error {
 		return fmt.Errorf("--url and --stdio cannot be used together")
 	}
 
-	if flagIncludeTools != "" && flagExcludeTools != "" {
-		return fmt.Errorf("--include-tools and --exclude-tools cannot be used together")
-	}
-
 	if flagVerbose && flagQuiet {
 		return fmt.Errorf("--verbose and --quiet cannot be used together")
 	}
 
+	if flagJobs < 0 {
+		return fmt.Errorf("--jobs must be 0 or more")
+	}
+
+	if flagSignKey != "" {
+		if _, err := os.Stat(flagSignKey); err != nil {
+			return fmt.Errorf("--sign-key: %w", err)
+		}
+	}
+
+	if flagUpdateKey != "" && flagUpdateURL == "" {
+		return fmt.Errorf("--update-key requires --update-url")
+	}
+	updateKey, err := readUpdateKey()
+	if err != nil {
+		return err
+	}
+	if flagUpdateURL != "" {
+		if err := update.CheckURL(flagUpdateURL, updateKey); err != nil {
+			return fmt.Errorf("--update-url: %w", err)
+		}
+	}
+
+	for _, profile := range []string{flagProfile, os.Getenv("CLIHUB_PROFILE")} {
+		if profile != "" {
+			if err := auth.ValidateProfileName(profile); err != nil {
+				return err
+			}
+		}
+	}
+
 	// --oauth is a convenience alias for --auth-type oauth2
 	if flagOAuth {
 		if flagAuthType != "" && flagAuthType != "oauth2" {
@@ -856,16 +1536,28 @@ func validateFlags() error {
 		flagAuthType = "oauth2"
 	}
 
+	// --auth-exec implies --auth-type exec
+	if flagAuthExec != "" {
+		if flagAuthType != "" && flagAuthType != "exec" {
+			return fmt.Errorf("--auth-exec conflicts with --auth-type %s", flagAuthType)
+		}
+		flagAuthType = "exec"
+	}
+
+	if flagDevice && flagAuthType != "" && flagAuthType != "oauth2" && flagAuthType != "dcr_oauth" {
+		return fmt.Errorf("--device only applies to OAuth, not --auth-type %s", flagAuthType)
+	}
+
 	if (flagAuthType == "oauth2" || flagAuthType == "dcr_oauth") && flagStdio != "" {
 		return fmt.Errorf("--auth-type %s is not supported for stdio servers", flagAuthType)
 	}
 
 	if flagClientID != "" || flagClientSecret != "" {
 		switch flagAuthType {
-		case "oauth2", "dcr_oauth", "s2s_oauth2":
+		case "oauth2", "dcr_oauth", "s2s_oauth2", "token_exchange":
 			// valid
 		default:
-			return fmt.Errorf("--client-id and --client-secret require --auth-type oauth2, dcr_oauth, or s2s_oauth2")
+			return fmt.Errorf("--client-id and --client-secret require --auth-type oauth2, dcr_oauth, s2s_oauth2, or token_exchange")
 		}
 	}
 
@@ -876,10 +1568,10 @@ func validateFlags() error {
 	if flagAuthType != "" {
 		switch flagAuthType {
 		case "bearer", "bearer_token", "api_key", "basic", "basic_auth", "none", "no_auth",
-			"oauth2", "dcr_oauth", "s2s_oauth2", "google_sa":
+			"oauth2", "dcr_oauth", "s2s_oauth2", "google_sa", "token_exchange", "exec":
 			// valid
 		default:
-			return fmt.Errorf("invalid --auth-type %q: valid types are bearer, api_key, basic, oauth2, s2s_oauth2, dcr_oauth, google_sa, none", flagAuthType)
+			return fmt.Errorf("invalid --auth-type %q: valid types are bearer, api_key, basic, oauth2, s2s_oauth2, dcr_oauth, google_sa, token_exchange, exec, none", flagAuthType)
 		}
 	}
 
@@ -895,6 +1587,50 @@ func validateFlags() error {
 		return fmt.Errorf("--auth-type google_sa requires --auth-key-file")
 	}
 
+	if flagAuthType == "exec" && flagAuthExec == "" {
+		return fmt.Errorf("--auth-type exec requires --auth-exec")
+	}
+
+	tokenExchangeFlags := flagTokenEndpoint != "" || flagSubjectFile != "" || flagSubjectEnv != "" || flagSubjectType != "" || flagAudience != ""
+	if tokenExchangeFlags && flagAuthType != "token_exchange" {
+		return fmt.Errorf("--token-endpoint, --subject-token-* and --audience require --auth-type token_exchange")
+	}
+
+	if flagAuthType == "token_exchange" {
+		if flagTokenEndpoint == "" {
+			return fmt.Errorf("--auth-type token_exchange requires --token-endpoint")
+		}
+		if flagSubjectFile == "" && flagSubjectEnv == "" {
+			return fmt.Errorf("--auth-type token_exchange requires --subject-token-file or --subject-token-env")
+		}
+	}
+
+	if (flagAuthType == "token_exchange" || flagAuthType == "exec") && flagStdio != "" {
+		return fmt.Errorf("--auth-type %s is not supported for stdio servers", flagAuthType)
+	}
+
+	if (flagClientCert != "" || flagClientKey != "" || flagCAFile != "") && flagStdio != "" {
+		return fmt.Errorf("--client-cert, --client-key and --ca-file only apply to --url servers")
+	}
+
+	if (len(flagHeaders) > 0 || flagProxy != "") && flagStdio != "" {
+		return fmt.Errorf("--header and --proxy only apply to --url servers")
+	}
+
+	if _, err := auth.ParseHeaders(flagHeaders); err != nil {
+		return err
+	}
+
+	if flagProxy != "" {
+		if _, err := auth.ParseProxy(flagProxy); err != nil {
+			return err
+		}
+	}
+
+	if (flagClientCert == "") != (flagClientKey == "") {
+		return fmt.Errorf("--client-cert and --client-key must be used together")
+	}
+
 	for _, env := range flagEnv {
 		if !strings.Contains(env, "=") {
 			return fmt.Errorf("invalid --env format %q: expected KEY=VALUE", env)
@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thellimist/clihub/internal/packaging"
+	"github.com/thellimist/clihub/internal/release"
+)
+
+var (
+	flagPkgFormat      string
+	flagPkgOutput      string
+	flagPkgName        string
+	flagPkgDescription string
+	flagPkgHomepage    string
+	flagPkgLicense     string
+	flagPkgMaintainer  string
+	flagPkgBaseURL     string
+	flagPkgSignKey     string
+)
+
+// packageFormats are the values accepted by --format, in the order they are
+// built. homebrew and scoop read the archives, so they imply archive.
+var packageFormats = []string{"archive", "deb", "rpm", "homebrew", "scoop", "oci"}
+
+var packageCmd = &cobra.Command{
+	Use:   "package [dir]",
+	Short: "Package generated binaries for distribution",
+	Long: `Package the binaries clihub generate wrote to dir (default ./out/).
+
+Formats:
+  archive   tar.gz per platform (zip for windows) with completions and man pages
+  deb, rpm  linux packages installing to /usr/bin
+  homebrew  Homebrew formula for the darwin and linux archives (needs --base-url)
+  scoop     Scoop manifest for the windows archives (needs --base-url)
+  oci       OCI image tarball for the linux binaries, built without a daemon
+
+Shell completions are produced by running a binary built for this machine.
+Man pages are taken from dir/man/*.1 when present. Artifacts are written to
+dir/dist/ with their own SHA256SUMS.`,
+	Example: `  clihub package --format all --base-url https://releases.example.com/linear/v1.0.0
+  clihub package ./out --format deb,rpm --maintainer "Ops <ops@example.com>"
+  clihub package --format oci && docker load -i out/dist/linear_1.0.0_oci.tar`,
+	Args:          cobra.MaximumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runPackage,
+}
+
+func init() {
+	f := packageCmd.Flags()
+	f.StringVar(&flagPkgFormat, "format", "archive", "comma-separated formats ("+strings.Join(packageFormats, ", ")+") or 'all'")
+	f.StringVar(&flagPkgOutput, "output", "", "directory for the packages (default <dir>/dist)")
+	f.StringVar(&flagPkgName, "name", "", "CLI to package when dir holds several")
+	f.StringVar(&flagPkgDescription, "description", "", "one-line package description")
+	f.StringVar(&flagPkgHomepage, "homepage", "", "project homepage URL")
+	f.StringVar(&flagPkgLicense, "license", "", "license identifier, e.g. MIT")
+	f.StringVar(&flagPkgMaintainer, "maintainer", "", "package maintainer, as \"Name <email>\"")
+	f.StringVar(&flagPkgBaseURL, "base-url", "", "URL the archives will be published under, used by homebrew and scoop")
+	f.StringVar(&flagPkgSignKey, "sign-key", "", "minisign or cosign private key used to sign SHA256SUMS (password from $CLIHUB_SIGN_PASSWORD)")
+}
+
+func runPackage(cmd *cobra.Command, args []string) error {
+	dir := "./out/"
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	formats, err := parsePackageFormats(flagPkgFormat)
+	if err != nil {
+		return err
+	}
+	if (formats["homebrew"] || formats["scoop"]) && flagPkgBaseURL == "" {
+		return fmt.Errorf("--base-url is required for the homebrew and scoop formats")
+	}
+
+	spec, err := packaging.Discover(dir, flagPkgName)
+	if err != nil {
+		return err
+	}
+	spec.Description = flagPkgDescription
+	spec.Homepage = flagPkgHomepage
+	spec.License = flagPkgLicense
+	spec.Maintainer = flagPkgMaintainer
+	spec.BaseURL = flagPkgBaseURL
+
+	out := cmd.OutOrStdout()
+	spec.Completions = packageCompletions(spec)
+	if spec.Completions == nil {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: no binary for this machine in %s; packaging without shell completions\n", dir)
+	}
+	if spec.ManPages, err = readManPages(filepath.Join(dir, "man")); err != nil {
+		return err
+	}
+	if formats["oci"] {
+		spec.CACerts = hostCACerts()
+		if spec.CACerts == nil {
+			fmt.Fprintln(cmd.ErrOrStderr(), "Warning: no CA bundle found on this machine; the OCI image can't verify TLS certificates")
+		}
+	}
+
+	outDir := flagPkgOutput
+	if outDir == "" {
+		outDir = filepath.Join(dir, "dist")
+	}
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+
+	var artifacts []string
+	add := func(paths ...string) { artifacts = append(artifacts, paths...) }
+	for _, format := range packageFormats {
+		if !formats[format] {
+			continue
+		}
+		switch format {
+		case "archive":
+			paths, err := packaging.WriteArchives(spec, outDir)
+			if err != nil {
+				return err
+			}
+			add(paths...)
+		case "deb":
+			paths, err := packaging.WriteDebs(spec, outDir)
+			if err != nil {
+				return err
+			}
+			add(paths...)
+		case "rpm":
+			paths, err := packaging.WriteRPMs(spec, outDir)
+			if err != nil {
+				return err
+			}
+			add(paths...)
+		case "homebrew":
+			path, err := packaging.WriteFormula(spec, outDir)
+			if err != nil {
+				return err
+			}
+			add(path)
+		case "scoop":
+			path, err := packaging.WriteScoopManifest(spec, outDir)
+			if err != nil {
+				return err
+			}
+			add(path)
+		case "oci":
+			path, err := packaging.WriteOCIImage(spec, outDir)
+			if err != nil {
+				return err
+			}
+			add(path)
+		}
+	}
+	if len(artifacts) == 0 {
+		return fmt.Errorf("nothing to package: no binaries in %s match the requested formats", dir)
+	}
+
+	sums, err := release.WriteChecksums(outDir, artifacts)
+	if err != nil {
+		return err
+	}
+	add(sums)
+	if err := release.RemoveSignatures(sums); err != nil {
+		return err
+	}
+	if flagPkgSignKey != "" {
+		sig, err := release.Sign(sums, flagPkgSignKey)
+		if err != nil {
+			return err
+		}
+		add(sig)
+	}
+
+	fmt.Fprintf(out, "Packaged %s %s (%d files)\n", spec.Name, spec.Version, len(artifacts))
+	for _, a := range artifacts {
+		fmt.Fprintf(out, "  %s\n", a)
+	}
+	return nil
+}
+
+// parsePackageFormats parses --format into a set. homebrew and scoop add
+// archive, since they describe the archives.
+func parsePackageFormats(value string) (map[string]bool, error) {
+	formats := map[string]bool{}
+	for _, f := range strings.Split(value, ",") {
+		f = strings.TrimSpace(f)
+		switch {
+		case f == "":
+		case f == "all":
+			for _, name := range packageFormats {
+				formats[name] = true
+			}
+		case slices.Contains(packageFormats, f):
+			formats[f] = true
+		default:
+			return nil, fmt.Errorf("unknown package format %q (valid: %s, all)", f, strings.Join(packageFormats, ", "))
+		}
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no package formats specified")
+	}
+	if formats["homebrew"] || formats["scoop"] {
+		formats["archive"] = true
+	}
+	return formats, nil
+}
+
+// packageCompletions runs a binary built for this machine to produce its
+// shell completion scripts. Returns nil when there is no such binary.
+func packageCompletions(spec *packaging.Spec) map[string][]byte {
+	for _, b := range spec.Binaries {
+		if !b.Platform.IsHost() {
+			continue
+		}
+		completions := map[string][]byte{}
+		for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+			script, err := exec.Command(b.Path, "completion", shell).Output()
+			if err == nil && len(script) > 0 {
+				completions[shell] = script
+			}
+		}
+		return completions
+	}
+	return nil
+}
+
+// readManPages reads the section 1 man pages in dir, if it exists.
+func readManPages(dir string) (map[string][]byte, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.1"))
+	if err != nil {
+		return nil, err
+	}
+	pages := map[string][]byte{}
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("read man page: %w", err)
+		}
+		pages[filepath.Base(p)] = data
+	}
+	return pages, nil
+}
+
+// hostCACerts returns this machine's PEM root certificate bundle, from the
+// locations Go's crypto/x509 checks on linux.
+func hostCACerts() []byte {
+	for _, path := range []string{
+		"/etc/ssl/certs/ca-certificates.crt",
+		"/etc/pki/tls/certs/ca-bundle.crt",
+		"/etc/ssl/ca-bundle.pem",
+		"/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem",
+		"/etc/ssl/cert.pem",
+	} {
+		if data, err := os.ReadFile(path); err == nil {
+			return data
+		}
+	}
+	return nil
+}
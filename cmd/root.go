@@ -23,6 +23,8 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(generateCmd)
+	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(packageCmd)
 	rootCmd.SetVersionTemplate(fmt.Sprintf("clihub v%s\n", appVersion))
 }
 
@@ -0,0 +1,179 @@
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// agentOutputFormats are the values of the generated CLI's --output flag.
+var agentOutputFormats = []string{"text", "json", "markdown", "raw"}
+
+// AgentManifest is the JSON tool manifest written for LLM agents: how to
+// call each tool from the shell, without the full input schemas.
+type AgentManifest struct {
+	Name          string          `json:"name"`
+	Version       string          `json:"version"`
+	Server        string          `json:"server"`
+	Auth          string          `json:"auth"`
+	OutputFormats []string        `json:"output_formats"`
+	Tools         []AgentToolInfo `json:"tools"`
+}
+
+// AgentToolInfo describes one tool in an AgentManifest.
+type AgentToolInfo struct {
+	Tool       string          `json:"tool"`
+	Command    string          `json:"command"`
+	Summary    string          `json:"summary,omitempty"`
+	Positional []string        `json:"positional,omitempty"`
+	Flags      []AgentFlagInfo `json:"flags,omitempty"`
+	Hints      []string        `json:"hints,omitempty"`
+	Example    string          `json:"example"`
+}
+
+// AgentFlagInfo describes one flag of a tool in an AgentManifest.
+type AgentFlagInfo struct {
+	Flag     string   `json:"flag"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+}
+
+// authSetup tells an agent how to get the CLI authenticated, in one or two
+// sentences.
+func (c GenerateContext) authSetup() string {
+	if !c.IsHTTP {
+		if len(c.EnvKeys) == 0 {
+			return "No setup needed."
+		}
+		return "Set the environment variables " + strings.Join(c.EnvKeys, ", ") + " before running it."
+	}
+	switch c.Auth.Type {
+	case "none":
+		return "No authentication needed."
+	case "token_exchange", "exec":
+		return "Credentials are configured at build time; no sign-in is needed."
+	case "bearer", "api_key":
+		return fmt.Sprintf("Run `%s auth --token <token>` once, or set CLIHUB_AUTH_TOKEN.", c.CLIName)
+	case "google_sa":
+		return fmt.Sprintf("Run `%s auth --key-file <key.json>` once with a Google service account key.", c.CLIName)
+	case "basic", "s2s_oauth2":
+		return fmt.Sprintf("Ask the user to run `%s auth` once; it prompts for credentials.", c.CLIName)
+	}
+	return fmt.Sprintf("Ask the user to run `%s auth` once (it opens a browser; `--device` on headless machines), or set CLIHUB_AUTH_TOKEN. `%s auth status` shows whether it is signed in.", c.CLIName, c.CLIName)
+}
+
+// agentGuide renders the Markdown agent guide. heading is the Markdown
+// prefix of its top heading, "##" for an AGENTS.md section or "#" for a
+// skill file.
+func agentGuide(ctx GenerateContext, heading string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n\n", heading, ctx.CLIName)
+	fmt.Fprintf(&b, "`%s` is a CLI for the MCP server `%s`, one command per tool. `%s <command> --help` lists every flag.\n\n", ctx.CLIName, ctx.serverName(), ctx.CLIName)
+	fmt.Fprintf(&b, "- Auth: %s\n", ctx.authSetup())
+	fmt.Fprintf(&b, "- Output: add `-o json` for machine-readable results (formats: %s).\n", strings.Join(agentOutputFormats, ", "))
+	b.WriteString("- Input: `--from-json @params.json` (or `-` for stdin, or inline JSON/YAML) passes all parameters as one object; flags given alongside override its keys.\n")
+	b.WriteString("- Safety: destructive tools ask for confirmation; pass `--yes` only when the user approved. `--read-only` refuses every tool not annotated read-only.\n")
+	fmt.Fprintf(&b, "\n%s# Commands\n\n", heading)
+	for _, t := range sortedTools(ctx.Tools) {
+		fmt.Fprintf(&b, "- `%s`", t.Example(ctx.CLIName))
+		if s := t.Summary(); s != "" {
+			fmt.Fprintf(&b, " — %s", strings.Join(strings.Fields(s), " "))
+		}
+		switch {
+		case t.ReadOnly:
+			b.WriteString(" (read-only)")
+		case t.Destructive:
+			b.WriteString(" (destructive)")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// AgentsSection renders the agent guide as a section to paste into an
+// AGENTS.md file.
+func AgentsSection(ctx GenerateContext) string {
+	return agentGuide(ctx, "##")
+}
+
+// SkillFile renders the agent guide as a SKILL.md, with the name and
+// description frontmatter agents use to decide when to load it.
+func SkillFile(ctx GenerateContext) string {
+	tools := sortedTools(ctx.Tools)
+	var commands []string
+	for i, t := range tools {
+		if i == 8 {
+			commands = append(commands, fmt.Sprintf("and %d more", len(tools)-i))
+			break
+		}
+		commands = append(commands, t.CommandPath())
+	}
+	desc := fmt.Sprintf("Use the %s CLI to call the tools of the MCP server %s", ctx.CLIName, ctx.serverName())
+	if len(commands) > 0 {
+		desc += " (" + strings.Join(commands, ", ") + ")"
+	}
+	desc += "."
+	return fmt.Sprintf("---\nname: %s\ndescription: %s\n---\n\n", ctx.CLIName, strings.ReplaceAll(desc, "\n", " ")) + agentGuide(ctx, "#")
+}
+
+// BuildAgentManifest returns the JSON tool manifest for the CLI.
+func BuildAgentManifest(ctx GenerateContext) AgentManifest {
+	m := AgentManifest{
+		Name:          ctx.CLIName,
+		Version:       ctx.Version,
+		Server:        ctx.serverName(),
+		Auth:          ctx.authSetup(),
+		OutputFormats: agentOutputFormats,
+		Tools:         []AgentToolInfo{},
+	}
+	for _, t := range sortedTools(ctx.Tools) {
+		info := AgentToolInfo{
+			Tool:       t.Name,
+			Command:    ctx.CLIName + " " + t.CommandPath(),
+			Summary:    t.Summary(),
+			Positional: t.Positional,
+			Hints:      t.Hints(),
+			Example:    t.Example(ctx.CLIName),
+		}
+		for _, o := range t.Options {
+			info.Flags = append(info.Flags, AgentFlagInfo{Flag: "--" + o.FlagName, Type: o.GoType, Required: o.Required, Enum: o.EnumValues})
+		}
+		m.Tools = append(m.Tools, info)
+	}
+	return m
+}
+
+// WriteAgentGuide writes the agent guide into outputDir/agent in three
+// forms: AGENTS.md (a section to paste into a project's AGENTS.md),
+// skills/<cli>/SKILL.md (a skill folder to copy into an agent's skills
+// directory) and <cli>.tools.json (the tool manifest). Returns the written
+// paths.
+func WriteAgentGuide(ctx GenerateContext, outputDir string) ([]string, error) {
+	manifest, err := json.MarshalIndent(BuildAgentManifest(ctx), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("encode tool manifest: %w", err)
+	}
+	dir := filepath.Join(outputDir, "agent")
+	files := map[string]string{
+		"AGENTS.md": AgentsSection(ctx),
+		filepath.Join("skills", ctx.CLIName, "SKILL.md"): SkillFile(ctx),
+		ctx.CLIName + ".tools.json":                      string(manifest) + "\n",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return nil, fmt.Errorf("create agent guide dir: %w", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return nil, fmt.Errorf("write %s: %w", name, err)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
@@ -1,15 +1,53 @@
 package codegen
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/thellimist/clihub/internal/release"
 	"github.com/thellimist/clihub/internal/schema"
+	"github.com/thellimist/clihub/runtime/auth"
 )
 
+// buildGeneratedCLI generates ctx into a temp dir and builds it with any
+// extra go build flags. It returns the project dir and the binary path.
+func buildGeneratedCLI(t *testing.T, ctx GenerateContext, buildFlags ...string) (string, string) {
+	t.Helper()
+	projectDir, err := Generate(ctx, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	binPath := filepath.Join(t.TempDir(), ctx.CLIName)
+	args := append(append([]string{"build"}, buildFlags...), "-o", binPath, ".")
+	buildCmd := exec.Command("go", args...)
+	buildCmd.Dir = projectDir
+	if out, err := buildCmd.CombinedOutput(); err != nil {
+		mainGo, _ := os.ReadFile(filepath.Join(projectDir, "main.go"))
+		t.Fatalf("go build failed: %v\nOutput: %s\nGenerated main.go:\n%s", err, out, mainGo)
+	}
+	return projectDir, binPath
+}
+
 func TestGenerateProducesValidGo(t *testing.T) {
 	ctx := GenerateContext{
 		CLIName:       "testcli",
@@ -74,11 +112,7 @@ func TestGenerateProducesValidGo(t *testing.T) {
 		},
 	}
 
-	dir := t.TempDir()
-	projectDir, err := Generate(ctx, dir)
-	if err != nil {
-		t.Fatalf("Generate failed: %v", err)
-	}
+	projectDir, _ := buildGeneratedCLI(t, ctx)
 
 	// Verify files exist
 	for _, f := range []string{"main.go", "go.mod", "go.sum"} {
@@ -97,6 +131,14 @@ func TestGenerateProducesValidGo(t *testing.T) {
 		t.Fatalf("generated go.mod must declare go 1.24.x, got:\n%s", string(modBytes))
 	}
 
+	// HTTP projects import the auth runtime from a vendored copy.
+	if !strings.Contains(string(modBytes), "replace github.com/thellimist/clihub => ./third_party/clihub") {
+		t.Fatalf("generated go.mod must replace the clihub runtime, got:\n%s", string(modBytes))
+	}
+	if _, err := os.Stat(filepath.Join(projectDir, "third_party", "clihub", "runtime", "auth", "provider.go")); err != nil {
+		t.Errorf("expected vendored auth runtime: %v", err)
+	}
+
 	// Verify generated Go code passes go vet
 	vetCmd := exec.Command("go", "vet", "./...")
 	vetCmd.Dir = projectDir
@@ -105,14 +147,6 @@ func TestGenerateProducesValidGo(t *testing.T) {
 		mainGo, _ := os.ReadFile(filepath.Join(projectDir, "main.go"))
 		t.Fatalf("go vet failed: %v\nOutput: %s\nGenerated main.go:\n%s", err, string(out), string(mainGo))
 	}
-
-	// Verify it compiles
-	buildCmd := exec.Command("go", "build", "-o", filepath.Join(t.TempDir(), "testcli"), ".")
-	buildCmd.Dir = projectDir
-	if out, err := buildCmd.CombinedOutput(); err != nil {
-		mainGo, _ := os.ReadFile(filepath.Join(projectDir, "main.go"))
-		t.Fatalf("go build failed: %v\nOutput: %s\nGenerated main.go:\n%s", err, string(out), string(mainGo))
-	}
 }
 
 func TestGenerateStdioMode(t *testing.T) {
@@ -140,18 +174,11 @@ func TestGenerateStdioMode(t *testing.T) {
 		},
 	}
 
-	dir := t.TempDir()
-	projectDir, err := Generate(ctx, dir)
-	if err != nil {
-		t.Fatalf("Generate failed: %v", err)
-	}
+	projectDir, _ := buildGeneratedCLI(t, ctx)
 
-	// Verify it compiles
-	buildCmd := exec.Command("go", "build", "-o", filepath.Join(t.TempDir(), "testcli"), ".")
-	buildCmd.Dir = projectDir
-	if out, err := buildCmd.CombinedOutput(); err != nil {
-		mainGo, _ := os.ReadFile(filepath.Join(projectDir, "main.go"))
-		t.Fatalf("go build failed: %v\nOutput: %s\nGenerated main.go:\n%s", err, string(out), string(mainGo))
+	// Stdio CLIs have no auth, so they don't carry the runtime.
+	if _, err := os.Stat(filepath.Join(projectDir, "third_party")); !os.IsNotExist(err) {
+		t.Errorf("stdio project should not contain third_party, stat err = %v", err)
 	}
 }
 
@@ -184,17 +211,906 @@ func TestGenerateWithRawBooleanOptionCompiles(t *testing.T) {
 		},
 	}
 
+	buildGeneratedCLI(t, ctx)
+}
+
+func TestGenerateRetryAndSafetyWiring(t *testing.T) {
+	ctx := GenerateContext{
+		CLIName:       "retrytest",
+		ServerURL:     "https://example.com/mcp",
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Tools: []ToolDef{
+			{Name: "get_item", CommandName: "get-item", Description: "Get an item", Idempotent: true},
+			{Name: "create_item", CommandName: "create-item", Description: "Create an item"},
+			{Name: "delete_item", CommandName: "delete-item", Description: "Delete an item", Destructive: true},
+		},
+	}
+
 	dir := t.TempDir()
-	projectDir, err := Generate(ctx, dir)
+	projectDir, err := Generate(ctx, dir, nil)
 	if err != nil {
 		t.Fatalf("Generate failed: %v", err)
 	}
 
-	buildCmd := exec.Command("go", "build", "-o", filepath.Join(t.TempDir(), "rawtest"), ".")
-	buildCmd.Dir = projectDir
-	if out, err := buildCmd.CombinedOutput(); err != nil {
-		mainGo, _ := os.ReadFile(filepath.Join(projectDir, "main.go"))
-		t.Fatalf("go build failed: %v\nOutput: %s\nGenerated main.go:\n%s", err, string(out), string(mainGo))
+	mainGo, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	if err != nil {
+		t.Fatalf("read generated main.go: %v", err)
+	}
+	for _, want := range []string{
+		`callTool("get_item", params, true)`,
+		`callTool("create_item", params, false)`,
+		`"retries"`,
+		`"retry-backoff"`,
+		`"retry-unsafe"`,
+		`checkToolSafety("get-item", false, false)`,
+		`checkToolSafety("delete-item", false, true)`,
+		`"read-only"`,
+	} {
+		if !strings.Contains(string(mainGo), want) {
+			t.Errorf("generated main.go missing %s", want)
+		}
+	}
+
+	vetCmd := exec.Command("go", "vet", "./...")
+	vetCmd.Dir = projectDir
+	if out, err := vetCmd.CombinedOutput(); err != nil {
+		t.Fatalf("go vet failed: %v\nOutput: %s\nGenerated main.go:\n%s", err, string(out), string(mainGo))
+	}
+}
+
+// retryPolicyTest exercises the generated retry helpers from inside the
+// generated package.
+const retryPolicyTest = `package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestRetryable(t *testing.T) {
+	rec := &responseRecorder{}
+	tests := []struct {
+		name  string
+		err   *callAttemptError
+		safe  bool
+		retry bool
+	}{
+		{"connect transport error", rec.attemptError("connect", fmt.Errorf("MCP connection failed: %w", io.EOF)), false, true},
+		{"handshake transport error", rec.attemptError("handshake", fmt.Errorf("MCP handshake failed: %w", transport.NewError(io.EOF))), false, true},
+		{"handshake JSON-RPC error", rec.attemptError("handshake", fmt.Errorf("MCP handshake failed: %w", mcp.ErrMethodNotFound)), true, false},
+		{"unsupported protocol", rec.attemptError("handshake", mcp.UnsupportedProtocolVersionError{Version: "1999-01-01"}), true, false},
+		{"call transport error, safe", rec.attemptError("call", transport.NewError(io.EOF)), true, true},
+		{"call transport error, unsafe", rec.attemptError("call", transport.NewError(io.EOF)), false, false},
+		{"timed out, unsafe", &callAttemptError{err: fmt.Errorf("timed out"), timedOut: true}, false, false},
+		{"timed out, safe", &callAttemptError{err: fmt.Errorf("timed out"), timedOut: true}, true, false},
+		{"503", &callAttemptError{err: transport.NewError(io.EOF), phase: "handshake", status: 503, retryAfter: time.Second}, false, true},
+		{"429", &callAttemptError{err: transport.NewError(io.EOF), phase: "connect", status: 429}, false, true},
+		{"400", &callAttemptError{err: transport.NewError(io.EOF), phase: "connect", status: 400}, true, false},
+	}
+	for _, tt := range tests {
+		if retry, _ := tt.err.retryable(tt.safe); retry != tt.retry {
+			t.Errorf("%s: retryable = %v, want %v", tt.name, retry, tt.retry)
+		}
+	}
+	if _, wait := tests[8].err.retryable(false); wait != time.Second {
+		t.Errorf("503 wait = %v, want the Retry-After of 1s", wait)
+	}
+}
+
+func TestCallToolOnce_MissingEnvIsPermanent(t *testing.T) {
+	t.Setenv("RETRYTEST_TOKEN", "")
+	globalTimeout = 1000
+	_, attemptErr := callToolOnce("get_item", nil)
+	if attemptErr == nil {
+		t.Fatal("expected an error")
+	}
+	if retry, _ := attemptErr.retryable(true); retry {
+		t.Errorf("missing environment variable is retried: %v", attemptErr.err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	globalRetryBackoff = 100
+	for attempt := 0; attempt < 4; attempt++ {
+		full := time.Duration(100<<attempt) * time.Millisecond
+		for i := 0; i < 50; i++ {
+			if d := retryDelay(attempt, 0); d < full/2 || d > full {
+				t.Fatalf("retryDelay(%d) = %v, want within [%v, %v]", attempt, d, full/2, full)
+			}
+		}
+	}
+	if d := retryDelay(30, 0); d > 30*time.Second {
+		t.Errorf("retryDelay(30) = %v, want at most 30s", d)
+	}
+	if d := retryDelay(0, 5*time.Second); d != 5*time.Second {
+		t.Errorf("retryDelay with Retry-After 5s = %v", d)
+	}
+	if d := retryDelay(0, time.Hour); d != 2*time.Minute {
+		t.Errorf("retryDelay with Retry-After 1h = %v, want the 2m cap", d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := parseRetryAfter("3"); d != 3*time.Second {
+		t.Errorf("seconds: %v", d)
+	}
+	date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if d := parseRetryAfter(date); d < 8*time.Second || d > 10*time.Second {
+		t.Errorf("HTTP date %s: %v", date, d)
+	}
+	for _, v := range []string{"", "0", "-5", "soon", time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)} {
+		if d := parseRetryAfter(v); d != 0 {
+			t.Errorf("parseRetryAfter(%q) = %v, want 0", v, d)
+		}
+	}
+}
+`
+
+func TestGeneratedRetryPolicy(t *testing.T) {
+	ctx := GenerateContext{
+		CLIName:       "retrytest",
+		StdioCommand:  "true",
+		EnvKeys:       []string{"RETRYTEST_TOKEN"},
+		ClihubVersion: "test",
+		Tools:         []ToolDef{{Name: "get_item", CommandName: "get-item", Description: "Get an item", Idempotent: true}},
+	}
+	projectDir, err := Generate(ctx, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "retry_test.go"), []byte(retryPolicyTest), 0644); err != nil {
+		t.Fatal(err)
+	}
+	testCmd := exec.Command("go", "test", "./...")
+	testCmd.Dir = projectDir
+	if out, err := testCmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test in generated project failed: %v\n%s", err, out)
+	}
+}
+
+func TestGenerateGroupedCommandsCompiles(t *testing.T) {
+	ctx := GenerateContext{
+		CLIName:       "grouptest",
+		ServerURL:     "https://example.com/mcp",
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Tools: []ToolDef{
+			{Name: "github_issue_create", CommandName: "create", Group: "issue", Description: "Create an issue"},
+			{Name: "github_issue_list", CommandName: "list", Group: "issue", Description: "List issues", Aliases: []string{"ls"}},
+			{Name: "github_search", CommandName: "search", Description: "Search"},
+		},
+	}
+	if err := CheckCommandNames(ctx.Tools); err != nil {
+		t.Fatalf("CheckCommandNames: %v", err)
+	}
+
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	for _, args := range [][]string{
+		{"issue", "create", "--help"},
+		{"issue", "ls", "--help"},
+		{"github_issue_create", "--help"},
+		{"search", "--help"},
+	} {
+		if out, err := exec.Command(binPath, args...).CombinedOutput(); err != nil {
+			t.Errorf("grouptest %v failed: %v\n%s", args, err, out)
+		}
+	}
+}
+
+func TestGeneratePositionalArgsCompiles(t *testing.T) {
+	ctx := GenerateContext{
+		CLIName:       "argtest",
+		ServerURL:     "https://example.com/mcp",
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Tools: []ToolDef{
+			{
+				Name: "get_issue", CommandName: "get", Group: "issue", Description: "Get an issue",
+				Options: []schema.ToolOption{
+					{PropertyName: "issue_id", FlagName: "issue-id", GoType: "int", Required: true},
+					{PropertyName: "verbose", FlagName: "verbose", GoType: "bool"},
+				},
+				Positional: []string{"issue-id"},
+			},
+		},
+	}
+
+	projectDir, binPath := buildGeneratedCLI(t, ctx)
+	mainGo, _ := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	if !strings.Contains(string(mainGo), `Use:     "get <issue-id>"`) {
+		t.Errorf("expected positional usage line in main.go")
+	}
+
+	// --read-only stops the call after arguments are parsed, so these run
+	// without a server.
+	tests := []struct {
+		args    []string
+		wantOut string
+	}{
+		{[]string{"issue", "get", "42", "--read-only"}, "not annotated read-only"},
+		{[]string{"get_issue", "42", "--read-only"}, "not annotated read-only"},
+		{[]string{"issue", "get", "--issue-id", "42", "--read-only"}, "not annotated read-only"},
+		{[]string{"issue", "get", "abc"}, `invalid argument "abc" for <issue-id>`},
+		{[]string{"issue", "get", "1", "--issue-id", "2"}, "given both as an argument and as --issue-id"},
+		{[]string{"issue", "get", "1", "2"}, "accepts at most 1 arg"},
+		{[]string{"get_issue", "--help"}, "get_issue <issue-id>"},
+	}
+	for _, tt := range tests {
+		out, _ := exec.Command(binPath, tt.args...).CombinedOutput()
+		if !strings.Contains(string(out), tt.wantOut) {
+			t.Errorf("argtest %v: expected output containing %q, got:\n%s", tt.args, tt.wantOut, out)
+		}
+	}
+
+	// CLIHUB_READ_ONLY is read with strconv.ParseBool.
+	for value, readOnly := range map[string]bool{"1": true, "true": true, "0": false, "false": false} {
+		cmd := exec.Command(binPath, "issue", "get", "42", "--timeout", "1", "--retries", "0")
+		cmd.Env = append(os.Environ(), "CLIHUB_READ_ONLY="+value)
+		out, _ := cmd.CombinedOutput()
+		if got := strings.Contains(string(out), "not annotated read-only"); got != readOnly {
+			t.Errorf("CLIHUB_READ_ONLY=%s: read-only = %v, want %v:\n%s", value, got, readOnly, out)
+		}
+	}
+}
+
+func TestGeneratedCredentialsShareSecretStore(t *testing.T) {
+	ctx := GenerateContext{
+		CLIName:       "storetest",
+		ServerURL:     "https://example.com/mcp",
+		ClihubVersion: "test",
+		IsHTTP:        true,
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", credPath)
+	t.Setenv("CLIHUB_SECRET_STORE", "file")
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "correct horse")
+
+	if out, err := exec.Command(binPath, "auth", "--token", "tok-123").CombinedOutput(); err != nil {
+		t.Fatalf("auth --token failed: %v\n%s", err, out)
+	}
+	raw, _ := os.ReadFile(credPath)
+	if strings.Contains(string(raw), "tok-123") {
+		t.Errorf("generated CLI wrote the token in plaintext:\n%s", raw)
+	}
+
+	// clihub reads what the generated CLI stored.
+	creds, err := auth.LoadCredentials(credPath)
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	if got := creds.Servers["https://example.com/mcp"].Token; got != "tok-123" {
+		t.Errorf("Token = %q, want tok-123", got)
+	}
+
+	if out, err := exec.Command(binPath, "auth", "logout").CombinedOutput(); err != nil {
+		t.Fatalf("auth logout failed: %v\n%s", err, out)
+	}
+	store, err := auth.OpenSecretStore(auth.SecretStoreFile, credPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("https://example.com/mcp"); err != auth.ErrSecretNotFound {
+		t.Errorf("secret left in store after logout: %v", err)
+	}
+}
+
+func TestGeneratedCredentialProfiles(t *testing.T) {
+	const url = "https://example.com/mcp"
+	ctx := GenerateContext{
+		CLIName:       "profiletest",
+		ServerURL:     url,
+		ClihubVersion: "test",
+		IsHTTP:        true,
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", credPath)
+	t.Setenv("CLIHUB_SECRET_STORE", "json")
+	t.Setenv("CLIHUB_PROFILE", "")
+
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command(binPath, args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v failed: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	run("auth", "--token", "personal")
+	run("--profile", "bot", "auth", "--token", "robot")
+
+	status := run("auth", "status")
+	if !strings.Contains(status, "Profile:   default") || !strings.Contains(status, "* default") || !strings.Contains(status, "  bot") {
+		t.Errorf("auth status does not list both profiles:\n%s", status)
+	}
+
+	if out, err := exec.Command(binPath, "auth", "use", "nope").CombinedOutput(); err == nil {
+		t.Errorf("auth use accepted an unknown profile:\n%s", out)
+	}
+	run("auth", "use", "bot")
+
+	creds, err := auth.LoadCredentials(credPath)
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	if got := creds.ResolveProfile(url, ""); got != "bot" {
+		t.Errorf("default profile = %q, want bot", got)
+	}
+	if got := auth.GetToken(creds, url, "bot"); got != "robot" {
+		t.Errorf("bot token = %q, want robot", got)
+	}
+	if got := auth.GetToken(creds, url, auth.DefaultProfile); got != "personal" {
+		t.Errorf("default token = %q, want personal", got)
+	}
+
+	t.Setenv("CLIHUB_PROFILE", "default")
+	if status := run("auth", "status"); !strings.Contains(status, "Profile:   default") {
+		t.Errorf("CLIHUB_PROFILE not honoured:\n%s", status)
+	}
+}
+
+func TestGeneratedConcurrentRefresh(t *testing.T) {
+	var refreshes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/token" {
+			http.Error(w, "no MCP here", http.StatusBadRequest)
+			return
+		}
+		n := atomic.AddInt32(&refreshes, 1)
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"new-%d","refresh_token":"rotated-%d","expires_in":3600}`, n, n)
+	}))
+	defer srv.Close()
+
+	mcpURL := srv.URL + "/mcp"
+	ctx := GenerateContext{
+		CLIName:       "refreshtest",
+		ServerURL:     mcpURL,
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Tools:         []ToolDef{{Name: "ping", CommandName: "ping", Description: "Ping"}},
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", credPath)
+	t.Setenv("CLIHUB_SECRET_STORE", "json")
+	expired := time.Now().Add(-time.Minute)
+	creds := &auth.CredentialsFile{Version: 2, Servers: map[string]auth.ServerCredential{
+		mcpURL: {
+			AuthType:      "oauth2",
+			AccessToken:   "old",
+			RefreshToken:  "refresh-1",
+			ExpiresAt:     &expired,
+			ClientID:      "cid",
+			TokenEndpoint: srv.URL + "/token",
+		},
+	}}
+	if err := auth.SaveCredentials(credPath, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every run finds the token expired at startup; only one may spend the
+	// refresh token. The tool calls themselves fail against the fake server.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = exec.Command(binPath, "ping", "--retries", "0").CombinedOutput()
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&refreshes); got != 1 {
+		t.Errorf("token endpoint called %d times, want 1", got)
+	}
+	loaded, err := auth.LoadCredentials(credPath)
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	if got := loaded.Servers[mcpURL]; got.AccessToken != "new-1" || got.RefreshToken != "rotated-1" {
+		t.Errorf("stored tokens = %q/%q, want new-1/rotated-1", got.AccessToken, got.RefreshToken)
+	}
+}
+
+func TestGeneratedProactiveRefresh(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			fmt.Fprint(w, `{"access_token":"fresh","expires_in":3600}`)
+			return
+		}
+		mu.Lock()
+		sent = append(sent, r.Header.Get("Authorization"))
+		mu.Unlock()
+		http.Error(w, "no MCP here", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	mcpURL := srv.URL + "/mcp"
+	ctx := GenerateContext{
+		CLIName:       "skewtest",
+		ServerURL:     mcpURL,
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Tools:         []ToolDef{{Name: "ping", CommandName: "ping", Description: "Ping"}},
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", credPath)
+	t.Setenv("CLIHUB_SECRET_STORE", "json")
+	t.Setenv("CLIHUB_TOKEN_REFRESH_SKEW", "5m")
+	expiring := time.Now().Add(2 * time.Minute)
+	creds := &auth.CredentialsFile{Version: 2, Servers: map[string]auth.ServerCredential{
+		mcpURL: {
+			AuthType:      "oauth2",
+			AccessToken:   "expiring",
+			RefreshToken:  "refresh",
+			ExpiresAt:     &expiring,
+			TokenEndpoint: srv.URL + "/token",
+		},
+	}}
+	if err := auth.SaveCredentials(credPath, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = exec.Command(binPath, "ping", "--retries", "0").CombinedOutput()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) == 0 || sent[0] != "Bearer fresh" {
+		t.Errorf("MCP requests sent Authorization %q, want the refreshed token", sent)
+	}
+}
+
+func TestGeneratedDeviceFlow(t *testing.T) {
+	var polls int32
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"issuer":%q,"authorization_endpoint":"%[1]s/authorize","token_endpoint":"%[1]s/token","device_authorization_endpoint":"%[1]s/device"}`, srv.URL)
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"device_code":"dev","user_code":"WDJB-MJHT","verification_uri":"%s/activate","expires_in":60,"interval":1}`, srv.URL)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&polls, 1) == 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"authorization_pending"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"device-access","refresh_token":"device-refresh","expires_in":3600}`)
+	})
+	srv = httptest.NewServer(mux)
+	defer srv.Close()
+
+	mcpURL := srv.URL + "/mcp"
+	ctx := GenerateContext{
+		CLIName:       "devicetest",
+		ServerURL:     mcpURL,
+		ClihubVersion: "test",
+		IsHTTP:        true,
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", credPath)
+	t.Setenv("CLIHUB_SECRET_STORE", "json")
+	t.Setenv("CLIHUB_PROFILE", "")
+
+	out, err := exec.Command(binPath, "auth", "--device", "--client-id", "preregistered").CombinedOutput()
+	if err != nil {
+		t.Fatalf("auth --device failed: %v\n%s", err, out)
+	}
+	if !strings.Contains(string(out), "WDJB-MJHT") || !strings.Contains(string(out), srv.URL+"/activate") {
+		t.Errorf("user code and verification URL not printed:\n%s", out)
+	}
+	creds, err := auth.LoadCredentials(credPath)
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	if got := creds.Servers[mcpURL]; got.AccessToken != "device-access" || got.ClientID != "preregistered" {
+		t.Errorf("stored credential = %+v", got)
+	}
+}
+
+func TestGenerateEmbedsOAuthDiscovery(t *testing.T) {
+	ctx := GenerateContext{
+		CLIName:       "discoverytest",
+		ServerURL:     "https://mcp.example.com/mcp",
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Auth: AuthConfig{
+			Type:                "oauth2",
+			ResourceMetadataURL: "https://mcp.example.com/.well-known/oauth-protected-resource/mcp",
+			AuthServerURL:       "https://auth.example.com",
+		},
+		Tools: []ToolDef{{Name: "ping", CommandName: "ping", Description: "Ping"}},
+	}
+	projectDir, err := Generate(ctx, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	mainGo, err := os.ReadFile(filepath.Join(projectDir, "main.go"))
+	if err != nil {
+		t.Fatalf("read generated main.go: %v", err)
+	}
+	for _, want := range []string{
+		`ResourceMetadataURL: "https://mcp.example.com/.well-known/oauth-protected-resource/mcp",`,
+		`AuthServerURL:       "https://auth.example.com",`,
+	} {
+		if !strings.Contains(string(mainGo), want) {
+			t.Errorf("generated main.go missing %s", want)
+		}
+	}
+}
+
+func TestGeneratedWorkloadAuth(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			r.ParseForm()
+			if r.FormValue("grant_type") != auth.TokenExchangeGrantType || r.FormValue("subject_token") != "ci-oidc" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"exchanged","expires_in":3600}`)
+			return
+		}
+		mu.Lock()
+		sent = append(sent, r.Header.Get("Authorization"))
+		mu.Unlock()
+		http.Error(w, "no MCP here", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	mcpURL := srv.URL + "/mcp"
+	ctx := GenerateContext{
+		CLIName:       "workloadtest",
+		ServerURL:     mcpURL,
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Tools:         []ToolDef{{Name: "ping", CommandName: "ping", Description: "Ping"}},
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", credPath)
+	t.Setenv("CLIHUB_SECRET_STORE", "file")
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "correct horse")
+	t.Setenv("CLIHUB_PROFILE", "")
+	t.Setenv("CI_OIDC_TOKEN", "ci-oidc")
+	creds := &auth.CredentialsFile{Version: 2, Servers: map[string]auth.ServerCredential{
+		mcpURL: {AuthType: "token_exchange", TokenEndpoint: srv.URL + "/token", SubjectTokenEnv: "CI_OIDC_TOKEN"},
+	}}
+	if err := auth.SaveCredentials(credPath, creds); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = exec.Command(binPath, "ping", "--retries", "0").CombinedOutput()
+
+	// The helper counts its runs; its cached token must be reused by a
+	// second invocation and by clihub's own provider.
+	counter := filepath.Join(t.TempDir(), "runs")
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	helper := fmt.Sprintf(`echo run >> %s; echo '{"token":"helper","expires_at":"%s"}'`, counter, expires)
+	for i := 0; i < 2; i++ {
+		_, _ = exec.Command(binPath, "ping", "--retries", "0", "--auth-exec", helper).CombinedOutput()
+	}
+	p := &auth.ExecCredentialProvider{Command: helper, ServerURL: mcpURL, CredPath: credPath}
+	if headers, err := p.GetHeaders(t.Context()); err != nil || headers["Authorization"] != "Bearer helper" {
+		t.Errorf("clihub provider headers = %v, %v", headers, err)
+	}
+	if data, _ := os.ReadFile(counter); strings.Count(string(data), "run") != 1 {
+		t.Errorf("helper ran %d times, want 1 (token cached)", strings.Count(string(data), "run"))
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) == 0 || sent[0] != "Bearer exchanged" {
+		t.Fatalf("token_exchange requests sent Authorization %q, want the exchanged token", sent)
+	}
+	if sent[len(sent)-1] != "Bearer helper" {
+		t.Errorf("exec requests sent Authorization %q, want the helper token", sent)
+	}
+}
+
+func TestGeneratedMutualTLS(t *testing.T) {
+	// Self-signed client certificate the server trusts
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "generated-cli"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	clientCert, _ := x509.ParseCertificate(der)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	var requests atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "no MCP here", http.StatusBadRequest)
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	ctx := GenerateContext{
+		CLIName:       "mtlstest",
+		ServerURL:     srv.URL + "/mcp",
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		CABundle:      string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})),
+		Tools:         []ToolDef{{Name: "ping", CommandName: "ping", Description: "Ping"}},
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials.json"))
+
+	// The embedded CA verifies the server, but it rejects a client without
+	// a certificate
+	out, _ := exec.Command(binPath, "ping", "--retries", "0").CombinedOutput()
+	if got := requests.Load(); got != 0 {
+		t.Fatalf("server handled %d requests without a client certificate:\n%s", got, out)
+	}
+	if strings.Contains(string(out), "certificate signed by unknown authority") {
+		t.Fatalf("embedded CA bundle not trusted:\n%s", out)
+	}
+
+	out, _ = exec.Command(binPath, "ping", "--retries", "0", "--client-cert", certFile, "--client-key", keyFile).CombinedOutput()
+	if requests.Load() == 0 {
+		t.Fatalf("no request reached the server with a client certificate:\n%s", out)
+	}
+
+	t.Setenv("CLIHUB_CLIENT_CERT", certFile)
+	out, _ = exec.Command(binPath, "ping", "--retries", "0").CombinedOutput()
+	if !strings.Contains(string(out), "--client-cert and --client-key must be used together") {
+		t.Errorf("expected error for a certificate without a key, got:\n%s", out)
+	}
+}
+
+func TestGeneratedProxyHeadersAndUserAgent(t *testing.T) {
+	var mu sync.Mutex
+	var seen []*http.Request
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r)
+		mu.Unlock()
+		http.Error(w, "no MCP here", http.StatusBadRequest)
+	}))
+	defer proxy.Close()
+	last := func() *http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(seen) == 0 {
+			return nil
+		}
+		return seen[len(seen)-1]
+	}
+	count := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(seen)
+	}
+
+	// Loopback hosts are never proxied, so the server uses a name only the
+	// proxy "resolves"
+	ctx := GenerateContext{
+		CLIName:       "proxytest",
+		ServerURL:     "http://mcp.clihub.test/mcp",
+		ClihubVersion: "test",
+		Version:       "2.3.4",
+		IsHTTP:        true,
+		Headers:       map[string]string{"X-Tenant": "acme", "X-Request-Source": "default"},
+		Tools:         []ToolDef{{Name: "ping", CommandName: "ping", Description: "Ping"}},
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials.json"))
+	for _, key := range []string{"HTTPS_PROXY", "https_proxy", "http_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(key, "")
+	}
+	proxyURL := strings.Replace(proxy.URL, "http://", "http://user:s3cret@", 1)
+	t.Setenv("HTTP_PROXY", proxyURL)
+
+	out, _ := exec.Command(binPath, "ping", "--retries", "0", "--header", "X-Request-Source=ci").CombinedOutput()
+	r := last()
+	if r == nil {
+		t.Fatalf("HTTP_PROXY not used:\n%s", out)
+	}
+	if r.URL.Host != "mcp.clihub.test" {
+		t.Errorf("proxied URL = %s, want host mcp.clihub.test", r.URL)
+	}
+	if got := r.Header.Get("X-Tenant"); got != "acme" {
+		t.Errorf("X-Tenant = %q, want the embedded default", got)
+	}
+	if got := r.Header.Get("X-Request-Source"); got != "ci" {
+		t.Errorf("X-Request-Source = %q, want --header to override the default", got)
+	}
+	if got := r.Header.Get("User-Agent"); got != "proxytest/2.3.4 clihub/test" {
+		t.Errorf("User-Agent = %q", got)
+	}
+	if got, want := r.Header.Get("Proxy-Authorization"), "Basic "+base64.StdEncoding.EncodeToString([]byte("user:s3cret")); got != want {
+		t.Errorf("Proxy-Authorization = %q, want %q", got, want)
+	}
+
+	// NO_PROXY bypasses the proxy (the fake host then fails to resolve)
+	before := count()
+	t.Setenv("NO_PROXY", "mcp.clihub.test")
+	_, _ = exec.Command(binPath, "ping", "--retries", "0").CombinedOutput()
+	if count() != before {
+		t.Error("request was proxied despite NO_PROXY")
+	}
+
+	// --proxy works without the environment
+	t.Setenv("HTTP_PROXY", "")
+	t.Setenv("NO_PROXY", "")
+	out, _ = exec.Command(binPath, "ping", "--retries", "0", "--proxy", proxy.URL).CombinedOutput()
+	if count() == before {
+		t.Errorf("--proxy not used:\n%s", out)
+	}
+
+	out, err := exec.Command(binPath, "ping", "--header", "no-equals-sign").CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "expected KEY=VALUE") {
+		t.Errorf("invalid --header accepted: %v\n%s", err, out)
+	}
+	out, err = exec.Command(binPath, "--version").CombinedOutput()
+	if err != nil || !strings.Contains(string(out), "2.3.4") {
+		t.Errorf("--version = %q, %v", out, err)
+	}
+}
+
+func TestGeneratedEmbeddedAuth(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("X-Key"))
+		mu.Unlock()
+		http.Error(w, "no MCP here", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	ctx := GenerateContext{
+		CLIName:       "embedtest",
+		ServerURL:     srv.URL,
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Auth:          AuthConfig{Type: "api_key", HeaderName: "X-Key"},
+		Tools:         []ToolDef{{Name: "ping", CommandName: "ping", Description: "Ping"}},
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", credPath)
+	t.Setenv("CLIHUB_SECRET_STORE", "json")
+	t.Setenv("CLIHUB_PROFILE", "")
+
+	// `auth` knows to ask for an API key and stores it with its header
+	authCmd := exec.Command(binPath, "auth")
+	authCmd.Stdin = strings.NewReader("stored-key\n")
+	if out, err := authCmd.CombinedOutput(); err != nil {
+		t.Fatalf("auth failed: %v\nOutput: %s", err, out)
+	}
+	creds, err := auth.LoadCredentials(credPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, ok := creds.Credential(srv.URL, "")
+	if !ok || sc.AuthType != "api_key" || sc.HeaderName != "X-Key" || sc.Token != "stored-key" {
+		t.Fatalf("stored credential = %+v, %v", sc, ok)
+	}
+	_, _ = exec.Command(binPath, "ping", "--retries", "0").CombinedOutput()
+
+	// A token from the environment goes in the same header
+	envCmd := exec.Command(binPath, "ping", "--retries", "0")
+	envCmd.Env = append(os.Environ(), "CLIHUB_AUTH_TOKEN=env-key")
+	_, _ = envCmd.CombinedOutput()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(keys) < 2 || keys[0] != "stored-key" || keys[len(keys)-1] != "env-key" {
+		t.Errorf("X-Key headers = %q, want the stored key then the env key", keys)
+	}
+}
+
+func TestGeneratedScopeStepUp(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="files:write", resource_metadata="https://example.com/x"`)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	ctx := GenerateContext{
+		CLIName:       "stepuptest",
+		ServerURL:     srv.URL,
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Tools:         []ToolDef{{Name: "ping", CommandName: "ping", Description: "Ping"}},
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", credPath)
+	t.Setenv("CLIHUB_SECRET_STORE", "json")
+	t.Setenv("CLIHUB_PROFILE", "")
+	creds := &auth.CredentialsFile{Version: 2, Servers: map[string]auth.ServerCredential{
+		srv.URL: {AuthType: "oauth2", AccessToken: "narrow", Scope: "files:read"},
+	}}
+	if err := auth.SaveCredentials(credPath, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a terminal to ask for consent, the error says how to grant it.
+	cmd := exec.Command(binPath, "ping", "--retries", "0")
+	cmd.Stdin = strings.NewReader("y\n")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("expected the call to fail\nOutput: %s", out)
+	}
+	if want := `stepuptest auth --scope "files:read files:write"`; !strings.Contains(string(out), want) {
+		t.Errorf("output %q does not suggest %s", out, want)
+	}
+}
+
+func TestCheckCommandNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		tools   []ToolDef
+		wantErr string
+	}{
+		{"distinct", []ToolDef{{Name: "a", CommandName: "a"}, {Name: "b", CommandName: "b", Group: "g"}}, ""},
+		{"duplicate command", []ToolDef{{Name: "a", CommandName: "x"}, {Name: "b", CommandName: "x"}}, `command "x" is used by both`},
+		{"alias clash", []ToolDef{{Name: "a", CommandName: "a", Aliases: []string{"b"}}, {Name: "b", CommandName: "b"}}, `command "b" is used by both`},
+		{"reserved command", []ToolDef{{Name: "login", CommandName: "auth"}}, "collides with a built-in"},
+		{"reserved group", []ToolDef{{Name: "a", CommandName: "a", Group: "help"}}, "collides with a built-in"},
+		{"top-level vs group", []ToolDef{{Name: "a", CommandName: "g"}, {Name: "b", CommandName: "b", Group: "g"}}, "collides with a built-in or group"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCommandNames(tt.tools)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
 	}
 }
 
@@ -214,6 +1130,14 @@ func TestTemplateFunctions(t *testing.T) {
 		{"cobraFlag bool", func() string { return cobraFlagType("bool") }, "BoolVar"},
 		{"cobraFlag float64", func() string { return cobraFlagType("float64") }, "Float64Var"},
 		{"cobraFlag []string", func() string { return cobraFlagType("[]string") }, "StringSliceVar"},
+		{"toolUse plain", func() string { return toolUse(ToolDef{CommandName: "list"}) }, "list"},
+		{"toolUse positional", func() string {
+			return toolUse(ToolDef{CommandName: "get", Positional: []string{"owner", "repo"}})
+		}, "get <owner> <repo>"},
+		{"toolLong no hints", func() string { return toolLongDescription(ToolDef{Description: "List items"}) }, "List items"},
+		{"toolLong hints", func() string {
+			return toolLongDescription(ToolDef{Description: "Delete item", Destructive: true, Idempotent: true})
+		}, "Delete item\n\nHints: destructive, idempotent"},
 	}
 
 	for _, tt := range tests {
@@ -225,3 +1149,334 @@ func TestTemplateFunctions(t *testing.T) {
 		})
 	}
 }
+
+func TestWriteDocs(t *testing.T) {
+	ctx := GenerateContext{
+		CLIName:   "linear",
+		ServerURL: "https://mcp.linear.app/mcp",
+		IsHTTP:    true,
+		Version:   "2.0.0",
+		Tools: []ToolDef{
+			{
+				Name:        "create_issue",
+				CommandName: "create",
+				Group:       "issue",
+				Description: "Create an issue. Returns the new issue.",
+				Destructive: true,
+				Positional:  []string{"title"},
+				Options: []schema.ToolOption{
+					{PropertyName: "title", FlagName: "title", GoType: "string", Required: true, Description: "Issue title"},
+					{PropertyName: "team", FlagName: "team", GoType: "string", Required: true, Examples: []string{"ENG"}},
+					{PropertyName: "priority", FlagName: "priority", GoType: "string", Required: true, EnumValues: []string{"low", "high"}},
+					{PropertyName: "label", FlagName: "label", GoType: "string", Description: "a | b", DefaultValue: "bug"},
+				},
+			},
+			{Name: "list_teams", CommandName: "list-teams", Description: "List teams", ReadOnly: true},
+		},
+	}
+
+	if got, want := ctx.Tools[0].Example("linear"), "linear issue create <title> --team ENG --priority low"; got != want {
+		t.Errorf("Example = %q, want %q", got, want)
+	}
+
+	dir := t.TempDir()
+	paths, err := WriteDocs(ctx, dir)
+	if err != nil {
+		t.Fatalf("WriteDocs: %v", err)
+	}
+	var names []string
+	for _, p := range paths {
+		rel, _ := filepath.Rel(dir, p)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	want := "docs/index.md docs/issue-create.md docs/list-teams.md man/linear-issue-create.1 man/linear-list-teams.1 man/linear.1"
+	if strings.Join(names, " ") != want {
+		t.Errorf("paths = %v", names)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	for name, wants := range map[string][]string{
+		"docs/index.md": {
+			"| [`issue create`](issue-create.md) | Create an issue. |",
+			"| [`list-teams`](list-teams.md) | List teams |",
+			"Run `linear auth` to sign in",
+		},
+		"docs/issue-create.md": {
+			"linear issue create <title> [flags]",
+			"- Hints: destructive",
+			"| `--priority` | string | yes |  | One of: low, high. |",
+			"| `--label` | string |  | `bug` | a \\| b |",
+			"linear issue create <title> --team ENG --priority low",
+		},
+		"man/linear.1": {
+			".TH \"LINEAR\" 1",
+			".B issue create\nCreate an issue.",
+			".BR linear\\-list\\-teams (1)",
+		},
+		"man/linear-issue-create.1": {
+			"linear\\-issue\\-create \\- Create an issue.",
+			".BI \"\\-\\-team \" string\nRequired. Example: ENG.",
+			"Default: bug.",
+		},
+	} {
+		content := read(name)
+		for _, w := range wants {
+			if !strings.Contains(content, w) {
+				t.Errorf("%s missing %q:\n%s", name, w, content)
+			}
+		}
+	}
+}
+
+func TestWriteAgentGuide(t *testing.T) {
+	ctx := GenerateContext{
+		CLIName:   "linear",
+		ServerURL: "https://mcp.linear.app/mcp",
+		IsHTTP:    true,
+		Version:   "2.0.0",
+		Auth:      AuthConfig{Type: "api_key"},
+		Tools: []ToolDef{
+			{
+				Name:        "create_issue",
+				CommandName: "create",
+				Group:       "issue",
+				Description: "Create an issue. Returns the new issue.",
+				Destructive: true,
+				Options: []schema.ToolOption{
+					{PropertyName: "title", FlagName: "title", GoType: "string", Required: true},
+					{PropertyName: "priority", FlagName: "priority", GoType: "string", EnumValues: []string{"low", "high"}},
+				},
+			},
+			{Name: "list_teams", CommandName: "list-teams", Description: "List teams", ReadOnly: true},
+		},
+	}
+
+	dir := t.TempDir()
+	paths, err := WriteAgentGuide(ctx, dir)
+	if err != nil {
+		t.Fatalf("WriteAgentGuide: %v", err)
+	}
+	var names []string
+	for _, p := range paths {
+		rel, _ := filepath.Rel(dir, p)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	if got, want := strings.Join(names, " "), "agent/AGENTS.md agent/linear.tools.json agent/skills/linear/SKILL.md"; got != want {
+		t.Errorf("paths = %s, want %s", got, want)
+	}
+
+	section := AgentsSection(ctx)
+	for _, w := range []string{
+		"## linear\n",
+		"- Auth: Run `linear auth --token <token>` once, or set CLIHUB_AUTH_TOKEN.",
+		"- `linear issue create --title <title>` — Create an issue. (destructive)\n",
+		"- `linear list-teams` — List teams (read-only)\n",
+	} {
+		if !strings.Contains(section, w) {
+			t.Errorf("AGENTS.md section missing %q:\n%s", w, section)
+		}
+	}
+
+	skill := SkillFile(ctx)
+	if !strings.HasPrefix(skill, "---\nname: linear\ndescription: Use the linear CLI to call the tools of the MCP server https://mcp.linear.app/mcp (issue create, list-teams).\n---\n\n# linear\n") {
+		t.Errorf("SKILL.md header:\n%s", skill)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "agent", "linear.tools.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m AgentManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("tool manifest: %v", err)
+	}
+	if m.Name != "linear" || len(m.Tools) != 2 || len(m.OutputFormats) != 4 {
+		t.Fatalf("manifest = %+v", m)
+	}
+	create := m.Tools[0]
+	if create.Tool != "create_issue" || create.Command != "linear issue create" || create.Example != "linear issue create --title <title>" {
+		t.Errorf("tool = %+v", create)
+	}
+	if len(create.Flags) != 2 || !create.Flags[0].Required || create.Flags[1].Enum[1] != "high" {
+		t.Errorf("flags = %+v", create.Flags)
+	}
+}
+
+func TestGeneratedSelfUpdate(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	keyPath := filepath.Join(t.TempDir(), "cosign.key")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	releaseDir := t.TempDir()
+
+	build := func(version string) string {
+		t.Helper()
+		ctx := GenerateContext{
+			CLIName:       "testcli",
+			StdioCommand:  "true",
+			ClihubVersion: "test",
+			Version:       version,
+			Update:        UpdateConfig{URL: releaseDir, PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))},
+			Tools:         []ToolDef{{Name: "hello", CommandName: "hello", Description: "Say hello"}},
+		}
+		_, binPath := buildGeneratedCLI(t, ctx, "-trimpath")
+		return binPath
+	}
+	binPath := build("1.0.0")
+	released := filepath.Join(releaseDir, "testcli")
+	if err := os.Rename(build("1.1.0"), released); err != nil {
+		t.Fatal(err)
+	}
+	sbom, err := release.WriteSBOM(released, "testcli", "1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums, err := release.WriteChecksums(releaseDir, []string{released, sbom})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := release.Sign(sums, keyPath); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) string {
+		t.Helper()
+		out, err := exec.Command(binPath, args...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v: %v\n%s", args, err, out)
+		}
+		return string(out)
+	}
+	if out := run("self-update", "--check"); !strings.Contains(out, "Update available: 1.0.0 → 1.1.0") {
+		t.Errorf("self-update --check = %q", out)
+	}
+	if out := run("self-update", "--check", "-o", "json"); !strings.Contains(out, `"available":true`) || !strings.Contains(out, `"signed":true`) {
+		t.Errorf("self-update --check -o json = %q", out)
+	}
+	if out := run("--version"); !strings.Contains(out, "1.0.0") {
+		t.Errorf("--check changed the binary: %q", out)
+	}
+	if out := run("self-update"); !strings.Contains(out, "from 1.0.0 to 1.1.0") {
+		t.Errorf("self-update = %q", out)
+	}
+	if out := run("--version"); !strings.Contains(out, "1.1.0") {
+		t.Errorf("--version after update = %q", out)
+	}
+	if out := run("self-update", "--check"); !strings.Contains(out, "testcli 1.1.0 is up to date") {
+		t.Errorf("self-update --check after update = %q", out)
+	}
+
+	// A SHA256SUMS the key didn't sign is refused.
+	if err := os.WriteFile(sums, []byte("0000  testcli\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := exec.Command(binPath, "self-update").CombinedOutput()
+	if err == nil || !strings.Contains(string(out), "signature verification failed") {
+		t.Errorf("self-update with tampered SHA256SUMS: %v\n%s", err, out)
+	}
+}
+
+// echoMCPServer is a minimal streamable HTTP MCP server whose tools reply
+// with their arguments as JSON text.
+func echoMCPServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				Arguments json.RawMessage `json:"arguments"`
+			} `json:"params"`
+		}
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.ID == nil {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{"protocolVersion": "2025-03-26", "capabilities": map[string]any{}, "serverInfo": map[string]any{"name": "echo", "version": "1"}}
+		case "tools/call":
+			result = map[string]any{"content": []map[string]any{{"type": "text", "text": string(req.Params.Arguments)}}}
+		default:
+			result = map[string]any{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestGeneratedFromJSON(t *testing.T) {
+	srv := echoMCPServer(t)
+	ctx := GenerateContext{
+		CLIName:       "jsontest",
+		ServerURL:     srv.URL + "/mcp",
+		ClihubVersion: "test",
+		IsHTTP:        true,
+		Auth:          AuthConfig{Type: "none"},
+		Tools: []ToolDef{
+			{
+				Name: "create_issue", CommandName: "create-issue", Description: "Create an issue",
+				Options: []schema.ToolOption{
+					{PropertyName: "title", FlagName: "title", GoType: "string"},
+					{PropertyName: "priority", FlagName: "priority", GoType: "string", DefaultValue: "low", EnumValues: []string{"low", "high"}},
+					{PropertyName: "count", FlagName: "count", GoType: "int"},
+				},
+				Positional: []string{"title"},
+			},
+		},
+	}
+	_, binPath := buildGeneratedCLI(t, ctx)
+	t.Setenv("CLIHUB_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials.json"))
+
+	dir := t.TempDir()
+	jsonFile := filepath.Join(dir, "issue.json")
+	yamlFile := filepath.Join(dir, "issue.yaml")
+	os.WriteFile(jsonFile, []byte(`{"title":"From file","priority":"high","labels":["a"]}`), 0644)
+	os.WriteFile(yamlFile, []byte("title: From YAML\ncount: 3\nmeta:\n  1: one\n"), 0644)
+
+	tests := []struct {
+		args  []string
+		stdin string
+		want  string
+	}{
+		{[]string{"--from-json", `{"title":"Inline"}`}, "", `{"title":"Inline"}`},
+		{[]string{"--from-json", "@" + jsonFile}, "", `{"labels":["a"],"priority":"high","title":"From file"}`},
+		{[]string{"--from-json", "@" + yamlFile}, "", `{"count":3,"meta":{"1":"one"},"title":"From YAML"}`},
+		{[]string{"--from-json", `{title: Flow YAML, count: 3}`}, "", `{"count":3,"title":"Flow YAML"}`},
+		{[]string{"--from-json", "-"}, `{"title":"Stdin"}`, `{"title":"Stdin"}`},
+		{[]string{"--from-json", "-"}, "title: Stdin YAML\n", `{"title":"Stdin YAML"}`},
+		// Typed flags and positional args override keys; unset flags with
+		// defaults leave them alone.
+		{[]string{"--from-json", "@" + jsonFile, "--count", "2"}, "", `{"count":2,"labels":["a"],"priority":"high","title":"From file"}`},
+		{[]string{"Patched", "--from-json", "@" + jsonFile, "--priority", "low"}, "", `{"labels":["a"],"priority":"low","title":"Patched"}`},
+		{[]string{"Flags only"}, "", `{"priority":"low","title":"Flags only"}`},
+		{[]string{"--from-json", `{"priority":"urgent"}`}, "", `invalid value "urgent" for --priority`},
+		{[]string{"--from-json", `{"title":`}, "", "invalid --from-json: JSON:"},
+		{[]string{"--from-json", "[1, 2]"}, "", "invalid --from-json: expected an object"},
+		{[]string{"--from-json", "@" + filepath.Join(dir, "missing.json")}, "", "invalid --from-json: open"},
+	}
+	for _, tt := range tests {
+		cmd := exec.Command(binPath, append([]string{"create-issue", "--retries", "0"}, tt.args...)...)
+		cmd.Stdin = strings.NewReader(tt.stdin)
+		out, _ := cmd.CombinedOutput()
+		if !strings.Contains(string(out), tt.want) {
+			t.Errorf("jsontest %v: expected output containing %q, got:\n%s", tt.args, tt.want, out)
+		}
+	}
+}
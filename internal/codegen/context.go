@@ -1,23 +1,153 @@
 package codegen
 
-import "github.com/thellimist/clihub/internal/schema"
+import (
+	"fmt"
+	"sort"
+
+	"github.com/thellimist/clihub/internal/nameutil"
+	"github.com/thellimist/clihub/internal/schema"
+)
 
 // GenerateContext holds all data needed to generate a CLI project.
 type GenerateContext struct {
-	CLIName       string    // Generated CLI binary name
-	ServerURL     string    // MCP server URL (for HTTP mode)
-	StdioCommand  string    // Stdio command (for stdio mode)
-	StdioArgs     []string  // Stdio command args
-	EnvKeys       []string  // Env var keys to embed (not values)
-	Tools         []ToolDef // Tool definitions with options
-	ClihubVersion string    // clihub version for header comment
-	IsHTTP        bool      // True = HTTP transport, false = stdio
+	CLIName       string            // Generated CLI binary name
+	ServerURL     string            // MCP server URL (for HTTP mode)
+	StdioCommand  string            // Stdio command (for stdio mode)
+	StdioArgs     []string          // Stdio command args
+	EnvKeys       []string          // Env var keys to embed (not values)
+	Tools         []ToolDef         // Tool definitions with options
+	ClihubVersion string            // clihub version for header comment
+	IsHTTP        bool              // True = HTTP transport, false = stdio
+	CABundle      string            // PEM CA bundle trusted by the generated CLI (HTTP mode)
+	Headers       map[string]string // Default HTTP headers sent to the server (HTTP mode)
+	Version       string            // Generated CLI version (default 1.0.0)
+	Auth          AuthConfig        // Auth detected at generation time (HTTP mode)
+	Update        UpdateConfig      // Release location for self-update; empty URL disables it
+}
+
+// UpdateConfig is where the generated CLI's self-update command looks for
+// new releases.
+type UpdateConfig struct {
+	URL       string // directory, file:// URL or http(s) base URL of a published clihub output directory
+	PublicKey string // minisign or PEM public key SHA256SUMS must be signed with; empty checks checksums only
+}
+
+// UsesRuntime reports whether the generated project imports clihub's
+// runtime packages, and so needs its copy under third_party.
+func (c GenerateContext) UsesRuntime() bool {
+	return c.IsHTTP || c.Update.URL != ""
+}
+
+// AuthConfig is the server's auth setup, embedded so the generated CLI knows
+// which flow to run. It never holds secrets.
+type AuthConfig struct {
+	Type                string // bearer, api_key, basic, oauth2, s2s_oauth2, google_sa, token_exchange, exec, none; empty if unknown
+	HeaderName          string // api_key header
+	Scope               string // OAuth scope to request
+	ClientID            string // pre-registered OAuth client ID
+	ResourceMetadataURL string // RFC 9728 protected resource metadata URL
+	AuthServerURL       string // OAuth authorization server (issuer) URL
+	TokenEndpoint       string // s2s_oauth2 and token_exchange
+	SubjectTokenFile    string // token_exchange
+	SubjectTokenEnv     string // token_exchange
+	SubjectTokenType    string // token_exchange
+	Audience            string // token_exchange
+	Command             string // exec credential helper
 }
 
 // ToolDef represents a single MCP tool for code generation.
 type ToolDef struct {
 	Name        string              // Original MCP tool name (e.g., "list_issues")
-	CommandName string              // Kebab-case command (e.g., "list-issues")
+	CommandName string              // Kebab-case command (e.g., "list-issues"); the leaf name when grouped
+	Group       string              // Parent command (e.g., "issue"); empty for top-level commands
+	Aliases     []string            // Extra command aliases; the MCP name is always an alias
 	Description string              // Tool description
 	Options     []schema.ToolOption // CLI flag options derived from schema
+	Positional  []string            // Flag names also accepted as positional args, in order
+	ReadOnly    bool                // MCP readOnlyHint: the tool does not modify its environment
+	Destructive bool                // MCP destructiveHint: the tool may perform destructive updates
+	Idempotent  bool                // MCP idempotentHint: repeated calls have no additional effect
+	OpenWorld   bool                // MCP openWorldHint: the tool interacts with external entities
+}
+
+// Hints returns the human-readable names of the annotations set on the tool.
+func (t ToolDef) Hints() []string {
+	var hints []string
+	if t.ReadOnly {
+		hints = append(hints, "read-only")
+	}
+	if t.Destructive {
+		hints = append(hints, "destructive")
+	}
+	if t.Idempotent {
+		hints = append(hints, "idempotent")
+	}
+	if t.OpenWorld {
+		hints = append(hints, "open-world")
+	}
+	return hints
+}
+
+// Groups returns the distinct parent command names used by the tools, sorted.
+func (c GenerateContext) Groups() []string {
+	seen := make(map[string]bool)
+	var groups []string
+	for _, t := range c.Tools {
+		if t.Group != "" && !seen[t.Group] {
+			seen[t.Group] = true
+			groups = append(groups, t.Group)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// CheckCommandNames returns an error if two tools would end up with the same
+// command or alias, or if a tool collides with a built-in command.
+func CheckCommandNames(tools []ToolDef) error {
+	owners := make(map[string]string) // command path → tool name
+	claim := func(path, tool string) error {
+		if prev, ok := owners[path]; ok && prev != tool {
+			return fmt.Errorf("command %q is used by both %q and %q", path, prev, tool)
+		}
+		owners[path] = tool
+		return nil
+	}
+
+	groups := make(map[string]bool)
+	for _, t := range tools {
+		if t.Group != "" {
+			groups[t.Group] = true
+		}
+	}
+
+	for _, t := range tools {
+		prefix := ""
+		if t.Group != "" {
+			prefix = t.Group + " "
+		}
+		for _, n := range append([]string{t.CommandName}, t.Aliases...) {
+			if t.Group == "" && (nameutil.ReservedCommands[n] || groups[n]) {
+				return fmt.Errorf("command %q for tool %q collides with a built-in or group command", n, t.Name)
+			}
+			if err := claim(prefix+n, t.Name); err != nil {
+				return err
+			}
+		}
+		// The MCP name is always reachable at the top level: as an alias of
+		// top-level commands, or as a hidden command for grouped ones.
+		if groups[t.Name] {
+			return fmt.Errorf("tool %q collides with group command %q", t.Name, t.Name)
+		}
+		if err := claim(t.Name, t.Name); err != nil {
+			return err
+		}
+	}
+
+	for g := range groups {
+		if nameutil.ReservedCommands[g] {
+			return fmt.Errorf("group %q collides with a built-in command", g)
+		}
+	}
+	return nil
 }
@@ -0,0 +1,305 @@
+package codegen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/thellimist/clihub/internal/schema"
+)
+
+// CommandPath returns the words that follow the CLI name to run the tool:
+// "issue create" for a grouped tool, "list-issues" otherwise.
+func (t ToolDef) CommandPath() string {
+	if t.Group != "" {
+		return t.Group + " " + t.CommandName
+	}
+	return t.CommandName
+}
+
+// Summary returns the first sentence of the tool's description.
+func (t ToolDef) Summary() string {
+	s, _, _ := strings.Cut(strings.TrimSpace(t.Description), "\n")
+	if i := strings.Index(s, ". "); i >= 0 {
+		s = s[:i+1]
+	}
+	return strings.TrimSpace(s)
+}
+
+// Example returns an invocation of the tool with every required parameter
+// set, positional ones as arguments. Values come from the schema's examples,
+// then its enum and default, falling back to a <flag-name> placeholder.
+func (t ToolDef) Example(cliName string) string {
+	parts := []string{cliName, t.CommandPath()}
+	positional := make(map[string]bool, len(t.Positional))
+	for _, p := range t.Positional {
+		positional[p] = true
+		for _, o := range t.Options {
+			if o.FlagName == p {
+				parts = append(parts, shellQuote(exampleValue(o)))
+			}
+		}
+	}
+	for _, o := range t.Options {
+		if !o.Required || positional[o.FlagName] {
+			continue
+		}
+		if o.GoType == "bool" {
+			parts = append(parts, "--"+o.FlagName)
+			continue
+		}
+		parts = append(parts, "--"+o.FlagName, shellQuote(exampleValue(o)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func exampleValue(o schema.ToolOption) string {
+	switch {
+	case len(o.Examples) > 0:
+		return o.Examples[0]
+	case len(o.EnumValues) > 0:
+		return o.EnumValues[0]
+	case o.DefaultValue != nil:
+		return schema.FlagValue(o.DefaultValue)
+	}
+	return "<" + o.FlagName + ">"
+}
+
+// shellQuote single-quotes s when a POSIX shell would otherwise split or
+// expand it. <placeholders> are left bare so they read as placeholders.
+func shellQuote(s string) string {
+	placeholder := strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">") && !strings.ContainsAny(s, " \t\n")
+	if placeholder || s != "" && !strings.ContainsAny(s, " \t\n'\"\\$`!*?;&|<>()[]{}#~") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// docGlobalFlags are the generated CLI's most used global flags, listed in
+// the reference docs. Keep in sync with the persistent flags in main.go.
+var docGlobalFlags = []struct{ flag, desc string }{
+	{"-o, --output <format>", "Output format: text, json, markdown or raw (default text)."},
+	{"-t, --timeout <ms>", "Per-call timeout in milliseconds (default 30000)."},
+	{"--retries <n>", "Retries for transient connection, 429 and 5xx failures (default 2)."},
+	{"--read-only", "Refuse to run tools that are not annotated read-only."},
+	{"-y, --yes", "Skip the confirmation prompt for destructive tools."},
+	{"--from-json <json|@file|->", "Pass tool parameters as one JSON or YAML object, inline, from a file or from stdin; flags override its keys."},
+}
+
+// usage returns the tool's command path with its positional arguments:
+// "issue get <issue-id>".
+func (t ToolDef) usage() string {
+	return strings.TrimSpace(t.Group + " " + toolUse(t))
+}
+
+// docPageName is the base name of a tool's reference page: "issue-create".
+func docPageName(t ToolDef) string {
+	return strings.ReplaceAll(t.CommandPath(), " ", "-")
+}
+
+// sortedTools returns the tools ordered by command path, as they appear in
+// --help.
+func sortedTools(tools []ToolDef) []ToolDef {
+	sorted := append([]ToolDef(nil), tools...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CommandPath() < sorted[j].CommandPath() })
+	return sorted
+}
+
+func (c GenerateContext) serverName() string {
+	if c.IsHTTP {
+		return c.ServerURL
+	}
+	return strings.TrimSpace(c.StdioCommand + " " + strings.Join(c.StdioArgs, " "))
+}
+
+// WriteDocs writes reference docs for the generated CLI into outputDir:
+// Markdown (an index.md plus one page per tool) under docs/, and roff man
+// pages (<cli>.1 plus <cli>-<command>.1 per tool) under man/. Returns the
+// written paths.
+func WriteDocs(ctx GenerateContext, outputDir string) ([]string, error) {
+	var paths []string
+	for sub, files := range map[string]map[string]string{
+		"docs": MarkdownDocs(ctx),
+		"man":  ManPages(ctx),
+	} {
+		dir := filepath.Join(outputDir, sub)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("create %s dir: %w", sub, err)
+		}
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				return nil, fmt.Errorf("write %s: %w", name, err)
+			}
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// MarkdownDocs renders the Markdown reference, keyed by file name.
+func MarkdownDocs(ctx GenerateContext) map[string]string {
+	files := make(map[string]string, len(ctx.Tools)+1)
+	tools := sortedTools(ctx.Tools)
+
+	var idx strings.Builder
+	fmt.Fprintf(&idx, "# %s\n\n", ctx.CLIName)
+	fmt.Fprintf(&idx, "`%s` %s is a command-line client for the MCP server `%s`, with one command per tool.\n", ctx.CLIName, ctx.Version, ctx.serverName())
+	fmt.Fprintf(&idx, "\n## Tools\n\n| Command | Description |\n|---------|-------------|\n")
+	for _, t := range tools {
+		fmt.Fprintf(&idx, "| [`%s`](%s.md) | %s |\n", t.CommandPath(), docPageName(t), mdCell(t.Summary()))
+	}
+	fmt.Fprintf(&idx, "\n## Global flags\n\n| Flag | Description |\n|------|-------------|\n")
+	for _, g := range docGlobalFlags {
+		fmt.Fprintf(&idx, "| `%s` | %s |\n", g.flag, g.desc)
+	}
+	if ctx.IsHTTP {
+		fmt.Fprintf(&idx, "\nRun `%s auth` to sign in, and `%s --help-auth` for the authentication flags.\n", ctx.CLIName, ctx.CLIName)
+	}
+	files["index.md"] = idx.String()
+
+	for _, t := range tools {
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s %s\n\n", ctx.CLIName, t.CommandPath())
+		if t.Description != "" {
+			fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(t.Description))
+		}
+		fmt.Fprintf(&b, "```\n%s %s [flags]\n```\n\n", ctx.CLIName, t.usage())
+		fmt.Fprintf(&b, "- MCP tool: `%s`\n", t.Name)
+		if len(t.Aliases) > 0 {
+			fmt.Fprintf(&b, "- Aliases: `%s`\n", strings.Join(t.Aliases, "`, `"))
+		}
+		if hints := t.Hints(); len(hints) > 0 {
+			fmt.Fprintf(&b, "- Hints: %s\n", strings.Join(hints, ", "))
+		}
+		if len(t.Options) > 0 {
+			b.WriteString("\n## Flags\n\n| Flag | Type | Required | Default | Description |\n|------|------|----------|---------|-------------|\n")
+			for _, o := range t.Options {
+				required := ""
+				if o.Required {
+					required = "yes"
+				}
+				def := ""
+				if o.DefaultValue != nil {
+					def = "`" + schema.FlagValue(o.DefaultValue) + "`"
+				}
+				fmt.Fprintf(&b, "| `--%s` | %s | %s | %s | %s |\n", o.FlagName, o.GoType, required, def, mdCell(optionDescription(o)))
+			}
+			b.WriteString("\nAll parameters can also be passed as one JSON or YAML object with `--from-json` (inline, `@file` or `-` for stdin); flags given alongside override its keys.\n")
+		}
+		fmt.Fprintf(&b, "\n## Example\n\n```sh\n%s\n```\n", t.Example(ctx.CLIName))
+		fmt.Fprintf(&b, "\nSee [all commands](index.md).\n")
+		files[docPageName(t)+".md"] = b.String()
+	}
+	return files
+}
+
+// optionDescription is a flag's description with its allowed values and
+// schema examples appended.
+func optionDescription(o schema.ToolOption) string {
+	desc := strings.TrimSpace(o.Description)
+	add := func(s string) {
+		if desc != "" && !strings.HasSuffix(desc, ".") {
+			desc += "."
+		}
+		desc = strings.TrimSpace(desc + " " + s)
+	}
+	if len(o.EnumValues) > 0 {
+		add("One of: " + strings.Join(o.EnumValues, ", ") + ".")
+	}
+	if len(o.Examples) > 0 {
+		add("Example: " + o.Examples[0] + ".")
+	}
+	return desc
+}
+
+func mdCell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// ManPages renders section 1 man pages, keyed by file name.
+func ManPages(ctx GenerateContext) map[string]string {
+	files := make(map[string]string, len(ctx.Tools)+1)
+	tools := sortedTools(ctx.Tools)
+	title := strings.ToUpper(ctx.CLIName)
+	header := func(b *strings.Builder, name string) {
+		fmt.Fprintf(b, ".TH \"%s\" 1 \"\" \"%s %s\" \"User Commands\"\n", roff(strings.ToUpper(name)), roff(ctx.CLIName), ctx.Version)
+	}
+
+	var idx strings.Builder
+	header(&idx, title)
+	fmt.Fprintf(&idx, ".SH NAME\n%s \\- command-line client for an MCP server\n", roff(ctx.CLIName))
+	fmt.Fprintf(&idx, ".SH SYNOPSIS\n.B %s\n.I command\n[flags]\n", roff(ctx.CLIName))
+	fmt.Fprintf(&idx, ".SH DESCRIPTION\n%s runs the tools of the MCP server\n.BR %s ,\none command per tool.\n", roff(ctx.CLIName), roff(ctx.serverName()))
+	idx.WriteString(".SH COMMANDS\n")
+	for _, t := range tools {
+		fmt.Fprintf(&idx, ".TP\n.B %s\n%s\n", roff(t.CommandPath()), roff(t.Summary()))
+	}
+	idx.WriteString(".SH GLOBAL FLAGS\n")
+	for _, g := range docGlobalFlags {
+		fmt.Fprintf(&idx, ".TP\n.B %s\n%s\n", roff(g.flag), roff(g.desc))
+	}
+	idx.WriteString(".SH SEE ALSO\n")
+	for i, t := range tools {
+		sep := ","
+		if i == len(tools)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(&idx, ".BR %s (1)%s\n", roff(ctx.CLIName+"-"+docPageName(t)), sep)
+	}
+	files[ctx.CLIName+".1"] = idx.String()
+
+	for _, t := range tools {
+		page := ctx.CLIName + "-" + docPageName(t)
+		var b strings.Builder
+		header(&b, page)
+		fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", roff(page), roff(t.Summary()))
+		fmt.Fprintf(&b, ".SH SYNOPSIS\n.B %s %s\n", roff(ctx.CLIName), roff(t.CommandPath()))
+		for _, p := range t.Positional {
+			fmt.Fprintf(&b, ".I %s\n", roff(p))
+		}
+		b.WriteString("[flags]\n")
+		fmt.Fprintf(&b, ".SH DESCRIPTION\n%s\n", roff(strings.TrimSpace(t.Description)))
+		fmt.Fprintf(&b, ".PP\nMCP tool: %s.", roff(t.Name))
+		if hints := t.Hints(); len(hints) > 0 {
+			fmt.Fprintf(&b, " Hints: %s.", roff(strings.Join(hints, ", ")))
+		}
+		b.WriteString("\n")
+		if len(t.Options) > 0 {
+			b.WriteString(".SH OPTIONS\n")
+			for _, o := range t.Options {
+				fmt.Fprintf(&b, ".TP\n.BI \"%s \" %s\n", roff("--"+o.FlagName), o.GoType)
+				desc := optionDescription(o)
+				if o.Required {
+					desc = strings.TrimSpace("Required. " + desc)
+				}
+				if o.DefaultValue != nil {
+					desc += " Default: " + schema.FlagValue(o.DefaultValue) + "."
+				}
+				fmt.Fprintf(&b, "%s\n", roff(desc))
+			}
+		}
+		fmt.Fprintf(&b, ".SH EXAMPLE\n.nf\n%s\n.fi\n", roff(t.Example(ctx.CLIName)))
+		fmt.Fprintf(&b, ".SH SEE ALSO\n.BR %s (1)\n", roff(ctx.CLIName))
+		files[page+".1"] = b.String()
+	}
+	return files
+}
+
+// roff escapes text for a man page line: backslashes and hyphens are
+// escaped, and lines that would start a request are guarded with \&.
+func roff(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'") {
+			lines[i] = `\&` + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -2,15 +2,23 @@ package codegen
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+
+	clihubruntime "github.com/thellimist/clihub/runtime"
 )
 
 // Generate creates a Go project (main.go + go.mod) in the given output directory.
+// HTTP projects and those with self-update also get a copy of clihub's
+// runtime packages under third_party.
 // If outputDir is empty, a temporary directory is created and its path returned.
+// env, such as the entries from compile.CacheEnv, is added to the
+// environment of go mod tidy.
 // Returns the project directory path.
-func Generate(ctx GenerateContext, outputDir string) (string, error) {
+func Generate(ctx GenerateContext, outputDir string, env []string) (string, error) {
 	if outputDir == "" {
 		dir, err := os.MkdirTemp("", "clihub-*")
 		if err != nil {
@@ -19,6 +27,10 @@ func Generate(ctx GenerateContext, outputDir string) (string, error) {
 		outputDir = dir
 	}
 
+	if ctx.Version == "" {
+		ctx.Version = "1.0.0"
+	}
+
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return "", fmt.Errorf("create output dir: %w", err)
 	}
@@ -47,12 +59,45 @@ func Generate(ctx GenerateContext, outputDir string) (string, error) {
 		return outputDir, fmt.Errorf("render go.mod template: %w", err)
 	}
 
+	if ctx.UsesRuntime() {
+		if err := writeRuntime(filepath.Join(outputDir, "third_party", "clihub")); err != nil {
+			return outputDir, fmt.Errorf("copy clihub runtime: %w", err)
+		}
+	}
+
 	// Run go mod tidy to download dependencies and generate go.sum
 	tidyCmd := exec.Command("go", "mod", "tidy")
 	tidyCmd.Dir = outputDir
+	tidyCmd.Env = append(os.Environ(), env...)
 	if out, err := tidyCmd.CombinedOutput(); err != nil {
 		return outputDir, fmt.Errorf("go mod tidy failed: %s\n%s", err, string(out))
 	}
 
 	return outputDir, nil
 }
+
+// writeRuntime copies the runtime packages, without their tests, into dir
+// as the module the generated go.mod replaces github.com/thellimist/clihub
+// with.
+func writeRuntime(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(runtimeGoMod), 0644); err != nil {
+		return err
+	}
+	return fs.WalkDir(clihubruntime.Source, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		data, err := fs.ReadFile(clihubruntime.Source, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, "runtime", filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0644)
+	})
+}
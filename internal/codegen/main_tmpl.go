@@ -4,50 +4,73 @@ const mainTemplateSource = `// Code generated by clihub v{{.ClihubVersion}}. DO
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
-{{- if .IsHTTP}}
-	"crypto/rand"
-	"crypto/sha256"
-{{- end}}
-	"encoding/base64"
-{{- if .IsHTTP}}
-	"encoding/hex"
-{{- end}}
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-{{- if .IsHTTP}}
-	"net"
-{{- end}}
+	mathrand "math/rand/v2"
 	"net/http"
-	"net/url"
 	"os"
-{{- if .IsHTTP}}
-	"os/exec"
-{{- end}}
-	"path/filepath"
-{{- if .IsHTTP}}
-	"runtime"
-{{- end}}
+	"strconv"
 	"strings"
-{{- if .IsHTTP}}
 	"sync"
-{{- end}}
 	"time"
 
 	mcpclient "github.com/mark3labs/mcp-go/client"
-{{- if .IsHTTP}}
 	"github.com/mark3labs/mcp-go/client/transport"
-{{- end}}
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/spf13/cobra"
-	"golang.org/x/oauth2/google"
+{{- if .IsHTTP}}
+	"github.com/thellimist/clihub/runtime/auth"
+{{- end}}
+{{- if .Update.URL}}
+	"github.com/thellimist/clihub/runtime/update"
+{{- end}}
+	"gopkg.in/yaml.v3"
 )
 
 // --- Embedded server configuration ---
+const cliVersion = {{quote .Version}}
+const userAgent = {{quote (printf "%s/%s clihub/%s" .CLIName .Version .ClihubVersion)}}
 {{- if .IsHTTP}}
 const serverURL = {{quote .ServerURL}}
+
+// embeddedCABundle is trusted in addition to the system roots.
+const embeddedCABundle = {{quote .CABundle}}
+
+// defaultHeaders are sent with every MCP request; --header overrides them.
+var defaultHeaders = map[string]string{
+{{- range $key, $value := .Headers}}
+	{{quote $key}}: {{quote $value}},
+{{- end}}
+}
+
+// authConfig is the server's auth setup as detected by clihub; it holds no
+// secrets. An empty Type means unknown.
+type authConfig struct {
+	Type, HeaderName, Scope, ClientID                   string
+	ResourceMetadataURL, AuthServerURL, TokenEndpoint  string
+	SubjectTokenFile, SubjectTokenEnv, SubjectTokenType string
+	Audience, Command                                   string
+}
+
+var embeddedAuth = authConfig{
+	Type:                {{quote .Auth.Type}},
+	HeaderName:          {{quote .Auth.HeaderName}},
+	Scope:               {{quote .Auth.Scope}},
+	ClientID:            {{quote .Auth.ClientID}},
+	ResourceMetadataURL: {{quote .Auth.ResourceMetadataURL}},
+	AuthServerURL:       {{quote .Auth.AuthServerURL}},
+	TokenEndpoint:       {{quote .Auth.TokenEndpoint}},
+	SubjectTokenFile:    {{quote .Auth.SubjectTokenFile}},
+	SubjectTokenEnv:     {{quote .Auth.SubjectTokenEnv}},
+	SubjectTokenType:    {{quote .Auth.SubjectTokenType}},
+	Audience:            {{quote .Auth.Audience}},
+	Command:             {{quote .Auth.Command}},
+}
 {{- else}}
 var stdioCommand = {{quote .StdioCommand}}
 var stdioArgs = {{quoteSlice .StdioArgs}}
@@ -63,13 +86,31 @@ var (
 	globalAuthHeaderName string
 	globalAuthUsername   string
 	globalAuthPassword   string
+	globalAuthExec       string
+	globalTokenEndpoint  string
+	globalSubjectFile    string
+	globalSubjectEnv     string
+	globalSubjectType    string
+	globalAudience       string
 	globalHelpAuth       bool
+	globalRetries        int
+	globalRetryBackoff   int
+	globalRetryUnsafe    bool
+	globalReadOnly       bool
+	globalYes            bool
+	globalProfile        string
+	globalClientCert     string
+	globalClientKey      string
+	globalCAFile         string
+	globalHeaders        []string
+	globalProxy          string
 )
 
 func main() {
 	rootCmd := &cobra.Command{
 		Use:   {{quote .CLIName}},
 		Short: {{quote (printf "CLI for %s MCP server" .CLIName)}},
+		Version: cliVersion,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 	}
@@ -82,14 +123,50 @@ func main() {
 	rootCmd.PersistentFlags().StringVar(&globalAuthUsername, "auth-username", "", "username for basic auth")
 	rootCmd.PersistentFlags().StringVar(&globalAuthPassword, "auth-password", "", "password for basic auth")
 	rootCmd.PersistentFlags().BoolVar(&globalHelpAuth, "help-auth", false, "show authentication flags and exit")
+	rootCmd.PersistentFlags().IntVar(&globalRetries, "retries", 2, "retries for transient connection, handshake, 429 and 5xx failures")
+	rootCmd.PersistentFlags().IntVar(&globalRetryBackoff, "retry-backoff", 500, "base retry backoff in milliseconds (exponential with jitter)")
+	rootCmd.PersistentFlags().BoolVar(&globalRetryUnsafe, "retry-unsafe", false, "also retry failed tool calls for tools not annotated idempotent")
+	rootCmd.PersistentFlags().BoolVar(&globalReadOnly, "read-only", envBool("CLIHUB_READ_ONLY"), "refuse to run tools that are not annotated read-only (or set CLIHUB_READ_ONLY)")
+	rootCmd.PersistentFlags().BoolVarP(&globalYes, "yes", "y", false, "skip the confirmation prompt for destructive tools")
+{{- if .IsHTTP}}
+	rootCmd.PersistentFlags().StringVar(&globalAuthExec, "auth-exec", os.Getenv("CLIHUB_AUTH_EXEC"), "credential helper command that prints {\"token\", \"expires_at\"} JSON (or set CLIHUB_AUTH_EXEC)")
+	rootCmd.PersistentFlags().StringVar(&globalTokenEndpoint, "token-endpoint", "", "token endpoint for --auth-type token_exchange")
+	rootCmd.PersistentFlags().StringVar(&globalSubjectFile, "subject-token-file", "", "file holding the subject token to exchange (workload identity)")
+	rootCmd.PersistentFlags().StringVar(&globalSubjectEnv, "subject-token-env", "", "environment variable holding the subject token to exchange")
+	rootCmd.PersistentFlags().StringVar(&globalSubjectType, "subject-token-type", "", "subject token type URN (default urn:ietf:params:oauth:token-type:jwt)")
+	rootCmd.PersistentFlags().StringVar(&globalAudience, "audience", "", "audience to request in the token exchange")
+	rootCmd.PersistentFlags().StringVar(&globalClientCert, "client-cert", os.Getenv("CLIHUB_CLIENT_CERT"), "PEM client certificate for mTLS (or set CLIHUB_CLIENT_CERT)")
+	rootCmd.PersistentFlags().StringVar(&globalClientKey, "client-key", os.Getenv("CLIHUB_CLIENT_KEY"), "PEM private key for --client-cert (or set CLIHUB_CLIENT_KEY)")
+	rootCmd.PersistentFlags().StringVar(&globalCAFile, "ca-file", os.Getenv("CLIHUB_CA_FILE"), "PEM CA bundle to trust in addition to system roots (or set CLIHUB_CA_FILE)")
+	rootCmd.PersistentFlags().StringArrayVar(&globalHeaders, "header", nil, "extra HTTP header for MCP requests (KEY=VALUE, repeatable; overrides built-in defaults)")
+	rootCmd.PersistentFlags().StringVar(&globalProxy, "proxy", "", "HTTP(S) proxy URL, may include user:password (default: $HTTPS_PROXY/$HTTP_PROXY, honouring $NO_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&globalProfile, "profile", "", "credential profile (default: $CLIHUB_PROFILE or the one chosen with ` + "`" + `auth use` + "`" + `)")
+{{- end}}
 	hideAuthFlags(rootCmd)
+{{- if .IsHTTP}}
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		return configureTransport()
+	}
+{{- end}}
 
+{{- range .Groups}}
+	{{groupVar .}} := &cobra.Command{Use: {{quote .}}, Short: {{quote (printf "%s commands" .)}}}
+	rootCmd.AddCommand({{groupVar .}})
+{{- end}}
 {{- range .Tools}}
+{{- if .Group}}
+	{{groupVar .Group}}.AddCommand(cmd{{funcName .Name}}())
+	rootCmd.AddCommand(hiddenToolCommand(cmd{{funcName .Name}}(), {{quote .Name}}))
+{{- else}}
 	rootCmd.AddCommand(cmd{{funcName .Name}}())
 {{- end}}
+{{- end}}
 {{- if .IsHTTP}}
 	rootCmd.AddCommand(cmdAuth())
 {{- end}}
+{{- if .Update.URL}}
+	rootCmd.AddCommand(cmdSelfUpdate())
+{{- end}}
 
 	if wantsAuthHelp(os.Args[1:]) {
 		printAuthFlagHelp(os.Stdout)
@@ -118,6 +195,12 @@ func hideAuthFlags(rootCmd *cobra.Command) {
 		"auth-header-name",
 		"auth-username",
 		"auth-password",
+		"auth-exec",
+		"token-endpoint",
+		"subject-token-file",
+		"subject-token-env",
+		"subject-token-type",
+		"audience",
 	} {
 		_ = rootCmd.PersistentFlags().MarkHidden(name)
 	}
@@ -126,10 +209,46 @@ func hideAuthFlags(rootCmd *cobra.Command) {
 func printAuthFlagHelp(out io.Writer) {
 	fmt.Fprintln(out, "Authentication flags:")
 	fmt.Fprintln(out, "  --auth-token string         bearer token for authenticated MCP servers")
+{{- if .IsHTTP}}
+	fmt.Fprintln(out, "  --auth-type string          authentication type: bearer, api_key, basic, token_exchange, exec, none")
+{{- else}}
 	fmt.Fprintln(out, "  --auth-type string          authentication type: bearer, api_key, basic, none")
+{{- end}}
 	fmt.Fprintln(out, "  --auth-header-name string   custom header name for api_key auth (default X-API-Key)")
 	fmt.Fprintln(out, "  --auth-username string      username for basic auth")
 	fmt.Fprintln(out, "  --auth-password string      password for basic auth")
+{{- if .IsHTTP}}
+	fmt.Fprintln(out, "  --auth-exec string          credential helper command that prints {\"token\", \"expires_at\"} JSON (or set CLIHUB_AUTH_EXEC)")
+	fmt.Fprintln(out, "  --token-endpoint string     token endpoint for --auth-type token_exchange")
+	fmt.Fprintln(out, "  --subject-token-file string file holding the subject token to exchange (workload identity)")
+	fmt.Fprintln(out, "  --subject-token-env string  environment variable holding the subject token to exchange")
+	fmt.Fprintln(out, "  --subject-token-type string subject token type URN (default urn:ietf:params:oauth:token-type:jwt)")
+	fmt.Fprintln(out, "  --audience string           audience to request in the token exchange")
+{{- end}}
+}
+
+// hiddenToolCommand keeps a grouped tool reachable at the top level under its
+// original MCP name without listing it twice in help output.
+func hiddenToolCommand(cmd *cobra.Command, name string) *cobra.Command {
+	cmd.Use = name + strings.TrimPrefix(cmd.Use, cmd.Name())
+	cmd.Aliases = nil
+	cmd.Hidden = true
+	return cmd
+}
+
+// setPositionalArgs copies positional arguments into the flags they stand
+// for, so they are parsed and validated exactly like --flag values.
+func setPositionalArgs(cmd *cobra.Command, flags, args []string) error {
+	for i, arg := range args {
+		name := flags[i]
+		if cmd.Flags().Changed(name) {
+			return fmt.Errorf("<%s> given both as an argument and as --%s", name, name)
+		}
+		if err := cmd.Flags().Set(name, arg); err != nil {
+			return fmt.Errorf("invalid argument %q for <%s>: %w", arg, name, err)
+		}
+	}
+	return nil
 }
 
 func chooseFromJSONFlagName(cmd *cobra.Command) string {
@@ -141,6 +260,69 @@ func chooseFromJSONFlagName(cmd *cobra.Command) string {
 	return "clihub-from-json"
 }
 
+// readToolInput parses a --from-json value: a JSON or YAML object given
+// inline, read from a file with @path, or read from stdin with -.
+func readToolInput(value string) (map[string]interface{}, error) {
+	data := []byte(value)
+	switch {
+	case value == "-":
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("read stdin: %w", err)
+		}
+		data = b
+	case strings.HasPrefix(value, "@"):
+		b, err := os.ReadFile(value[1:])
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	}
+
+	// Input that looks like a JSON object is tried as JSON first, for its
+	// clearer errors, then as a YAML flow mapping such as {a: 1}; anything
+	// else is read as YAML.
+	var doc interface{}
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		if jsonErr := json.Unmarshal(trimmed, &doc); jsonErr != nil {
+			doc = nil
+			if err := yaml.Unmarshal(data, &doc); err != nil {
+				return nil, fmt.Errorf("JSON: %w", jsonErr)
+			}
+		}
+	} else if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("YAML: %w", err)
+	}
+	params, ok := yamlToJSON(doc).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an object of tool parameters")
+	}
+	return params, nil
+}
+
+// yamlToJSON converts YAML maps with non-string keys into JSON objects.
+func yamlToJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = yamlToJSON(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[fmt.Sprint(k)] = yamlToJSON(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = yamlToJSON(e)
+		}
+		return v
+	}
+	return v
+}
+
 // --- Tool commands ---
 {{range .Tools}}
 func cmd{{funcName .Name}}() *cobra.Command {
@@ -151,55 +333,46 @@ func cmd{{funcName .Name}}() *cobra.Command {
 	fromJSONFlagName := "from-json"
 
 	cmd := &cobra.Command{
-		Use:     {{quote .CommandName}},
-		Aliases: []string{ {{quote .Name}} },
+		Use:     {{quote (toolUse .)}},
+		Aliases: {{quoteSlice (aliases .)}},
 		Short:   {{quote .Description}},
+		Long:    {{quote (toolLong .)}},
+{{- if .Positional}}
+		Args:    cobra.MaximumNArgs({{len .Positional}}),
+{{- end}}
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			params := make(map[string]interface{})
-			if flagFromJSON != "" {
-				var conflictingFlag string
-{{- range .Options}}
-				if conflictingFlag == "" && cmd.Flags().Changed({{quote .FlagName}}) {
-					conflictingFlag = {{quote .FlagName}}
-				}
+{{- if .Positional}}
+			if err := setPositionalArgs(cmd, {{quoteSlice .Positional}}, args); err != nil {
+				return err
+			}
 {{- end}}
-				if conflictingFlag != "" {
-					return fmt.Errorf("--%s cannot be combined with --%s", fromJSONFlagName, conflictingFlag)
-				}
-				if err := json.Unmarshal([]byte(flagFromJSON), &params); err != nil {
-					return fmt.Errorf("invalid --%s JSON: %w", fromJSONFlagName, err)
+			params := make(map[string]interface{})
+			fromJSON := flagFromJSON != ""
+			if fromJSON {
+				input, err := readToolInput(flagFromJSON)
+				if err != nil {
+					return fmt.Errorf("invalid --%s: %w", fromJSONFlagName, err)
 				}
-			} else {
+				params = input
+			}
+			// Typed flags set on the command line override --from-json keys.
 {{- range .Options}}
 {{- if eq .GoType "string"}}
-					if {{varName .FlagName}} != "" {
-					params[{{quote .PropertyName}}] = {{varName .FlagName}}
-				}
-{{- else if eq .GoType "int"}}
-				if cmd.Flags().Changed({{quote .FlagName}}) {
-					params[{{quote .PropertyName}}] = {{varName .FlagName}}
-				}
-{{- else if eq .GoType "float64"}}
-				if cmd.Flags().Changed({{quote .FlagName}}) {
-					params[{{quote .PropertyName}}] = {{varName .FlagName}}
-				}
-{{- else if eq .GoType "bool"}}
-				if cmd.Flags().Changed({{quote .FlagName}}) {
-					params[{{quote .PropertyName}}] = {{varName .FlagName}}
-				}
-{{- else if eq .GoType "[]string"}}
-				if len({{varName .FlagName}}) > 0 {
-					params[{{quote .PropertyName}}] = {{varName .FlagName}}
-				}
-{{- else if eq .GoType "[]int"}}
-				if len({{varName .FlagName}}) > 0 {
-					params[{{quote .PropertyName}}] = {{varName .FlagName}}
-				}
+			if fromJSON && cmd.Flags().Changed({{quote .FlagName}}) || !fromJSON && {{varName .FlagName}} != "" {
+				params[{{quote .PropertyName}}] = {{varName .FlagName}}
+			}
+{{- else if or (eq .GoType "[]string") (eq .GoType "[]int")}}
+			if fromJSON && cmd.Flags().Changed({{quote .FlagName}}) || !fromJSON && len({{varName .FlagName}}) > 0 {
+				params[{{quote .PropertyName}}] = {{varName .FlagName}}
+			}
+{{- else}}
+			if cmd.Flags().Changed({{quote .FlagName}}) {
+				params[{{quote .PropertyName}}] = {{varName .FlagName}}
+			}
 {{- end}}
 {{- end}}
-			}
 {{range .Options}}{{if .EnumValues}}
 			// Validate enum for {{.FlagName}}
 			if v, ok := params[{{quote .PropertyName}}]; ok {
@@ -218,7 +391,10 @@ func cmd{{funcName .Name}}() *cobra.Command {
 				}
 			}
 {{end}}{{end}}
-			return callTool({{quote .Name}}, params)
+			if err := checkToolSafety({{quote .CommandName}}, {{.ReadOnly}}, {{.Destructive}}); err != nil {
+				return err
+			}
+			return callTool({{quote .Name}}, params, {{.Idempotent}})
 		},
 	}
 
@@ -226,24 +402,111 @@ func cmd{{funcName .Name}}() *cobra.Command {
 	cmd.Flags().{{cobraFlag .GoType}}(&{{varName .FlagName}}, {{quote .FlagName}}, {{defaultLit .GoType .DefaultValue}}, {{quote (hasEnumDesc .Description .EnumValues)}})
 {{- end}}
 	fromJSONFlagName = chooseFromJSONFlagName(cmd)
-	cmd.Flags().StringVar(&flagFromJSON, fromJSONFlagName, "", "tool input as JSON (bypasses typed flags)")
+	cmd.Flags().StringVar(&flagFromJSON, fromJSONFlagName, "", "tool input as a JSON or YAML object, @file to read a file, or - for stdin; typed flags override its keys")
 
 	return cmd
 }
 {{end}}
 
+// --- Tool safety ---
+
+// checkToolSafety enforces --read-only and asks for confirmation before
+// running a tool the server annotated as destructive.
+func checkToolSafety(command string, readOnly, destructive bool) error {
+	if globalReadOnly && !readOnly {
+		return fmt.Errorf("%s is not annotated read-only and --read-only is set", command)
+	}
+	if !destructive || globalYes || !stdinIsTerminal() {
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "%s is marked destructive by the server. Continue? [y/N] ", command)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return fmt.Errorf("aborted (pass --yes to skip confirmation)")
+	}
+}
+
+func stdinIsTerminal() bool {
+	fi, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
 // --- MCP client via mcp-go SDK ---
 
-func callTool(toolName string, params map[string]interface{}) error {
+func callTool(toolName string, params map[string]interface{}, idempotent bool) error {
+{{- if .IsHTTP}}
 	provider := resolveAuthProvider()
+{{- end}}
+
+	var result *mcp.CallToolResult
+{{- if .IsHTTP}}
+	steppedUp := false
+{{- end}}
+	for attempt := 0; ; attempt++ {
+		var attemptErr *callAttemptError
+		result, attemptErr = callToolOnce({{if .IsHTTP}}provider, {{end}}toolName, params)
+		if attemptErr == nil {
+			break
+		}
+{{- if .IsHTTP}}
+		var scopeErr *auth.InsufficientScopeError
+		if p, ok := provider.(*auth.OAuth2Provider); ok && !steppedUp && errors.As(attemptErr.err, &scopeErr) {
+			// Sign in again outside this attempt's --timeout, then try
+			// again with a fresh one; the step-up is not a retry.
+			stepped, err := p.StepUp(context.Background(), scopeErr.Scope, confirmScopeStepUp)
+			if err != nil {
+				return fmt.Errorf("server requires scope %q: %w", scopeErr.Scope, err)
+			}
+			if stepped {
+				steppedUp = true
+				attempt--
+				continue
+			}
+		}
+{{- end}}
+		retry, wait := attemptErr.retryable(idempotent || globalRetryUnsafe)
+		if !retry || attempt >= globalRetries {
+			return attemptErr.err
+		}
+		delay := retryDelay(attempt, wait)
+		fmt.Fprintf(os.Stderr, "Warning: %s; retrying in %s (%d/%d)\n", attemptErr.err, delay.Round(time.Millisecond), attempt+1, globalRetries)
+		time.Sleep(delay)
+	}
+
+	if result.IsError {
+		// Extract error text from content
+		var errTexts []string
+		for _, content := range result.Content {
+			if tc, ok := content.(mcp.TextContent); ok {
+				errTexts = append(errTexts, tc.Text)
+			}
+		}
+		if len(errTexts) > 0 {
+			return fmt.Errorf("tool error: %s", strings.Join(errTexts, "\n"))
+		}
+		return fmt.Errorf("tool returned an error")
+	}
+
+	return formatOutput(result, globalOutput)
+}
 
+// callToolOnce runs a single connect, handshake and tools/call attempt.
+func callToolOnce({{if .IsHTTP}}provider auth.AuthProvider, {{end}}toolName string, params map[string]interface{}) (*mcp.CallToolResult, *callAttemptError) {
 	timeout := time.Duration(globalTimeout) * time.Millisecond
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	c, err := createClient(ctx, provider)
+	rec := &responseRecorder{}
+	c, err := createClient({{if .IsHTTP}}provider, {{end}}rec)
 	if err != nil {
-		return err
+		// A missing variable or a bad option fails the same way every time.
+		return nil, &callAttemptError{err: err, phase: "connect", permanent: true}
 	}
 	defer c.Close()
 
@@ -251,32 +514,32 @@ func callTool(toolName string, params map[string]interface{}) error {
 	// Start HTTP transport
 	if err := c.Start(ctx); err != nil {
 		if ctx.Err() != nil {
-			return fmt.Errorf("tool call timed out after %dms", globalTimeout)
+			return nil, &callAttemptError{err: fmt.Errorf("tool call timed out after %dms", globalTimeout), timedOut: true}
 		}
-		return fmt.Errorf("MCP connection failed: %w", err)
+		return nil, rec.attemptError("connect", fmt.Errorf("MCP connection failed: %w", err))
 	}
 {{- end}}
 
 	// Initialize handshake
 	initReq := mcp.InitializeRequest{}
 	initReq.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-	initReq.Params.ClientInfo = mcp.Implementation{Name: {{quote .CLIName}}, Version: "1.0.0"}
+	initReq.Params.ClientInfo = mcp.Implementation{Name: {{quote .CLIName}}, Version: cliVersion}
 	initReq.Params.Capabilities = mcp.ClientCapabilities{}
 
 	if _, err := c.Initialize(ctx, initReq); err != nil {
 		if ctx.Err() != nil {
-			return fmt.Errorf("tool call timed out after %dms", globalTimeout)
+			return nil, &callAttemptError{err: fmt.Errorf("tool call timed out after %dms", globalTimeout), timedOut: true}
 		}
 {{- if not .IsHTTP}}
 		// Capture stderr from crashed subprocess
 		if r, ok := mcpclient.GetStderr(c); ok && r != nil {
 			buf := make([]byte, 2048)
 			if n, _ := r.Read(buf); n > 0 {
-				return fmt.Errorf("MCP server crashed:\n  %s", strings.ReplaceAll(strings.TrimSpace(string(buf[:n])), "\n", "\n  "))
+				return nil, &callAttemptError{err: fmt.Errorf("MCP server crashed:\n  %s", strings.ReplaceAll(strings.TrimSpace(string(buf[:n])), "\n", "\n  ")), phase: "handshake"}
 			}
 		}
 {{- end}}
-		return fmt.Errorf("MCP handshake failed: %w", err)
+		return nil, rec.attemptError("handshake", fmt.Errorf("MCP handshake failed: %w", err))
 	}
 
 	// Call tool
@@ -287,38 +550,45 @@ func callTool(toolName string, params map[string]interface{}) error {
 	result, err := c.CallTool(ctx, callReq)
 	if err != nil {
 		if ctx.Err() != nil {
-			return fmt.Errorf("tool call timed out after %dms", globalTimeout)
-		}
-		return fmt.Errorf("tool call failed: %w", err)
-	}
-	if result.IsError {
-		// Extract error text from content
-		var errTexts []string
-		for _, content := range result.Content {
-			if tc, ok := content.(mcp.TextContent); ok {
-				errTexts = append(errTexts, tc.Text)
-			}
-		}
-		if len(errTexts) > 0 {
-			return fmt.Errorf("tool error: %s", strings.Join(errTexts, "\n"))
+			return nil, &callAttemptError{err: fmt.Errorf("tool call timed out after %dms", globalTimeout), timedOut: true}
 		}
-		return fmt.Errorf("tool returned an error")
+		return nil, rec.attemptError("call", fmt.Errorf("tool call failed: %w", err))
 	}
-
-	return formatOutput(result, globalOutput)
+	return result, nil
 }
-
-func createClient(ctx context.Context, provider authProvider) (*mcpclient.Client, error) {
 {{- if .IsHTTP}}
-	var opts []transport.StreamableHTTPCOption
-	headers := provider.getHeaders(ctx)
-	if len(headers) > 0 {
+
+func createClient(provider auth.AuthProvider, rec *responseRecorder) (*mcpclient.Client, error) {
+	var rt http.RoundTripper = rec
+	if _, ok := provider.(*auth.OAuth2Provider); ok {
+		// callTool steps up when the server asks for a broader scope.
+		rt = &auth.ScopeStepUpTransport{Base: rec}
+	}
+	opts := []transport.StreamableHTTPCOption{
+		transport.WithHTTPBasicClient(&http.Client{Transport: rt}),
+	}
+	extra := requestHeaders()
+	if _, noAuth := provider.(*auth.NoAuthProvider); !noAuth || len(extra) > 0 {
 		opts = append(opts, transport.WithHTTPHeaderFunc(func(ctx context.Context) map[string]string {
-			return provider.getHeaders(ctx)
+			headers := make(map[string]string, len(extra))
+			for k, v := range extra {
+				headers[k] = v
+			}
+			authHeaders, err := provider.GetHeaders(ctx)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+			}
+			for k, v := range authHeaders {
+				headers[k] = v
+			}
+			return headers
 		}))
 	}
 	return mcpclient.NewStreamableHttpClient(serverURL, opts...)
+}
 {{- else}}
+
+func createClient(rec *responseRecorder) (*mcpclient.Client, error) {
 	// Build environment for subprocess
 	env := os.Environ()
 	for _, key := range envKeys {
@@ -328,7 +598,165 @@ func createClient(ctx context.Context, provider authProvider) (*mcpclient.Client
 		}
 	}
 	return mcpclient.NewStdioMCPClient(stdioCommand, env, stdioArgs...)
+}
+{{- end}}
+
+// --- Retries ---
+
+// responseRecorder is an http.RoundTripper that remembers the status and
+// Retry-After header of the last response, so failed attempts can be
+// classified after mcp-go has turned them into plain errors.
+type responseRecorder struct {
+	mu         sync.Mutex
+	status     int
+	retryAfter time.Duration
+}
+
+func (r *responseRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.record(0, "")
+	resp, err := httpTransport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	r.record(resp.StatusCode, resp.Header.Get("Retry-After"))
+	return resp, nil
+}
+
+// httpTransport is used for MCP requests and, through the auth package,
+// OAuth requests; configureTransport adds --proxy, TLS settings and the
+// User-Agent.
+var httpTransport http.RoundTripper = http.DefaultTransport
+{{- if .IsHTTP}}
+
+// requestHeaders merges the built-in default headers with --header.
+func requestHeaders() map[string]string {
+	headers := make(map[string]string, len(defaultHeaders)+len(globalHeaders))
+	for k, v := range defaultHeaders {
+		headers[k] = v
+	}
+	extra, _ := auth.ParseHeaders(globalHeaders)
+	for k, v := range extra {
+		headers[k] = v
+	}
+	return headers
+}
+
+// configureTransport validates --header and sets up httpTransport with
+// --proxy, the TLS settings and the User-Agent.
+func configureTransport() error {
+	if _, err := auth.ParseHeaders(globalHeaders); err != nil {
+		return err
+	}
+	tlsOpts, err := tlsOptions()
+	if err != nil {
+		return err
+	}
+	t, err := auth.TransportOptions{TLS: tlsOpts, Proxy: globalProxy, UserAgent: userAgent}.RoundTripper()
+	if err != nil {
+		return err
+	}
+	httpTransport = t
+	auth.Transport = httpTransport
+	return nil
+}
+
+// tlsOptions applies --client-cert/--client-key, --ca-file and the
+// embedded CA bundle.
+func tlsOptions() (auth.TLSOptions, error) {
+	if (globalClientCert == "") != (globalClientKey == "") {
+		return auth.TLSOptions{}, fmt.Errorf("--client-cert and --client-key must be used together")
+	}
+	return auth.TLSOptions{
+		ClientCert: globalClientCert,
+		ClientKey:  globalClientKey,
+		CAFile:     globalCAFile,
+		CAPEM:      []byte(embeddedCABundle),
+	}, nil
+}
 {{- end}}
+
+func (r *responseRecorder) record(status int, retryAfter string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+	r.retryAfter = parseRetryAfter(retryAfter)
+}
+
+// attemptError classifies err from the given phase. After connecting, any
+// error mcp-go doesn't report as a transport error is the server's JSON-RPC
+// answer (or a protocol mismatch), which a retry would only repeat.
+func (r *responseRecorder) attemptError(phase string, err error) *callAttemptError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var transportErr *transport.Error
+	permanent := phase != "connect" && !errors.As(err, &transportErr)
+	return &callAttemptError{err: err, phase: phase, status: r.status, retryAfter: r.retryAfter, permanent: permanent}
+}
+
+// callAttemptError describes why a single tool call attempt failed.
+type callAttemptError struct {
+	err        error
+	phase      string // "connect", "handshake" or "call"
+	status     int    // HTTP status of the last response, 0 if none was received
+	retryAfter time.Duration
+	timedOut   bool
+	permanent  bool // a configuration or protocol error, not a transient one
+}
+
+// retryable reports whether the attempt may be retried and how long the
+// server asked us to wait. Failures during tools/call are only retried when
+// the call is safe to repeat.
+func (e *callAttemptError) retryable(safeToRepeat bool) (bool, time.Duration) {
+	if e.timedOut || e.permanent {
+		return false, 0
+	}
+	if e.phase == "call" && !safeToRepeat {
+		return false, 0
+	}
+	switch {
+	case e.status == http.StatusTooManyRequests || e.status >= 500:
+		return true, e.retryAfter
+	case e.status != 0:
+		// The server answered; the failure is not a transport problem.
+		return false, 0
+	}
+	return true, 0
+}
+
+// retryDelay returns the backoff before retry number attempt+1: exponential
+// from --retry-backoff with jitter, or the server's Retry-After if longer.
+func retryDelay(attempt int, retryAfter time.Duration) time.Duration {
+	const maxDelay = 30 * time.Second
+	base := time.Duration(globalRetryBackoff) * time.Millisecond
+	if base <= 0 {
+		base = 100 * time.Millisecond
+	}
+	delay := base << attempt
+	if delay <= 0 || delay > maxDelay {
+		delay = maxDelay
+	}
+	// Jitter between 50% and 100% of the exponential delay.
+	delay = delay/2 + time.Duration(mathrand.Int64N(int64(delay/2)+1))
+	if retryAfter > delay {
+		delay = min(retryAfter, 2*time.Minute)
+	}
+	return delay
+}
+
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
 }
 
 // --- Output formatting ---
@@ -386,508 +814,344 @@ func extractText(result *mcp.CallToolResult) string {
 	return string(data)
 }
 
-// --- Auth provider dispatch ---
-
-type authProvider interface {
-	getHeaders(ctx context.Context) map[string]string
-}
-
-type noAuthProvider struct{}
-func (p *noAuthProvider) getHeaders(_ context.Context) map[string]string { return nil }
-
-type bearerTokenProvider struct{ token string }
-func (p *bearerTokenProvider) getHeaders(_ context.Context) map[string]string {
-	if p.token == "" { return nil }
-	return map[string]string{"Authorization": "Bearer " + p.token}
-}
-
-type apiKeyProvider struct{ token, headerName string }
-func (p *apiKeyProvider) getHeaders(_ context.Context) map[string]string {
-	if p.token == "" { return nil }
-	name := p.headerName
-	if name == "" { name = "X-API-Key" }
-	return map[string]string{name: p.token}
-}
-
-type basicAuthProvider struct{ username, password string }
-func (p *basicAuthProvider) getHeaders(_ context.Context) map[string]string {
-	if p.username == "" && p.password == "" { return nil }
-	encoded := base64.StdEncoding.EncodeToString([]byte(p.username + ":" + p.password))
-	return map[string]string{"Authorization": "Basic " + encoded}
-}
-
-type s2sOAuth2Provider struct {
-	clientID, clientSecret, tokenEndpoint string
-	cachedToken string
-	expiresAt   time.Time
-}
-func (p *s2sOAuth2Provider) getHeaders(ctx context.Context) map[string]string {
-	if p.cachedToken == "" || (!p.expiresAt.IsZero() && time.Now().After(p.expiresAt)) {
-		token, expiresIn, err := s2sAuthenticate(ctx, p.tokenEndpoint, p.clientID, p.clientSecret)
-		if err != nil {
-			return nil
-		}
-		p.cachedToken = token
-		if expiresIn > 0 {
-			p.expiresAt = time.Now().Add(time.Duration(expiresIn) * time.Second)
-		}
-	}
-	return map[string]string{"Authorization": "Bearer " + p.cachedToken}
-}
-
-type googleSAProvider struct {
-	keyFile string
-	scopes  []string
-}
-func (p *googleSAProvider) getHeaders(ctx context.Context) map[string]string {
-	keyData, err := os.ReadFile(p.keyFile)
-	if err != nil { return nil }
-	scopes := p.scopes
-	if len(scopes) == 0 {
-		scopes = []string{"https://www.googleapis.com/auth/cloud-platform"}
-	}
-	creds, err := google.CredentialsFromJSON(ctx, keyData, scopes...)
-	if err != nil { return nil }
-	token, err := creds.TokenSource.Token()
-	if err != nil { return nil }
-	return map[string]string{"Authorization": "Bearer " + token.AccessToken}
-}
-
-// --- Credential store types ---
-
-type credentialsFile struct {
-	Version int                         ` + "`" + `json:"version,omitempty"` + "`" + `
-	Servers map[string]serverCredential ` + "`" + `json:"servers"` + "`" + `
-}
-
-type serverCredential struct {
-	AuthType      string   ` + "`" + `json:"auth_type,omitempty"` + "`" + `
-	Type          string   ` + "`" + `json:"type,omitempty"` + "`" + `
-	Token         string   ` + "`" + `json:"token,omitempty"` + "`" + `
-	AccessToken   string   ` + "`" + `json:"access_token,omitempty"` + "`" + `
-	RefreshToken  string   ` + "`" + `json:"refresh_token,omitempty"` + "`" + `
-	ExpiresAt     string   ` + "`" + `json:"expires_at,omitempty"` + "`" + `
-	ClientID      string   ` + "`" + `json:"client_id,omitempty"` + "`" + `
-	ClientSecret  string   ` + "`" + `json:"client_secret,omitempty"` + "`" + `
-	TokenEndpoint string   ` + "`" + `json:"token_endpoint,omitempty"` + "`" + `
-	Scope         string   ` + "`" + `json:"scope,omitempty"` + "`" + `
-	HeaderName    string   ` + "`" + `json:"header_name,omitempty"` + "`" + `
-	Username      string   ` + "`" + `json:"username,omitempty"` + "`" + `
-	Password      string   ` + "`" + `json:"password,omitempty"` + "`" + `
-	KeyFile       string   ` + "`" + `json:"key_file,omitempty"` + "`" + `
-	Scopes        []string ` + "`" + `json:"scopes,omitempty"` + "`" + `
+// envBool reports whether the environment variable key holds a true value
+// as strconv.ParseBool reads it; unset or unparsable values are false.
+func envBool(key string) bool {
+	v, _ := strconv.ParseBool(os.Getenv(key))
+	return v
 }
 
-func (s serverCredential) resolveAuthType() string {
-	if s.AuthType != "" { return s.AuthType }
-	switch s.Type {
-	case "bearer": return "bearer_token"
-	case "oauth": return "oauth2"
-	default: return s.Type
+func orDefault(value, fallback string) string {
+	if value != "" {
+		return value
 	}
+	return fallback
 }
+{{- if .Update.URL}}
 
-func (s serverCredential) isExpired() bool {
-	if s.ExpiresAt == "" { return false }
-	t, err := time.Parse(time.RFC3339, s.ExpiresAt)
-	if err != nil { return false }
-	return time.Now().After(t)
-}
-
-// --- Token refresh ---
-
-func refreshOAuthToken(credPath, srvURL string, sc serverCredential) (string, bool, error) {
-	if sc.RefreshToken == "" {
-		return "", true, fmt.Errorf("access token expired and no refresh token available. Run ` + "`" + `%s auth` + "`" + ` to re-authenticate", os.Args[0])
-	}
-	tokenEndpoint := sc.TokenEndpoint
-	if tokenEndpoint == "" {
-		discovered, err := discoverTokenEndpointForServer(context.Background(), srvURL)
-		if err != nil {
-			return "", false, fmt.Errorf("access token expired and no token endpoint stored, and discovery failed: %w", err)
-		}
-		tokenEndpoint = discovered
-	}
-
-	form := url.Values{
-		"grant_type":    {"refresh_token"},
-		"client_id":     {sc.ClientID},
-		"refresh_token": {sc.RefreshToken},
-	}
-	if sc.ClientSecret != "" {
-		form.Set("client_secret", sc.ClientSecret)
-	}
-
-	httpClient := &http.Client{Timeout: 30 * time.Second}
-	req, err := http.NewRequestWithContext(context.Background(), "POST", tokenEndpoint, strings.NewReader(form.Encode()))
-	if err != nil {
-		return "", false, fmt.Errorf("token refresh request: %w", err)
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+// --- Self-update ---
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return "", false, fmt.Errorf("token refresh request failed: %w", err)
-	}
-	defer resp.Body.Close()
+// updateURL is where releases of this CLI are published: the output
+// directory of clihub generate, as a directory or an http(s) base URL.
+const updateURL = {{quote .Update.URL}}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		permanent := isPermanentOAuthFailure(resp.StatusCode, body)
-		return "", permanent, fmt.Errorf("token refresh failed (%d): %s. Run ` + "`" + `%s auth` + "`" + ` to re-authenticate", resp.StatusCode, string(body), os.Args[0])
-	}
+// updatePublicKey must have signed the release's SHA256SUMS; empty means
+// releases are checked against SHA256SUMS only.
+const updatePublicKey = {{quote .Update.PublicKey}}
 
-	var tokenResp struct {
-		AccessToken  string ` + "`" + `json:"access_token"` + "`" + `
-		RefreshToken string ` + "`" + `json:"refresh_token"` + "`" + `
-		ExpiresIn    int    ` + "`" + `json:"expires_in"` + "`" + `
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", false, fmt.Errorf("parse refresh response: %w", err)
-	}
+func cmdSelfUpdate() *cobra.Command {
+	var flagCheck bool
+	var flagURL string
 
-	if tokenResp.AccessToken == "" {
-		return "", false, fmt.Errorf("refresh response missing access_token")
+	long := "Download the latest release from " + updateURL + ", verify it against SHA256SUMS"
+	if updatePublicKey != "" {
+		long += " and its signature"
 	}
+	long += ", and replace this binary with it."
 
-	// Update credential store
-	data, err := os.ReadFile(credPath)
-	if err == nil {
-		var creds credentialsFile
-		if json.Unmarshal(data, &creds) == nil && creds.Servers != nil {
-			entry := creds.Servers[srvURL]
-			if entry.AuthType == "" {
-				entry.AuthType = "oauth2"
-				entry.Type = "oauth"
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Update this binary to the latest release",
+		Long:  long,
+		Args:  cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := update.Config{
+				Name:      {{quote .CLIName}},
+				Version:   cliVersion,
+				URL:       flagURL,
+				PublicKey: updatePublicKey,
+				Client:    &http.Client{Transport: httpTransport},
 			}
-			entry.AccessToken = tokenResp.AccessToken
-			entry.TokenEndpoint = tokenEndpoint
-			if tokenResp.RefreshToken != "" {
-				entry.RefreshToken = tokenResp.RefreshToken
+			rel, err := update.Check(cmd.Context(), cfg)
+			if err != nil {
+				return fmt.Errorf("check for updates: %w", err)
 			}
-			if tokenResp.ExpiresIn > 0 {
-				entry.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second).Format(time.RFC3339)
+			if flagCheck && (globalOutput == "json" || globalOutput == "raw") {
+				data, err := json.Marshal(map[string]any{"current": cliVersion, "latest": rel.Version, "available": rel.Available, "signed": rel.Signed})
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(data))
+				return nil
 			}
-			creds.Servers[srvURL] = entry
-			if creds.Version < 2 {
-				creds.Version = 2
+			if !rel.Signed {
+				fmt.Fprintf(os.Stderr, "Warning: the release at %s is not signed; it is only checked against SHA256SUMS.\n", flagURL)
 			}
-			if updated, err := json.MarshalIndent(creds, "", "  "); err == nil {
-				os.WriteFile(credPath, updated, 0600)
+			if rel.Version == "" {
+				msg := fmt.Sprintf("the release at %s has no SBOM, so its version is unknown", flagURL)
+				if flagCheck {
+					fmt.Printf("Cannot tell whether %s is newer than %s: %s.\n", rel.Binary, cliVersion, msg)
+					return nil
+				}
+				return fmt.Errorf("%s; refusing to update", msg)
 			}
-		}
-	}
-
-	return tokenResp.AccessToken, false, nil
-}
-
-func isPermanentOAuthFailure(status int, body []byte) bool {
-	if status == http.StatusUnauthorized {
-		return true
-	}
-	if status != http.StatusBadRequest {
-		return false
-	}
-
-	var oauthErr struct {
-		Error string ` + "`" + `json:"error"` + "`" + `
-	}
-	if err := json.Unmarshal(body, &oauthErr); err != nil {
-		return false
-	}
-	switch oauthErr.Error {
-	case "invalid_grant", "invalid_client", "unauthorized_client", "invalid_request":
-		return true
-	default:
-		return false
-	}
-}
-
-func discoverTokenEndpointForServer(ctx context.Context, srvURL string) (string, error) {
-	httpClient := &http.Client{Timeout: 30 * time.Second}
-
-	type resourceMeta struct {
-		AuthorizationServers []string ` + "`" + `json:"authorization_servers"` + "`" + `
-	}
-	type authMeta struct {
-		TokenEndpoint string ` + "`" + `json:"token_endpoint"` + "`" + `
-	}
-
-	authServers := []string{discoveryServerRoot(srvURL)}
-	for _, wk := range oauthWellKnownURLsForDiscovery(srvURL, "oauth-protected-resource") {
-		req, err := http.NewRequestWithContext(ctx, "GET", wk, nil)
-		if err != nil {
-			continue
-		}
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			continue
-		}
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			continue
-		}
-		var meta resourceMeta
-		err = json.NewDecoder(resp.Body).Decode(&meta)
-		resp.Body.Close()
-		if err == nil && len(meta.AuthorizationServers) > 0 {
-			authServers = meta.AuthorizationServers
-			break
-		}
-	}
-
-	for _, authServer := range authServers {
-		for _, wk := range oauthWellKnownURLsForDiscovery(authServer, "oauth-authorization-server") {
-			req, err := http.NewRequestWithContext(ctx, "GET", wk, nil)
-			if err != nil {
-				continue
-			}
-			resp, err := httpClient.Do(req)
-			if err != nil {
-				continue
+			if !rel.Available {
+				fmt.Printf("%s %s is up to date.\n", {{quote .CLIName}}, cliVersion)
+				return nil
 			}
-			if resp.StatusCode != http.StatusOK {
-				resp.Body.Close()
-				continue
+			if flagCheck {
+				fmt.Printf("Update available: %s → %s. Run ` + "`" + `%s self-update` + "`" + ` to install it.\n", cliVersion, rel.Version, os.Args[0])
+				return nil
 			}
-			var meta authMeta
-			err = json.NewDecoder(resp.Body).Decode(&meta)
-			resp.Body.Close()
-			if err == nil && meta.TokenEndpoint != "" {
-				return meta.TokenEndpoint, nil
+			path, err := update.Apply(cmd.Context(), cfg, rel)
+			if err != nil {
+				return err
 			}
-		}
-	}
-
-	return "", fmt.Errorf("could not discover token endpoint for %s", srvURL)
-}
-
-func discoveryServerRoot(rawURL string) string {
-	u, err := url.Parse(rawURL)
-	if err != nil || u.Scheme == "" || u.Host == "" {
-		return rawURL
-	}
-	return u.Scheme + "://" + u.Host
-}
-
-func oauthWellKnownURLsForDiscovery(rawURL, suffix string) []string {
-	u, err := url.Parse(rawURL)
-	if err != nil {
-		return nil
-	}
-	path := strings.TrimRight(u.Path, "/")
-	base := fmt.Sprintf("%s://%s/.well-known/%s", u.Scheme, u.Host, suffix)
-	if path == "" {
-		return []string{base}
+			fmt.Printf("Updated %s from %s to %s.\n", path, cliVersion, rel.Version)
+			return nil
+		},
 	}
-	return []string{base + "/" + strings.TrimLeft(path, "/"), base}
+	cmd.Flags().BoolVar(&flagCheck, "check", false, "only report whether an update is available")
+	cmd.Flags().StringVar(&flagURL, "url", orDefault(os.Getenv("CLIHUB_UPDATE_URL"), updateURL), "release location to update from (or set CLIHUB_UPDATE_URL)")
+	return cmd
 }
+{{- end}}
+{{- if .IsHTTP}}
 
-// --- S2S OAuth2 ---
-
-func s2sAuthenticate(ctx context.Context, tokenEndpoint, clientID, clientSecret string) (string, int, error) {
-	form := url.Values{
-		"grant_type":    {"client_credentials"},
-		"client_id":     {clientID},
-		"client_secret": {clientSecret},
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", tokenEndpoint, strings.NewReader(form.Encode()))
-	if err != nil {
-		return "", 0, err
-	}
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-	resp, err := (&http.Client{Timeout: 30 * time.Second}).Do(req)
-	if err != nil {
-		return "", 0, fmt.Errorf("S2S token request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		body, _ := io.ReadAll(resp.Body)
-		return "", 0, fmt.Errorf("S2S token endpoint returned %d: %s", resp.StatusCode, string(body))
-	}
-
-	var tokenResp struct {
-		AccessToken string ` + "`" + `json:"access_token"` + "`" + `
-		ExpiresIn   int    ` + "`" + `json:"expires_in"` + "`" + `
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
-		return "", 0, fmt.Errorf("parse S2S response: %w", err)
-	}
-	if tokenResp.AccessToken == "" {
-		return "", 0, fmt.Errorf("S2S response missing access_token")
-	}
-	return tokenResp.AccessToken, tokenResp.ExpiresIn, nil
-}
+// --- Auth ---
+//
+// Providers, the credential file and its secret stores, and the OAuth flows
+// come from clihub's runtime/auth package, so this CLI and clihub share
+// credentials, locks and caches.
 
 // resolveAuthProvider builds an auth provider using the following priority:
 //  1. --auth-type + auth flags → explicit provider
-//  2. --auth-token without --auth-type → bearer (backwards compat)
-//  3. CLIHUB_AUTH_TOKEN env var → bearer
+//  2. --auth-token without --auth-type → bearer, or the built-in api_key header
+//  3. CLIHUB_AUTH_TOKEN env var → likewise, then --auth-exec or
+//     CLIHUB_AUTH_EXEC → credential helper
 //  4. Credentials file → provider from stored auth_type (with token refresh)
-//  5. No credentials → no auth
-func resolveAuthProvider() authProvider {
+//  5. Built-in token_exchange or exec configuration
+//  6. No credentials → no auth
+func resolveAuthProvider() auth.AuthProvider {
 	// 1. Explicit --auth-type
 	if globalAuthType != "" {
 		switch globalAuthType {
 		case "bearer", "bearer_token":
-			return &bearerTokenProvider{token: globalAuthToken}
+			return &auth.BearerTokenProvider{Token: globalAuthToken}
 		case "api_key":
-			return &apiKeyProvider{token: globalAuthToken, headerName: globalAuthHeaderName}
+			return &auth.APIKeyProvider{Token: globalAuthToken, HeaderName: orDefault(globalAuthHeaderName, embeddedHeaderName())}
 		case "basic", "basic_auth":
-			return &basicAuthProvider{username: globalAuthUsername, password: globalAuthPassword}
+			return &auth.BasicAuthProvider{Username: globalAuthUsername, Password: globalAuthPassword}
+		case "token_exchange":
+			if globalTokenEndpoint == "" && globalSubjectFile == "" && globalSubjectEnv == "" {
+				return embeddedTokenExchange()
+			}
+			return &auth.TokenExchangeProvider{
+				TokenEndpoint: globalTokenEndpoint, SubjectTokenFile: globalSubjectFile,
+				SubjectTokenEnv: globalSubjectEnv, SubjectTokenType: globalSubjectType, Audience: globalAudience,
+			}
+		case "exec":
+			return execProvider(globalAuthExec)
 		default:
-			return &noAuthProvider{}
+			return &auth.NoAuthProvider{}
 		}
 	}
 
-	// 2. --auth-token without --auth-type → infer bearer
+	// 2. --auth-token without --auth-type → the built-in token type
 	if globalAuthToken != "" {
-		return &bearerTokenProvider{token: globalAuthToken}
+		return tokenProvider(globalAuthToken)
 	}
 
 	// 3. CLIHUB_AUTH_TOKEN env var
 	if token := os.Getenv("CLIHUB_AUTH_TOKEN"); token != "" {
-		return &bearerTokenProvider{token: token}
+		return tokenProvider(token)
 	}
 
-	// 4. Credentials file
-	credPath := os.Getenv("CLIHUB_CREDENTIALS_FILE")
-	if credPath == "" {
-		home, err := os.UserHomeDir()
-		if err == nil {
-			credPath = filepath.Join(home, ".clihub", "credentials.json")
-		}
+	// 3b. --auth-exec or CLIHUB_AUTH_EXEC → credential helper
+	if globalAuthExec != "" {
+		return execProvider(globalAuthExec)
 	}
-	if credPath != "" {
-		data, err := os.ReadFile(credPath)
-		if err == nil {
-			var creds credentialsFile
-			if json.Unmarshal(data, &creds) == nil && creds.Servers != nil {
-{{- if .IsHTTP}}
-				if s, ok := creds.Servers[serverURL]; ok {
-					authType := s.resolveAuthType()
-					switch authType {
-					case "bearer_token":
-						return &bearerTokenProvider{token: s.Token}
-					case "api_key":
-						return &apiKeyProvider{token: s.Token, headerName: s.HeaderName}
-					case "basic_auth":
-						return &basicAuthProvider{username: s.Username, password: s.Password}
-					case "oauth2":
-						token := s.AccessToken
-						if s.isExpired() {
-							if s.RefreshToken != "" {
-								refreshed, permanent, err := refreshOAuthToken(credPath, serverURL, s)
-								if err != nil {
-									fmt.Fprintf(os.Stderr, "Warning: token refresh failed: %s\n", err)
-									if permanent {
-										deleteCredential(credPath, serverURL)
-										fmt.Fprintf(os.Stderr, "Stored credentials removed. Run ` + "`" + `%s auth` + "`" + ` to re-authenticate.\n", os.Args[0])
-									} else {
-										fmt.Fprintf(os.Stderr, "Stored credentials were kept (refresh error may be transient).\n")
-									}
-									return &noAuthProvider{}
-								}
-								token = refreshed
-							} else {
-								fmt.Fprintf(os.Stderr, "Warning: access token expired and no refresh token available.\n")
-								deleteCredential(credPath, serverURL)
-								fmt.Fprintf(os.Stderr, "Stored credentials removed. Run ` + "`" + `%s auth` + "`" + ` to re-authenticate.\n", os.Args[0])
-								return &noAuthProvider{}
-							}
-						}
-						return &bearerTokenProvider{token: token}
-					case "s2s_oauth2":
-						return &s2sOAuth2Provider{
-							clientID: s.ClientID, clientSecret: s.ClientSecret,
-							tokenEndpoint: s.TokenEndpoint,
-						}
-					case "google_sa":
-						return &googleSAProvider{keyFile: s.KeyFile, scopes: s.Scopes}
-					}
-				}
-{{- end}}
+
+	// 4. Credentials file
+	if credPath := auth.DefaultCredentialsPath(); credPath != "" {
+		creds, err := auth.LoadServerCredentials(credPath, serverURL)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not read stored credentials: %s\n", err)
+		} else {
+			profile := creds.ResolveProfile(serverURL, globalProfile)
+			if sc, ok := creds.Credential(serverURL, profile); ok {
+				return storedAuthProvider(credPath, profile, sc)
 			}
 		}
 	}
 
-	// 5. No auth
-	return &noAuthProvider{}
-}
+	// 5. Built-in workload identity needs nothing stored
+	switch embeddedAuth.Type {
+	case "token_exchange":
+		return embeddedTokenExchange()
+	case "exec":
+		return execProvider(embeddedAuth.Command)
+	}
 
-// --- Credential helpers ---
+	// 6. No auth
+	return &auth.NoAuthProvider{}
+}
 
-func defaultCredPath() string {
-	if p := os.Getenv("CLIHUB_CREDENTIALS_FILE"); p != "" {
-		return p
+// storedAuthProvider returns the provider for a stored credential.
+func storedAuthProvider(credPath, profile string, sc auth.ServerCredential) auth.AuthProvider {
+	switch sc.ResolveAuthType() {
+	case "bearer_token":
+		return &auth.BearerTokenProvider{Token: sc.Token}
+	case "api_key":
+		return &auth.APIKeyProvider{Token: sc.Token, HeaderName: sc.HeaderName}
+	case "basic_auth":
+		return &auth.BasicAuthProvider{Username: sc.Username, Password: sc.Password}
+	case "oauth2":
+		// Tokens are refreshed shortly before they expire; only one process
+		// refreshes at a time.
+		return &auth.OAuth2Provider{
+			ServerURL:           serverURL,
+			CredPath:            credPath,
+			Profile:             profile,
+			ClientID:            orDefault(sc.ClientID, embeddedAuth.ClientID),
+			ClientSecret:        sc.ClientSecret,
+			ResourceMetadataURL: embeddedAuth.ResourceMetadataURL,
+			AuthServerURL:       embeddedAuth.AuthServerURL,
+			ClientName:          {{quote .CLIName}},
+			AuthCommand:         os.Args[0] + " auth",
+			OnRefreshError:      warnRefreshFailed,
+		}
+	case "s2s_oauth2":
+		return &auth.S2SOAuth2Provider{
+			ClientID: sc.ClientID, ClientSecret: sc.ClientSecret,
+			TokenEndpoint: sc.TokenEndpoint, ServerURL: serverURL,
+		}
+	case "google_sa":
+		return &auth.GoogleSAProvider{KeyFile: sc.KeyFile, Scopes: sc.Scopes}
+	case "token_exchange":
+		return &auth.TokenExchangeProvider{
+			TokenEndpoint: sc.TokenEndpoint, SubjectTokenFile: sc.SubjectTokenFile,
+			SubjectTokenEnv: sc.SubjectTokenEnv, SubjectTokenType: sc.SubjectTokenType,
+			Audience: sc.Audience, Resource: sc.Resource, Scope: sc.Scope,
+			ClientID: sc.ClientID, ClientSecret: sc.ClientSecret,
+		}
+	case "exec":
+		return execProvider(sc.Command)
 	}
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ""
+	return &auth.NoAuthProvider{}
+}
+
+// execProvider runs a credential helper; tokens with an expiry are cached
+// in the secret store of the credentials file.
+func execProvider(command string) auth.AuthProvider {
+	return &auth.ExecCredentialProvider{
+		Command: command, ServerURL: serverURL,
+		CredPath: auth.DefaultCredentialsPath(), OnCacheError: warnCacheFailed,
 	}
-	return filepath.Join(home, ".clihub", "credentials.json")
 }
 
-func writeCredentials(path string, creds *credentialsFile) error {
-	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
-		return err
+// warnCacheFailed reports that a credential helper token could not be
+// cached; the helper then runs again next time.
+func warnCacheFailed(err error) {
+	fmt.Fprintf(os.Stderr, "Warning: %s\n", err)
+}
+
+var refreshWarning sync.Once
+
+// warnRefreshFailed reports a failed token refresh once; the current token
+// is still sent and the server decides whether it is good.
+func warnRefreshFailed(err error) {
+	refreshWarning.Do(func() {
+		fmt.Fprintf(os.Stderr, "Warning: token refresh failed: %s\nRun ` + "`" + `%s auth` + "`" + ` to re-authenticate if the call fails.\n", err, os.Args[0])
+	})
+}
+
+// tokenProvider sends token as the server expects: in the built-in api_key
+// header, or as a bearer token.
+func tokenProvider(token string) auth.AuthProvider {
+	if name := embeddedHeaderName(); name != "" {
+		return &auth.APIKeyProvider{Token: token, HeaderName: name}
 	}
-	if creds.Version < 2 {
-		creds.Version = 2
+	return &auth.BearerTokenProvider{Token: token}
+}
+
+// embeddedHeaderName is the api_key header detected at generation time.
+func embeddedHeaderName() string {
+	if embeddedAuth.Type == "api_key" {
+		return orDefault(embeddedAuth.HeaderName, "X-API-Key")
 	}
-	data, err := json.MarshalIndent(creds, "", "  ")
-	if err != nil {
-		return err
+	return ""
+}
+
+func embeddedTokenExchange() auth.AuthProvider {
+	return &auth.TokenExchangeProvider{
+		TokenEndpoint: embeddedAuth.TokenEndpoint, SubjectTokenFile: embeddedAuth.SubjectTokenFile,
+		SubjectTokenEnv: embeddedAuth.SubjectTokenEnv, SubjectTokenType: embeddedAuth.SubjectTokenType,
+		Audience: embeddedAuth.Audience, Scope: embeddedAuth.Scope, ClientID: embeddedAuth.ClientID,
 	}
-	return os.WriteFile(path, data, 0600)
 }
 
-func deleteCredential(credPath, srvURL string) {
-	if credPath == "" {
-		return
+// confirmScopeStepUp asks before signing in again for a broader scope.
+// Without a terminal to ask on, it says how to grant the scope instead.
+func confirmScopeStepUp(scope string) bool {
+	if !stdinIsTerminal() {
+		fmt.Fprintf(os.Stderr, "The server requires more permissions: run ` + "`" + `%s auth --scope %q` + "`" + ` to grant them.\n", os.Args[0], scope)
+		return false
 	}
-	data, err := os.ReadFile(credPath)
+	answer, err := promptLine(fmt.Sprintf("The server requires more permissions. Sign in again to grant %q? [y/N] ", scope))
 	if err != nil {
-		return
-	}
-	var creds credentialsFile
-	if json.Unmarshal(data, &creds) != nil || creds.Servers == nil {
-		return
-	}
-	delete(creds.Servers, srvURL)
-	if updated, err := json.MarshalIndent(creds, "", "  "); err == nil {
-		os.WriteFile(credPath, updated, 0600)
+		return false
 	}
+	a := strings.ToLower(answer)
+	return a == "y" || a == "yes"
 }
 
 // --- Auth command ---
-{{- if .IsHTTP}}
 
 func cmdAuth() *cobra.Command {
 	var flagToken string
 	var flagClientID string
 	var flagClientSecret string
+	var flagKeyFile string
+	var flagScope string
+	var flagDevice bool
 
 	cmd := &cobra.Command{
 		Use:   "auth",
 		Short: "Authenticate with the MCP server",
-		Long:  "Run the OAuth browser flow or manually provide credentials for " + serverURL,
+		Long:  "Sign in to " + serverURL + " with the flow it uses" + authFlowHint(),
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if flagToken != "" {
 				return saveManualToken(flagToken)
 			}
-			return runOAuthFlow(flagClientID, flagClientSecret)
+			clientID := orDefault(flagClientID, embeddedAuth.ClientID)
+			switch embeddedAuth.Type {
+			case "bearer", "api_key":
+				token, err := promptLine("Token: ")
+				if err != nil {
+					return err
+				}
+				return saveManualToken(token)
+			case "basic":
+				return saveBasicAuth()
+			case "s2s_oauth2":
+				return saveS2SCredentials(clientID, flagClientSecret)
+			case "google_sa":
+				if flagKeyFile == "" {
+					return fmt.Errorf("this server uses a Google service account: run ` + "`" + `%s auth --key-file <key.json>` + "`" + `", os.Args[0])
+				}
+				return saveCredential(auth.ServerCredential{AuthType: "google_sa", KeyFile: flagKeyFile}, "Service account key")
+			case "token_exchange", "exec":
+				fmt.Printf("%s uses %s auth configured at build time; no sign-in is needed.\n", serverURL, embeddedAuth.Type)
+				return nil
+			case "none":
+				fmt.Printf("%s does not require authentication.\n", serverURL)
+				return nil
+			}
+			return runOAuthFlow(clientID, flagClientSecret, flagScope, flagDevice)
 		},
 	}
 
-	cmd.Flags().StringVar(&flagToken, "token", "", "manually save a bearer token")
+	cmd.Flags().StringVar(&flagToken, "token", "", "save a token instead of signing in")
 	cmd.Flags().StringVar(&flagClientID, "client-id", "", "pre-registered OAuth client ID")
 	cmd.Flags().StringVar(&flagClientSecret, "client-secret", "", "pre-registered OAuth client secret")
+	cmd.Flags().StringVar(&flagKeyFile, "key-file", "", "Google service account JSON key file")
+	cmd.Flags().StringVar(&flagScope, "scope", "", "OAuth scopes to request (space-separated; default: the server's)")
+	cmd.Flags().BoolVar(&flagDevice, "device", false, "authorize on another device (device flow, or paste the redirect URL) instead of opening a local browser")
 
 	cmd.AddCommand(&cobra.Command{
 		Use:   "status",
@@ -905,458 +1169,264 @@ func cmdAuth() *cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			credPath := defaultCredPath()
+			credPath := auth.DefaultCredentialsPath()
 			if credPath == "" {
 				return fmt.Errorf("could not determine credentials path")
 			}
-			deleteCredential(credPath, serverURL)
-			fmt.Printf("Credentials removed for %s\n", serverURL)
+			var profile string
+			err := auth.UpdateCredentials(credPath, func(creds *auth.CredentialsFile) error {
+				profile = creds.ResolveProfile(serverURL, globalProfile)
+				creds.DeleteCredential(serverURL, profile)
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("remove credentials: %w", err)
+			}
+			fmt.Printf("Credentials removed for %s (profile %s)\n", serverURL, profile)
 			return nil
 		},
 	})
 
+	cmd.AddCommand(&cobra.Command{
+		Use:   "use <profile>",
+		Short: "Set the default credential profile for this server",
+		Args:  cobra.ExactArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return useProfile(args[0])
+		},
+	})
+
 	return cmd
 }
 
-func saveManualToken(token string) error {
-	credPath := defaultCredPath()
+func useProfile(profile string) error {
+	if err := auth.ValidateProfileName(profile); err != nil {
+		return err
+	}
+	credPath := auth.DefaultCredentialsPath()
 	if credPath == "" {
 		return fmt.Errorf("could not determine credentials path")
 	}
-	data, _ := os.ReadFile(credPath)
-	var creds credentialsFile
-	if json.Unmarshal(data, &creds) != nil || creds.Servers == nil {
-		creds = credentialsFile{Version: 2, Servers: map[string]serverCredential{}}
-	}
-	creds.Servers[serverURL] = serverCredential{
-		AuthType: "bearer_token",
-		Type:     "bearer",
-		Token:    token,
-	}
-	if err := writeCredentials(credPath, &creds); err != nil {
-		return fmt.Errorf("save credentials: %w", err)
+	err := auth.UpdateCredentials(credPath, func(creds *auth.CredentialsFile) error {
+		if err := creds.UseProfile(serverURL, profile); err != nil {
+			return fmt.Errorf("%w. Run ` + "`" + `%s auth --profile %s` + "`" + ` first", err, os.Args[0], profile)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	fmt.Printf("Token saved for %s\n", serverURL)
+	fmt.Printf("Default profile for %s is now %s\n", serverURL, profile)
 	return nil
 }
 
-func showAuthStatus() error {
-	credPath := defaultCredPath()
-	if credPath == "" {
-		fmt.Println("Not authenticated (no credentials path)")
-		return nil
-	}
-	data, err := os.ReadFile(credPath)
-	if err != nil {
-		fmt.Println("Not authenticated")
-		return nil
-	}
-	var creds credentialsFile
-	if json.Unmarshal(data, &creds) != nil || creds.Servers == nil {
-		fmt.Println("Not authenticated")
-		return nil
-	}
-	s, ok := creds.Servers[serverURL]
-	if !ok {
-		fmt.Println("Not authenticated")
-		return nil
+// saveManualToken stores token as a bearer token, or in the built-in
+// api_key header.
+func saveManualToken(token string) error {
+	if token == "" {
+		return fmt.Errorf("no token given")
 	}
-	authType := s.resolveAuthType()
-	fmt.Printf("Server:    %s\n", serverURL)
-	fmt.Printf("Auth type: %s\n", authType)
-	switch authType {
-	case "oauth2":
-		if s.isExpired() {
-			fmt.Println("Status:    expired")
-		} else {
-			fmt.Println("Status:    authenticated")
-		}
-		if s.ExpiresAt != "" {
-			fmt.Printf("Expires:   %s\n", s.ExpiresAt)
-		}
-	default:
-		fmt.Println("Status:    authenticated")
+	if name := embeddedHeaderName(); name != "" {
+		return saveCredential(auth.ServerCredential{AuthType: "api_key", Token: token, HeaderName: name}, "API key")
 	}
-	return nil
+	return saveCredential(auth.ServerCredential{AuthType: "bearer_token", Type: "bearer", Token: token}, "Token")
 }
 
-func runOAuthFlow(clientID, clientSecret string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
-	defer cancel()
-	httpClient := &http.Client{Timeout: 30 * time.Second}
-
-	fmt.Println("Discovering OAuth endpoints...")
-
-	// Step 1: Discover protected resource metadata
-	var authServerURL string
-	var scopeFromResource string
-	resMeta, err := fetchResourceMeta(ctx, httpClient, serverURL)
-	if err == nil && len(resMeta.AuthorizationServers) > 0 {
-		authServerURL = resMeta.AuthorizationServers[0]
-		if len(resMeta.ScopesSupported) > 0 {
-			scopeFromResource = strings.Join(resMeta.ScopesSupported, " ")
+func saveBasicAuth() error {
+	username := globalAuthUsername
+	if username == "" {
+		var err error
+		if username, err = promptLine("Username: "); err != nil {
+			return err
 		}
-	} else {
-		// Fallback: use server root as auth server
-		u, _ := url.Parse(serverURL)
-		if u != nil {
-			authServerURL = u.Scheme + "://" + u.Host
-		} else {
-			authServerURL = serverURL
+	}
+	password := globalAuthPassword
+	if password == "" {
+		var err error
+		if password, err = promptLine("Password: "); err != nil {
+			return err
 		}
 	}
+	return saveCredential(auth.ServerCredential{AuthType: "basic_auth", Username: username, Password: password}, "Credentials")
+}
 
-	// Step 2: Discover auth server metadata
-	authMeta, err := fetchAuthMeta(ctx, httpClient, authServerURL)
-	if err != nil {
-		return fmt.Errorf("OAuth discovery failed: %w\n\nIf this server doesn't support OAuth, provide a token manually:\n  %s auth --token <YOUR_TOKEN>", err, os.Args[0])
+// saveS2SCredentials checks the client credentials against the built-in
+// token endpoint before saving them.
+func saveS2SCredentials(clientID, clientSecret string) error {
+	if clientID == "" || clientSecret == "" {
+		return fmt.Errorf("this server uses client credentials: run ` + "`" + `%s auth --client-id <id> --client-secret <secret>` + "`" + `", os.Args[0])
 	}
-	fmt.Printf("Found authorization server: %s\n", authMeta.Issuer)
-
-	// Determine scope
-	scope := "mcp:tools"
-	if scopeFromResource != "" {
-		scope = scopeFromResource
-	} else if len(authMeta.ScopesSupported) > 0 {
-		scope = strings.Join(authMeta.ScopesSupported, " ")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(globalTimeout)*time.Millisecond)
+	defer cancel()
+	provider := &auth.S2SOAuth2Provider{
+		ClientID: clientID, ClientSecret: clientSecret,
+		TokenEndpoint: embeddedAuth.TokenEndpoint, ServerURL: serverURL,
 	}
-
-	// Step 3: Start callback server
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
-	if err != nil {
-		return fmt.Errorf("could not start callback server: %w", err)
-	}
-	port := listener.Addr().(*net.TCPAddr).Port
-	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", port)
-	resultCh := make(chan oauthCallbackResult, 1)
-	var cbOnce sync.Once
-
-	mux := http.NewServeMux()
-	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		code := r.URL.Query().Get("code")
-		oauthErr := r.URL.Query().Get("error")
-		state := r.URL.Query().Get("state")
-		var res oauthCallbackResult
-		if oauthErr != "" {
-			desc := r.URL.Query().Get("error_description")
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprintf(w, "<html><body><h1>Authorization failed</h1><p>%s: %s</p></body></html>", oauthErr, desc)
-			res = oauthCallbackResult{err: fmt.Errorf("%s: %s", oauthErr, desc)}
-		} else if code == "" {
-			w.WriteHeader(http.StatusBadRequest)
-			fmt.Fprint(w, "<html><body><h1>Missing authorization code</h1></body></html>")
-			res = oauthCallbackResult{err: fmt.Errorf("missing authorization code")}
-		} else {
-			w.WriteHeader(http.StatusOK)
-			fmt.Fprint(w, "<html><body><h1>Authentication successful!</h1><p>You can close this window.</p></body></html>")
-			res = oauthCallbackResult{code: code, state: state}
-		}
-		cbOnce.Do(func() {
-			resultCh <- res
-		})
-	})
-	srv := &http.Server{Handler: mux}
-	go srv.Serve(listener)
-	defer srv.Close()
-
-	// Step 4: Client credentials (DCR or pre-registered)
-	if clientID == "" && authMeta.RegistrationEndpoint != "" {
-		fmt.Println("Registering OAuth client...")
-		regID, regSecret, err := registerOAuthClient(ctx, httpClient, authMeta.RegistrationEndpoint, redirectURI, scope)
-		if err != nil {
-			return fmt.Errorf("client registration failed: %w\n\n"+
-				"This server requires a pre-registered OAuth app.\n"+
-				"  1. Register at the provider's portal (%s)\n"+
-				"  2. Set redirect URI to: http://127.0.0.1/callback\n"+
-				"  3. Run: %s auth --client-id <ID> --client-secret <SECRET>",
-				err, authMeta.Issuer, os.Args[0])
-		}
-		clientID = regID
-		clientSecret = regSecret
-	} else if clientID == "" {
-		return fmt.Errorf("this server requires a pre-registered OAuth app\n\n"+
-			"  1. Register at the provider's portal (%s)\n"+
-			"  2. Set redirect URI to: http://127.0.0.1/callback\n"+
-			"  3. Run: %s auth --client-id <ID> --client-secret <SECRET>",
-			authMeta.Issuer, os.Args[0])
-	}
-
-	// Step 5: PKCE
-	verifier, err := generatePKCEVerifier()
-	if err != nil {
-		return fmt.Errorf("PKCE generation failed: %w", err)
+	if _, err := provider.Authenticate(ctx); err != nil {
+		return err
 	}
-	challenge := generatePKCEChallenge(verifier)
+	return saveCredential(auth.ServerCredential{
+		AuthType: "s2s_oauth2", Type: "oauth", ClientID: clientID, ClientSecret: clientSecret,
+		TokenEndpoint: provider.TokenEndpoint,
+	}, "Client credentials")
+}
 
-	// Step 6: State
-	state, err := generateOAuthState()
-	if err != nil {
-		return fmt.Errorf("state generation failed: %w", err)
+// saveCredential stores sc for serverURL under the active profile.
+func saveCredential(sc auth.ServerCredential, what string) error {
+	credPath := auth.DefaultCredentialsPath()
+	if credPath == "" {
+		return fmt.Errorf("could not determine credentials path")
 	}
-
-	// Step 7: Build authorization URL
-	authURL, err := url.Parse(authMeta.AuthorizationEndpoint)
-	if err != nil {
-		return fmt.Errorf("invalid authorization endpoint: %w", err)
-	}
-	q := authURL.Query()
-	q.Set("client_id", clientID)
-	q.Set("redirect_uri", redirectURI)
-	q.Set("response_type", "code")
-	q.Set("code_challenge", challenge)
-	q.Set("code_challenge_method", "S256")
-	q.Set("state", state)
-	q.Set("scope", scope)
-	authURL.RawQuery = q.Encode()
-
-	// Step 8: Open browser
-	fmt.Println("Opening browser for authentication...")
-	openDefaultBrowser(authURL.String())
-	fmt.Printf("If the browser doesn't open, visit:\n%s\n\nWaiting for authorization...\n", authURL.String())
-
-	// Step 9: Wait for callback
-	select {
-	case <-ctx.Done():
-		return fmt.Errorf("timed out waiting for authorization")
-	case result := <-resultCh:
-		if result.err != nil {
-			return fmt.Errorf("authorization failed: %w", result.err)
-		}
-		if result.state != state {
-			return fmt.Errorf("OAuth state mismatch (possible CSRF)")
-		}
-
-		// Step 10: Exchange code for tokens
-		fmt.Println("Exchanging authorization code for tokens...")
-		form := url.Values{
-			"grant_type":    {"authorization_code"},
-			"code":          {result.code},
-			"redirect_uri":  {redirectURI},
-			"client_id":     {clientID},
-			"code_verifier": {verifier},
-		}
-		if clientSecret != "" {
-			form.Set("client_secret", clientSecret)
-		}
-
-		tokenReq, err := http.NewRequestWithContext(ctx, "POST", authMeta.TokenEndpoint, strings.NewReader(form.Encode()))
-		if err != nil {
-			return fmt.Errorf("token exchange: %w", err)
-		}
-		tokenReq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-
-		tokenResp, err := httpClient.Do(tokenReq)
-		if err != nil {
-			return fmt.Errorf("token exchange request: %w", err)
-		}
-		defer tokenResp.Body.Close()
-
-		if tokenResp.StatusCode != http.StatusOK && tokenResp.StatusCode != http.StatusCreated {
-			body, _ := io.ReadAll(tokenResp.Body)
-			return fmt.Errorf("token exchange failed (%d): %s", tokenResp.StatusCode, string(body))
-		}
-
-		var tokens struct {
-			AccessToken  string ` + "`" + `json:"access_token"` + "`" + `
-			RefreshToken string ` + "`" + `json:"refresh_token"` + "`" + `
-			ExpiresIn    int    ` + "`" + `json:"expires_in"` + "`" + `
-			Scope        string ` + "`" + `json:"scope"` + "`" + `
-		}
-		if err := json.NewDecoder(tokenResp.Body).Decode(&tokens); err != nil {
-			return fmt.Errorf("parse token response: %w", err)
-		}
-		if tokens.AccessToken == "" {
-			return fmt.Errorf("token response missing access_token")
-		}
-
-		// Save to credential store
-		credPath := defaultCredPath()
-		if credPath == "" {
-			return fmt.Errorf("could not determine credentials path")
-		}
-		data, _ := os.ReadFile(credPath)
-		var creds credentialsFile
-		if json.Unmarshal(data, &creds) != nil || creds.Servers == nil {
-			creds = credentialsFile{Version: 2, Servers: map[string]serverCredential{}}
-		}
-		entry := serverCredential{
-			AuthType:      "oauth2",
-			Type:          "oauth",
-			AccessToken:   tokens.AccessToken,
-			RefreshToken:  tokens.RefreshToken,
-			ClientID:      clientID,
-			ClientSecret:  clientSecret,
-			TokenEndpoint: authMeta.TokenEndpoint,
-			Scope:         tokens.Scope,
-		}
-		if tokens.ExpiresIn > 0 {
-			entry.ExpiresAt = time.Now().Add(time.Duration(tokens.ExpiresIn) * time.Second).Format(time.RFC3339)
-		}
-		creds.Servers[serverURL] = entry
-		if err := writeCredentials(credPath, &creds); err != nil {
-			return fmt.Errorf("save credentials: %w", err)
+	var profile string
+	err := auth.UpdateCredentials(credPath, func(creds *auth.CredentialsFile) error {
+		profile = creds.ResolveProfile(serverURL, globalProfile)
+		if err := auth.ValidateProfileName(profile); err != nil {
+			return err
 		}
-
-		fmt.Println("Authentication successful! Credentials saved.")
+		creds.SetCredential(serverURL, profile, sc)
 		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("save credentials: %w", err)
 	}
+	fmt.Printf("%s saved for %s (profile %s)\n", what, serverURL, profile)
+	return nil
 }
 
-type oauthCallbackResult struct {
-	code, state string
-	err         error
-}
-
-type oauthServerMeta struct {
-	Issuer                string   ` + "`" + `json:"issuer"` + "`" + `
-	AuthorizationEndpoint string   ` + "`" + `json:"authorization_endpoint"` + "`" + `
-	TokenEndpoint         string   ` + "`" + `json:"token_endpoint"` + "`" + `
-	RegistrationEndpoint  string   ` + "`" + `json:"registration_endpoint"` + "`" + `
-	ScopesSupported       []string ` + "`" + `json:"scopes_supported"` + "`" + `
-}
+var stdinReader = bufio.NewReader(os.Stdin)
 
-type oauthResourceMeta struct {
-	AuthorizationServers []string ` + "`" + `json:"authorization_servers"` + "`" + `
-	ScopesSupported      []string ` + "`" + `json:"scopes_supported"` + "`" + `
+// promptLine prints label and reads one line from stdin.
+func promptLine(label string) (string, error) {
+	fmt.Print(label)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read %s: %w", strings.TrimSuffix(strings.ToLower(label), ": "), err)
+	}
+	return strings.TrimSpace(line), nil
 }
 
-func fetchResourceMeta(ctx context.Context, client *http.Client, srvURL string) (*oauthResourceMeta, error) {
-	for _, wk := range oauthWellKnownURLs(srvURL, "oauth-protected-resource") {
-		req, err := http.NewRequestWithContext(ctx, "GET", wk, nil)
-		if err != nil {
-			continue
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
-		}
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			continue
-		}
-		var meta oauthResourceMeta
-		err = json.NewDecoder(resp.Body).Decode(&meta)
-		resp.Body.Close()
-		if err != nil {
-			continue
-		}
-		if len(meta.AuthorizationServers) > 0 {
-			return &meta, nil
-		}
+// authFlowHint describes the built-in auth type for ` + "`" + `auth --help` + "`" + `.
+func authFlowHint() string {
+	switch embeddedAuth.Type {
+	case "":
+		return " (OAuth unless --token is given)."
+	case "oauth2":
+		return " (OAuth)."
+	default:
+		return " (" + embeddedAuth.Type + ")."
 	}
-	return nil, fmt.Errorf("no resource metadata found")
 }
 
-func fetchAuthMeta(ctx context.Context, client *http.Client, authSrvURL string) (*oauthServerMeta, error) {
-	for _, wk := range oauthWellKnownURLs(authSrvURL, "oauth-authorization-server") {
-		req, err := http.NewRequestWithContext(ctx, "GET", wk, nil)
-		if err != nil {
-			continue
-		}
-		resp, err := client.Do(req)
-		if err != nil {
-			continue
-		}
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			continue
+func showAuthStatus() error {
+	credPath := auth.DefaultCredentialsPath()
+	if credPath == "" {
+		fmt.Println("Not authenticated (no credentials path)")
+		return nil
+	}
+	// Status only needs metadata, so secrets are not unlocked here.
+	var creds auth.CredentialsFile
+	if data, err := os.ReadFile(credPath); err == nil {
+		_ = json.Unmarshal(data, &creds)
+	}
+	profile := creds.ResolveProfile(serverURL, globalProfile)
+	s, ok := creds.Credential(serverURL, profile)
+	if !ok {
+		fmt.Printf("Not authenticated (profile %s)\n", profile)
+		switch embeddedAuth.Type {
+		case "", "none":
+		case "token_exchange", "exec":
+			fmt.Printf("Auth type: %s (built in, no sign-in needed)\n", embeddedAuth.Type)
+		default:
+			fmt.Printf("Auth type: %s. Run ` + "`" + `%s auth` + "`" + ` to sign in.\n", embeddedAuth.Type, os.Args[0])
 		}
-		var meta oauthServerMeta
-		err = json.NewDecoder(resp.Body).Decode(&meta)
-		resp.Body.Close()
-		if err != nil {
-			continue
+	} else {
+		authType := s.ResolveAuthType()
+		fmt.Printf("Server:    %s\n", serverURL)
+		fmt.Printf("Profile:   %s\n", profile)
+		fmt.Printf("Auth type: %s\n", authType)
+		if s.SecretStore != "" {
+			fmt.Printf("Secrets:   %s store\n", s.SecretStore)
 		}
-		if meta.AuthorizationEndpoint != "" && meta.TokenEndpoint != "" {
-			return &meta, nil
+		fmt.Printf("Status:    %s\n", credentialStatus(s))
+		if authType == "oauth2" && s.ExpiresAt != nil {
+			fmt.Printf("Expires:   %s\n", s.ExpiresAt.Format(time.RFC3339))
 		}
 	}
-	return nil, fmt.Errorf("no auth server metadata found at %s", authSrvURL)
-}
 
-func oauthWellKnownURLs(rawURL, suffix string) []string {
-	u, err := url.Parse(rawURL)
-	if err != nil {
+	profiles := creds.ServerProfiles(serverURL)
+	if len(profiles) == 0 {
 		return nil
 	}
-	path := strings.TrimRight(u.Path, "/")
-	base := fmt.Sprintf("%s://%s/.well-known/%s", u.Scheme, u.Host, suffix)
-	if path == "" {
-		return []string{base}
+	fmt.Println()
+	fmt.Println("Profiles:")
+	for _, name := range profiles {
+		sc, _ := creds.Credential(serverURL, name)
+		marker := " "
+		if name == profile {
+			marker = "*"
+		}
+		fmt.Printf("%s %-12s %-14s %s\n", marker, name, sc.ResolveAuthType(), credentialStatus(sc))
 	}
-	return []string{base + path, base}
+	return nil
 }
 
-func registerOAuthClient(ctx context.Context, client *http.Client, endpoint, redirectURI, scope string) (string, string, error) {
-	body, _ := json.Marshal(map[string]interface{}{
-		"client_name":                {{quote .CLIName}},
-		"redirect_uris":             []string{redirectURI},
-		"grant_types":               []string{"authorization_code", "refresh_token"},
-		"response_types":            []string{"code"},
-		"token_endpoint_auth_method": "none",
-		"scope":                      scope,
-	})
-	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
-	if err != nil {
-		return "", "", err
-	}
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", "", err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		b, _ := io.ReadAll(resp.Body)
-		return "", "", fmt.Errorf("registration failed (%d): %s", resp.StatusCode, string(b))
-	}
-	var reg struct {
-		ClientID     string ` + "`" + `json:"client_id"` + "`" + `
-		ClientSecret string ` + "`" + `json:"client_secret"` + "`" + `
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&reg); err != nil {
-		return "", "", err
-	}
-	if reg.ClientID == "" {
-		return "", "", fmt.Errorf("server returned empty client_id")
+func credentialStatus(sc auth.ServerCredential) string {
+	if sc.ResolveAuthType() == "oauth2" && auth.IsTokenExpired(sc) {
+		return "expired"
 	}
-	return reg.ClientID, reg.ClientSecret, nil
+	return "authenticated"
 }
 
-func generatePKCEVerifier() (string, error) {
-	const chars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~"
-	b := make([]byte, 64)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+// runOAuthFlow signs in with the authorization code flow and a local
+// callback server. With device set it uses the device authorization grant
+// (RFC 8628) instead, or has the user paste the redirect URL when the
+// server has no device endpoint.
+func runOAuthFlow(clientID, clientSecret, scope string, device bool) error {
+	timeout := 5 * time.Minute
+	if device {
+		timeout = 15 * time.Minute // typical device code lifetime
 	}
-	for i := range b {
-		b[i] = chars[int(b[i])%len(chars)]
-	}
-	return string(b), nil
-}
-
-func generatePKCEChallenge(verifier string) string {
-	h := sha256.Sum256([]byte(verifier))
-	return base64.RawURLEncoding.EncodeToString(h[:])
-}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-func generateOAuthState() (string, error) {
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+	tokens, err := auth.Authenticate(ctx, auth.FlowConfig{
+		ServerURL:           serverURL,
+		ClientID:            clientID,
+		ClientSecret:        clientSecret,
+		ResourceMetadataURL: embeddedAuth.ResourceMetadataURL,
+		AuthServerURL:       embeddedAuth.AuthServerURL,
+		Scope:               orDefault(scope, embeddedAuth.Scope),
+		ClientName:          {{quote .CLIName}},
+		AuthCommand:         os.Args[0] + " auth",
+		Device:              device,
+		Input:               stdinReader,
+		Verbose: func(format string, args ...interface{}) {
+			fmt.Printf(format+"\n", args...)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%w\n\nIf this server doesn't use OAuth, save a token instead:\n  %s auth --token <YOUR_TOKEN>", err, os.Args[0])
 	}
-	return hex.EncodeToString(b), nil
-}
 
-func openDefaultBrowser(url string) {
-	switch runtime.GOOS {
-	case "darwin":
-		exec.Command("open", url).Start()
-	case "linux":
-		exec.Command("xdg-open", url).Start()
-	case "windows":
-		exec.Command("cmd", "/c", "start", "", url).Start()
+	credPath := auth.DefaultCredentialsPath()
+	if credPath == "" {
+		return fmt.Errorf("could not determine credentials path")
 	}
+	err = auth.UpdateCredentials(credPath, func(creds *auth.CredentialsFile) error {
+		profile := creds.ResolveProfile(serverURL, globalProfile)
+		if err := auth.ValidateProfileName(profile); err != nil {
+			return err
+		}
+		auth.SetOAuthTokens(creds, serverURL, profile, *tokens)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("save credentials: %w", err)
+	}
+	fmt.Println("Authentication successful! Credentials saved.")
+	return nil
 }
 {{- end}}
 `
@@ -1368,6 +1438,26 @@ go 1.24
 require (
 	github.com/mark3labs/mcp-go v0.44.0
 	github.com/spf13/cobra v1.10.2
+{{- if .UsesRuntime}}
+	github.com/thellimist/clihub v0.0.0
+{{- end}}
+	gopkg.in/yaml.v3 v3.0.1
+)
+{{- if .UsesRuntime}}
+
+// The clihub runtime is copied into the project, pinned to the clihub that
+// generated it. Its dependencies are downloaded as usual.
+replace github.com/thellimist/clihub => ./third_party/clihub
+{{- end}}
+`
+
+// runtimeGoMod is the go.mod of the runtime copied into generated projects.
+const runtimeGoMod = `module github.com/thellimist/clihub
+
+go 1.24
+
+require (
+	golang.org/x/crypto v0.48.0
 	golang.org/x/oauth2 v0.35.0
 )
 `
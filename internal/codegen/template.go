@@ -14,6 +14,10 @@ var mainTemplate = template.Must(template.New("main.go").Funcs(template.FuncMap{
 	"funcName":    toFuncName,
 	"quote":       quoteStr,
 	"hasEnumDesc": hasEnumDesc,
+	"toolLong":    toolLongDescription,
+	"groupVar":    groupVarName,
+	"aliases":     toolAliases,
+	"toolUse":     toolUse,
 }).Parse(mainTemplateSource))
 
 var goModTemplate = template.Must(template.New("go.mod").Parse(goModTemplateSource))
@@ -131,3 +135,34 @@ func hasEnumDesc(desc string, enums []string) string {
 	}
 	return desc + " (" + strings.Join(enums, "|") + ")"
 }
+
+// toolLongDescription returns the description shown by a tool's --help,
+// followed by its MCP annotation hints when the server provided any.
+func toolLongDescription(t ToolDef) string {
+	hints := t.Hints()
+	if len(hints) == 0 {
+		return t.Description
+	}
+	return t.Description + "\n\nHints: " + strings.Join(hints, ", ")
+}
+
+// groupVarName returns the Go variable holding a group command.
+func groupVarName(group string) string {
+	return "group" + toFuncName(group)
+}
+
+// toolAliases returns the cobra aliases for a tool command: the original MCP
+// tool name followed by any aliases from the manifest.
+func toolAliases(t ToolDef) []string {
+	return append([]string{t.Name}, t.Aliases...)
+}
+
+// toolUse returns the cobra Use line for a tool command, naming its
+// positional arguments after their flags: "get-issue <issue-id>".
+func toolUse(t ToolDef) string {
+	use := t.CommandName
+	for _, p := range t.Positional {
+		use += " <" + p + ">"
+	}
+	return use
+}
@@ -7,12 +7,24 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sync"
 	"time"
 )
 
+// buildFlags make builds reproducible: no local paths, no symbol tables, no
+// VCS stamp and an empty build ID, so the same inputs give the same bytes on
+// any machine.
+var buildFlags = []string{"-trimpath", "-buildvcs=false", "-ldflags=-s -w -buildid="}
+
 // Compile runs go build for the given project directory and target platform.
 // Returns the path to the compiled binary.
 func Compile(projectDir, outputDir, name string, p Platform, multiPlatform bool) (string, error) {
+	return compile(context.Background(), projectDir, outputDir, name, p, multiPlatform, nil)
+}
+
+// compile builds one platform. env is added to the environment of go build,
+// ahead of the target settings.
+func compile(ctx context.Context, projectDir, outputDir, name string, p Platform, multiPlatform bool, env []string) (string, error) {
 	binaryName := BinaryName(name, p, multiPlatform)
 
 	// Make output path absolute so go build writes to the right place
@@ -26,12 +38,14 @@ func Compile(projectDir, outputDir, name string, p Platform, multiPlatform bool)
 		return "", fmt.Errorf("create output dir: %w", err)
 	}
 
-	cmd := exec.Command("go", "build", "-o", binaryPath, ".")
+	args := append([]string{"build"}, buildFlags...)
+	cmd := exec.CommandContext(ctx, "go", append(args, "-o", binaryPath, ".")...)
 	cmd.Dir = projectDir
-	cmd.Env = append(os.Environ(),
+	cmd.Env = append(append(os.Environ(), env...),
 		"CGO_ENABLED=0",
 		"GOOS="+p.GOOS,
 		"GOARCH="+p.GOARCH,
+		"GOARM="+p.GOARM,
 	)
 
 	if out, err := cmd.CombinedOutput(); err != nil {
@@ -41,6 +55,99 @@ func Compile(projectDir, outputDir, name string, p Platform, multiPlatform bool)
 	return binaryPath, nil
 }
 
+// Progress reports a platform build starting (Done is false) or finishing.
+type Progress struct {
+	Platform Platform
+	Done     bool
+	Elapsed  time.Duration
+	Err      error
+}
+
+// CompileAll builds every platform, running at most jobs builds at once
+// (jobs <= 0 means one per CPU). progress, if set, is called as builds start
+// and finish, one call at a time. The first failure stops the remaining
+// builds and is returned. Binary paths are returned in platform order. env,
+// such as the entries from CacheEnv, is added to the environment of each build.
+func CompileAll(projectDir, outputDir, name string, platforms []Platform, jobs int, env []string, progress func(Progress)) ([]string, error) {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	multiPlatform := len(platforms) > 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	report := func(p Progress) {
+		if progress != nil {
+			progress(p)
+		}
+	}
+
+	binaries := make([]string, len(platforms))
+	sem := make(chan struct{}, jobs)
+	for i, p := range platforms {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			mu.Lock()
+			if ctx.Err() != nil {
+				mu.Unlock()
+				return
+			}
+			report(Progress{Platform: p})
+			mu.Unlock()
+
+			start := time.Now()
+			path, err := compile(ctx, projectDir, outputDir, name, p, multiPlatform, env)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil && ctx.Err() != nil {
+				// Stopped because another platform failed.
+				return
+			}
+			report(Progress{Platform: p, Done: true, Elapsed: time.Since(start), Err: err})
+			if err != nil {
+				firstErr = err
+				cancel()
+				return
+			}
+			binaries[i] = path
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return binaries, nil
+}
+
+// CacheEnv returns environment entries that keep the Go build cache and
+// module cache under dir, so separate generations reuse compiled packages
+// and downloaded modules. An empty dir returns nil, leaving Go's defaults.
+func CacheEnv(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve cache dir: %w", err)
+	}
+	return []string{
+		"GOCACHE=" + filepath.Join(abs, "go-build"),
+		"GOMODCACHE=" + filepath.Join(abs, "mod"),
+	}, nil
+}
+
 // SmokeTest runs the compiled binary with --help and verifies exit code 0.
 func SmokeTest(binaryPath string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
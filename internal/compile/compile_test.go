@@ -0,0 +1,127 @@
+package compile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeProject(t *testing.T, mainGo string) string {
+	t.Helper()
+	projectDir := t.TempDir()
+	files := map[string]string{
+		"go.mod":  "module repro\n\ngo 1.24\n",
+		"main.go": mainGo,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return projectDir
+}
+
+func TestCompileReproducible(t *testing.T) {
+	host := Platform{}
+	host.GOOS, host.GOARCH = CurrentPlatform()
+
+	// Same sources in two different directories must give identical binaries.
+	var builds [][]byte
+	for i := 0; i < 2; i++ {
+		projectDir := writeProject(t, "package main\n\nfunc main() { println(\"hello\") }\n")
+		binaryPath, err := Compile(projectDir, t.TempDir(), "repro", host, false)
+		if err != nil {
+			t.Fatalf("Compile: %v", err)
+		}
+		data, err := os.ReadFile(binaryPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte(projectDir)) {
+			t.Errorf("binary embeds the project path %s", projectDir)
+		}
+		builds = append(builds, data)
+	}
+	if !bytes.Equal(builds[0], builds[1]) {
+		t.Error("builds of the same sources differ")
+	}
+}
+
+func TestCompileAll(t *testing.T) {
+	projectDir := writeProject(t, "package main\n\nfunc main() {}\n")
+	outputDir := t.TempDir()
+	platforms := []Platform{{GOOS: "linux", GOARCH: "amd64"}, {GOOS: "linux", GOARCH: "arm64"}, {GOOS: "windows", GOARCH: "amd64"}}
+
+	started := map[string]bool{}
+	finished := map[string]bool{}
+	binaries, err := CompileAll(projectDir, outputDir, "repro", platforms, 2, nil, func(p Progress) {
+		if p.Done {
+			if p.Err != nil {
+				t.Errorf("%s failed: %v", p.Platform, p.Err)
+			}
+			finished[p.Platform.String()] = true
+		} else {
+			started[p.Platform.String()] = true
+		}
+	})
+	if err != nil {
+		t.Fatalf("CompileAll: %v", err)
+	}
+	if len(started) != 3 || len(finished) != 3 {
+		t.Errorf("progress: started %v, finished %v", started, finished)
+	}
+
+	want := []string{"repro-linux-amd64", "repro-linux-arm64", "repro-windows-amd64.exe"}
+	for i, b := range binaries {
+		if filepath.Base(b) != want[i] {
+			t.Errorf("binaries[%d] = %s, want %s", i, filepath.Base(b), want[i])
+		}
+		if _, err := os.Stat(b); err != nil {
+			t.Errorf("missing binary: %v", err)
+		}
+	}
+}
+
+func TestCompileAll_Failure(t *testing.T) {
+	projectDir := writeProject(t, "package main\n\nfunc main() { undefined() }\n")
+	platforms := []Platform{{GOOS: "linux", GOARCH: "amd64"}, {GOOS: "darwin", GOARCH: "arm64"}}
+
+	_, err := CompileAll(projectDir, t.TempDir(), "repro", platforms, 0, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "go build failed") {
+		t.Fatalf("expected build failure, got %v", err)
+	}
+}
+
+func TestCompileAll_Env(t *testing.T) {
+	// tagged() only exists with the tag, so the build needs env to reach go build.
+	projectDir := writeProject(t, "package main\n\nfunc main() { tagged() }\n")
+	tagged := "//go:build clihubenv\n\npackage main\n\nfunc tagged() {}\n"
+	if err := os.WriteFile(filepath.Join(projectDir, "tagged.go"), []byte(tagged), 0644); err != nil {
+		t.Fatal(err)
+	}
+	host := Platform{}
+	host.GOOS, host.GOARCH = CurrentPlatform()
+
+	if _, err := CompileAll(projectDir, t.TempDir(), "repro", []Platform{host}, 1, []string{"GOFLAGS=-tags=clihubenv"}, nil); err != nil {
+		t.Fatalf("CompileAll: %v", err)
+	}
+}
+
+func TestCacheEnv(t *testing.T) {
+	env, err := CacheEnv("")
+	if err != nil || env != nil {
+		t.Fatalf("CacheEnv(\"\") = %v, %v", env, err)
+	}
+
+	dir := t.TempDir()
+	env, err = CacheEnv(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"GOCACHE=" + filepath.Join(dir, "go-build"), "GOMODCACHE=" + filepath.Join(dir, "mod")}
+	if strings.Join(env, " ") != strings.Join(want, " ") {
+		t.Errorf("CacheEnv = %v, want %v", env, want)
+	}
+}
@@ -2,40 +2,77 @@ package compile
 
 import (
 	"fmt"
+	"os/exec"
 	"strings"
+	"sync"
+
+	"github.com/thellimist/clihub/runtime/update"
 )
 
-// Platform represents a GOOS/GOARCH target.
+// Platform represents a GOOS/GOARCH target. GOARM pins the ARM version for
+// GOARCH=arm ("linux/arm/v7"); empty means Go's default.
 type Platform struct {
 	GOOS   string
 	GOARCH string
+	GOARM  string
 }
 
 func (p Platform) String() string {
+	if p.GOARM != "" {
+		return p.GOOS + "/" + p.GOARCH + "/v" + p.GOARM
+	}
 	return p.GOOS + "/" + p.GOARCH
 }
 
-// ValidPlatforms are the 6 standard cross-compilation targets.
+// ValidPlatforms are the 6 standard cross-compilation targets that 'all'
+// expands to. Any other target the Go toolchain supports can be named
+// explicitly.
 var ValidPlatforms = []Platform{
-	{"linux", "amd64"},
-	{"linux", "arm64"},
-	{"darwin", "amd64"},
-	{"darwin", "arm64"},
-	{"windows", "amd64"},
-	{"windows", "arm64"},
+	{GOOS: "linux", GOARCH: "amd64"},
+	{GOOS: "linux", GOARCH: "arm64"},
+	{GOOS: "darwin", GOARCH: "amd64"},
+	{GOOS: "darwin", GOARCH: "arm64"},
+	{GOOS: "windows", GOARCH: "amd64"},
+	{GOOS: "windows", GOARCH: "arm64"},
 }
 
-var validPlatformSet map[string]bool
+// validGOARM are the ARM versions accepted as a /vN suffix on arm targets.
+var validGOARM = map[string]bool{"5": true, "6": true, "7": true}
 
-func init() {
-	validPlatformSet = make(map[string]bool, len(ValidPlatforms))
-	for _, p := range ValidPlatforms {
-		validPlatformSet[p.String()] = true
+// distList lists the GOOS/GOARCH pairs the installed toolchain can build.
+var distList = func() ([]string, error) {
+	out, err := exec.Command("go", "tool", "dist", "list").Output()
+	if err != nil {
+		return nil, fmt.Errorf("go tool dist list: %w", err)
 	}
+	return strings.Fields(string(out)), nil
+}
+
+var (
+	supportedOnce sync.Once
+	supportedSet  map[string]bool
+)
+
+// supportedPlatforms returns the GOOS/GOARCH pairs accepted by --platform,
+// falling back to ValidPlatforms when the toolchain can't be queried.
+func supportedPlatforms() map[string]bool {
+	supportedOnce.Do(func() {
+		supportedSet = make(map[string]bool)
+		if pairs, err := distList(); err == nil {
+			for _, pair := range pairs {
+				supportedSet[pair] = true
+			}
+		}
+		for _, p := range ValidPlatforms {
+			supportedSet[p.String()] = true
+		}
+	})
+	return supportedSet
 }
 
 // ParsePlatforms parses the --platform flag value into a list of platforms.
-// Handles "all" shorthand and validates each platform.
+// Handles "all" shorthand and validates each platform against the targets
+// reported by 'go tool dist list'.
 func ParsePlatforms(platformFlag string) ([]Platform, error) {
 	platformFlag = strings.TrimSpace(platformFlag)
 	if platformFlag == "all" {
@@ -54,17 +91,17 @@ func ParsePlatforms(platformFlag string) ([]Platform, error) {
 			continue
 		}
 
-		if !validPlatformSet[part] {
-			return nil, fmt.Errorf("invalid platform '%s'. Valid targets: %s", part, validTargetsList())
+		p, err := parsePlatform(part)
+		if err != nil {
+			return nil, err
 		}
 
-		if seen[part] {
+		if seen[p.String()] {
 			continue
 		}
-		seen[part] = true
+		seen[p.String()] = true
 
-		s := strings.SplitN(part, "/", 2)
-		platforms = append(platforms, Platform{GOOS: s[0], GOARCH: s[1]})
+		platforms = append(platforms, p)
 	}
 
 	if len(platforms) == 0 {
@@ -74,6 +111,24 @@ func ParsePlatforms(platformFlag string) ([]Platform, error) {
 	return platforms, nil
 }
 
+// parsePlatform parses one GOOS/GOARCH or GOOS/arm/vN target.
+func parsePlatform(s string) (Platform, error) {
+	fields := strings.Split(s, "/")
+	if len(fields) < 2 || len(fields) > 3 || !supportedPlatforms()[fields[0]+"/"+fields[1]] {
+		return Platform{}, fmt.Errorf("invalid platform '%s'. Standard targets: %s (run 'go tool dist list' for all)", s, validTargetsList())
+	}
+
+	p := Platform{GOOS: fields[0], GOARCH: fields[1]}
+	if len(fields) == 3 {
+		v := strings.TrimPrefix(fields[2], "v")
+		if p.GOARCH != "arm" || !validGOARM[v] {
+			return Platform{}, fmt.Errorf("invalid platform '%s'. Only arm targets take a version: v5, v6 or v7", s)
+		}
+		p.GOARM = v
+	}
+	return p, nil
+}
+
 func validTargetsList() string {
 	names := make([]string, len(ValidPlatforms))
 	for i, p := range ValidPlatforms {
@@ -82,20 +137,28 @@ func validTargetsList() string {
 	return strings.Join(names, ", ")
 }
 
-// BinaryName returns the output binary name for a platform.
-// Single platform: just <name> (or <name>.exe on windows).
-// Multi-platform: <name>-<os>-<arch> (with .exe for windows).
+// BinaryName returns the output binary name for a platform. The generated
+// CLI's self-update looks for the same names.
+// Single platform: just <name> (or <name>.exe on windows, <name>.wasm on wasip1).
+// Multi-platform: <name>-<os>-<arch>[v<arm>] with the same extensions.
 func BinaryName(name string, p Platform, multiPlatform bool) string {
-	if multiPlatform {
-		result := fmt.Sprintf("%s-%s-%s", name, p.GOOS, p.GOARCH)
-		if p.GOOS == "windows" {
-			result += ".exe"
-		}
-		return result
-	}
+	return update.BinaryName(name, p.GOOS, p.GOARCH, p.GOARM, multiPlatform)
+}
+
+// IsHost reports whether binaries for p run natively on this machine, and so
+// can be smoke tested.
+func (p Platform) IsHost() bool {
+	goos, goarch := CurrentPlatform()
+	return p.runsOn(goos, goarch, update.HostGOARM())
+}
 
-	if p.GOOS == "windows" {
-		return name + ".exe"
+// runsOn reports whether binaries for p run on goos/goarch. Build info and
+// SBOMs record a GOARM even for builds that left it at Go's default, so an
+// ARM version up to the host's own (or any, when it is unknown) runs there,
+// the same way self-update accepts both names for an ARM binary.
+func (p Platform) runsOn(goos, goarch, goarm string) bool {
+	if p.GOOS != goos || p.GOARCH != goarch {
+		return false
 	}
-	return name
+	return p.GOARM == "" || goarm == "" || p.GOARM <= goarm
 }
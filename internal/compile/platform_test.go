@@ -1,7 +1,10 @@
 package compile
 
 import (
+	"debug/buildinfo"
+	"errors"
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -18,6 +21,11 @@ func TestParsePlatforms(t *testing.T) {
 		{"with whitespace", " linux/amd64 , darwin/arm64 ", 2, ""},
 		{"dedup", "linux/amd64,linux/amd64", 1, ""},
 		{"invalid platform", "foo/bar", 0, "invalid platform 'foo/bar'"},
+		{"extended targets", "linux/386,linux/riscv64,freebsd/amd64,wasip1/wasm", 4, ""},
+		{"arm version", "linux/arm,linux/arm/v7,linux/arm/v6", 3, ""},
+		{"arm version dedup", "linux/arm/v7,linux/arm/7", 1, ""},
+		{"bad arm version", "linux/arm/v9", 0, "Only arm targets take a version"},
+		{"version on non-arm", "linux/amd64/v7", 0, "Only arm targets take a version"},
 		{"empty string", "", 0, "no platforms specified"},
 	}
 
@@ -65,6 +73,23 @@ func TestParsePlatformsAll(t *testing.T) {
 	}
 }
 
+func TestParsePlatformsWithoutToolchain(t *testing.T) {
+	orig := distList
+	distList = func() ([]string, error) { return nil, errors.New("no go") }
+	supportedOnce = sync.Once{}
+	t.Cleanup(func() {
+		distList = orig
+		supportedOnce = sync.Once{}
+	})
+
+	if _, err := ParsePlatforms("darwin/arm64"); err != nil {
+		t.Errorf("standard target rejected: %v", err)
+	}
+	if _, err := ParsePlatforms("linux/riscv64"); err == nil {
+		t.Error("expected linux/riscv64 to be rejected without go tool dist list")
+	}
+}
+
 func TestBinaryName(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -73,13 +98,16 @@ func TestBinaryName(t *testing.T) {
 		multi    bool
 		want     string
 	}{
-		{"single linux", "linear", Platform{"linux", "amd64"}, false, "linear"},
-		{"single darwin", "linear", Platform{"darwin", "arm64"}, false, "linear"},
-		{"single windows", "linear", Platform{"windows", "amd64"}, false, "linear.exe"},
-		{"multi linux amd64", "linear", Platform{"linux", "amd64"}, true, "linear-linux-amd64"},
-		{"multi darwin arm64", "linear", Platform{"darwin", "arm64"}, true, "linear-darwin-arm64"},
-		{"multi windows amd64", "linear", Platform{"windows", "amd64"}, true, "linear-windows-amd64.exe"},
-		{"multi windows arm64", "linear", Platform{"windows", "arm64"}, true, "linear-windows-arm64.exe"},
+		{"single linux", "linear", Platform{GOOS: "linux", GOARCH: "amd64"}, false, "linear"},
+		{"single darwin", "linear", Platform{GOOS: "darwin", GOARCH: "arm64"}, false, "linear"},
+		{"single windows", "linear", Platform{GOOS: "windows", GOARCH: "amd64"}, false, "linear.exe"},
+		{"multi linux amd64", "linear", Platform{GOOS: "linux", GOARCH: "amd64"}, true, "linear-linux-amd64"},
+		{"multi darwin arm64", "linear", Platform{GOOS: "darwin", GOARCH: "arm64"}, true, "linear-darwin-arm64"},
+		{"multi windows amd64", "linear", Platform{GOOS: "windows", GOARCH: "amd64"}, true, "linear-windows-amd64.exe"},
+		{"multi windows arm64", "linear", Platform{GOOS: "windows", GOARCH: "arm64"}, true, "linear-windows-arm64.exe"},
+		{"multi linux armv7", "linear", Platform{GOOS: "linux", GOARCH: "arm", GOARM: "7"}, true, "linear-linux-armv7"},
+		{"single wasip1", "linear", Platform{GOOS: "wasip1", GOARCH: "wasm"}, false, "linear.wasm"},
+		{"multi wasip1", "linear", Platform{GOOS: "wasip1", GOARCH: "wasm"}, true, "linear-wasip1-wasm.wasm"},
 	}
 
 	for _, tt := range tests {
@@ -91,3 +119,39 @@ func TestBinaryName(t *testing.T) {
 		})
 	}
 }
+
+func TestRunsOn_BareARM(t *testing.T) {
+	// A linux/arm build without a pinned version records Go's default GOARM,
+	// which is how it reads back from its SBOM.
+	projectDir := writeProject(t, "package main\n\nfunc main() {}\n")
+	bin, err := Compile(projectDir, t.TempDir(), "armtest", Platform{GOOS: "linux", GOARCH: "arm"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := buildinfo.ReadFile(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := Platform{GOOS: "linux", GOARCH: "arm"}
+	for _, s := range info.Settings {
+		if s.Key == "GOARM" {
+			p.GOARM = s.Value
+		}
+	}
+	if p.GOARM == "" {
+		t.Fatal("build info records no GOARM")
+	}
+
+	if !p.runsOn("linux", "arm", p.GOARM) {
+		t.Errorf("%s does not run on an ARM v%s host", p, p.GOARM)
+	}
+	if !p.runsOn("linux", "arm", "") {
+		t.Errorf("%s does not run on an ARM host of unknown version", p)
+	}
+	if p.runsOn("linux", "arm", "5") {
+		t.Errorf("%s runs on an ARM v5 host", p)
+	}
+	if p.runsOn("linux", "amd64", "") {
+		t.Errorf("%s runs on linux/amd64", p)
+	}
+}
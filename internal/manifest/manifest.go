@@ -0,0 +1,124 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/thellimist/clihub/internal/nameutil"
+	"github.com/thellimist/clihub/internal/toolfilter"
+)
+
+// Manifest lets teams curate the generated command layout without changing
+// the MCP server. It is read from a JSON file passed to --manifest:
+//
+//	{
+//	  "tools": {
+//	    "github_issue_create": {"command": "issue new", "aliases": ["ni"], "args": ["title"]}
+//	  }
+//	}
+type Manifest struct {
+	Tools map[string]ToolOverride `json:"tools"`
+}
+
+// ToolOverride customizes the command generated for a single tool.
+type ToolOverride struct {
+	// Command is the command path: "name" for a top-level command or
+	// "group name" to nest it under a group command.
+	Command string `json:"command,omitempty"`
+	// Aliases are extra names for the command. The original MCP tool name is
+	// always kept as an alias.
+	Aliases []string `json:"aliases,omitempty"`
+	// Args lists the parameters (property or flag names) accepted as
+	// positional arguments, in order. nil keeps the generator's heuristic;
+	// an explicit empty list disables positional arguments.
+	Args []string `json:"args,omitempty"`
+}
+
+// Load reads and validates a manifest file.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse manifest %s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+func (m *Manifest) validate() error {
+	for _, tool := range m.toolNames() {
+		o := m.Tools[tool]
+		if o.Command != "" {
+			if _, err := o.Path(); err != nil {
+				return fmt.Errorf("tool %q: %w", tool, err)
+			}
+		}
+		for _, a := range o.Aliases {
+			if !isCommandWord(a) {
+				return fmt.Errorf("tool %q: invalid alias %q (use lowercase letters, digits and dashes)", tool, a)
+			}
+		}
+	}
+	return nil
+}
+
+// Path parses Command into a command path.
+func (o ToolOverride) Path() (nameutil.CommandPath, error) {
+	words := strings.Fields(o.Command)
+	for _, w := range words {
+		if !isCommandWord(w) {
+			return nameutil.CommandPath{}, fmt.Errorf("invalid command %q (use lowercase letters, digits and dashes)", o.Command)
+		}
+	}
+	switch len(words) {
+	case 1:
+		return nameutil.CommandPath{Name: words[0]}, nil
+	case 2:
+		return nameutil.CommandPath{Group: words[0], Name: words[1]}, nil
+	default:
+		return nameutil.CommandPath{}, fmt.Errorf("invalid command %q (expected \"name\" or \"group name\")", o.Command)
+	}
+}
+
+// CheckTools returns an error if the manifest refers to a tool the server
+// does not expose, which usually means a typo.
+func (m *Manifest) CheckTools(available []string) error {
+	known := make(map[string]bool, len(available))
+	for _, name := range available {
+		known[name] = true
+	}
+	for _, tool := range m.toolNames() {
+		if known[tool] {
+			continue
+		}
+		msg := fmt.Sprintf("manifest refers to tool '%s' which is not on the server", tool)
+		if s := toolfilter.SuggestTool(tool, available); s != "" {
+			msg += fmt.Sprintf(". Did you mean '%s'?", s)
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
+// toolNames returns the manifest's tool names in sorted order so errors are
+// deterministic.
+func (m *Manifest) toolNames() []string {
+	names := make([]string, 0, len(m.Tools))
+	for name := range m.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isCommandWord(s string) bool {
+	return s != "" && nameutil.Slugify(s) == s
+}
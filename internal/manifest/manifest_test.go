@@ -0,0 +1,89 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/thellimist/clihub/internal/nameutil"
+)
+
+func writeManifest(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "clihub.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeManifest(t, `{"tools":{"github_issue_create":{"command":"issue new","aliases":["ni"]}}}`)
+	m, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	o := m.Tools["github_issue_create"]
+	p, err := o.Path()
+	if err != nil {
+		t.Fatalf("Path returned error: %v", err)
+	}
+	if p != (nameutil.CommandPath{Group: "issue", Name: "new"}) {
+		t.Errorf("Path() = %+v, want issue/new", p)
+	}
+	if len(o.Aliases) != 1 || o.Aliases[0] != "ni" {
+		t.Errorf("Aliases = %v, want [ni]", o.Aliases)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"bad json", `{"tools":`, "parse manifest"},
+		{"too many words", `{"tools":{"a":{"command":"x y z"}}}`, "expected \"name\" or \"group name\""},
+		{"uppercase command", `{"tools":{"a":{"command":"Issue"}}}`, "invalid command"},
+		{"bad alias", `{"tools":{"a":{"aliases":["new issue"]}}}`, "invalid alias"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeManifest(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCheckTools(t *testing.T) {
+	m := &Manifest{Tools: map[string]ToolOverride{"list_isues": {Command: "issues"}}}
+	err := m.CheckTools([]string{"list_issues", "create_issue"})
+	if err == nil {
+		t.Fatal("expected error for unknown tool")
+	}
+	if !strings.Contains(err.Error(), "Did you mean 'list_issues'?") {
+		t.Errorf("error should suggest list_issues, got: %v", err)
+	}
+	if err := m.CheckTools([]string{"list_isues"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadArgs(t *testing.T) {
+	m, err := Load(writeManifest(t, `{"tools":{"a":{"args":["owner","repo"]},"b":{"args":[]},"c":{}}}`))
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if got := m.Tools["a"].Args; len(got) != 2 || got[0] != "owner" || got[1] != "repo" {
+		t.Errorf("a.Args = %v, want [owner repo]", got)
+	}
+	if got := m.Tools["b"].Args; got == nil || len(got) != 0 {
+		t.Errorf("b.Args = %#v, want explicit empty list", got)
+	}
+	if got := m.Tools["c"].Args; got != nil {
+		t.Errorf("c.Args = %#v, want nil", got)
+	}
+}
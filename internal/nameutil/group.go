@@ -0,0 +1,84 @@
+package nameutil
+
+import "strings"
+
+// CommandPath is the place of a tool command in the generated CLI.
+type CommandPath struct {
+	Group string // parent command, empty for top-level commands
+	Name  string // leaf command name
+}
+
+// minGroupSize is the number of tools that must share a leading segment
+// before they are moved under a common parent command.
+const minGroupSize = 2
+
+// ReservedCommands are top-level command names the generated CLI defines
+// itself, so no tool or group may take them.
+var ReservedCommands = map[string]bool{
+	"auth":        true,
+	"help":        true,
+	"completion":  true,
+	"self-update": true,
+}
+
+// GroupCommands derives two-level command paths from kebab-case command
+// names. Leading segments shared by every command (such as a "github-"
+// prefix) are stripped first, much like InferName strips generic host
+// segments, and commands that then share a leading segment are grouped
+// under it:
+//
+//	github-issue-create, github-issue-list, github-search
+//	→ issue create, issue list, search
+//
+// The result is keyed by the input name.
+func GroupCommands(names []string) map[string]CommandPath {
+	segs := make([][]string, len(names))
+	for i, n := range names {
+		segs[i] = strings.Split(n, "-")
+	}
+
+	// Strip segments shared by every command while each keeps one segment.
+	for len(segs) > 1 {
+		first := segs[0][0]
+		shared := true
+		for _, s := range segs {
+			if len(s) < 2 || s[0] != first {
+				shared = false
+				break
+			}
+		}
+		if !shared {
+			break
+		}
+		for i := range segs {
+			segs[i] = segs[i][1:]
+		}
+	}
+
+	// Count candidate groups and note names that stay single-segment, since a
+	// group cannot share its name with a top-level command.
+	counts := make(map[string]int)
+	topLevel := make(map[string]bool)
+	for _, s := range segs {
+		if len(s) >= 2 {
+			counts[s[0]]++
+		} else {
+			topLevel[s[0]] = true
+		}
+	}
+
+	result := make(map[string]CommandPath, len(names))
+	for i, s := range segs {
+		group := s[0]
+		if len(s) >= 2 && counts[group] >= minGroupSize && !topLevel[group] && !ReservedCommands[group] {
+			result[names[i]] = CommandPath{Group: group, Name: strings.Join(s[1:], "-")}
+			continue
+		}
+		name := strings.Join(s, "-")
+		if ReservedCommands[name] {
+			name = names[i]
+		}
+		result[names[i]] = CommandPath{Name: name}
+	}
+	return result
+}
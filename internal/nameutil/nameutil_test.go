@@ -369,3 +369,67 @@ func sliceEqual(a, b []string) bool {
 	}
 	return true
 }
+
+func TestGroupCommands(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []string
+		want  map[string]CommandPath
+	}{
+		{
+			name:  "strips shared prefix and groups",
+			input: []string{"github-issue-create", "github-issue-list", "github-search"},
+			want: map[string]CommandPath{
+				"github-issue-create": {Group: "issue", Name: "create"},
+				"github-issue-list":   {Group: "issue", Name: "list"},
+				"github-search":       {Name: "search"},
+			},
+		},
+		{
+			name:  "single member prefixes stay flat",
+			input: []string{"list-issues", "list-teams", "create-issue"},
+			want: map[string]CommandPath{
+				"list-issues":  {Group: "list", Name: "issues"},
+				"list-teams":   {Group: "list", Name: "teams"},
+				"create-issue": {Name: "create-issue"},
+			},
+		},
+		{
+			name:  "group name taken by top-level command",
+			input: []string{"issue", "issue-create", "issue-list"},
+			want: map[string]CommandPath{
+				"issue":        {Name: "issue"},
+				"issue-create": {Name: "issue-create"},
+				"issue-list":   {Name: "issue-list"},
+			},
+		},
+		{
+			name:  "reserved names are not used",
+			input: []string{"svc-auth", "svc-help-search", "svc-help-list"},
+			want: map[string]CommandPath{
+				"svc-auth":        {Name: "svc-auth"},
+				"svc-help-search": {Name: "help-search"},
+				"svc-help-list":   {Name: "help-list"},
+			},
+		},
+		{
+			name:  "single tool keeps its name",
+			input: []string{"github-search"},
+			want:  map[string]CommandPath{"github-search": {Name: "github-search"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := GroupCommands(tt.input)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d paths, want %d: %v", len(got), len(tt.want), got)
+			}
+			for name, want := range tt.want {
+				if got[name] != want {
+					t.Errorf("GroupCommands()[%q] = %+v, want %+v", name, got[name], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package packaging
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+// ArchiveName returns the archive file name for a platform:
+// <name>_<version>_<os>_<arch>.tar.gz, or .zip for windows.
+func (s *Spec) ArchiveName(b Binary) string {
+	ext := ".tar.gz"
+	if b.Platform.GOOS == "windows" {
+		ext = ".zip"
+	}
+	return s.Name + "_" + s.Version + "_" + platformSuffix(b.Platform) + ext
+}
+
+// WriteArchives writes one archive per binary into outDir, holding the binary,
+// its shell completions and man pages. Returns the archive paths in binary
+// order.
+func WriteArchives(s *Spec, outDir string) ([]string, error) {
+	var paths []string
+	for _, b := range s.Binaries {
+		files, err := s.archiveFiles(b)
+		if err != nil {
+			return nil, err
+		}
+
+		var data []byte
+		if b.Platform.GOOS == "windows" {
+			data, err = zipFiles(files)
+		} else {
+			data, err = tarGzFiles(files)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(outDir, s.ArchiveName(b))
+		if err := writeFile(path, data); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// tarFiles writes files, preceded by their directories, as an uncompressed
+// tar. prefix is prepended to every name ("./" for deb members).
+func tarFiles(files []file, prefix string) ([]byte, error) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, dir := range dirsOf(files) {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeDir,
+			Name:     prefix + dir + "/",
+			Mode:     0755,
+			ModTime:  modTime,
+			Format:   tar.FormatUSTAR,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range files {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     prefix + f.path,
+			Mode:     f.mode,
+			Size:     int64(len(f.data)),
+			ModTime:  modTime,
+			Format:   tar.FormatUSTAR,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func tarGzFiles(files []file) ([]byte, error) {
+	data, err := tarFiles(files, "")
+	if err != nil {
+		return nil, err
+	}
+	return gzipBytes(data)
+}
+
+func zipFiles(files []file) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		hdr := &zip.FileHeader{
+			Name:     f.path,
+			Method:   zip.Deflate,
+			Modified: modTime,
+		}
+		hdr.SetMode(os.FileMode(f.mode))
+		w, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,133 @@
+package packaging
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/thellimist/clihub/internal/compile"
+)
+
+// debArch maps a Go platform to its Debian architecture, or "" when Debian
+// has no port for it.
+func debArch(p compile.Platform) string {
+	if p.GOOS != "linux" {
+		return ""
+	}
+	switch p.GOARCH {
+	case "amd64", "arm64", "riscv64", "s390x", "loong64":
+		return p.GOARCH
+	case "386":
+		return "i386"
+	case "ppc64le":
+		return "ppc64el"
+	case "mips64le":
+		return "mips64el"
+	case "arm":
+		if p.GOARM == "" || p.GOARM == "7" {
+			return "armhf"
+		}
+		return "armel"
+	}
+	return ""
+}
+
+// WriteDebs writes a .deb for every linux binary Debian has an architecture
+// for, installing the binary to /usr/bin with completions and man pages.
+func WriteDebs(s *Spec, outDir string) ([]string, error) {
+	var paths []string
+	for _, b := range s.Binaries {
+		arch := debArch(b.Platform)
+		if arch == "" {
+			continue
+		}
+		data, err := s.deb(b, arch)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(outDir, fmt.Sprintf("%s_%s_%s.deb", s.Name, s.Version, arch))
+		if err := writeFile(path, data); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+func (s *Spec) deb(b Binary, arch string) ([]byte, error) {
+	files, err := s.systemFiles(b)
+	if err != nil {
+		return nil, err
+	}
+
+	dataTar, err := tarFiles(files, "./")
+	if err != nil {
+		return nil, err
+	}
+	dataGz, err := gzipBytes(dataTar)
+	if err != nil {
+		return nil, err
+	}
+
+	var size int
+	var md5sums strings.Builder
+	for _, f := range files {
+		size += len(f.data)
+		sum := md5.Sum(f.data)
+		fmt.Fprintf(&md5sums, "%s  %s\n", hex.EncodeToString(sum[:]), f.path)
+	}
+
+	var control strings.Builder
+	fmt.Fprintf(&control, "Package: %s\n", s.Name)
+	fmt.Fprintf(&control, "Version: %s\n", s.Version)
+	fmt.Fprintf(&control, "Architecture: %s\n", arch)
+	fmt.Fprintf(&control, "Maintainer: %s\n", s.maintainer())
+	fmt.Fprintf(&control, "Installed-Size: %d\n", (size+1023)/1024)
+	fmt.Fprintf(&control, "Section: utils\n")
+	fmt.Fprintf(&control, "Priority: optional\n")
+	if s.Homepage != "" {
+		fmt.Fprintf(&control, "Homepage: %s\n", s.Homepage)
+	}
+	fmt.Fprintf(&control, "Description: %s\n", s.description())
+
+	controlTar, err := tarFiles([]file{
+		{path: "control", mode: 0644, data: []byte(control.String())},
+		{path: "md5sums", mode: 0644, data: []byte(md5sums.String())},
+	}, "./")
+	if err != nil {
+		return nil, err
+	}
+	controlGz, err := gzipBytes(controlTar)
+	if err != nil {
+		return nil, err
+	}
+
+	// A .deb is an ar archive of exactly these three members, in this order.
+	var buf bytes.Buffer
+	buf.WriteString("!<arch>\n")
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{
+		{"debian-binary", []byte("2.0\n")},
+		{"control.tar.gz", controlGz},
+		{"data.tar.gz", dataGz},
+	} {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", m.name, modTime.Unix(), 0, 0, "100644", len(m.data))
+		buf.Write(m.data)
+		if len(m.data)%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func (s *Spec) maintainer() string {
+	if s.Maintainer != "" {
+		return s.Maintainer
+	}
+	return s.Name + " maintainers <noreply@localhost>"
+}
@@ -0,0 +1,146 @@
+package packaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteFormula renders a Homebrew formula for the darwin and linux
+// amd64/arm64 archives in outDir, which WriteArchives must have written.
+// Downloads point at s.BaseURL.
+func WriteFormula(s *Spec, outDir string) (string, error) {
+	blocks := map[string]map[string]string{} // os -> on_arm/on_intel -> url/sha256 lines
+	for _, b := range s.Binaries {
+		cpu := map[string]string{"arm64": "on_arm", "amd64": "on_intel"}[b.Platform.GOARCH]
+		if (b.Platform.GOOS != "darwin" && b.Platform.GOOS != "linux") || cpu == "" {
+			continue
+		}
+		url, sum, err := s.archiveURL(b, outDir)
+		if err != nil {
+			return "", err
+		}
+		if blocks[b.Platform.GOOS] == nil {
+			blocks[b.Platform.GOOS] = map[string]string{}
+		}
+		blocks[b.Platform.GOOS][cpu] = fmt.Sprintf("      url %q\n      sha256 %q\n", url, sum)
+	}
+	if len(blocks) == 0 {
+		return "", fmt.Errorf("homebrew: no darwin or linux amd64/arm64 binaries to package")
+	}
+
+	var f strings.Builder
+	fmt.Fprintf(&f, "class %s < Formula\n", formulaClass(s.Name))
+	fmt.Fprintf(&f, "  desc %q\n", s.description())
+	if s.Homepage != "" {
+		fmt.Fprintf(&f, "  homepage %q\n", s.Homepage)
+	}
+	fmt.Fprintf(&f, "  version %q\n", s.Version)
+	if s.License != "" {
+		fmt.Fprintf(&f, "  license %q\n", s.License)
+	}
+	for _, goos := range []string{"darwin", "linux"} {
+		if blocks[goos] == nil {
+			continue
+		}
+		fmt.Fprintf(&f, "\n  on_%s do\n", map[string]string{"darwin": "macos", "linux": "linux"}[goos])
+		for _, cpu := range []string{"on_arm", "on_intel"} {
+			if lines, ok := blocks[goos][cpu]; ok {
+				fmt.Fprintf(&f, "    %s do\n%s    end\n", cpu, lines)
+			}
+		}
+		f.WriteString("  end\n")
+	}
+
+	f.WriteString("\n  def install\n")
+	fmt.Fprintf(&f, "    bin.install %q\n", s.Name)
+	if s.Completions["bash"] != nil {
+		fmt.Fprintf(&f, "    bash_completion.install \"completions/%s.bash\" => %q\n", s.Name, s.Name)
+	}
+	if s.Completions["zsh"] != nil {
+		fmt.Fprintf(&f, "    zsh_completion.install \"completions/_%s\"\n", s.Name)
+	}
+	if s.Completions["fish"] != nil {
+		fmt.Fprintf(&f, "    fish_completion.install \"completions/%s.fish\"\n", s.Name)
+	}
+	if len(s.ManPages) > 0 {
+		f.WriteString("    man1.install Dir[\"man/*.1\"]\n")
+	}
+	f.WriteString("  end\n")
+	fmt.Fprintf(&f, "\n  test do\n    system bin/%q, \"--version\"\n  end\nend\n", s.Name)
+
+	path := filepath.Join(outDir, s.Name+".rb")
+	return path, writeFile(path, []byte(f.String()))
+}
+
+// formulaClass turns a CLI name into the Ruby class Homebrew expects:
+// "linear-cli" becomes "LinearCli".
+func formulaClass(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// scoopManifest is a Scoop app manifest. Field order matches the Scoop docs.
+type scoopManifest struct {
+	Version      string                       `json:"version"`
+	Description  string                       `json:"description"`
+	Homepage     string                       `json:"homepage,omitempty"`
+	License      string                       `json:"license,omitempty"`
+	Architecture map[string]scoopArchitecture `json:"architecture"`
+	Bin          string                       `json:"bin"`
+}
+
+type scoopArchitecture struct {
+	URL  string `json:"url"`
+	Hash string `json:"hash"`
+}
+
+// WriteScoopManifest renders a Scoop manifest for the windows archives in
+// outDir, which WriteArchives must have written. Downloads point at
+// s.BaseURL.
+func WriteScoopManifest(s *Spec, outDir string) (string, error) {
+	m := scoopManifest{
+		Version:      s.Version,
+		Description:  s.description(),
+		Homepage:     s.Homepage,
+		License:      s.License,
+		Architecture: map[string]scoopArchitecture{},
+		Bin:          s.Name + ".exe",
+	}
+	for _, b := range s.Binaries {
+		arch := map[string]string{"amd64": "64bit", "386": "32bit", "arm64": "arm64"}[b.Platform.GOARCH]
+		if b.Platform.GOOS != "windows" || arch == "" {
+			continue
+		}
+		url, sum, err := s.archiveURL(b, outDir)
+		if err != nil {
+			return "", err
+		}
+		m.Architecture[arch] = scoopArchitecture{URL: url, Hash: sum}
+	}
+	if len(m.Architecture) == 0 {
+		return "", fmt.Errorf("scoop: no windows binaries to package")
+	}
+
+	data, err := json.MarshalIndent(m, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(outDir, s.Name+".json")
+	return path, writeFile(path, append(data, '\n'))
+}
+
+// archiveURL returns the download URL and SHA-256 of a binary's archive.
+func (s *Spec) archiveURL(b Binary, outDir string) (string, string, error) {
+	name := s.ArchiveName(b)
+	data, err := os.ReadFile(filepath.Join(outDir, name))
+	if err != nil {
+		return "", "", fmt.Errorf("read archive: %w", err)
+	}
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + name, sha256Hex(data), nil
+}
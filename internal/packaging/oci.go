@@ -0,0 +1,182 @@
+package packaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// OCI media types, from the image spec.
+const (
+	ociIndexType    = "application/vnd.oci.image.index.v1+json"
+	ociManifestType = "application/vnd.oci.image.manifest.v1+json"
+	ociConfigType   = "application/vnd.oci.image.config.v1+json"
+	ociLayerType    = "application/vnd.oci.image.layer.v1.tar+gzip"
+)
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int               `json:"size"`
+	Platform    *ociPlatform      `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type ociPlatform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+type ociConfig struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+	Config       struct {
+		Entrypoint []string          `json:"Entrypoint"`
+		Env        []string          `json:"Env"`
+		Labels     map[string]string `json:"Labels,omitempty"`
+	} `json:"config"`
+	RootFS struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// OCIImageName returns the file name of the OCI image tarball.
+func (s *Spec) OCIImageName() string {
+	return s.Name + "_" + s.Version + "_oci.tar"
+}
+
+// WriteOCIImage writes a multi-platform OCI image layout, as a tar, holding
+// one single-layer image per linux binary. Each image contains only the
+// binary at /usr/local/bin/<name> and s.CACerts, so it needs no base image
+// or container daemon to build. Load it with `docker load`, `podman load` or
+// `skopeo copy oci-archive:...`.
+func WriteOCIImage(s *Spec, outDir string) (string, error) {
+	blobs := map[string][]byte{}
+	addBlob := func(mediaType string, data []byte) ociDescriptor {
+		digest := "sha256:" + sha256Hex(data)
+		blobs[digest] = data
+		return ociDescriptor{MediaType: mediaType, Digest: digest, Size: len(data)}
+	}
+	addJSON := func(mediaType string, v any) (ociDescriptor, error) {
+		data, err := json.Marshal(v)
+		if err != nil {
+			return ociDescriptor{}, err
+		}
+		return addBlob(mediaType, data), nil
+	}
+
+	entrypoint := "/usr/local/bin/" + s.Name
+	index := ociIndex{SchemaVersion: 2, MediaType: ociIndexType, Manifests: []ociDescriptor{}}
+	for _, b := range s.Binaries {
+		if b.Platform.GOOS != "linux" {
+			continue
+		}
+		binary, err := os.ReadFile(b.Path)
+		if err != nil {
+			return "", err
+		}
+		files := []file{{path: entrypoint[1:], mode: 0755, data: binary}}
+		if s.CACerts != nil {
+			files = append(files, file{path: "etc/ssl/certs/ca-certificates.crt", mode: 0644, data: s.CACerts})
+		}
+		layerTar, err := tarFiles(files, "")
+		if err != nil {
+			return "", err
+		}
+		layerGz, err := gzipBytes(layerTar)
+		if err != nil {
+			return "", err
+		}
+		layer := addBlob(ociLayerType, layerGz)
+
+		platform := &ociPlatform{Architecture: b.Platform.GOARCH, OS: "linux"}
+		if b.Platform.GOARM != "" {
+			platform.Variant = "v" + b.Platform.GOARM
+		}
+
+		var cfg ociConfig
+		cfg.Architecture, cfg.OS, cfg.Variant = platform.Architecture, platform.OS, platform.Variant
+		cfg.Config.Entrypoint = []string{entrypoint}
+		cfg.Config.Env = []string{"PATH=/usr/local/bin:/usr/bin:/bin"}
+		cfg.Config.Labels = map[string]string{
+			"org.opencontainers.image.title":       s.Name,
+			"org.opencontainers.image.version":     s.Version,
+			"org.opencontainers.image.description": s.description(),
+		}
+		if s.Homepage != "" {
+			cfg.Config.Labels["org.opencontainers.image.url"] = s.Homepage
+		}
+		cfg.RootFS.Type = "layers"
+		cfg.RootFS.DiffIDs = []string{"sha256:" + sha256Hex(layerTar)}
+		config, err := addJSON(ociConfigType, cfg)
+		if err != nil {
+			return "", err
+		}
+
+		manifest, err := addJSON(ociManifestType, ociManifest{
+			SchemaVersion: 2,
+			MediaType:     ociManifestType,
+			Config:        config,
+			Layers:        []ociDescriptor{layer},
+		})
+		if err != nil {
+			return "", err
+		}
+		manifest.Platform = platform
+		index.Manifests = append(index.Manifests, manifest)
+	}
+	if len(index.Manifests) == 0 {
+		return "", fmt.Errorf("oci: no linux binaries to package")
+	}
+
+	imageIndex, err := addJSON(ociIndexType, index)
+	if err != nil {
+		return "", err
+	}
+	imageIndex.Annotations = map[string]string{
+		"org.opencontainers.image.ref.name": s.Version,
+		"io.containerd.image.name":          s.Name + ":" + s.Version,
+	}
+	top, err := json.Marshal(ociIndex{SchemaVersion: 2, MediaType: ociIndexType, Manifests: []ociDescriptor{imageIndex}})
+	if err != nil {
+		return "", err
+	}
+
+	files := []file{
+		{path: "oci-layout", mode: 0644, data: []byte(`{"imageLayoutVersion":"1.0.0"}`)},
+		{path: "index.json", mode: 0644, data: top},
+	}
+	digests := make([]string, 0, len(blobs))
+	for d := range blobs {
+		digests = append(digests, d)
+	}
+	sort.Strings(digests)
+	for _, d := range digests {
+		files = append(files, file{path: "blobs/sha256/" + d[len("sha256:"):], mode: 0644, data: blobs[d]})
+	}
+	data, err := tarFiles(files, "")
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(outDir, s.OCIImageName())
+	return path, writeFile(path, data)
+}
@@ -0,0 +1,247 @@
+// Package packaging wraps generated binaries for distribution: tar.gz and zip
+// archives, deb and rpm packages, a Homebrew formula, a Scoop manifest and an
+// OCI image tarball. Every artifact is built in Go, without dpkg, rpmbuild or
+// a container daemon, and with fixed timestamps so it is reproducible.
+package packaging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/thellimist/clihub/internal/compile"
+	"github.com/thellimist/clihub/internal/release"
+)
+
+// modTime is the timestamp of every file in every artifact.
+var modTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Spec describes the CLI being packaged.
+type Spec struct {
+	Name        string
+	Version     string
+	Description string
+	Homepage    string
+	Maintainer  string
+	License     string
+	// BaseURL is where the archives will be published. The Homebrew formula
+	// and Scoop manifest download from it.
+	BaseURL  string
+	Binaries []Binary
+	// Completions maps a shell (bash, zsh, fish, powershell) to its
+	// completion script.
+	Completions map[string][]byte
+	// ManPages maps a man page file name ("mycli.1") to its roff source.
+	ManPages map[string][]byte
+	// CACerts is a PEM bundle installed into the OCI image, which has no
+	// other root certificates.
+	CACerts []byte
+}
+
+// Binary is one compiled binary and the platform it targets.
+type Binary struct {
+	Path     string
+	Platform compile.Platform
+}
+
+// Discover finds the binaries in dir from the SBOMs generate writes next to
+// them. name selects one CLI when the directory holds several; empty accepts
+// a single CLI. The returned spec has Name, Version and Binaries set.
+func Discover(dir, name string) (*Spec, error) {
+	sboms, err := filepath.Glob(filepath.Join(dir, "*.sbom.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var spec *Spec
+	for _, sbom := range sboms {
+		info, err := release.ReadSBOM(sbom)
+		if err != nil {
+			return nil, err
+		}
+		if name != "" && info.Name != name {
+			continue
+		}
+		if spec == nil {
+			spec = &Spec{Name: info.Name, Version: info.Version}
+		} else if info.Name != spec.Name {
+			return nil, fmt.Errorf("%s holds binaries for both %s and %s; choose one with --name", dir, spec.Name, info.Name)
+		} else if info.Version != spec.Version {
+			return nil, fmt.Errorf("%s holds %s versions %s and %s", dir, spec.Name, spec.Version, info.Version)
+		}
+
+		base := strings.TrimSuffix(sbom, ".sbom.json")
+		path := ""
+		for _, ext := range []string{"", ".exe", ".wasm"} {
+			if _, err := os.Stat(base + ext); err == nil {
+				path = base + ext
+				break
+			}
+		}
+		if path == "" {
+			return nil, fmt.Errorf("no binary found for %s", sbom)
+		}
+		spec.Binaries = append(spec.Binaries, Binary{
+			Path:     path,
+			Platform: compile.Platform{GOOS: info.GOOS, GOARCH: info.GOARCH, GOARM: info.GOARM},
+		})
+	}
+
+	if spec == nil {
+		if name != "" {
+			return nil, fmt.Errorf("no binaries for %s in %s", name, dir)
+		}
+		return nil, fmt.Errorf("no generated binaries in %s (looked for *.sbom.json)", dir)
+	}
+	sort.Slice(spec.Binaries, func(i, j int) bool {
+		return spec.Binaries[i].Platform.String() < spec.Binaries[j].Platform.String()
+	})
+	return spec, nil
+}
+
+// file is one regular file placed in an artifact.
+type file struct {
+	path string // slash-separated, relative to the artifact root
+	mode int64
+	data []byte
+}
+
+// archiveFiles lays out a binary with its completions and man pages the way
+// the archives, and the Homebrew formula that unpacks them, expect.
+func (s *Spec) archiveFiles(b Binary) ([]file, error) {
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	files := []file{{path: s.binaryName(b.Platform), mode: 0755, data: data}}
+
+	if b.Platform.GOOS == "windows" {
+		if c := s.Completions["powershell"]; c != nil {
+			files = append(files, file{path: "completions/" + s.Name + ".ps1", mode: 0644, data: c})
+		}
+		return files, nil
+	}
+	for _, c := range []struct{ shell, path string }{
+		{"bash", "completions/" + s.Name + ".bash"},
+		{"zsh", "completions/_" + s.Name},
+		{"fish", "completions/" + s.Name + ".fish"},
+	} {
+		if data := s.Completions[c.shell]; data != nil {
+			files = append(files, file{path: c.path, mode: 0644, data: data})
+		}
+	}
+	for _, page := range s.manPageNames() {
+		files = append(files, file{path: "man/" + page, mode: 0644, data: s.ManPages[page]})
+	}
+	return files, nil
+}
+
+// systemFiles lays out a binary for installation under /usr, as deb and rpm
+// packages do. Paths have no leading slash.
+func (s *Spec) systemFiles(b Binary) ([]file, error) {
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return nil, err
+	}
+	files := []file{{path: "usr/bin/" + s.Name, mode: 0755, data: data}}
+	for _, c := range []struct{ shell, path string }{
+		{"bash", "usr/share/bash-completion/completions/" + s.Name},
+		{"fish", "usr/share/fish/vendor_completions.d/" + s.Name + ".fish"},
+		{"zsh", "usr/share/zsh/site-functions/_" + s.Name},
+	} {
+		if data := s.Completions[c.shell]; data != nil {
+			files = append(files, file{path: c.path, mode: 0644, data: data})
+		}
+	}
+	for _, page := range s.manPageNames() {
+		gz, err := gzipBytes(s.ManPages[page])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{path: "usr/share/man/man1/" + page + ".gz", mode: 0644, data: gz})
+	}
+	return files, nil
+}
+
+func (s *Spec) manPageNames() []string {
+	names := make([]string, 0, len(s.ManPages))
+	for name := range s.ManPages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *Spec) binaryName(p compile.Platform) string {
+	return compile.BinaryName(s.Name, p, false)
+}
+
+func (s *Spec) description() string {
+	if s.Description != "" {
+		return s.Description
+	}
+	return s.Name + " command-line tool"
+}
+
+// platformSuffix names a platform in artifact file names: linux_amd64,
+// linux_armv7.
+func platformSuffix(p compile.Platform) string {
+	arch := p.GOARCH
+	if p.GOARM != "" {
+		arch += "v" + p.GOARM
+	}
+	return p.GOOS + "_" + arch
+}
+
+// dirsOf returns every parent directory of the given files, parents first.
+func dirsOf(files []file) []string {
+	seen := map[string]bool{}
+	var dirs []string
+	for _, f := range files {
+		var parents []string
+		for dir := filepath.ToSlash(filepath.Dir(f.path)); dir != "." && !seen[dir]; dir = filepath.ToSlash(filepath.Dir(dir)) {
+			seen[dir] = true
+			parents = append(parents, dir)
+		}
+		for i := len(parents) - 1; i >= 0; i-- {
+			dirs = append(dirs, parents[i])
+		}
+	}
+	return dirs
+}
+
+// gzipBytes compresses data with an empty gzip header, so the output depends
+// only on the input.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func writeFile(path string, data []byte) error {
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("write %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
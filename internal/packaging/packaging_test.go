@@ -0,0 +1,679 @@
+package packaging
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/thellimist/clihub/internal/compile"
+)
+
+// testSpec returns a spec with fake binaries for linux, darwin and windows.
+func testSpec(t *testing.T) *Spec {
+	t.Helper()
+	dir := t.TempDir()
+	s := &Spec{
+		Name:        "linear",
+		Version:     "1.2.0",
+		Homepage:    "https://example.com/linear",
+		License:     "MIT",
+		BaseURL:     "https://releases.example.com/linear/",
+		Completions: map[string][]byte{"bash": []byte("# bash\n"), "zsh": []byte("#compdef linear\n"), "powershell": []byte("# ps\n")},
+		ManPages:    map[string][]byte{"linear.1": []byte(".TH LINEAR 1\n")},
+		CACerts:     []byte("-----BEGIN CERTIFICATE-----\n"),
+	}
+	for _, p := range []compile.Platform{
+		{GOOS: "linux", GOARCH: "amd64"},
+		{GOOS: "linux", GOARCH: "arm", GOARM: "7"},
+		{GOOS: "darwin", GOARCH: "arm64"},
+		{GOOS: "windows", GOARCH: "amd64"},
+	} {
+		path := filepath.Join(dir, compile.BinaryName("linear", p, true))
+		if err := os.WriteFile(path, []byte("binary for "+p.String()), 0755); err != nil {
+			t.Fatal(err)
+		}
+		s.Binaries = append(s.Binaries, Binary{Path: path, Platform: p})
+	}
+	return s
+}
+
+func readTarGz(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readTar(t, zr)
+}
+
+func readTar(t *testing.T, r io.Reader) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[hdr.Name] = string(data)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sbom := func(name, goos, goarch, goarm string) string {
+		props := `{"name":"go:GOOS","value":"` + goos + `"},{"name":"go:GOARCH","value":"` + goarch + `"}`
+		if goarm != "" {
+			props += `,{"name":"go:GOARM","value":"` + goarm + `"}`
+		}
+		return `{"bomFormat":"CycloneDX","metadata":{"component":{"name":"` + name + `","version":"1.0.0","properties":[` + props + `]}}}`
+	}
+	write("linear-linux-armv7", "bin")
+	write("linear-linux-armv7.sbom.json", sbom("linear", "linux", "arm", "7"))
+	write("linear-windows-amd64.exe", "bin")
+	write("linear-windows-amd64.sbom.json", sbom("linear", "windows", "amd64", ""))
+
+	spec, err := Discover(dir, "")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if spec.Name != "linear" || spec.Version != "1.0.0" || len(spec.Binaries) != 2 {
+		t.Fatalf("spec = %+v", spec)
+	}
+	if b := spec.Binaries[0]; b.Platform.String() != "linux/arm/v7" || filepath.Base(b.Path) != "linear-linux-armv7" {
+		t.Errorf("binaries[0] = %+v", b)
+	}
+	if b := spec.Binaries[1]; filepath.Base(b.Path) != "linear-windows-amd64.exe" {
+		t.Errorf("binaries[1] = %+v", b)
+	}
+
+	write("notion.sbom.json", sbom("notion", "linux", "amd64", ""))
+	write("notion", "bin")
+	if _, err := Discover(dir, ""); err == nil || !strings.Contains(err.Error(), "--name") {
+		t.Errorf("expected --name error for two CLIs, got %v", err)
+	}
+	if spec, err := Discover(dir, "notion"); err != nil || len(spec.Binaries) != 1 {
+		t.Errorf("Discover(notion) = %+v, %v", spec, err)
+	}
+}
+
+func TestWriteArchives(t *testing.T) {
+	s := testSpec(t)
+	outDir := t.TempDir()
+	paths, err := WriteArchives(s, outDir)
+	if err != nil {
+		t.Fatalf("WriteArchives: %v", err)
+	}
+	var names []string
+	for _, p := range paths {
+		names = append(names, filepath.Base(p))
+	}
+	want := "linear_1.2.0_linux_amd64.tar.gz linear_1.2.0_linux_armv7.tar.gz linear_1.2.0_darwin_arm64.tar.gz linear_1.2.0_windows_amd64.zip"
+	if strings.Join(names, " ") != want {
+		t.Errorf("archives = %v", names)
+	}
+
+	data, _ := os.ReadFile(paths[0])
+	files := readTarGz(t, data)
+	for name, content := range map[string]string{
+		"linear":                  "binary for linux/amd64",
+		"completions/linear.bash": "# bash\n",
+		"completions/_linear":     "#compdef linear\n",
+		"man/linear.1":            ".TH LINEAR 1\n",
+	} {
+		if files[name] != content {
+			t.Errorf("%s = %q, want %q", name, files[name], content)
+		}
+	}
+	for _, name := range []string{"completions/linear.fish", "completions/linear.ps1"} {
+		if _, ok := files[name]; ok {
+			t.Errorf("unexpected %s in linux archive", name)
+		}
+	}
+
+	zdata, _ := os.ReadFile(paths[3])
+	zr, err := zip.NewReader(bytes.NewReader(zdata), int64(len(zdata)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var zipNames []string
+	for _, f := range zr.File {
+		zipNames = append(zipNames, f.Name)
+	}
+	if strings.Join(zipNames, " ") != "linear.exe completions/linear.ps1" {
+		t.Errorf("zip entries = %v", zipNames)
+	}
+
+	// Same inputs, same bytes.
+	again, err := WriteArchives(s, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data2, _ := os.ReadFile(again[0])
+	if !bytes.Equal(data, data2) {
+		t.Error("archives are not reproducible")
+	}
+}
+
+func TestWriteDebs(t *testing.T) {
+	s := testSpec(t)
+	paths, err := WriteDebs(s, t.TempDir())
+	if err != nil {
+		t.Fatalf("WriteDebs: %v", err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[0]) != "linear_1.2.0_amd64.deb" || filepath.Base(paths[1]) != "linear_1.2.0_armhf.deb" {
+		t.Fatalf("debs = %v", paths)
+	}
+
+	data, _ := os.ReadFile(paths[0])
+	if !bytes.HasPrefix(data, []byte("!<arch>\n")) {
+		t.Fatal("not an ar archive")
+	}
+	members := map[string][]byte{}
+	var order []string
+	for rest := data[8:]; len(rest) >= 60; {
+		name := strings.TrimSpace(string(rest[:16]))
+		var size int
+		for _, c := range strings.TrimSpace(string(rest[48:58])) {
+			size = size*10 + int(c-'0')
+		}
+		members[name] = rest[60 : 60+size]
+		order = append(order, name)
+		rest = rest[60+size+size%2:]
+	}
+	if strings.Join(order, " ") != "debian-binary control.tar.gz data.tar.gz" {
+		t.Fatalf("members = %v", order)
+	}
+
+	control := readTarGz(t, members["control.tar.gz"])["./control"]
+	for _, want := range []string{"Package: linear\n", "Version: 1.2.0\n", "Architecture: amd64\n", "Homepage: https://example.com/linear\n"} {
+		if !strings.Contains(control, want) {
+			t.Errorf("control missing %q:\n%s", want, control)
+		}
+	}
+	files := readTarGz(t, members["data.tar.gz"])
+	if files["./usr/bin/linear"] != "binary for linux/amd64" {
+		t.Errorf("data files = %v", files)
+	}
+	if _, ok := files["./usr/share/man/man1/linear.1.gz"]; !ok {
+		t.Error("man page not installed")
+	}
+	if files["./usr/share/bash-completion/completions/linear"] != "# bash\n" {
+		t.Error("bash completion not installed")
+	}
+}
+
+func TestWriteRPMs(t *testing.T) {
+	s := testSpec(t)
+	s.Version = "1.2.0-rc1"
+	paths, err := WriteRPMs(s, t.TempDir())
+	if err != nil {
+		t.Fatalf("WriteRPMs: %v", err)
+	}
+	if len(paths) != 2 || filepath.Base(paths[0]) != "linear-1.2.0~rc1-1.x86_64.rpm" || filepath.Base(paths[1]) != "linear-1.2.0~rc1-1.armv7hl.rpm" {
+		t.Fatalf("rpms = %v", paths)
+	}
+
+	for i, want := range []struct{ arch, binary string }{
+		{"x86_64", "binary for linux/amd64"},
+		{"armv7hl", "binary for linux/arm/v7"},
+	} {
+		data, err := os.ReadFile(paths[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, files := readRPM(t, data)
+		if header[1000].str() != "linear" || header[1001].str() != "1.2.0~rc1" || header[1022].str() != want.arch || header[1044].str() == "" {
+			t.Errorf("header name/version/arch/sourcerpm = %q %q %q %q", header[1000].str(), header[1001].str(), header[1022].str(), header[1044].str())
+		}
+		if header[1014].str() != "MIT" {
+			t.Errorf("license = %q", header[1014].str())
+		}
+		if f := files["/usr/bin/linear"]; string(f.data) != want.binary || f.mode != 0100755 {
+			t.Errorf("/usr/bin/linear = %q mode %o", f.data, f.mode)
+		}
+		for _, path := range []string{"/usr/share/man/man1/linear.1.gz", "/usr/share/bash-completion/completions/linear", "/usr/share/zsh/site-functions/_linear"} {
+			if _, ok := files[path]; !ok {
+				t.Errorf("%s not installed; files = %v", path, fileNames(files))
+			}
+		}
+	}
+}
+
+// TestPackageTools checks the packages with the tools distributions use to
+// read them, where installed.
+func TestPackageTools(t *testing.T) {
+	t.Run("dpkg-deb", func(t *testing.T) {
+		if _, err := exec.LookPath("dpkg-deb"); err != nil {
+			t.Skip("dpkg-deb not installed")
+		}
+		paths, err := WriteDebs(testSpec(t), t.TempDir())
+		if err != nil {
+			t.Fatalf("WriteDebs: %v", err)
+		}
+		info, err := exec.Command("dpkg-deb", "--info", paths[0]).CombinedOutput()
+		if err != nil {
+			t.Fatalf("dpkg-deb --info: %v\n%s", err, info)
+		}
+		for _, want := range []string{"Package: linear", "Version: 1.2.0", "Architecture: amd64"} {
+			if !strings.Contains(string(info), want) {
+				t.Errorf("dpkg-deb --info missing %q:\n%s", want, info)
+			}
+		}
+		contents, err := exec.Command("dpkg-deb", "--contents", paths[0]).CombinedOutput()
+		if err != nil {
+			t.Fatalf("dpkg-deb --contents: %v\n%s", err, contents)
+		}
+		for _, want := range []string{"./usr/bin/linear", "./usr/share/man/man1/linear.1.gz", "./usr/share/bash-completion/completions/linear"} {
+			if !strings.Contains(string(contents), want) {
+				t.Errorf("dpkg-deb --contents missing %s:\n%s", want, contents)
+			}
+		}
+	})
+
+	t.Run("rpm", func(t *testing.T) {
+		if _, err := exec.LookPath("rpm"); err != nil {
+			t.Skip("rpm not installed")
+		}
+		s := testSpec(t)
+		s.Version = "1.2.0-rc1"
+		paths, err := WriteRPMs(s, t.TempDir())
+		if err != nil {
+			t.Fatalf("WriteRPMs: %v", err)
+		}
+		out, err := exec.Command("rpm", "-qip", paths[0]).CombinedOutput()
+		if err != nil {
+			t.Fatalf("rpm -qip: %v\n%s", err, out)
+		}
+		for _, want := range []string{"linear", "1.2.0~rc1", "x86_64", "MIT"} {
+			if !strings.Contains(string(out), want) {
+				t.Errorf("rpm -qip missing %q:\n%s", want, out)
+			}
+		}
+		out, err = exec.Command("rpm", "-qlp", paths[0]).CombinedOutput()
+		if err != nil {
+			t.Fatalf("rpm -qlp: %v\n%s", err, out)
+		}
+		if !strings.Contains(string(out), "/usr/bin/linear") {
+			t.Errorf("rpm -qlp missing /usr/bin/linear:\n%s", out)
+		}
+	})
+}
+
+// rpmValue is one decoded RPM header entry.
+type rpmValue struct {
+	strs []string
+	ints []int64
+	bin  []byte
+}
+
+// str returns a string entry's value, or a string array's joined by spaces.
+func (v rpmValue) str() string { return strings.Join(v.strs, " ") }
+
+type rpmFile struct {
+	mode uint32
+	data []byte
+}
+
+func fileNames(files map[string]rpmFile) []string {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readRPM reads a package the way rpm does, so a malformed one fails here
+// even where rpm is not installed: it checks the lead, parses the signature
+// and main headers, verifies the signature's sizes and digests, and unpacks
+// the cpio payload, which must hold exactly the files the header lists. It
+// returns the main header and the files by installed path.
+func readRPM(t *testing.T, data []byte) (map[int32]rpmValue, map[string]rpmFile) {
+	t.Helper()
+	if len(data) < 96 || !bytes.HasPrefix(data, []byte{0xed, 0xab, 0xee, 0xdb, 3}) {
+		t.Fatal("missing rpm v3 lead")
+	}
+	if typ, osnum, sigType := binary.BigEndian.Uint16(data[6:]), binary.BigEndian.Uint16(data[76:]), binary.BigEndian.Uint16(data[78:]); typ != 0 || osnum != 1 || sigType != 5 {
+		t.Fatalf("lead type/os/signature type = %d/%d/%d, want 0/1/5", typ, osnum, sigType)
+	}
+	name, _, ok := bytes.Cut(data[10:76], []byte{0})
+	if !ok {
+		t.Fatal("lead name is not NUL-terminated")
+	}
+
+	sig, n := parseRPMHeader(t, data[96:], rpmTagHeaderSignatures)
+	offset := 96 + n + (8-n%8)%8
+	if offset > len(data) {
+		t.Fatal("signature header runs past the end")
+	}
+	header, n := parseRPMHeader(t, data[offset:], rpmTagHeaderImmutable)
+	headerBytes := data[offset : offset+n]
+	payload := data[offset+n:]
+
+	nvr := header[1000].str() + "-" + header[1001].str() + "-" + header[1002].str()
+	if string(name) != nvr {
+		t.Errorf("lead name %q, want %q", name, nvr)
+	}
+	if got := sig[1000].ints; len(got) != 1 || got[0] != int64(len(headerBytes)+len(payload)) {
+		t.Errorf("signature SIZE = %v, want %d", got, len(headerBytes)+len(payload))
+	}
+	md5sum := md5.Sum(append(append([]byte(nil), headerBytes...), payload...))
+	if !bytes.Equal(sig[1004].bin, md5sum[:]) {
+		t.Error("signature MD5 does not match the header and payload")
+	}
+	sha1sum := sha1.Sum(headerBytes)
+	if sig[269].str() != hex.EncodeToString(sha1sum[:]) {
+		t.Error("signature SHA1 does not match the header")
+	}
+	sum := sha256.Sum256(headerBytes)
+	if sig[273].str() != hex.EncodeToString(sum[:]) {
+		t.Error("signature SHA256 does not match the header")
+	}
+	payloadSum := sha256.Sum256(payload)
+	if header[5092].str() != hex.EncodeToString(payloadSum[:]) {
+		t.Error("payload digest does not match the payload")
+	}
+	if header[1124].str() != "cpio" || header[1125].str() != "gzip" {
+		t.Fatalf("payload format %q compressor %q", header[1124].str(), header[1125].str())
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpio, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sig[1007].ints; len(got) != 1 || got[0] != int64(len(cpio)) {
+		t.Errorf("signature PAYLOADSIZE = %v, want %d", got, len(cpio))
+	}
+	archived := readCpioNewc(t, cpio)
+
+	// Every file the header lists is in the payload with the same mode,
+	// size and digest, and the payload holds nothing else.
+	bases, dirs, dirIndexes := header[1117].strs, header[1118].strs, header[1116].ints
+	sizes, modes, digests := header[1028].ints, header[1030].ints, header[1035].strs
+	for _, l := range []int{len(dirIndexes), len(sizes), len(modes), len(digests)} {
+		if l != len(bases) {
+			t.Fatalf("file arrays have different lengths: %d basenames, %d", len(bases), l)
+		}
+	}
+	files := map[string]rpmFile{}
+	for i, base := range bases {
+		if dirIndexes[i] < 0 || int(dirIndexes[i]) >= len(dirs) {
+			t.Fatalf("dir index %d out of range", dirIndexes[i])
+		}
+		path := dirs[dirIndexes[i]] + base
+		f, ok := archived["."+path]
+		if !ok {
+			t.Errorf("%s is in the header but not the payload", path)
+			continue
+		}
+		delete(archived, "."+path)
+		fileSum := sha256.Sum256(f.data)
+		if int64(f.mode) != modes[i]&0xffff || int64(len(f.data)) != sizes[i] || digests[i] != hex.EncodeToString(fileSum[:]) {
+			t.Errorf("%s: payload mode %o size %d does not match header mode %o size %d or its digest", path, f.mode, len(f.data), modes[i], sizes[i])
+		}
+		files[path] = f
+	}
+	if len(archived) > 0 {
+		t.Errorf("payload has files the header does not list: %v", fileNames(archived))
+	}
+	return header, files
+}
+
+// parseRPMHeader decodes an RPM header whose first entry is the region tag,
+// checking its structure as rpm does, and returns the entries with the
+// header's length.
+func parseRPMHeader(t *testing.T, data []byte, regionTag int32) (map[int32]rpmValue, int) {
+	t.Helper()
+	if len(data) < 16 || !bytes.HasPrefix(data, []byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0}) {
+		t.Fatal("bad header magic")
+	}
+	nindex := int(binary.BigEndian.Uint32(data[8:]))
+	hsize := int(binary.BigEndian.Uint32(data[12:]))
+	if nindex < 1 || 16+nindex*16+hsize > len(data) {
+		t.Fatalf("header with %d entries and %d bytes of data runs past the end", nindex, hsize)
+	}
+	store := data[16+nindex*16 : 16+nindex*16+hsize]
+
+	values := map[int32]rpmValue{}
+	var tags []int32
+	for i := 0; i < nindex; i++ {
+		e := data[16+i*16:]
+		tag := int32(binary.BigEndian.Uint32(e))
+		typ := binary.BigEndian.Uint32(e[4:])
+		off := int(binary.BigEndian.Uint32(e[8:]))
+		count := int(binary.BigEndian.Uint32(e[12:]))
+		tags = append(tags, tag)
+		if off >= len(store) {
+			t.Fatalf("tag %d: offset %d past the data store", tag, off)
+		}
+		var v rpmValue
+		switch typ {
+		case rpmTypeInt16, rpmTypeInt32:
+			size := map[uint32]int{rpmTypeInt16: 2, rpmTypeInt32: 4}[typ]
+			if off%size != 0 || off+count*size > len(store) {
+				t.Fatalf("tag %d: misaligned or out of range integers", tag)
+			}
+			for j := 0; j < count; j++ {
+				if size == 2 {
+					v.ints = append(v.ints, int64(binary.BigEndian.Uint16(store[off+j*2:])))
+				} else {
+					v.ints = append(v.ints, int64(int32(binary.BigEndian.Uint32(store[off+j*4:]))))
+				}
+			}
+		case rpmTypeBin:
+			if off+count > len(store) {
+				t.Fatalf("tag %d: binary data out of range", tag)
+			}
+			v.bin = store[off : off+count]
+		case rpmTypeString, rpmTypeStringArray, rpmTypeI18NString:
+			if typ == rpmTypeString && count != 1 {
+				t.Fatalf("tag %d: string with count %d", tag, count)
+			}
+			rest := store[off:]
+			for j := 0; j < count; j++ {
+				str, after, ok := bytes.Cut(rest, []byte{0})
+				if !ok {
+					t.Fatalf("tag %d: string is not NUL-terminated", tag)
+				}
+				v.strs = append(v.strs, string(str))
+				rest = after
+			}
+		default:
+			t.Fatalf("tag %d: unknown type %d", tag, typ)
+		}
+		values[tag] = v
+	}
+
+	// The region entry points at a trailer that spans the whole index.
+	if tags[0] != regionTag {
+		t.Fatalf("first tag %d, want region tag %d", tags[0], regionTag)
+	}
+	trailer := values[regionTag].bin
+	if len(trailer) != 16 ||
+		int32(binary.BigEndian.Uint32(trailer)) != regionTag ||
+		binary.BigEndian.Uint32(trailer[4:]) != rpmTypeBin ||
+		int32(binary.BigEndian.Uint32(trailer[8:])) != int32(-nindex*16) ||
+		binary.BigEndian.Uint32(trailer[12:]) != 16 {
+		t.Fatalf("bad region trailer %x", trailer)
+	}
+	if !sort.SliceIsSorted(tags[1:], func(i, j int) bool { return tags[i+1] < tags[j+1] }) {
+		t.Error("header tags are not sorted")
+	}
+	return values, 16 + nindex*16 + hsize
+}
+
+// readCpioNewc unpacks a "newc" cpio archive up to its trailer.
+func readCpioNewc(t *testing.T, data []byte) map[string]rpmFile {
+	t.Helper()
+	files := map[string]rpmFile{}
+	pad := func(n int) int { return (n + 3) &^ 3 }
+	for pos := 0; ; {
+		if pos+110 > len(data) || string(data[pos:pos+6]) != "070701" {
+			t.Fatalf("bad cpio header at %d", pos)
+		}
+		var fields [13]uint64
+		for i := range fields {
+			v, err := strconv.ParseUint(string(data[pos+6+i*8:pos+14+i*8]), 16, 32)
+			if err != nil {
+				t.Fatalf("bad cpio field at %d: %v", pos, err)
+			}
+			fields[i] = v
+		}
+		mode, size, namesize := fields[1], int(fields[6]), int(fields[11])
+		if namesize < 1 || pos+110+namesize > len(data) || data[pos+110+namesize-1] != 0 {
+			t.Fatalf("bad cpio name at %d", pos)
+		}
+		name := string(data[pos+110 : pos+110+namesize-1])
+		start := pad(pos + 110 + namesize)
+		if start+size > len(data) {
+			t.Fatalf("cpio entry %s runs past the end", name)
+		}
+		if name == "TRAILER!!!" {
+			if rest := data[pad(start+size):]; len(bytes.Trim(rest, "\x00")) != 0 {
+				t.Errorf("%d bytes after the cpio trailer", len(rest))
+			}
+			return files
+		}
+		if _, dup := files[name]; dup {
+			t.Errorf("cpio has %s twice", name)
+		}
+		files[name] = rpmFile{mode: uint32(mode), data: data[start : start+size]}
+		pos = pad(start + size)
+	}
+}
+
+func TestWriteFormulaAndScoop(t *testing.T) {
+	s := testSpec(t)
+	outDir := t.TempDir()
+	if _, err := WriteArchives(s, outDir); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := WriteFormula(s, outDir)
+	if err != nil {
+		t.Fatalf("WriteFormula: %v", err)
+	}
+	formula, _ := os.ReadFile(path)
+	darwin, _ := os.ReadFile(filepath.Join(outDir, "linear_1.2.0_darwin_arm64.tar.gz"))
+	for _, want := range []string{
+		"class Linear < Formula",
+		`url "https://releases.example.com/linear/linear_1.2.0_darwin_arm64.tar.gz"`,
+		`sha256 "` + sha256Hex(darwin) + `"`,
+		"on_linux do\n    on_intel do",
+		`bash_completion.install "completions/linear.bash" => "linear"`,
+		`man1.install Dir["man/*.1"]`,
+	} {
+		if !strings.Contains(string(formula), want) {
+			t.Errorf("formula missing %q:\n%s", want, formula)
+		}
+	}
+	if strings.Contains(string(formula), "fish_completion") {
+		t.Error("formula installs a fish completion that wasn't packaged")
+	}
+
+	path, err = WriteScoopManifest(s, outDir)
+	if err != nil {
+		t.Fatalf("WriteScoopManifest: %v", err)
+	}
+	var m scoopManifest
+	raw, _ := os.ReadFile(path)
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Bin != "linear.exe" || m.Architecture["64bit"].URL != "https://releases.example.com/linear/linear_1.2.0_windows_amd64.zip" || len(m.Architecture["64bit"].Hash) != 64 {
+		t.Errorf("scoop manifest = %+v", m)
+	}
+
+	if got := formulaClass("linear-mcp_cli"); got != "LinearMcpCli" {
+		t.Errorf("formulaClass = %q", got)
+	}
+}
+
+func TestWriteOCIImage(t *testing.T) {
+	s := testSpec(t)
+	path, err := WriteOCIImage(s, t.TempDir())
+	if err != nil {
+		t.Fatalf("WriteOCIImage: %v", err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	files := readTar(t, f)
+
+	if files["oci-layout"] != `{"imageLayoutVersion":"1.0.0"}` {
+		t.Errorf("oci-layout = %q", files["oci-layout"])
+	}
+	blob := func(d ociDescriptor) string {
+		data, ok := files["blobs/sha256/"+strings.TrimPrefix(d.Digest, "sha256:")]
+		if !ok || "sha256:"+sha256Hex([]byte(data)) != d.Digest || len(data) != d.Size {
+			t.Fatalf("blob %s missing or corrupt", d.Digest)
+		}
+		return data
+	}
+
+	var top ociIndex
+	if err := json.Unmarshal([]byte(files["index.json"]), &top); err != nil || len(top.Manifests) != 1 {
+		t.Fatalf("index.json = %s", files["index.json"])
+	}
+	if top.Manifests[0].Annotations["org.opencontainers.image.ref.name"] != "1.2.0" {
+		t.Errorf("ref name = %v", top.Manifests[0].Annotations)
+	}
+	var index ociIndex
+	if err := json.Unmarshal([]byte(blob(top.Manifests[0])), &index); err != nil {
+		t.Fatal(err)
+	}
+	var platforms []string
+	for _, d := range index.Manifests {
+		platforms = append(platforms, d.Platform.OS+"/"+d.Platform.Architecture+d.Platform.Variant)
+	}
+	if strings.Join(platforms, " ") != "linux/amd64 linux/armv7" {
+		t.Fatalf("platforms = %v", platforms)
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal([]byte(blob(index.Manifests[0])), &manifest); err != nil {
+		t.Fatal(err)
+	}
+	var cfg ociConfig
+	if err := json.Unmarshal([]byte(blob(manifest.Config)), &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Config.Entrypoint[0] != "/usr/local/bin/linear" || cfg.Architecture != "amd64" {
+		t.Errorf("config = %+v", cfg)
+	}
+	layer := readTarGz(t, []byte(blob(manifest.Layers[0])))
+	if layer["usr/local/bin/linear"] != "binary for linux/amd64" || layer["etc/ssl/certs/ca-certificates.crt"] == "" {
+		t.Errorf("layer files = %v", layer)
+	}
+}
@@ -0,0 +1,335 @@
+package packaging
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/thellimist/clihub/internal/compile"
+)
+
+// rpmArch maps a Go platform to its RPM architecture, or "" for non-linux
+// targets.
+func rpmArch(p compile.Platform) string {
+	if p.GOOS != "linux" {
+		return ""
+	}
+	switch p.GOARCH {
+	case "amd64":
+		return "x86_64"
+	case "arm64":
+		return "aarch64"
+	case "386":
+		return "i686"
+	case "loong64":
+		return "loongarch64"
+	case "riscv64", "ppc64le", "s390x":
+		return p.GOARCH
+	case "arm":
+		switch p.GOARM {
+		case "5":
+			return "armv5tel"
+		case "6":
+			return "armv6hl"
+		}
+		return "armv7hl"
+	}
+	return ""
+}
+
+const rpmRelease = "1"
+
+// WriteRPMs writes an .rpm for every linux binary RPM has an architecture
+// for, installing the binary to /usr/bin with completions and man pages.
+func WriteRPMs(s *Spec, outDir string) ([]string, error) {
+	var paths []string
+	for _, b := range s.Binaries {
+		arch := rpmArch(b.Platform)
+		if arch == "" {
+			continue
+		}
+		data, err := s.rpm(b, arch)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(outDir, fmt.Sprintf("%s-%s-%s.%s.rpm", s.Name, s.rpmVersion(), rpmRelease, arch))
+		if err := writeFile(path, data); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// rpmVersion returns the version with '-', which RPM forbids, turned into
+// '~' so pre-releases like 1.0.0-rc1 sort before 1.0.0.
+func (s *Spec) rpmVersion() string {
+	return strings.ReplaceAll(s.Version, "-", "~")
+}
+
+// RPM header tags and types used below; see rpmtag.h.
+const (
+	rpmTypeInt16       = 3
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeBin         = 7
+	rpmTypeStringArray = 8
+	rpmTypeI18NString  = 9
+
+	rpmTagHeaderSignatures = 62
+	rpmTagHeaderImmutable  = 63
+
+	rpmSenseLessEqualRPMLib = 1<<1 | 1<<3 | 1<<24
+	rpmSenseEqual           = 1 << 3
+	rpmDigestSHA256         = 8
+)
+
+func (s *Spec) rpm(b Binary, arch string) ([]byte, error) {
+	files, err := s.systemFiles(b)
+	if err != nil {
+		return nil, err
+	}
+
+	cpio := cpioNewc(files)
+	payload, err := gzipBytes(cpio)
+	if err != nil {
+		return nil, err
+	}
+
+	version := s.rpmVersion()
+	nvr := s.Name + "-" + version + "-" + rpmRelease
+
+	h := &rpmHeader{}
+	h.add(100, rpmTypeStringArray, 1, stringsData("C"))             // HEADERI18NTABLE
+	h.addString(1000, s.Name)                                       // NAME
+	h.addString(1001, version)                                      // VERSION
+	h.addString(1002, rpmRelease)                                   // RELEASE
+	h.add(1004, rpmTypeI18NString, 1, stringsData(s.description())) // SUMMARY
+	h.add(1005, rpmTypeI18NString, 1, stringsData(s.description())) // DESCRIPTION
+	h.addInt32(1006, int32(modTime.Unix()))                         // BUILDTIME
+	h.addString(1007, "localhost")                                  // BUILDHOST
+	license := s.License
+	if license == "" {
+		license = "Unspecified"
+	}
+	h.addString(1014, license)                                    // LICENSE
+	h.add(1016, rpmTypeI18NString, 1, stringsData("Unspecified")) // GROUP
+	if s.Homepage != "" {
+		h.addString(1020, s.Homepage) // URL
+	}
+	h.addString(1021, "linux")        // OS
+	h.addString(1022, arch)           // ARCH
+	h.addString(1044, nvr+".src.rpm") // SOURCERPM
+
+	var (
+		size                        int32
+		sizes, mtimes, flags        []int32
+		devices, inodes, dirIndexes []int32
+		modes, rdevs                []uint16
+		digests, linktos, langs     []string
+		users, groups, bases, dirs  []string
+		dirIndex                    = map[string]int32{}
+	)
+	for i, f := range files {
+		dir, base := path.Split("/" + f.path)
+		if _, ok := dirIndex[dir]; !ok {
+			dirIndex[dir] = int32(len(dirs))
+			dirs = append(dirs, dir)
+		}
+		size += int32(len(f.data))
+		sizes = append(sizes, int32(len(f.data)))
+		mtimes = append(mtimes, int32(modTime.Unix()))
+		flags = append(flags, 0)
+		devices = append(devices, 1)
+		inodes = append(inodes, int32(i+1))
+		dirIndexes = append(dirIndexes, dirIndex[dir])
+		modes = append(modes, uint16(0100000|f.mode))
+		rdevs = append(rdevs, 0)
+		digests = append(digests, sha256Hex(f.data))
+		linktos = append(linktos, "")
+		langs = append(langs, "")
+		users = append(users, "root")
+		groups = append(groups, "root")
+		bases = append(bases, base)
+	}
+	n := int32(len(files))
+	h.addInt32(1009, size)                                      // SIZE
+	h.add(1028, rpmTypeInt32, n, int32sData(sizes))             // FILESIZES
+	h.add(1030, rpmTypeInt16, n, uint16sData(modes))            // FILEMODES
+	h.add(1033, rpmTypeInt16, n, uint16sData(rdevs))            // FILERDEVS
+	h.add(1034, rpmTypeInt32, n, int32sData(mtimes))            // FILEMTIMES
+	h.add(1035, rpmTypeStringArray, n, stringsData(digests...)) // FILEDIGESTS
+	h.add(1036, rpmTypeStringArray, n, stringsData(linktos...)) // FILELINKTOS
+	h.add(1037, rpmTypeInt32, n, int32sData(flags))             // FILEFLAGS
+	h.add(1039, rpmTypeStringArray, n, stringsData(users...))   // FILEUSERNAME
+	h.add(1040, rpmTypeStringArray, n, stringsData(groups...))  // FILEGROUPNAME
+	h.add(1047, rpmTypeStringArray, 1, stringsData(s.Name))     // PROVIDENAME
+	requires := []string{"rpmlib(CompressedFileNames)", "rpmlib(FileDigests)", "rpmlib(PayloadFilesHavePrefix)"}
+	h.add(1048, rpmTypeInt32, 3, int32sData([]int32{rpmSenseLessEqualRPMLib, rpmSenseLessEqualRPMLib, rpmSenseLessEqualRPMLib})) // REQUIREFLAGS
+	h.add(1049, rpmTypeStringArray, 3, stringsData(requires...))                                                                 // REQUIRENAME
+	h.add(1050, rpmTypeStringArray, 3, stringsData("3.0.4-1", "4.6.0-1", "4.0-1"))                                               // REQUIREVERSION
+	h.add(1095, rpmTypeInt32, n, int32sData(devices))                                                                            // FILEDEVICES
+	h.add(1096, rpmTypeInt32, n, int32sData(inodes))                                                                             // FILEINODES
+	h.add(1097, rpmTypeStringArray, n, stringsData(langs...))                                                                    // FILELANGS
+	h.add(1112, rpmTypeInt32, 1, int32sData([]int32{rpmSenseEqual}))                                                             // PROVIDEFLAGS
+	h.add(1113, rpmTypeStringArray, 1, stringsData(version+"-"+rpmRelease))                                                      // PROVIDEVERSION
+	h.add(1116, rpmTypeInt32, n, int32sData(dirIndexes))                                                                         // DIRINDEXES
+	h.add(1117, rpmTypeStringArray, n, stringsData(bases...))                                                                    // BASENAMES
+	h.add(1118, rpmTypeStringArray, int32(len(dirs)), stringsData(dirs...))                                                      // DIRNAMES
+	h.addString(1124, "cpio")                                                                                                    // PAYLOADFORMAT
+	h.addString(1125, "gzip")                                                                                                    // PAYLOADCOMPRESSOR
+	h.addString(1126, "9")                                                                                                       // PAYLOADFLAGS
+	h.addInt32(5011, rpmDigestSHA256)                                                                                            // FILEDIGESTALGO
+	h.add(5092, rpmTypeStringArray, 1, stringsData(sha256Hex(payload)))                                                          // PAYLOADDIGEST
+	h.addInt32(5093, rpmDigestSHA256)                                                                                            // PAYLOADDIGESTALGO
+	header := h.encode(rpmTagHeaderImmutable)
+
+	headerSHA1 := sha1.Sum(header)
+	headerMD5 := md5.New()
+	headerMD5.Write(header)
+	headerMD5.Write(payload)
+
+	sig := &rpmHeader{}
+	sig.addString(269, hex.EncodeToString(headerSHA1[:])) // SHA1
+	sig.addString(273, sha256Hex(header))                 // SHA256
+	sig.addInt32(1000, int32(len(header)+len(payload)))   // SIZE
+	sig.add(1004, rpmTypeBin, 16, headerMD5.Sum(nil))     // MD5
+	sig.addInt32(1007, int32(len(cpio)))                  // PAYLOADSIZE
+	signature := sig.encode(rpmTagHeaderSignatures)
+
+	var buf bytes.Buffer
+	buf.Write(rpmLead(nvr))
+	buf.Write(signature)
+	// The signature header is padded to 8 bytes; the main header isn't.
+	buf.Write(make([]byte, (8-len(signature)%8)%8))
+	buf.Write(header)
+	buf.Write(payload)
+	return buf.Bytes(), nil
+}
+
+// rpmLead is the obsolete fixed-size lead every RPM still starts with.
+func rpmLead(nvr string) []byte {
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	binary.BigEndian.PutUint16(lead[6:], 0) // binary package
+	binary.BigEndian.PutUint16(lead[8:], 1)
+	copy(lead[10:75], nvr)
+	binary.BigEndian.PutUint16(lead[76:], 1) // linux
+	binary.BigEndian.PutUint16(lead[78:], 5) // header-style signature
+	return lead
+}
+
+type rpmEntry struct {
+	tag, typ, count int32
+	data            []byte
+}
+
+// rpmHeader builds an RPM header structure: an index of tagged entries over
+// a data store, wrapped in a region so rpm treats it as immutable.
+type rpmHeader struct {
+	entries []rpmEntry
+}
+
+func (h *rpmHeader) add(tag, typ, count int32, data []byte) {
+	h.entries = append(h.entries, rpmEntry{tag, typ, count, data})
+}
+
+func (h *rpmHeader) addString(tag int32, s string) {
+	h.add(tag, rpmTypeString, 1, stringsData(s))
+}
+
+func (h *rpmHeader) addInt32(tag int32, v int32) {
+	h.add(tag, rpmTypeInt32, 1, int32sData([]int32{v}))
+}
+
+func (h *rpmHeader) encode(regionTag int32) []byte {
+	entries := append([]rpmEntry(nil), h.entries...)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	var store []byte
+	offsets := make([]int32, len(entries))
+	for i, e := range entries {
+		align := map[int32]int{rpmTypeInt16: 2, rpmTypeInt32: 4}[e.typ]
+		for align > 0 && len(store)%align != 0 {
+			store = append(store, 0)
+		}
+		offsets[i] = int32(len(store))
+		store = append(store, e.data...)
+	}
+
+	nindex := int32(len(entries) + 1)
+	trailerOffset := int32(len(store))
+	store = binary.BigEndian.AppendUint32(store, uint32(regionTag))
+	store = binary.BigEndian.AppendUint32(store, rpmTypeBin)
+	store = binary.BigEndian.AppendUint32(store, uint32(-nindex*16))
+	store = binary.BigEndian.AppendUint32(store, 16)
+
+	out := []byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0}
+	out = binary.BigEndian.AppendUint32(out, uint32(nindex))
+	out = binary.BigEndian.AppendUint32(out, uint32(len(store)))
+	index := func(tag, typ, offset, count int32) {
+		for _, v := range []int32{tag, typ, offset, count} {
+			out = binary.BigEndian.AppendUint32(out, uint32(v))
+		}
+	}
+	index(regionTag, rpmTypeBin, trailerOffset, 16)
+	for i, e := range entries {
+		index(e.tag, e.typ, offsets[i], e.count)
+	}
+	return append(out, store...)
+}
+
+func stringsData(ss ...string) []byte {
+	var b []byte
+	for _, s := range ss {
+		b = append(append(b, s...), 0)
+	}
+	return b
+}
+
+func int32sData(vs []int32) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.BigEndian.AppendUint32(b, uint32(v))
+	}
+	return b
+}
+
+func uint16sData(vs []uint16) []byte {
+	var b []byte
+	for _, v := range vs {
+		b = binary.BigEndian.AppendUint16(b, v)
+	}
+	return b
+}
+
+// cpioNewc writes files as a "newc" cpio archive with ./-prefixed names, the
+// payload format rpm expects.
+func cpioNewc(files []file) []byte {
+	var buf bytes.Buffer
+	entry := func(ino int, mode int64, name string, data []byte) {
+		fmt.Fprintf(&buf, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+			ino, mode, 0, 0, 1, modTime.Unix(), len(data), 0, 0, 0, 0, len(name)+1, 0)
+		buf.WriteString(name)
+		buf.WriteByte(0)
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+		buf.Write(data)
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	for i, f := range files {
+		entry(i+1, 0100000|f.mode, "./"+f.path, f.data)
+	}
+	entry(0, 0, "TRAILER!!!", nil)
+	return buf.Bytes()
+}
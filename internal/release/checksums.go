@@ -0,0 +1,95 @@
+// Package release writes the files that let others verify generated
+// binaries: a SHA256SUMS file, an SBOM per binary and an optional signature.
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ChecksumsFile is the name of the checksum file written to the output
+// directory, in the format read by `sha256sum -c`.
+const ChecksumsFile = "SHA256SUMS"
+
+// WriteChecksums writes SHA256SUMS into dir for the given files, which must
+// live in dir. Entries already in SHA256SUMS for other files that still
+// exist are kept, so CLIs generated into the same directory share one
+// checksum file. Lines are sorted by file name so the file is reproducible.
+func WriteChecksums(dir string, files []string) (string, error) {
+	path := filepath.Join(dir, ChecksumsFile)
+	sums, err := readChecksums(path)
+	if err != nil {
+		return "", err
+	}
+	for name := range sums {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			delete(sums, name)
+		}
+	}
+	for _, f := range files {
+		name := filepath.Base(f)
+		sum, err := fileSHA256(filepath.Join(dir, name))
+		if err != nil {
+			return "", err
+		}
+		sums[name] = sum
+	}
+
+	names := make([]string, 0, len(sums))
+	for name := range sums {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", sums[name], name)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return "", fmt.Errorf("write %s: %w", ChecksumsFile, err)
+	}
+	return path, nil
+}
+
+// readChecksums parses an existing checksum file into file name → sum. A
+// missing file is empty.
+func readChecksums(path string) (map[string]string, error) {
+	sums := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return sums, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", ChecksumsFile, err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok || len(sum) != sha256.Size*2 || name == "" {
+			continue
+		}
+		sums[name] = sum
+	}
+	return sums, nil
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("checksum %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("checksum %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,68 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteChecksums(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"b-linux-amd64": "bbb", "a-darwin-arm64": "aaa"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := WriteChecksums(dir, []string{filepath.Join(dir, "b-linux-amd64"), filepath.Join(dir, "a-darwin-arm64")})
+	if err != nil {
+		t.Fatalf("WriteChecksums: %v", err)
+	}
+	if filepath.Base(path) != ChecksumsFile {
+		t.Errorf("path = %s, want %s", path, ChecksumsFile)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "9834876dcfb05cb167a5c24953eba58c4ac89b1adf57f28f2f9d09af107ee8f0  a-darwin-arm64\n" +
+		"3e744b9dc39389baf0c5a0660589b8402f3dbb49b89b3e75f2c9355852a3c677  b-linux-amd64\n"
+	if string(got) != want {
+		t.Errorf("SHA256SUMS =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteChecksums_MissingFile(t *testing.T) {
+	if _, err := WriteChecksums(t.TempDir(), []string{"missing"}); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestWriteChecksums_MergesExisting(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a-linux-amd64": "aaa", "b-linux-amd64": "bbb", "gone-linux-amd64": "ggg"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A first CLI, then a second one generated into the same directory.
+	if _, err := WriteChecksums(dir, []string{filepath.Join(dir, "a-linux-amd64"), filepath.Join(dir, "gone-linux-amd64")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "gone-linux-amd64")); err != nil {
+		t.Fatal(err)
+	}
+	path, err := WriteChecksums(dir, []string{filepath.Join(dir, "b-linux-amd64")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "9834876dcfb05cb167a5c24953eba58c4ac89b1adf57f28f2f9d09af107ee8f0  a-linux-amd64\n" +
+		"3e744b9dc39389baf0c5a0660589b8402f3dbb49b89b3e75f2c9355852a3c677  b-linux-amd64\n"
+	if string(got) != want {
+		t.Errorf("SHA256SUMS =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,184 @@
+package release
+
+import (
+	"bytes"
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime/debug"
+	"strings"
+)
+
+// CycloneDX 1.5 JSON, reduced to what a Go binary can tell us. There is no
+// timestamp or serial number so the SBOM is as reproducible as the binary.
+type cdxBOM struct {
+	BOMFormat   string         `json:"bomFormat"`
+	SpecVersion string         `json:"specVersion"`
+	Version     int            `json:"version"`
+	Metadata    cdxMetadata    `json:"metadata"`
+	Components  []cdxComponent `json:"components"`
+}
+
+type cdxMetadata struct {
+	Component cdxComponent `json:"component"`
+}
+
+type cdxComponent struct {
+	Type       string        `json:"type"`
+	BOMRef     string        `json:"bom-ref,omitempty"`
+	Name       string        `json:"name"`
+	Version    string        `json:"version,omitempty"`
+	PURL       string        `json:"purl,omitempty"`
+	Properties []cdxProperty `json:"properties,omitempty"`
+}
+
+type cdxProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// SBOMPath returns where the SBOM for a binary is written: next to it, with
+// .exe or .wasm dropped and .sbom.json added.
+func SBOMPath(binaryPath string) string {
+	binaryPath = strings.TrimSuffix(binaryPath, ".exe")
+	return strings.TrimSuffix(binaryPath, ".wasm") + ".sbom.json"
+}
+
+// WriteSBOM writes a CycloneDX SBOM listing the Go modules compiled into the
+// binary, read from its embedded build info. name and version describe the
+// binary itself.
+func WriteSBOM(binaryPath, name, version string) (string, error) {
+	info, err := buildinfo.ReadFile(binaryPath)
+	if err != nil && strings.HasSuffix(binaryPath, ".wasm") {
+		info, err = readWasmBuildInfo(binaryPath)
+	}
+	if err != nil {
+		return "", fmt.Errorf("read build info from %s: %w", binaryPath, err)
+	}
+
+	main := cdxComponent{
+		Type:    "application",
+		BOMRef:  name,
+		Name:    name,
+		Version: version,
+		Properties: []cdxProperty{
+			{Name: "go:version", Value: info.GoVersion},
+		},
+	}
+	for _, s := range info.Settings {
+		if s.Key == "GOOS" || s.Key == "GOARCH" || s.Key == "GOARM" {
+			main.Properties = append(main.Properties, cdxProperty{Name: "go:" + s.Key, Value: s.Value})
+		}
+	}
+
+	bom := cdxBOM{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.5",
+		Version:     1,
+		Metadata:    cdxMetadata{Component: main},
+		Components:  []cdxComponent{},
+	}
+	for _, dep := range info.Deps {
+		c := cdxComponent{
+			Type:    "library",
+			Name:    dep.Path,
+			Version: dep.Version,
+		}
+		if dep.Replace != nil {
+			if dep.Replace.Version != "" {
+				c.Version = dep.Replace.Version
+			}
+			c.Properties = []cdxProperty{{Name: "go:replace", Value: strings.TrimSpace(dep.Replace.Path + " " + dep.Replace.Version)}}
+		}
+		c.BOMRef = "pkg:golang/" + c.Name + "@" + c.Version
+		c.PURL = c.BOMRef
+		bom.Components = append(bom.Components, c)
+	}
+
+	data, err := json.MarshalIndent(bom, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := SBOMPath(binaryPath)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return "", fmt.Errorf("write SBOM: %w", err)
+	}
+	return path, nil
+}
+
+// modinfoStart and modinfoEnd wrap runtime.modinfo, the module info the Go
+// linker embeds as a string in every binary.
+var (
+	modinfoStart = []byte("0w\xaf\x0c\x92t\x08\x02A\xe1\xc1\x07\xe6\xd6\x18\xe6")
+	modinfoEnd   = []byte("\xf92C1\x86\x18 r\x00\x82B\x10A\x16\xd8\xf2")
+	goVersionRe  = regexp.MustCompile(`go1\.\d+(\.\d+)?((rc|beta)\d+)?`)
+)
+
+// readWasmBuildInfo recovers build info from a WebAssembly module, which
+// debug/buildinfo can't parse and the linker writes no buildinfo header for.
+// Wasm data segments are stored verbatim, so the module info is found by its
+// sentinels and the Go version is the first version string in the data.
+func readWasmBuildInfo(path string) (*debug.BuildInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	start := bytes.Index(data, modinfoStart)
+	if start < 0 {
+		return nil, errors.New("no Go module info found")
+	}
+	mod := data[start+len(modinfoStart):]
+	end := bytes.Index(mod, modinfoEnd)
+	if end < 0 {
+		return nil, errors.New("malformed Go module info")
+	}
+
+	info, err := debug.ParseBuildInfo(string(mod[:end]))
+	if err != nil {
+		return nil, err
+	}
+	info.GoVersion = string(goVersionRe.Find(data))
+	return info, nil
+}
+
+// SBOMInfo is what an SBOM written by WriteSBOM records about its binary.
+type SBOMInfo struct {
+	Name    string
+	Version string
+	GOOS    string
+	GOARCH  string
+	GOARM   string
+}
+
+// ReadSBOM reads the binary's name, version and target platform back from an
+// SBOM written by WriteSBOM.
+func ReadSBOM(path string) (*SBOMInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read SBOM: %w", err)
+	}
+	var bom cdxBOM
+	if err := json.Unmarshal(data, &bom); err != nil || bom.BOMFormat != "CycloneDX" {
+		return nil, fmt.Errorf("%s is not a CycloneDX SBOM", path)
+	}
+
+	c := bom.Metadata.Component
+	info := &SBOMInfo{Name: c.Name, Version: c.Version}
+	for _, p := range c.Properties {
+		switch p.Name {
+		case "go:GOOS":
+			info.GOOS = p.Value
+		case "go:GOARCH":
+			info.GOARCH = p.Value
+		case "go:GOARM":
+			info.GOARM = p.Value
+		}
+	}
+	if info.Name == "" || info.GOOS == "" || info.GOARCH == "" {
+		return nil, fmt.Errorf("SBOM %s does not describe a binary", path)
+	}
+	return info, nil
+}
@@ -0,0 +1,130 @@
+package release
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSBOMPath(t *testing.T) {
+	for in, want := range map[string]string{
+		"out/linear":                   "out/linear.sbom.json",
+		"out/linear-windows-amd64.exe": "out/linear-windows-amd64.sbom.json",
+		"out/linear-wasip1-wasm.wasm":  "out/linear-wasip1-wasm.sbom.json",
+	} {
+		if got := SBOMPath(in); got != want {
+			t.Errorf("SBOMPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWriteSBOM(t *testing.T) {
+	// The test binary carries build info like any Go binary.
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	binaryPath := filepath.Join(t.TempDir(), "release.test")
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(binaryPath, data, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := WriteSBOM(binaryPath, "mycli", "1.2.3")
+	if err != nil {
+		t.Fatalf("WriteSBOM: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bom cdxBOM
+	if err := json.Unmarshal(raw, &bom); err != nil {
+		t.Fatalf("parse SBOM: %v", err)
+	}
+
+	if bom.BOMFormat != "CycloneDX" || bom.SpecVersion != "1.5" {
+		t.Errorf("format = %s %s", bom.BOMFormat, bom.SpecVersion)
+	}
+	if c := bom.Metadata.Component; c.Name != "mycli" || c.Version != "1.2.3" || c.Type != "application" {
+		t.Errorf("metadata component = %+v", c)
+	}
+	props := map[string]string{}
+	for _, p := range bom.Metadata.Component.Properties {
+		props[p.Name] = p.Value
+	}
+	if props["go:version"] != runtime.Version() {
+		t.Errorf("go:version = %q, want %q", props["go:version"], runtime.Version())
+	}
+
+	info, err := ReadSBOM(path)
+	if err != nil {
+		t.Fatalf("ReadSBOM: %v", err)
+	}
+	if info.Name != "mycli" || info.Version != "1.2.3" || info.GOOS != runtime.GOOS || info.GOARCH != runtime.GOARCH {
+		t.Errorf("ReadSBOM = %+v", info)
+	}
+
+	var found bool
+	for _, c := range bom.Components {
+		if c.Name == "golang.org/x/crypto" {
+			found = true
+			if !strings.HasPrefix(c.PURL, "pkg:golang/golang.org/x/crypto@v") {
+				t.Errorf("purl = %q", c.PURL)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("golang.org/x/crypto not listed in components: %+v", bom.Components)
+	}
+}
+
+func TestWriteSBOM_Wasm(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":  "module wasmcli\n\ngo 1.24\n",
+		"main.go": "package main\n\nfunc main() {}\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	binaryPath := filepath.Join(dir, "wasmcli.wasm")
+	cmd := exec.Command("go", "build", "-trimpath", "-ldflags=-s -w -buildid=", "-o", binaryPath, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+
+	path, err := WriteSBOM(binaryPath, "wasmcli", "1.0.0")
+	if err != nil {
+		t.Fatalf("WriteSBOM: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"go:GOOS"`, `"wasip1"`, `"go:version"`} {
+		if !strings.Contains(string(raw), want) {
+			t.Errorf("SBOM missing %s:\n%s", want, raw)
+		}
+	}
+}
+
+func TestWriteSBOM_NotGoBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "script")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WriteSBOM(path, "script", "1.0.0"); err == nil {
+		t.Fatal("expected error for a non-Go binary")
+	}
+}
@@ -0,0 +1,254 @@
+package release
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Sign signs the file at path with the private key in keyPath and returns
+// the signature file it wrote:
+//
+//   - a minisign secret key writes <path>.minisig, checked with
+//     `minisign -Vm <path> -p <key>.pub`
+//   - a cosign key (or any PEM ECDSA/Ed25519 private key) writes <path>.sig,
+//     checked with `cosign verify-blob --key <key>.pub --signature <path>.sig <path>`
+//
+// Encrypted keys are unlocked with $CLIHUB_SIGN_PASSWORD; cosign keys also
+// accept $COSIGN_PASSWORD.
+func Sign(path, keyPath string) (string, error) {
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return "", fmt.Errorf("read signing key: %w", err)
+	}
+	msg, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	var sigPath string
+	var sig []byte
+	if strings.HasPrefix(string(key), "untrusted comment:") {
+		sigPath = path + ".minisig"
+		sig, err = minisignSign(msg, filepath.Base(path), key, os.Getenv("CLIHUB_SIGN_PASSWORD"))
+	} else {
+		password, ok := os.LookupEnv("CLIHUB_SIGN_PASSWORD")
+		if !ok {
+			password = os.Getenv("COSIGN_PASSWORD")
+		}
+		sigPath = path + ".sig"
+		sig, err = pemSign(msg, key, password)
+	}
+	if err != nil {
+		return "", fmt.Errorf("sign with %s: %w", keyPath, err)
+	}
+
+	if err := os.WriteFile(sigPath, sig, 0644); err != nil {
+		return "", fmt.Errorf("write signature: %w", err)
+	}
+	return sigPath, nil
+}
+
+// RemoveSignatures deletes the signature files Sign may have written for
+// path, so a rewritten file is not left with a signature that no longer
+// matches it.
+func RemoveSignatures(path string) error {
+	for _, sig := range []string{path + ".minisig", path + ".sig"} {
+		if err := os.Remove(sig); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove stale signature: %w", err)
+		}
+	}
+	return nil
+}
+
+// minisignSign produces a prehashed ("ED") minisign signature file.
+//
+// A minisign secret key is, base64-encoded on the second line:
+// sig_alg "Ed" | kdf_alg "Sc" or zeros | cksum_alg "B2" | salt[32] |
+// opslimit[8] | memlimit[8] | key_id[8] | secret_key[64] | checksum[32],
+// with the last 104 bytes XORed with scrypt output when kdf_alg is "Sc".
+func minisignSign(msg []byte, fileName string, keyFile []byte, password string) ([]byte, error) {
+	lines := strings.Split(strings.TrimSpace(string(keyFile)), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("invalid minisign secret key")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(raw) != 158 {
+		return nil, fmt.Errorf("invalid minisign secret key")
+	}
+	if string(raw[0:2]) != "Ed" || string(raw[4:6]) != "B2" {
+		return nil, fmt.Errorf("unsupported minisign key algorithm")
+	}
+
+	keynum := append([]byte(nil), raw[54:158]...)
+	switch string(raw[2:4]) {
+	case "Sc":
+		n, r, p := minisignScryptParams(binary.LittleEndian.Uint64(raw[38:46]), binary.LittleEndian.Uint64(raw[46:54]))
+		stream, err := scrypt.Key([]byte(password), raw[6:38], n, r, p, len(keynum))
+		if err != nil {
+			return nil, err
+		}
+		for i := range keynum {
+			keynum[i] ^= stream[i]
+		}
+	case "\x00\x00":
+	default:
+		return nil, fmt.Errorf("unsupported minisign key derivation")
+	}
+
+	keyID, secret, checksum := keynum[0:8], keynum[8:72], keynum[72:104]
+	want := blake2b.Sum256(append(append([]byte("Ed"), keyID...), secret...))
+	if !bytes.Equal(want[:], checksum) {
+		return nil, fmt.Errorf("wrong password for minisign key (set CLIHUB_SIGN_PASSWORD)")
+	}
+	sk := ed25519.PrivateKey(secret)
+
+	digest := blake2b.Sum512(msg)
+	sig := ed25519.Sign(sk, digest[:])
+	trusted := fmt.Sprintf("timestamp:%d\tfile:%s\thashed", time.Now().Unix(), fileName)
+	global := ed25519.Sign(sk, append(append([]byte(nil), sig...), trusted...))
+
+	var b bytes.Buffer
+	b.WriteString("untrusted comment: signature from minisign secret key\n")
+	b.WriteString(base64.StdEncoding.EncodeToString(append(append([]byte("ED"), keyID...), sig...)) + "\n")
+	b.WriteString("trusted comment: " + trusted + "\n")
+	b.WriteString(base64.StdEncoding.EncodeToString(global) + "\n")
+	return b.Bytes(), nil
+}
+
+// minisignScryptParams mirrors libsodium's pickparams, which turns the
+// opslimit and memlimit stored in a minisign key into scrypt N, r and p.
+func minisignScryptParams(opslimit, memlimit uint64) (n, r, p int) {
+	if opslimit < 32768 {
+		opslimit = 32768
+	}
+	r = 8
+	var nLog2 uint
+	if opslimit < memlimit/32 {
+		p = 1
+		maxN := opslimit / uint64(r*4)
+		for nLog2 = 1; nLog2 < 63; nLog2++ {
+			if uint64(1)<<nLog2 > maxN/2 {
+				break
+			}
+		}
+	} else {
+		maxN := memlimit / uint64(r*128)
+		for nLog2 = 1; nLog2 < 63; nLog2++ {
+			if uint64(1)<<nLog2 > maxN/2 {
+				break
+			}
+		}
+		maxrp := (opslimit / 4) / (uint64(1) << nLog2)
+		if maxrp > 0x3fffffff {
+			maxrp = 0x3fffffff
+		}
+		p = int(maxrp) / r
+	}
+	return 1 << nLog2, r, p
+}
+
+// cosignEncryptedKey is the JSON inside an "ENCRYPTED SIGSTORE PRIVATE KEY"
+// (or older "ENCRYPTED COSIGN PRIVATE KEY") PEM block.
+type cosignEncryptedKey struct {
+	KDF struct {
+		Name   string `json:"name"`
+		Params struct {
+			N int `json:"N"`
+			R int `json:"r"`
+			P int `json:"p"`
+		} `json:"params"`
+		Salt []byte `json:"salt"`
+	} `json:"kdf"`
+	Cipher struct {
+		Name  string `json:"name"`
+		Nonce []byte `json:"nonce"`
+	} `json:"cipher"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// pemSign signs msg the way `cosign sign-blob` does: base64 of an ASN.1
+// ECDSA signature over SHA-256(msg), or of an Ed25519 signature over msg.
+func pemSign(msg, keyFile []byte, password string) ([]byte, error) {
+	block, _ := pem.Decode(keyFile)
+	if block == nil {
+		return nil, fmt.Errorf("not a minisign or PEM private key")
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "ENCRYPTED SIGSTORE PRIVATE KEY", "ENCRYPTED COSIGN PRIVATE KEY":
+		der, derr := decryptCosignKey(block.Bytes, password)
+		if derr != nil {
+			return nil, derr
+		}
+		key, err = x509.ParsePKCS8PrivateKey(der)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse private key: %w", err)
+	}
+
+	var sig []byte
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(msg)
+		sig, err = ecdsa.SignASN1(rand.Reader, k, digest[:])
+		if err != nil {
+			return nil, err
+		}
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, msg)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n"), nil
+}
+
+func decryptCosignKey(data []byte, password string) ([]byte, error) {
+	var enc cosignEncryptedKey
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return nil, fmt.Errorf("parse encrypted key: %w", err)
+	}
+	if enc.KDF.Name != "scrypt" || enc.Cipher.Name != "nacl/secretbox" || len(enc.Cipher.Nonce) != 24 {
+		return nil, fmt.Errorf("unsupported key encryption %s/%s", enc.KDF.Name, enc.Cipher.Name)
+	}
+	k, err := scrypt.Key([]byte(password), enc.KDF.Salt, enc.KDF.Params.N, enc.KDF.Params.R, enc.KDF.Params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	var key [32]byte
+	var nonce [24]byte
+	copy(key[:], k)
+	copy(nonce[:], enc.Cipher.Nonce)
+	der, ok := secretbox.Open(nil, enc.Ciphertext, &nonce, &key)
+	if !ok {
+		return nil, fmt.Errorf("wrong password for encrypted key (set CLIHUB_SIGN_PASSWORD or COSIGN_PASSWORD)")
+	}
+	return der, nil
+}
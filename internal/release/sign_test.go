@@ -0,0 +1,327 @@
+package release
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// writeMinisignKey writes a minisign secret key, encrypted when password is
+// not empty. Small scrypt limits keep the test fast.
+func writeMinisignKey(t *testing.T, password string) (string, ed25519.PublicKey, []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	checksum := blake2b.Sum256(append(append([]byte("Ed"), keyID...), priv...))
+	keynum := append(append(append([]byte(nil), keyID...), priv...), checksum[:]...)
+
+	raw := []byte("Ed\x00\x00B2")
+	salt := make([]byte, 32)
+	rand.Read(salt)
+	raw = append(raw, salt...)
+	raw = binary.LittleEndian.AppendUint64(raw, 32768)
+	raw = binary.LittleEndian.AppendUint64(raw, 65536)
+	if password != "" {
+		copy(raw[2:4], "Sc")
+		n, r, p := minisignScryptParams(32768, 65536)
+		stream, err := scrypt.Key([]byte(password), salt, n, r, p, len(keynum))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range keynum {
+			keynum[i] ^= stream[i]
+		}
+	}
+	raw = append(raw, keynum...)
+
+	path := filepath.Join(t.TempDir(), "minisign.key")
+	content := "untrusted comment: minisign encrypted secret key\n" + base64.StdEncoding.EncodeToString(raw) + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, pub, keyID
+}
+
+func writeSignedFile(t *testing.T) (string, []byte) {
+	t.Helper()
+	msg := []byte("abc  mycli\n")
+	path := filepath.Join(t.TempDir(), ChecksumsFile)
+	if err := os.WriteFile(path, msg, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, msg
+}
+
+func verifyMinisig(t *testing.T, sigFile []byte, msg []byte, pub ed25519.PublicKey, keyID []byte) {
+	t.Helper()
+	lines := strings.Split(strings.TrimSpace(string(sigFile)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("minisig has %d lines:\n%s", len(lines), sigFile)
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 74 {
+		t.Fatalf("bad signature line %q", lines[1])
+	}
+	if string(sig[:2]) != "ED" || string(sig[2:10]) != string(keyID) {
+		t.Fatalf("signature header = %q %x", sig[:2], sig[2:10])
+	}
+	digest := blake2b.Sum512(msg)
+	if !ed25519.Verify(pub, digest[:], sig[10:]) {
+		t.Fatal("signature does not verify")
+	}
+	trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+	if !strings.Contains(trusted, "file:"+ChecksumsFile) {
+		t.Errorf("trusted comment = %q", trusted)
+	}
+	global, _ := base64.StdEncoding.DecodeString(lines[3])
+	if !ed25519.Verify(pub, append(append([]byte(nil), sig[10:]...), trusted...), global) {
+		t.Fatal("global signature does not verify")
+	}
+}
+
+func TestSign_Minisign(t *testing.T) {
+	keyPath, pub, keyID := writeMinisignKey(t, "")
+	path, msg := writeSignedFile(t)
+
+	sigPath, err := Sign(path, keyPath)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if sigPath != path+".minisig" {
+		t.Errorf("sigPath = %s", sigPath)
+	}
+	sigFile, err := os.ReadFile(sigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyMinisig(t, sigFile, msg, pub, keyID)
+}
+
+func TestSign_MinisignEncrypted(t *testing.T) {
+	keyPath, pub, keyID := writeMinisignKey(t, "hunter2")
+	path, msg := writeSignedFile(t)
+
+	t.Setenv("CLIHUB_SIGN_PASSWORD", "wrong")
+	if _, err := Sign(path, keyPath); err == nil || !strings.Contains(err.Error(), "wrong password") {
+		t.Fatalf("expected wrong password error, got %v", err)
+	}
+
+	t.Setenv("CLIHUB_SIGN_PASSWORD", "hunter2")
+	sigPath, err := Sign(path, keyPath)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	sigFile, _ := os.ReadFile(sigPath)
+	verifyMinisig(t, sigFile, msg, pub, keyID)
+}
+
+func TestMinisignScryptParams_Defaults(t *testing.T) {
+	// minisign's default limits: scrypt with N=2^20, r=8, p=1.
+	n, r, p := minisignScryptParams(33554432, 1073741824)
+	if n != 1<<20 || r != 8 || p != 1 {
+		t.Errorf("params = %d, %d, %d", n, r, p)
+	}
+}
+
+func TestSign_CosignEncrypted(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var enc cosignEncryptedKey
+	enc.KDF.Name = "scrypt"
+	enc.KDF.Params.N, enc.KDF.Params.R, enc.KDF.Params.P = 1024, 8, 1
+	enc.KDF.Salt = make([]byte, 32)
+	rand.Read(enc.KDF.Salt)
+	enc.Cipher.Name = "nacl/secretbox"
+	enc.Cipher.Nonce = make([]byte, 24)
+	rand.Read(enc.Cipher.Nonce)
+	k, _ := scrypt.Key([]byte("s3cret"), enc.KDF.Salt, 1024, 8, 1, 32)
+	var key [32]byte
+	var nonce [24]byte
+	copy(key[:], k)
+	copy(nonce[:], enc.Cipher.Nonce)
+	enc.Ciphertext = secretbox.Seal(nil, der, &nonce, &key)
+	data, _ := json.Marshal(enc)
+
+	keyPath := filepath.Join(t.TempDir(), "cosign.key")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED SIGSTORE PRIVATE KEY", Bytes: data}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path, msg := writeSignedFile(t)
+
+	t.Setenv("COSIGN_PASSWORD", "s3cret")
+	sigPath, err := Sign(path, keyPath)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if sigPath != path+".sig" {
+		t.Errorf("sigPath = %s", sigPath)
+	}
+	raw, _ := os.ReadFile(sigPath)
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(msg)
+	if !ecdsa.VerifyASN1(&priv.PublicKey, digest[:], sig) {
+		t.Fatal("signature does not verify")
+	}
+}
+
+// copyFixture copies a key from testdata into a temp dir, so it can be
+// passed to Sign and the signing tools by path.
+func copyFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSign_MinisignToolKey(t *testing.T) {
+	keyPath := copyFixture(t, "minisign.key")
+	pubPath := copyFixture(t, "minisign.pub")
+	path, msg := writeSignedFile(t)
+
+	t.Setenv("CLIHUB_SIGN_PASSWORD", "fixture")
+	sigPath, err := Sign(path, keyPath)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	pubFile, _ := os.ReadFile(pubPath)
+	lines := strings.Split(strings.TrimSpace(string(pubFile)), "\n")
+	pub, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+	if err != nil || len(pub) != 42 {
+		t.Fatalf("bad public key %q", pubFile)
+	}
+	sigFile, _ := os.ReadFile(sigPath)
+	verifyMinisig(t, sigFile, msg, ed25519.PublicKey(pub[10:]), pub[2:10])
+
+	if _, err := exec.LookPath("minisign"); err != nil {
+		t.Skip("minisign not installed")
+	}
+	if out, err := exec.Command("minisign", "-Vm", path, "-p", pubPath).CombinedOutput(); err != nil {
+		t.Fatalf("minisign -V: %v\n%s", err, out)
+	}
+}
+
+func TestSign_CosignToolKey(t *testing.T) {
+	keyPath := copyFixture(t, "cosign.key")
+	pubPath := copyFixture(t, "cosign.pub")
+	path, msg := writeSignedFile(t)
+
+	t.Setenv("COSIGN_PASSWORD", "fixture")
+	sigPath, err := Sign(path, keyPath)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	pubFile, _ := os.ReadFile(pubPath)
+	block, _ := pem.Decode(pubFile)
+	if block == nil {
+		t.Fatalf("bad public key %q", pubFile)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := os.ReadFile(sigPath)
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(msg)
+	if k, ok := pub.(*ecdsa.PublicKey); !ok || !ecdsa.VerifyASN1(k, digest[:], sig) {
+		t.Fatal("signature does not verify")
+	}
+
+	if _, err := exec.LookPath("cosign"); err != nil {
+		t.Skip("cosign not installed")
+	}
+	cmd := exec.Command("cosign", "verify-blob", "--key", pubPath, "--signature", sigPath, "--insecure-ignore-tlog", path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("cosign verify-blob: %v\n%s", err, out)
+	}
+}
+
+func TestSign_PlainEd25519PEM(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	keyPath := filepath.Join(t.TempDir(), "ed25519.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	path, msg := writeSignedFile(t)
+
+	sigPath, err := Sign(path, keyPath)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	raw, _ := os.ReadFile(sigPath)
+	sig, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if !ed25519.Verify(pub, msg, sig) {
+		t.Fatal("signature does not verify")
+	}
+}
+
+func TestSign_UnknownKey(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "key.txt")
+	os.WriteFile(keyPath, []byte("not a key"), 0600)
+	path, _ := writeSignedFile(t)
+	if _, err := Sign(path, keyPath); err == nil {
+		t.Fatal("expected error for unknown key format")
+	}
+}
+
+func TestRemoveSignatures(t *testing.T) {
+	keyPath, _, _ := writeMinisignKey(t, "")
+	path, _ := writeSignedFile(t)
+	sig, err := Sign(path, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+".sig", []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveSignatures(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{sig, path + ".sig"} {
+		if _, err := os.Stat(f); !os.IsNotExist(err) {
+			t.Errorf("%s not removed: %v", f, err)
+		}
+	}
+	// Nothing to remove is not an error.
+	if err := RemoveSignatures(path); err != nil {
+		t.Error(err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 )
 
 // ExtractOptions parses a JSON Schema inputSchema and returns a sorted slice
@@ -94,6 +95,15 @@ func ExtractOptions(inputSchema json.RawMessage) ([]ToolOption, error) {
 			opt.EnumValues = vals
 		}
 
+		// Examples. "example" is the OpenAPI spelling some servers use.
+		if exRaw, ok := prop["examples"].([]interface{}); ok {
+			for _, v := range exRaw {
+				opt.Examples = append(opt.Examples, FlagValue(v))
+			}
+		} else if ex, ok := prop["example"]; ok && ex != nil {
+			opt.Examples = []string{FlagValue(ex)}
+		}
+
 		options = append(options, opt)
 	}
 
@@ -107,3 +117,73 @@ func ExtractOptions(inputSchema json.RawMessage) ([]ToolOption, error) {
 
 	return options, nil
 }
+
+// FlagValue formats a schema value (an example or default) the way it would
+// be typed as a flag value: lists comma-separated, everything else with %v.
+func FlagValue(v interface{}) string {
+	if list, ok := v.([]interface{}); ok {
+		parts := make([]string, len(list))
+		for i, item := range list {
+			parts[i] = fmt.Sprintf("%v", item)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// isScalar reports whether a Go type can be given as a single positional
+// argument.
+func isScalar(goType string) bool {
+	switch goType {
+	case "string", "int", "float64":
+		return true
+	default:
+		return false
+	}
+}
+
+// PrimaryArgs returns the flag names of options that should also be accepted
+// as positional arguments. The heuristic picks the required option when a tool
+// has exactly one required scalar option, so `get-issue 123` works alongside
+// `get-issue --issue-id 123`. It returns nil when there is no obvious choice.
+func PrimaryArgs(options []ToolOption) []string {
+	var primary []string
+	for _, opt := range options {
+		if opt.Required && isScalar(opt.GoType) {
+			primary = append(primary, opt.FlagName)
+		}
+	}
+	if len(primary) != 1 {
+		return nil
+	}
+	return primary
+}
+
+// ResolveArgs maps names from a manifest "args" list to flag names. Each name
+// may be a schema property name or a flag name, and must refer to a scalar
+// option.
+func ResolveArgs(options []ToolOption, names []string) ([]string, error) {
+	flags := make([]string, 0, len(names))
+	for _, name := range names {
+		var found *ToolOption
+		for i := range options {
+			if options[i].PropertyName == name || options[i].FlagName == name {
+				found = &options[i]
+				break
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("unknown parameter %q", name)
+		}
+		if !isScalar(found.GoType) {
+			return nil, fmt.Errorf("parameter %q has type %s; only string and number parameters can be positional", name, found.GoType)
+		}
+		for _, f := range flags {
+			if f == found.FlagName {
+				return nil, fmt.Errorf("parameter %q listed twice", name)
+			}
+		}
+		flags = append(flags, found.FlagName)
+	}
+	return flags, nil
+}
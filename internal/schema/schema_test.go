@@ -2,6 +2,7 @@ package schema
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -330,3 +331,78 @@ func TestExtractOptions_SortOrder(t *testing.T) {
 		}
 	}
 }
+
+func TestExtractOptions_Examples(t *testing.T) {
+	schema := `{
+		"type": "object",
+		"properties": {
+			"team": {"type": "string", "examples": ["ENG", "OPS"]},
+			"labels": {"type": "array", "items": {"type": "string"}, "examples": [["bug", "p1"]]},
+			"limit": {"type": "integer", "example": 25},
+			"query": {"type": "string"}
+		}
+	}`
+
+	opts, err := ExtractOptions(json.RawMessage(schema))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{"team": "ENG OPS", "labels": "bug,p1", "limit": "25", "query": ""}
+	for _, o := range opts {
+		if got := strings.Join(o.Examples, " "); got != want[o.PropertyName] {
+			t.Errorf("%s examples = %q, want %q", o.PropertyName, got, want[o.PropertyName])
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Positional argument tests
+// ---------------------------------------------------------------------------
+
+func TestPrimaryArgs(t *testing.T) {
+	id := ToolOption{PropertyName: "issue_id", FlagName: "issue-id", GoType: "string", Required: true}
+	labels := ToolOption{PropertyName: "labels", FlagName: "labels", GoType: "[]string", Required: true}
+	limit := ToolOption{PropertyName: "limit", FlagName: "limit", GoType: "int"}
+	owner := ToolOption{PropertyName: "owner", FlagName: "owner", GoType: "string", Required: true}
+
+	tests := []struct {
+		name string
+		opts []ToolOption
+		want []string
+	}{
+		{"single required scalar", []ToolOption{id, limit}, []string{"issue-id"}},
+		{"required list ignored", []ToolOption{id, labels}, []string{"issue-id"}},
+		{"two required scalars are ambiguous", []ToolOption{id, owner}, nil},
+		{"nothing required", []ToolOption{limit}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PrimaryArgs(tt.opts)
+			if len(got) != len(tt.want) || (len(got) == 1 && got[0] != tt.want[0]) {
+				t.Errorf("PrimaryArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveArgs(t *testing.T) {
+	opts := []ToolOption{
+		{PropertyName: "repo_owner", FlagName: "repo-owner", GoType: "string"},
+		{PropertyName: "repo", FlagName: "repo", GoType: "string"},
+		{PropertyName: "labels", FlagName: "labels", GoType: "[]string"},
+	}
+
+	got, err := ResolveArgs(opts, []string{"repo_owner", "repo"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0] != "repo-owner" || got[1] != "repo" {
+		t.Errorf("ResolveArgs() = %v, want [repo-owner repo]", got)
+	}
+
+	for _, names := range [][]string{{"missing"}, {"labels"}, {"repo", "repo"}} {
+		if _, err := ResolveArgs(opts, names); err == nil {
+			t.Errorf("ResolveArgs(%v) expected error", names)
+		}
+	}
+}
@@ -9,4 +9,5 @@ type ToolOption struct {
 	GoType       string   // Go type: "string", "int", "float64", "bool", "[]string", "[]int"
 	DefaultValue any      // From schema default field, nil if not set
 	EnumValues   []string // From schema enum field, nil if not an enum
+	Examples     []string // From schema examples (or example) field, as CLI values
 }
@@ -0,0 +1,49 @@
+package toolfilter
+
+import "fmt"
+
+// AnnotationFilter selects tools by their MCP annotation hints.
+type AnnotationFilter struct {
+	OnlyReadOnly       bool // keep only tools annotated readOnlyHint
+	ExcludeDestructive bool // drop tools annotated destructiveHint
+}
+
+// Active reports whether any annotation filter is enabled.
+func (f AnnotationFilter) Active() bool {
+	return f.OnlyReadOnly || f.ExcludeDestructive
+}
+
+// IsDestructive applies the MCP defaults to a tool's readOnlyHint and
+// destructiveHint, either of which may be unset: a tool that is not
+// read-only is destructive unless destructiveHint is explicitly false.
+func IsDestructive(readOnlyHint, destructiveHint *bool) bool {
+	if readOnlyHint != nil && *readOnlyHint {
+		return false
+	}
+	return destructiveHint == nil || *destructiveHint
+}
+
+// FilterByAnnotations removes tools that do not satisfy f. Tools without
+// annotations are treated as not read-only and, per IsDestructive, as
+// destructive. If no tools remain, an error is returned.
+func FilterByAnnotations(tools []Tool, f AnnotationFilter) ([]Tool, error) {
+	if !f.Active() {
+		return tools, nil
+	}
+
+	var result []Tool
+	for _, t := range tools {
+		if f.OnlyReadOnly && !t.ReadOnly {
+			continue
+		}
+		if f.ExcludeDestructive && t.Destructive {
+			continue
+		}
+		result = append(result, t)
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no tools left after annotation filters — nothing to generate")
+	}
+	return result, nil
+}
@@ -9,93 +9,106 @@ import (
 type Tool struct {
 	Name        string
 	Description string
+	ReadOnly    bool // MCP readOnlyHint
+	Destructive bool // MCP destructiveHint
 }
 
-// ParseToolList splits a comma-separated string into a deduplicated, trimmed
-// list of tool names. Empty entries are removed and order is preserved (first
-// occurrence wins on duplicates).
-func ParseToolList(csv string) []string {
-	if csv == "" {
-		return nil
-	}
-
-	parts := strings.Split(csv, ",")
+// ParseToolList splits comma-separated values, one per --include-tools or
+// --exclude-tools flag, into a deduplicated, trimmed list of entries. Empty
+// entries are removed and order is preserved (first occurrence wins on
+// duplicates). A "re:" entry runs to the end of its value, so a regular
+// expression may contain commas ("re:^a{1,3}$"); repeat the flag to list
+// more entries after it.
+func ParseToolList(values ...string) []string {
 	seen := make(map[string]struct{})
 	var result []string
 
-	for _, p := range parts {
-		name := strings.TrimSpace(p)
-		if name == "" {
-			continue
-		}
-		if _, dup := seen[name]; dup {
-			continue
+	for _, value := range values {
+		for value != "" {
+			part := strings.TrimLeft(value, " \t")
+			if strings.HasPrefix(part, "re:") {
+				value = ""
+			} else {
+				part, value, _ = strings.Cut(value, ",")
+			}
+			name := strings.TrimSpace(part)
+			if name == "" {
+				continue
+			}
+			if _, dup := seen[name]; dup {
+				continue
+			}
+			seen[name] = struct{}{}
+			result = append(result, name)
 		}
-		seen[name] = struct{}{}
-		result = append(result, name)
 	}
 
 	return result
 }
 
-// FilterTools applies include or exclude filtering to a list of tools.
+// FilterTools applies include-then-exclude filtering to a list of tools.
+//
+// Each entry in include and exclude is an exact tool name, a glob pattern
+// (e.g. "list_*", see path.Match) or a regular expression prefixed with "re:"
+// (e.g. "re:^(get|list)_").
 //
 // Rules:
-//   - If both include and exclude are non-empty, an error is returned
-//     (defense-in-depth; the flag layer should prevent this).
-//   - Include mode: only tools whose Name appears in include are kept.
-//     Any include name that does not match a tool produces an error with a
-//     suggestion when one is close (Levenshtein <= 3).
-//   - Exclude mode: tools whose Name appears in exclude are removed.
-//     If that leaves zero tools, an error is returned.
+//   - Include: when include is non-empty, only tools matching at least one
+//     entry are kept. An exact name that does not match a tool produces an
+//     error with a suggestion when one is close (Levenshtein <= 3); a pattern
+//     that matches nothing is also an error.
+//   - Exclude: tools matching any exclude entry are then removed from the
+//     included set.
+//   - If that leaves zero tools, an error is returned.
 //   - If both slices are empty, all tools are returned unchanged.
+//
+// The returned tools keep the order of the input slice.
 func FilterTools(tools []Tool, include, exclude []string) ([]Tool, error) {
-	if len(include) > 0 && len(exclude) > 0 {
-		return nil, fmt.Errorf("--include-tools and --exclude-tools cannot be used together")
-	}
-
 	// No filtering — pass through.
 	if len(include) == 0 && len(exclude) == 0 {
 		return tools, nil
 	}
 
-	// Build a name→tool index and a list of available names.
-	byName := make(map[string]Tool, len(tools))
+	includeMatchers, err := compileMatchers(include)
+	if err != nil {
+		return nil, err
+	}
+	excludeMatchers, err := compileMatchers(exclude)
+	if err != nil {
+		return nil, err
+	}
+
 	available := make([]string, 0, len(tools))
 	for _, t := range tools {
-		byName[t.Name] = t
 		available = append(available, t.Name)
 	}
 
-	// Include mode.
-	if len(include) > 0 {
-		var result []Tool
-		for _, name := range include {
-			t, ok := byName[name]
-			if !ok {
-				msg := fmt.Sprintf("tool '%s' not found on server. Available tools: %s",
-					name, strings.Join(available, ", "))
-				if suggestion := SuggestTool(name, available); suggestion != "" {
-					msg += fmt.Sprintf(" Did you mean '%s'?", suggestion)
-				}
-				return nil, fmt.Errorf("%s", msg)
-			}
-			result = append(result, t)
+	// Every include entry must select at least one tool.
+	for _, m := range includeMatchers {
+		if matchesAny(m, tools) {
+			continue
 		}
-		return result, nil
-	}
-
-	// Exclude mode.
-	excludeSet := make(map[string]struct{}, len(exclude))
-	for _, name := range exclude {
-		excludeSet[name] = struct{}{}
+		if m.isPattern {
+			return nil, fmt.Errorf("pattern '%s' matched no tools on server. Available tools: %s",
+				m.raw, strings.Join(available, ", "))
+		}
+		msg := fmt.Sprintf("tool '%s' not found on server. Available tools: %s",
+			m.raw, strings.Join(available, ", "))
+		if suggestion := SuggestTool(m.raw, available); suggestion != "" {
+			msg += fmt.Sprintf(" Did you mean '%s'?", suggestion)
+		}
+		return nil, fmt.Errorf("%s", msg)
 	}
 
 	var result []Tool
 	for _, t := range tools {
-		if _, skip := excludeSet[t.Name]; !skip {
-			result = append(result, t)
+		if len(includeMatchers) > 0 && !matchesName(includeMatchers, t.Name) {
+			continue
+		}
+		if matchesName(excludeMatchers, t.Name) {
+			continue
 		}
+		result = append(result, t)
 	}
 
 	if len(result) == 0 {
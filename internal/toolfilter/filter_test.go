@@ -12,34 +12,44 @@ import (
 func TestParseToolList(t *testing.T) {
 	tests := []struct {
 		name  string
-		input string
+		input []string
 		want  []string
 	}{
 		{
 			name:  "basic comma separated",
-			input: "foo, bar, baz",
+			input: []string{"foo, bar, baz"},
 			want:  []string{"foo", "bar", "baz"},
 		},
 		{
 			name:  "deduplication preserves order",
-			input: "foo, bar, foo",
+			input: []string{"foo, bar, foo"},
 			want:  []string{"foo", "bar"},
 		},
 		{
 			name:  "trim whitespace and skip empty",
-			input: "  a , b ,  ",
+			input: []string{"  a , b ,  "},
 			want:  []string{"a", "b"},
 		},
 		{
 			name:  "empty string",
-			input: "",
+			input: []string{""},
 			want:  nil,
 		},
+		{
+			name:  "repeated flag values",
+			input: []string{"foo,bar", "baz", "foo"},
+			want:  []string{"foo", "bar", "baz"},
+		},
+		{
+			name:  "regex keeps its commas",
+			input: []string{"list_*, re:^a{1,3}$", "re:^(get|list)_,x"},
+			want:  []string{"list_*", "re:^a{1,3}$", "re:^(get|list)_,x"},
+		},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			got := ParseToolList(tc.input)
+			got := ParseToolList(tc.input...)
 			if !strSliceEqual(got, tc.want) {
 				t.Errorf("ParseToolList(%q) = %v, want %v", tc.input, got, tc.want)
 			}
@@ -140,13 +150,14 @@ func TestFilterToolsExclude(t *testing.T) {
 // FilterTools — edge cases
 // ---------------------------------------------------------------------------
 
-func TestFilterToolsBothIncludeAndExclude(t *testing.T) {
-	_, err := FilterTools(nil, []string{"a"}, []string{"b"})
-	if err == nil {
-		t.Fatal("expected error when both include and exclude provided")
+func TestFilterToolsIncludeThenExclude(t *testing.T) {
+	tools := []Tool{{Name: "list_issues"}, {Name: "list_secrets"}, {Name: "create_issue"}}
+	got, err := FilterTools(tools, []string{"list_*"}, []string{"list_secrets"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if !strings.Contains(err.Error(), "cannot be used together") {
-		t.Errorf("unexpected error message: %v", err)
+	if !toolNamesEqual(got, []string{"list_issues"}) {
+		t.Errorf("got tools %v, want [list_issues]", toolNames(got))
 	}
 }
 
@@ -161,6 +172,114 @@ func TestFilterToolsNoFilter(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// FilterTools — patterns
+// ---------------------------------------------------------------------------
+
+func TestFilterToolsPatterns(t *testing.T) {
+	tools := []Tool{
+		{Name: "get_issue"},
+		{Name: "list_issues"},
+		{Name: "list_teams"},
+		{Name: "delete_issue"},
+	}
+
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    []string
+		wantErr string
+	}{
+		{"glob include", []string{"list_*"}, nil, []string{"list_issues", "list_teams"}, ""},
+		{"regex include", []string{"re:^(get|list)_issue"}, nil, []string{"get_issue", "list_issues"}, ""},
+		{"glob exclude", nil, []string{"*_issue"}, []string{"list_issues", "list_teams"}, ""},
+		{"mixed exact and glob keeps server order", []string{"delete_issue", "get_*"}, nil, []string{"get_issue", "delete_issue"}, ""},
+		{"pattern matching nothing", []string{"search_*"}, nil, nil, "pattern 'search_*' matched no tools"},
+		{"invalid regex", []string{"re:("}, nil, nil, "invalid tool pattern 're:('"},
+		{"invalid glob", nil, []string{"list_["}, nil, "invalid tool pattern 'list_['"},
+		{"exclude everything included", []string{"list_*"}, []string{"re:^list_"}, nil, "all tools excluded"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := FilterTools(tools, tc.include, tc.exclude)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !toolNamesEqual(got, tc.want) {
+				t.Errorf("got tools %v, want %v", toolNames(got), tc.want)
+			}
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FilterByAnnotations tests
+// ---------------------------------------------------------------------------
+
+func TestIsDestructive(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name                  string
+		readOnly, destructive *bool
+		want                  bool
+	}{
+		{"unannotated", nil, nil, true},
+		{"read-only", &yes, nil, false},
+		{"read-only wins", &yes, &yes, false},
+		{"not read-only", &no, nil, true},
+		{"explicitly not destructive", nil, &no, false},
+		{"explicitly destructive", &no, &yes, true},
+	}
+	for _, tt := range tests {
+		if got := IsDestructive(tt.readOnly, tt.destructive); got != tt.want {
+			t.Errorf("%s: IsDestructive = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFilterByAnnotations(t *testing.T) {
+	tools := []Tool{
+		{Name: "get_issue", ReadOnly: true},
+		{Name: "update_issue"},
+		{Name: "delete_issue", Destructive: true},
+	}
+
+	t.Run("only read-only", func(t *testing.T) {
+		got, err := FilterByAnnotations(tools, AnnotationFilter{OnlyReadOnly: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !toolNamesEqual(got, []string{"get_issue"}) {
+			t.Errorf("got tools %v, want [get_issue]", toolNames(got))
+		}
+	})
+
+	t.Run("exclude destructive", func(t *testing.T) {
+		got, err := FilterByAnnotations(tools, AnnotationFilter{ExcludeDestructive: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !toolNamesEqual(got, []string{"get_issue", "update_issue"}) {
+			t.Errorf("got tools %v, want [get_issue update_issue]", toolNames(got))
+		}
+	})
+
+	t.Run("nothing left errors", func(t *testing.T) {
+		_, err := FilterByAnnotations(tools[1:], AnnotationFilter{OnlyReadOnly: true})
+		if err == nil || !strings.Contains(err.Error(), "no tools left") {
+			t.Fatalf("expected no tools left error, got %v", err)
+		}
+	})
+}
+
 // ---------------------------------------------------------------------------
 // LevenshteinDistance tests
 // ---------------------------------------------------------------------------
@@ -241,3 +360,18 @@ func toolNames(tools []Tool) []string {
 func toolNamesEqual(tools []Tool, want []string) bool {
 	return strSliceEqual(toolNames(tools), want)
 }
+
+func TestFilterToolsRegexWithComma(t *testing.T) {
+	tools := []Tool{{Name: "a"}, {Name: "aa"}, {Name: "aaaa"}, {Name: "b"}}
+	got, err := FilterTools(tools, ParseToolList("re:^a{1,3}$"), nil)
+	if err != nil {
+		t.Fatalf("FilterTools: %v", err)
+	}
+	var names []string
+	for _, tool := range got {
+		names = append(names, tool.Name)
+	}
+	if !strSliceEqual(names, []string{"a", "aa"}) {
+		t.Errorf("re:^a{1,3}$ selected %v, want [a aa]", names)
+	}
+}
@@ -0,0 +1,74 @@
+package toolfilter
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks a filter entry as a regular expression.
+const regexPrefix = "re:"
+
+// matcher matches tool names against a single filter entry.
+type matcher struct {
+	raw       string
+	isPattern bool // false for exact names
+	re        *regexp.Regexp
+}
+
+// compileMatchers turns filter entries into matchers, validating glob and
+// regex syntax up front so typos are reported before connecting anywhere.
+func compileMatchers(entries []string) ([]matcher, error) {
+	matchers := make([]matcher, 0, len(entries))
+	for _, e := range entries {
+		m := matcher{raw: e}
+		switch {
+		case strings.HasPrefix(e, regexPrefix):
+			re, err := regexp.Compile(strings.TrimPrefix(e, regexPrefix))
+			if err != nil {
+				return nil, fmt.Errorf("invalid tool pattern '%s': %s", e, err)
+			}
+			m.isPattern = true
+			m.re = re
+		case strings.ContainsAny(e, "*?["):
+			if _, err := path.Match(e, ""); err != nil {
+				return nil, fmt.Errorf("invalid tool pattern '%s': %s", e, err)
+			}
+			m.isPattern = true
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
+// match reports whether name is selected by this entry.
+func (m matcher) match(name string) bool {
+	switch {
+	case m.re != nil:
+		return m.re.MatchString(name)
+	case m.isPattern:
+		ok, _ := path.Match(m.raw, name)
+		return ok
+	default:
+		return m.raw == name
+	}
+}
+
+func matchesName(matchers []matcher, name string) bool {
+	for _, m := range matchers {
+		if m.match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(m matcher, tools []Tool) bool {
+	for _, t := range tools {
+		if m.match(t.Name) {
+			return true
+		}
+	}
+	return false
+}
@@ -138,9 +138,9 @@ func TestSetAndGetToken(t *testing.T) {
 		Version: 2,
 		Servers: make(map[string]ServerCredential),
 	}
-	SetToken(creds, "https://example.com", "mytoken")
+	SetToken(creds, "https://example.com", "", "mytoken")
 
-	got := GetToken(creds, "https://example.com")
+	got := GetToken(creds, "https://example.com", "")
 	if got != "mytoken" {
 		t.Errorf("GetToken = %q, want %q", got, "mytoken")
 	}
@@ -157,7 +157,7 @@ func TestGetToken_EmptyMap(t *testing.T) {
 		Version: 2,
 		Servers: make(map[string]ServerCredential),
 	}
-	got := GetToken(creds, "https://nonexistent.com")
+	got := GetToken(creds, "https://nonexistent.com", "")
 	if got != "" {
 		t.Errorf("GetToken = %q, want empty string", got)
 	}
@@ -165,7 +165,7 @@ func TestGetToken_EmptyMap(t *testing.T) {
 
 func TestGetToken_NilMap(t *testing.T) {
 	creds := &CredentialsFile{Version: 2}
-	got := GetToken(creds, "https://example.com")
+	got := GetToken(creds, "https://example.com", "")
 	if got != "" {
 		t.Errorf("GetToken with nil map = %q, want empty string", got)
 	}
@@ -173,12 +173,12 @@ func TestGetToken_NilMap(t *testing.T) {
 
 func TestSetToken_NilServersMap(t *testing.T) {
 	creds := &CredentialsFile{Version: 2}
-	SetToken(creds, "https://example.com", "tok")
+	SetToken(creds, "https://example.com", "", "tok")
 
 	if creds.Servers == nil {
 		t.Fatal("Servers should be initialized, got nil")
 	}
-	got := GetToken(creds, "https://example.com")
+	got := GetToken(creds, "https://example.com", "")
 	if got != "tok" {
 		t.Errorf("GetToken = %q, want %q", got, "tok")
 	}
@@ -191,7 +191,7 @@ func TestSetToken_NilServersMap(t *testing.T) {
 func TestSetAndGetOAuthTokens(t *testing.T) {
 	creds := &CredentialsFile{Version: 2, Servers: make(map[string]ServerCredential)}
 	exp := time.Now().Add(1 * time.Hour)
-	SetOAuthTokens(creds, "https://mcp.notion.com", OAuthTokens{
+	SetOAuthTokens(creds, "https://mcp.notion.com", "", OAuthTokens{
 		AccessToken:   "access-1",
 		RefreshToken:  "refresh-1",
 		ExpiresAt:     exp,
@@ -225,14 +225,14 @@ func TestSetAndGetOAuthTokens(t *testing.T) {
 func TestGetToken_OAuthType(t *testing.T) {
 	creds := &CredentialsFile{Version: 2, Servers: make(map[string]ServerCredential)}
 	exp := time.Now().Add(1 * time.Hour)
-	SetOAuthTokens(creds, "https://mcp.example.com", OAuthTokens{
+	SetOAuthTokens(creds, "https://mcp.example.com", "", OAuthTokens{
 		AccessToken:  "oauth-access",
 		RefreshToken: "refresh",
 		ExpiresAt:    exp,
 		ClientID:     "cid",
 	})
 
-	got := GetToken(creds, "https://mcp.example.com")
+	got := GetToken(creds, "https://mcp.example.com", "")
 	if got != "oauth-access" {
 		t.Errorf("GetToken = %q, want %q", got, "oauth-access")
 	}
@@ -241,7 +241,7 @@ func TestGetToken_OAuthType(t *testing.T) {
 func TestGetOAuthCredential(t *testing.T) {
 	creds := &CredentialsFile{Version: 2, Servers: make(map[string]ServerCredential)}
 	exp := time.Now().Add(1 * time.Hour)
-	SetOAuthTokens(creds, "https://example.com", OAuthTokens{
+	SetOAuthTokens(creds, "https://example.com", "", OAuthTokens{
 		AccessToken:  "a",
 		RefreshToken: "r",
 		ExpiresAt:    exp,
@@ -249,7 +249,7 @@ func TestGetOAuthCredential(t *testing.T) {
 		Scope:        "s",
 	})
 
-	sc := GetOAuthCredential(creds, "https://example.com")
+	sc := GetOAuthCredential(creds, "https://example.com", "")
 	if sc == nil {
 		t.Fatal("expected non-nil credential")
 	}
@@ -258,8 +258,8 @@ func TestGetOAuthCredential(t *testing.T) {
 	}
 
 	// Bearer type should return nil
-	SetToken(creds, "https://bearer.com", "tok")
-	if got := GetOAuthCredential(creds, "https://bearer.com"); got != nil {
+	SetToken(creds, "https://bearer.com", "", "tok")
+	if got := GetOAuthCredential(creds, "https://bearer.com", ""); got != nil {
 		t.Error("expected nil for bearer credential")
 	}
 }
@@ -301,7 +301,7 @@ func TestCredentialsBackwardCompatibility(t *testing.T) {
 	if creds.Version != 2 {
 		t.Errorf("Version = %d, want 2 (auto-migrated)", creds.Version)
 	}
-	got := GetToken(creds, "https://old.com")
+	got := GetToken(creds, "https://old.com", "")
 	if got != "old-tok" {
 		t.Errorf("got %q, want %q", got, "old-tok")
 	}
@@ -334,7 +334,7 @@ func TestV1OAuthMigration(t *testing.T) {
 	if sc.RefreshToken != "rt" {
 		t.Errorf("RefreshToken = %q, want %q", sc.RefreshToken, "rt")
 	}
-	got := GetToken(creds, "https://mcp.notion.com")
+	got := GetToken(creds, "https://mcp.notion.com", "")
 	if got != "at" {
 		t.Errorf("GetToken = %q, want %q", got, "at")
 	}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	bundleKind = "clihub-credentials"
+	bundleAAD  = "clihub-bundle-v1"
+)
+
+// MinBundlePassphrase is the shortest passphrase accepted for a bundle.
+const MinBundlePassphrase = 8
+
+// credentialsBundle is the on-disk format of an exported credentials bundle:
+// the credentials, secrets included, encrypted with AES-256-GCM under a key
+// derived from a passphrase with PBKDF2.
+type credentialsBundle struct {
+	Kind       string `json:"kind"`
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// ExportBundle encrypts entries, secrets included, with passphrase. The
+// result can be moved to another machine and read with ImportBundle.
+func ExportBundle(entries []CredentialEntry, passphrase string) ([]byte, error) {
+	if len(passphrase) < MinBundlePassphrase {
+		return nil, fmt.Errorf("bundle passphrase must be at least %d characters", MinBundlePassphrase)
+	}
+	creds := &CredentialsFile{Version: 2, Servers: map[string]ServerCredential{}}
+	for _, e := range entries {
+		sc := e.Credential
+		sc.SecretStore = "" // secrets are inline in the bundle
+		creds.SetCredential(e.ServerURL, e.Profile, sc)
+	}
+	plain, err := json.Marshal(creds)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	key := &encryptedFileStore{kdf: kdfPBKDF2, secret: []byte(passphrase)}
+	aead, err := key.aead(salt, pbkdf2Iterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(credentialsBundle{
+		Kind:       bundleKind,
+		Version:    1,
+		KDF:        kdfPBKDF2,
+		Iterations: pbkdf2Iterations,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plain, []byte(bundleAAD)),
+	}, "", "  ")
+}
+
+// ImportBundle decrypts a bundle written by ExportBundle.
+func ImportBundle(data []byte, passphrase string) ([]CredentialEntry, error) {
+	var bundle credentialsBundle
+	if err := json.Unmarshal(data, &bundle); err != nil || bundle.Kind != bundleKind {
+		return nil, fmt.Errorf("not a clihub credentials bundle")
+	}
+	if bundle.Version != 1 || bundle.KDF != kdfPBKDF2 {
+		return nil, fmt.Errorf("unsupported credentials bundle (version %d, %s)", bundle.Version, bundle.KDF)
+	}
+	key := &encryptedFileStore{kdf: kdfPBKDF2, secret: []byte(passphrase)}
+	aead, err := key.aead(bundle.Salt, bundle.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(bundle.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("corrupt credentials bundle")
+	}
+	plain, err := aead.Open(nil, bundle.Nonce, bundle.Ciphertext, []byte(bundleAAD))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt credentials bundle: wrong passphrase")
+	}
+	var creds CredentialsFile
+	if err := json.Unmarshal(plain, &creds); err != nil {
+		return nil, fmt.Errorf("parse credentials bundle: %w", err)
+	}
+	return creds.Entries(), nil
+}
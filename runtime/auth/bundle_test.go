@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBundleRoundTrip(t *testing.T) {
+	creds := sampleCredentials()
+	creds.SetCredential("https://a.example.com", "work", ServerCredential{AuthType: "bearer_token", Token: "work-token", SecretStore: SecretStoreFile})
+
+	data, err := ExportBundle(creds.Entries(), "correct horse")
+	if err != nil {
+		t.Fatalf("ExportBundle: %v", err)
+	}
+	for _, secret := range []string{"access-a", "hunter2", "work-token"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("bundle contains %q in plain text", secret)
+		}
+	}
+
+	entries, err := ImportBundle(data, "correct horse")
+	if err != nil {
+		t.Fatalf("ImportBundle: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("imported %d entries, want 4", len(entries))
+	}
+	if e := entries[1]; e.ServerURL != "https://a.example.com" || e.Profile != "work" || e.Credential.Token != "work-token" || e.Credential.SecretStore != "" {
+		t.Errorf("work entry = %+v", e)
+	}
+	if got := entries[2].Credential.Password; got != "hunter2" {
+		t.Errorf("basic auth password = %q", got)
+	}
+
+	if _, err := ImportBundle(data, "wrong horse"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("wrong passphrase error = %v", err)
+	}
+	if _, err := ImportBundle([]byte(`{"version":2}`), "correct horse"); err == nil {
+		t.Error("expected an error for a non-bundle file")
+	}
+	if _, err := ExportBundle(creds.Entries(), "short"); err == nil {
+		t.Error("expected an error for a short passphrase")
+	}
+}
+
+func TestEntriesOrder(t *testing.T) {
+	creds := sampleCredentials()
+	creds.SetCredential("https://a.example.com", "zeta", ServerCredential{AuthType: "bearer_token"})
+	creds.SetCredential("https://a.example.com", "alpha", ServerCredential{AuthType: "bearer_token"})
+
+	var got []string
+	for _, e := range creds.Entries() {
+		got = append(got, e.ServerURL+" "+e.Profile)
+	}
+	want := "https://a.example.com default,https://a.example.com alpha,https://a.example.com zeta,https://b.example.com default,https://c.example.com default"
+	if strings.Join(got, ",") != want {
+		t.Errorf("Entries = %v", got)
+	}
+}
+
+func TestServerCredentialRedacted(t *testing.T) {
+	sc := ServerCredential{AuthType: "oauth2", AccessToken: "a", RefreshToken: "r", ClientID: "id", Scope: "read"}
+	r := sc.Redacted()
+	if r.AccessToken != "REDACTED" || r.RefreshToken != "REDACTED" || r.ClientSecret != "" {
+		t.Errorf("Redacted = %+v", r)
+	}
+	if r.ClientID != "id" || r.Scope != "read" {
+		t.Errorf("Redacted dropped metadata: %+v", r)
+	}
+	if sc.AccessToken != "a" {
+		t.Error("Redacted modified the original")
+	}
+}
@@ -0,0 +1,321 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CredentialsFile represents the ~/.clihub/credentials.json file.
+// Servers holds the default profile; named profiles live under Profiles so
+// older readers keep seeing the default identity.
+type CredentialsFile struct {
+	Version int                         `json:"version"`
+	Servers map[string]ServerCredential `json:"servers"`
+	// Profiles maps profile name → server URL → credential.
+	Profiles map[string]map[string]ServerCredential `json:"profiles,omitempty"`
+	// DefaultProfiles maps server URL → profile selected with `auth use`.
+	DefaultProfiles map[string]string `json:"default_profiles,omitempty"`
+
+	// loaded maps store name → secret key → the secrets read from that
+	// store, so a save only writes what changed.
+	loaded map[string]map[string]string
+	// server is set by LoadServerCredentials: only that server's secrets
+	// were read, so the file must not be saved whole.
+	server string
+}
+
+// ServerCredential holds auth info for a single server.
+// v2 schema uses AuthType to identify the provider; v1 used Type.
+type ServerCredential struct {
+	// v2 field — identifies which AuthProvider to instantiate
+	AuthType string `json:"auth_type,omitempty"`
+	// v1 field — kept for backwards compat during migration
+	Type string `json:"type,omitempty"`
+
+	// bearer_token / api_key
+	Token      string `json:"token,omitempty"`
+	HeaderName string `json:"header_name,omitempty"` // api_key header name
+
+	// basic_auth
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+
+	// oauth2
+	AccessToken  string     `json:"access_token,omitempty"`
+	RefreshToken string     `json:"refresh_token,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	ClientID     string     `json:"client_id,omitempty"`
+	Scope        string     `json:"scope,omitempty"`
+
+	// s2s_oauth2
+	ClientSecret  string `json:"client_secret,omitempty"`
+	TokenEndpoint string `json:"token_endpoint,omitempty"`
+
+	// google_sa
+	KeyFile string   `json:"key_file,omitempty"`
+	Scopes  []string `json:"scopes,omitempty"`
+
+	// token_exchange (TokenEndpoint, Scope, ClientID, ClientSecret shared)
+	SubjectTokenFile string `json:"subject_token_file,omitempty"`
+	SubjectTokenEnv  string `json:"subject_token_env,omitempty"`
+	SubjectTokenType string `json:"subject_token_type,omitempty"`
+	Audience         string `json:"audience,omitempty"`
+	Resource         string `json:"resource,omitempty"`
+
+	// exec
+	Command string `json:"command,omitempty"`
+
+	// SecretStore names the SecretStore holding this entry's secret fields.
+	// Empty means they are stored inline in the JSON file.
+	SecretStore string `json:"secret_store,omitempty"`
+}
+
+// ResolveAuthType returns the effective auth type, handling both v1 and v2 schemas.
+func (sc *ServerCredential) ResolveAuthType() string {
+	if sc.AuthType != "" {
+		return sc.AuthType
+	}
+	// v1 fallback
+	switch sc.Type {
+	case "bearer":
+		return "bearer_token"
+	case "oauth":
+		return "oauth2"
+	default:
+		return sc.Type
+	}
+}
+
+// LoadCredentials reads and parses a credentials file at the given path.
+// If the file does not exist, it returns an empty CredentialsFile with
+// Version=2 and an empty Servers map (not an error).
+// v1 credentials are auto-migrated to v2 on load. Secret fields kept in a
+// SecretStore are filled back in.
+func LoadCredentials(path string) (*CredentialsFile, error) {
+	return loadAndMigrate(path, "")
+}
+
+// LoadServerCredentials is LoadCredentials for callers that need one
+// server: only the secrets of serverURL's credentials (in every profile)
+// are read from their secret stores, so other servers' keyring entries are
+// not touched. The result cannot be passed to SaveCredentials; change
+// entries with UpdateCredentials.
+func LoadServerCredentials(path, serverURL string) (*CredentialsFile, error) {
+	return loadAndMigrate(path, serverURL)
+}
+
+func loadAndMigrate(path, serverURL string) (*CredentialsFile, error) {
+	creds, migrated, err := loadCredentials(path, serverURL)
+	if err != nil {
+		return nil, err
+	}
+	if migrated {
+		// Save migrated file back (transparent upgrade)
+		_ = UpdateCredentials(path, func(*CredentialsFile) error { return nil })
+	}
+	return creds, nil
+}
+
+// loadCredentials is LoadCredentials without saving a migrated file. It
+// reports whether the file was migrated from v1. A non-empty serverURL
+// limits the secrets read to that server's.
+func loadCredentials(path, serverURL string) (*CredentialsFile, bool, error) {
+	creds, err := readCredentialsFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if creds == nil {
+		return &CredentialsFile{
+			Version: 2,
+			Servers: make(map[string]ServerCredential),
+		}, false, nil
+	}
+	creds.server = serverURL
+	if err := loadSecrets(path, creds, serverURL); err != nil {
+		return nil, false, err
+	}
+
+	// Auto-migrate v1 → v2
+	if creds.Version < 2 {
+		migrateV1ToV2(creds)
+		return creds, true, nil
+	}
+	return creds, false, nil
+}
+
+// readCredentialsFile parses the credentials file as stored, without
+// resolving secrets. It returns nil if the file does not exist.
+func readCredentialsFile(path string) (*CredentialsFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var creds CredentialsFile
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return nil, err
+	}
+	if creds.Servers == nil {
+		creds.Servers = make(map[string]ServerCredential)
+	}
+	return &creds, nil
+}
+
+// migrateV1ToV2 converts v1 credential entries to v2 format.
+func migrateV1ToV2(creds *CredentialsFile) {
+	creds.Version = 2
+	for url, sc := range creds.Servers {
+		if sc.AuthType != "" {
+			continue // Already v2
+		}
+		switch sc.Type {
+		case "bearer":
+			sc.AuthType = "bearer_token"
+		case "oauth":
+			sc.AuthType = "oauth2"
+		default:
+			if sc.Token != "" {
+				sc.AuthType = "bearer_token"
+			}
+		}
+		creds.Servers[url] = sc
+	}
+}
+
+// SaveCredentials writes the credentials to the given path. It creates
+// the parent directory with 0700 permissions if needed, and writes the
+// file with 0600 permissions (owner-only read/write). Secret fields go to
+// the store chosen by DefaultSecretStore; creds itself is not modified.
+//
+// SaveCredentials replaces the whole file. To change some entries while
+// other processes may be writing, use UpdateCredentials instead.
+func SaveCredentials(path string, creds *CredentialsFile) error {
+	if creds.server != "" {
+		return fmt.Errorf("credentials loaded for %s only cannot be saved whole; use UpdateCredentials", creds.server)
+	}
+	unlock, err := lockFile(credentialsLockPath(path))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return saveCredentials(path, creds)
+}
+
+// UpdateCredentials applies fn to the current contents of the credentials
+// file and saves the result, holding the credentials lock throughout so
+// concurrent updates from other processes are not lost. Nothing is written
+// if fn returns an error.
+func UpdateCredentials(path string, fn func(*CredentialsFile) error) error {
+	unlock, err := lockFile(credentialsLockPath(path))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	creds, _, err := loadCredentials(path, "")
+	if err != nil {
+		return err
+	}
+	if err := fn(creds); err != nil {
+		return err
+	}
+	return saveCredentials(path, creds)
+}
+
+// saveCredentials writes creds atomically; the caller holds the lock.
+func saveCredentials(path string, creds *CredentialsFile) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	store, err := DefaultSecretStore(path)
+	if err != nil {
+		return err
+	}
+	stored, err := storeSecrets(path, creds, store)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// GetToken returns the token for the given server URL and profile, or an
+// empty string if there is no such credential.
+// For OAuth credentials, returns the access_token.
+func GetToken(creds *CredentialsFile, serverURL, profile string) string {
+	sc, ok := creds.Credential(serverURL, profile)
+	if !ok {
+		return ""
+	}
+	authType := sc.ResolveAuthType()
+	if authType == "oauth2" {
+		return sc.AccessToken
+	}
+	return sc.Token
+}
+
+// GetOAuthCredential returns the full OAuth credential for the given server
+// URL and profile, or nil if none exists or the type is not oauth2.
+func GetOAuthCredential(creds *CredentialsFile, serverURL, profile string) *ServerCredential {
+	sc, ok := creds.Credential(serverURL, profile)
+	if !ok {
+		return nil
+	}
+	if sc.ResolveAuthType() != "oauth2" {
+		return nil
+	}
+	return &sc
+}
+
+// SetOAuthTokens stores OAuth tokens for the given server URL and profile
+// using v2 format.
+func SetOAuthTokens(creds *CredentialsFile, serverURL, profile string, tokens OAuthTokens) {
+	var expiresAt *time.Time
+	if !tokens.ExpiresAt.IsZero() {
+		t := tokens.ExpiresAt
+		expiresAt = &t
+	}
+	creds.SetCredential(serverURL, profile, ServerCredential{
+		AuthType:      "oauth2",
+		Type:          "oauth", // kept for any v1 readers
+		AccessToken:   tokens.AccessToken,
+		RefreshToken:  tokens.RefreshToken,
+		ExpiresAt:     expiresAt,
+		ClientID:      tokens.ClientID,
+		ClientSecret:  tokens.ClientSecret,
+		TokenEndpoint: tokens.TokenEndpoint,
+		Scope:         tokens.Scope,
+	})
+}
+
+// IsTokenExpired returns true if the credential has an expires_at in the past.
+// Returns false if there is no expiry set.
+func IsTokenExpired(sc ServerCredential) bool {
+	if sc.ExpiresAt == nil {
+		return false
+	}
+	return time.Now().After(*sc.ExpiresAt)
+}
+
+// SetToken stores a bearer token for the given server URL and profile using
+// v2 format.
+func SetToken(creds *CredentialsFile, serverURL, profile, token string) {
+	creds.SetCredential(serverURL, profile, ServerCredential{
+		AuthType: "bearer_token",
+		Type:     "bearer", // kept for any v1 readers
+		Token:    token,
+	})
+}
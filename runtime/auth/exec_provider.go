@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExecCredential is the JSON a credential helper prints on stdout.
+// ExpiresAt is RFC 3339; without it the token is used for the current
+// process only.
+type ExecCredential struct {
+	Token     string     `json:"token"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// ExecCredentialProvider runs a helper command that prints an
+// ExecCredential, similar to kubectl exec plugins. Tokens with an expiry
+// are cached in the secret store (per command and server) so short-lived
+// CLI invocations do not run the helper every time.
+type ExecCredentialProvider struct {
+	// Command is run with the system shell (sh -c, or cmd /C on Windows).
+	Command string
+	// ServerURL is passed to the helper as CLIHUB_SERVER_URL.
+	ServerURL string
+	// CredPath is the credentials file whose secret store (see
+	// DefaultSecretStore) caches the tokens. Empty, or the json store,
+	// keeps them in memory for the current process only.
+	CredPath string
+	// OnCacheError, if set, is told when a token cannot be cached.
+	OnCacheError func(err error)
+
+	mu     sync.Mutex
+	cached *ExecCredential
+}
+
+func (p *ExecCredentialProvider) GetHeaders(ctx context.Context) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cached == nil || p.stale(p.cached) {
+		if cred := p.readCache(); cred != nil && !p.stale(cred) {
+			p.cached = cred
+		} else if err := p.run(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]string{"Authorization": "Bearer " + p.cached.Token}, nil
+}
+
+func (p *ExecCredentialProvider) OnUnauthorized(ctx context.Context, _ *http.Response) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.run(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Credential returns the configuration to persist with --save-credentials.
+func (p *ExecCredentialProvider) Credential() ServerCredential {
+	return ServerCredential{AuthType: "exec", Command: p.Command}
+}
+
+func (p *ExecCredentialProvider) stale(cred *ExecCredential) bool {
+	return cred.ExpiresAt != nil && time.Until(*cred.ExpiresAt) < RefreshSkewFromEnv()
+}
+
+// run executes the helper and caches its credential; the caller holds p.mu.
+func (p *ExecCredentialProvider) run(ctx context.Context) error {
+	cred, err := RunCredentialHelper(ctx, p.Command, p.ServerURL)
+	if err != nil {
+		return err
+	}
+	p.cached = cred
+	if cred.ExpiresAt != nil {
+		p.writeCache(cred)
+	}
+	return nil
+}
+
+// RunCredentialHelper runs command with the system shell and parses the
+// ExecCredential it prints.
+func RunCredentialHelper(ctx context.Context, command, serverURL string) (*ExecCredential, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), "CLIHUB_SERVER_URL="+serverURL)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, fmt.Errorf("credential helper failed: %w", err)
+		}
+		return nil, fmt.Errorf("credential helper failed: %w: %s", err, msg)
+	}
+
+	var cred ExecCredential
+	if err := json.Unmarshal(stdout.Bytes(), &cred); err != nil {
+		return nil, fmt.Errorf("credential helper output is not {\"token\", \"expires_at\"} JSON: %w", err)
+	}
+	if cred.Token == "" {
+		return nil, fmt.Errorf("credential helper returned no token")
+	}
+	return &cred, nil
+}
+
+// cacheStore returns the secret store that caches tokens, or nil to keep
+// them in memory only.
+func (p *ExecCredentialProvider) cacheStore() (SecretStore, error) {
+	if p.CredPath == "" {
+		return nil, nil
+	}
+	return DefaultSecretStore(p.CredPath)
+}
+
+// cacheKey identifies the cached token in the secret store.
+func (p *ExecCredentialProvider) cacheKey() string {
+	sum := sha256.Sum256([]byte(p.Command + "\x00" + p.ServerURL))
+	return fmt.Sprintf("exec %x", sum[:12])
+}
+
+func (p *ExecCredentialProvider) readCache() *ExecCredential {
+	store, err := p.cacheStore()
+	if err != nil || store == nil {
+		return nil
+	}
+	raw, err := store.Get(p.cacheKey())
+	if err != nil {
+		return nil
+	}
+	var cred ExecCredential
+	if json.Unmarshal([]byte(raw), &cred) != nil || cred.Token == "" {
+		return nil
+	}
+	return &cred
+}
+
+func (p *ExecCredentialProvider) writeCache(cred *ExecCredential) {
+	if err := p.storeCache(cred); err != nil && p.OnCacheError != nil {
+		p.OnCacheError(err)
+	}
+}
+
+// storeCache saves cred in the secret store, holding the credentials lock
+// so it does not race a concurrent credentials save.
+func (p *ExecCredentialProvider) storeCache(cred *ExecCredential) error {
+	store, err := p.cacheStore()
+	if err != nil || store == nil {
+		return err
+	}
+	data, err := json.Marshal(cred)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockFile(credentialsLockPath(p.CredPath))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := store.Set(p.cacheKey(), string(data)); err != nil {
+		return fmt.Errorf("cache credential helper token in %s store: %w", store.Name(), err)
+	}
+	return nil
+}
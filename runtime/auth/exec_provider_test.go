@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeHelper writes a shell credential helper that appends a line to a
+// counter file and prints output.
+func writeHelper(t *testing.T, output string) (command, counter string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("helper script uses sh")
+	}
+	dir := t.TempDir()
+	counter = filepath.Join(dir, "calls")
+	script := filepath.Join(dir, "helper.sh")
+	body := "#!/bin/sh\necho run >> " + counter + "\ncat <<'JSON'\n" + output + "\nJSON\n"
+	if err := os.WriteFile(script, []byte(body), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return script, counter
+}
+
+func helperCalls(t *testing.T, counter string) int {
+	t.Helper()
+	data, err := os.ReadFile(counter)
+	if err != nil {
+		return 0
+	}
+	return strings.Count(string(data), "run")
+}
+
+func TestExecCredentialProvider_ImplementsInterface(t *testing.T) {
+	var _ AuthProvider = &ExecCredentialProvider{}
+}
+
+func TestExecCredentialProvider_CachesAcrossProcesses(t *testing.T) {
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	command, counter := writeHelper(t, `{"token":"helper-token","expires_at":"`+expires+`"}`)
+	t.Setenv("CLIHUB_SECRET_STORE", SecretStoreFile)
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "correct horse")
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+
+	for i := 0; i < 2; i++ {
+		// A fresh provider per iteration stands in for a new CLI invocation
+		p := &ExecCredentialProvider{Command: command, ServerURL: "https://mcp.example.com", CredPath: credPath}
+		headers, err := p.GetHeaders(context.Background())
+		if err != nil {
+			t.Fatalf("GetHeaders: %v", err)
+		}
+		if got := headers["Authorization"]; got != "Bearer helper-token" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer helper-token")
+		}
+	}
+	if got := helperCalls(t, counter); got != 1 {
+		t.Errorf("helper runs = %d, want 1 (cached in the secret store)", got)
+	}
+	if data, err := os.ReadFile(SecretsFilePath(credPath)); err != nil || strings.Contains(string(data), "helper-token") {
+		t.Errorf("token not cached encrypted: %v\n%s", err, data)
+	}
+
+	p := &ExecCredentialProvider{Command: command, ServerURL: "https://mcp.example.com", CredPath: credPath}
+	if retry, err := p.OnUnauthorized(context.Background(), nil); err != nil || !retry {
+		t.Fatalf("OnUnauthorized = %v, %v; want true, nil", retry, err)
+	}
+	if got := helperCalls(t, counter); got != 2 {
+		t.Errorf("helper runs = %d, want 2 after 401", got)
+	}
+}
+
+func TestExecCredentialProvider_RerunsExpiringToken(t *testing.T) {
+	expires := time.Now().Add(5 * time.Second).UTC().Format(time.RFC3339)
+	command, counter := writeHelper(t, `{"token":"short","expires_at":"`+expires+`"}`)
+	p := &ExecCredentialProvider{Command: command, CredPath: filepath.Join(t.TempDir(), "credentials.json")}
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetHeaders(context.Background()); err != nil {
+			t.Fatalf("GetHeaders: %v", err)
+		}
+	}
+	if got := helperCalls(t, counter); got != 2 {
+		t.Errorf("helper runs = %d, want 2 (token expires within the refresh skew)", got)
+	}
+}
+
+func TestExecCredentialProvider_NoExpiryNotPersisted(t *testing.T) {
+	command, counter := writeHelper(t, `{"token":"session"}`)
+	t.Setenv("CLIHUB_SECRET_STORE", SecretStoreFile)
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "correct horse")
+	credPath := filepath.Join(t.TempDir(), "credentials.json")
+	for i := 0; i < 2; i++ {
+		p := &ExecCredentialProvider{Command: command, CredPath: credPath}
+		if _, err := p.GetHeaders(context.Background()); err != nil {
+			t.Fatalf("GetHeaders: %v", err)
+		}
+	}
+	if got := helperCalls(t, counter); got != 2 {
+		t.Errorf("helper runs = %d, want 2 (tokens without expiry are not cached)", got)
+	}
+}
+
+func TestExecCredentialProvider_JSONStoreKeepsTokenInMemory(t *testing.T) {
+	expires := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	command, counter := writeHelper(t, `{"token":"helper-token","expires_at":"`+expires+`"}`)
+	dir := t.TempDir()
+	for i := 0; i < 2; i++ {
+		p := &ExecCredentialProvider{Command: command, CredPath: filepath.Join(dir, "credentials.json")}
+		if _, err := p.GetHeaders(context.Background()); err != nil {
+			t.Fatalf("GetHeaders: %v", err)
+		}
+	}
+	if got := helperCalls(t, counter); got != 2 {
+		t.Errorf("helper runs = %d, want 2 (the json store does not cache tokens)", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("json store wrote %d files, want none", len(entries))
+	}
+}
+
+func TestRunCredentialHelper_Errors(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	ctx := context.Background()
+	if _, err := RunCredentialHelper(ctx, "echo denied >&2; exit 3", ""); err == nil || !strings.Contains(err.Error(), "denied") {
+		t.Errorf("error = %v, want helper stderr", err)
+	}
+	if _, err := RunCredentialHelper(ctx, "echo not-json", ""); err == nil {
+		t.Error("expected error for non-JSON output")
+	}
+	if _, err := RunCredentialHelper(ctx, `echo '{"expires_at":null}'`, ""); err == nil {
+		t.Error("expected error for missing token")
+	}
+	cred, err := RunCredentialHelper(ctx, `printf '{"token":"%s"}' "$CLIHUB_SERVER_URL"`, "https://mcp.example.com")
+	if err != nil || cred.Token != "https://mcp.example.com" {
+		t.Errorf("cred = %+v, %v; want server URL passed via CLIHUB_SERVER_URL", cred, err)
+	}
+}
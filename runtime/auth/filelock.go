@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Lock files are created exclusively next to the file they guard, so the
+// scheme works on every platform and is shared with generated CLIs, which
+// use the same lock names for the same credentials file.
+var (
+	// lockTimeout bounds how long a process waits for another one. It is
+	// longer than a token refresh request (30s) so waiters outlast a slow
+	// refresh rather than racing it.
+	lockTimeout = 60 * time.Second
+	// lockStaleAfter is the age after which a lock is assumed to belong to
+	// a process that died without removing it.
+	lockStaleAfter = 2 * time.Minute
+	lockPollEvery  = 50 * time.Millisecond
+	// staleLockSeen runs when a waiter finds a stale lock, before it acts
+	// on it. Tests use it to widen the window between the two.
+	staleLockSeen = func() {}
+)
+
+// lockFile acquires the lock file at path, waiting for other holders, and
+// returns a function that releases it.
+func lockFile(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			_, _ = f.WriteString(strconv.Itoa(os.Getpid()))
+			_ = f.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("create lock %s: %w", path, err)
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > lockStaleAfter {
+			staleLockSeen()
+			if breakStaleLock(path, info) {
+				continue
+			}
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock %s (remove it if no other process is running)", path)
+		}
+		time.Sleep(lockPollEvery)
+	}
+}
+
+// breakStaleLock removes the stale lock file described by info and reports
+// whether it did. Waiters that saw the same stale lock would otherwise race:
+// one removes it and takes a new lock, and the other, still acting on its
+// earlier stat, removes that live lock too. So a breaker first takes the
+// exclusive path+".break" lock, checks that path is still the same stale
+// file, and only then removes it. A guard left behind by a dead breaker is
+// cleared once it is stale as well.
+func breakStaleLock(path string, info fs.FileInfo) bool {
+	guard := path + ".break"
+	f, err := os.OpenFile(guard, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		if g, statErr := os.Stat(guard); statErr == nil && time.Since(g.ModTime()) > lockStaleAfter {
+			_ = os.Remove(guard)
+		}
+		return false
+	}
+	_ = f.Close()
+	defer os.Remove(guard)
+
+	current, err := os.Stat(path)
+	if err != nil {
+		// Already removed by its holder or an earlier breaker.
+		return errors.Is(err, fs.ErrNotExist)
+	}
+	if !os.SameFile(info, current) || time.Since(current.ModTime()) <= lockStaleAfter {
+		return false
+	}
+	return os.Remove(path) == nil
+}
+
+// credentialsLockPath guards read-modify-write cycles of the credentials
+// file and its secret store.
+func credentialsLockPath(credPath string) string {
+	return credPath + ".lock"
+}
+
+// refreshLockPath serializes token refreshes for one server and profile, so
+// one process refreshes while the others wait and reuse the new token.
+func refreshLockPath(credPath, serverURL, profile string) string {
+	sum := sha256.Sum256([]byte(secretKey(serverURL, profile)))
+	return fmt.Sprintf("%s.refresh-%x.lock", credPath, sum[:8])
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory and
+// renames it over path, so readers never see a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
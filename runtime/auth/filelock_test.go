@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestUpdateCredentialsConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- UpdateCredentials(path, func(creds *CredentialsFile) error {
+				SetToken(creds, fmt.Sprintf("https://server-%d.example.com", i), "", "tok")
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	creds, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds.Servers) != writers {
+		t.Errorf("got %d servers after concurrent updates, want %d", len(creds.Servers), writers)
+	}
+	if _, err := os.Stat(credentialsLockPath(path)); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestUpdateCredentialsErrorSkipsWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	err := UpdateCredentials(path, func(creds *CredentialsFile) error {
+		SetToken(creds, "https://example.com", "", "tok")
+		return fmt.Errorf("boom")
+	})
+	if err == nil {
+		t.Fatal("expected error from fn")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("credentials written despite error: %v", err)
+	}
+}
+
+func TestLockFileStaleAndTimeout(t *testing.T) {
+	oldTimeout := lockTimeout
+	lockTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { lockTimeout = oldTimeout })
+
+	path := filepath.Join(t.TempDir(), "credentials.json.lock")
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := lockFile(path); err == nil {
+		t.Fatal("second lockFile succeeded while the lock was held")
+	}
+
+	// A lock older than lockStaleAfter belongs to a dead process.
+	old := time.Now().Add(-2 * lockStaleAfter)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock2, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("stale lock not taken over: %v", err)
+	}
+	unlock2()
+	unlock()
+}
+
+func TestLockFileStaleConcurrent(t *testing.T) {
+	oldPoll := lockPollEvery
+	lockPollEvery = time.Millisecond
+	// Hold every waiter between seeing the stale lock and acting on it, so
+	// the others have time to replace it.
+	staleLockSeen = func() { time.Sleep(5 * time.Millisecond) }
+	t.Cleanup(func() {
+		lockPollEvery = oldPoll
+		staleLockSeen = func() {}
+	})
+
+	path := filepath.Join(t.TempDir(), "credentials.json.lock")
+	old := time.Now().Add(-2 * lockStaleAfter)
+	for round := 0; round < 10; round++ {
+		// Leave a stale lock, as a crashed process would, and let several
+		// waiters find it at once. Only one of them may hold the lock.
+		if err := os.WriteFile(path, []byte("1"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatal(err)
+		}
+
+		const waiters = 8
+		var holders, maxHolders int32
+		var wg sync.WaitGroup
+		for i := 0; i < waiters; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock, err := lockFile(path)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				n := atomic.AddInt32(&holders, 1)
+				for {
+					m := atomic.LoadInt32(&maxHolders)
+					if n <= m || atomic.CompareAndSwapInt32(&maxHolders, m, n) {
+						break
+					}
+				}
+				time.Sleep(2 * time.Millisecond)
+				atomic.AddInt32(&holders, -1)
+				unlock()
+			}()
+		}
+		wg.Wait()
+		if maxHolders != 1 {
+			t.Fatalf("round %d: %d waiters held the lock at once", round, maxHolders)
+		}
+	}
+	if _, err := os.Stat(path + ".break"); !os.IsNotExist(err) {
+		t.Errorf("break guard left behind: %v", err)
+	}
+}
+
+func TestOAuth2Provider_OnUnauthorized_SingleRefresh(t *testing.T) {
+	var refreshes int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&refreshes, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"new-%d","refresh_token":"rotated-%d","token_type":"Bearer","expires_in":3600}`, n, n)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	expired := time.Now().Add(-time.Minute)
+	creds := &CredentialsFile{Version: 2, Servers: map[string]ServerCredential{
+		"https://example.com": {
+			AuthType:      "oauth2",
+			AccessToken:   "old",
+			RefreshToken:  "refresh-1",
+			ExpiresAt:     &expired,
+			ClientID:      "cid",
+			TokenEndpoint: srv.URL,
+		},
+	}}
+	if err := SaveCredentials(path, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	const callers = 5
+	providers := make([]*OAuth2Provider, callers)
+	var wg sync.WaitGroup
+	for i := range providers {
+		providers[i] = &OAuth2Provider{ServerURL: "https://example.com", CredPath: path, cachedToken: "old"}
+		wg.Add(1)
+		go func(p *OAuth2Provider) {
+			defer wg.Done()
+			if retry, err := p.OnUnauthorized(context.Background(), nil); !retry || err != nil {
+				t.Errorf("OnUnauthorized = %v, %v", retry, err)
+			}
+		}(providers[i])
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&refreshes); got != 1 {
+		t.Errorf("token endpoint called %d times, want 1", got)
+	}
+	for _, p := range providers {
+		if p.cachedToken != "new-1" {
+			t.Errorf("provider token = %q, want new-1", p.cachedToken)
+		}
+	}
+	loaded, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.Servers["https://example.com"].RefreshToken; got != "rotated-1" {
+		t.Errorf("refresh token = %q, want rotated-1", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
@@ -67,6 +68,9 @@ func (p *GoogleSAProvider) getTokenSource(ctx context.Context) (oauth2.TokenSour
 		scopes = []string{"https://www.googleapis.com/auth/cloud-platform"}
 	}
 
+	if Transport != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, newHTTPClient(30*time.Second))
+	}
 	creds, err := google.CredentialsFromJSON(ctx, keyData, scopes...)
 	if err != nil {
 		return nil, fmt.Errorf("parse service account key: %w", err)
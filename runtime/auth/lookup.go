@@ -23,7 +23,8 @@ func DefaultCredentialsPath() string {
 //  1. flagToken (from --auth-token flag) — returned if non-empty
 //  2. CLIHUB_AUTH_TOKEN env var — returned if set
 //  3. Credentials file at DefaultCredentialsPath() — returned if it
-//     contains a token for serverURL
+//     contains a token for serverURL in the active profile
+//     (CLIHUB_PROFILE, then the profile chosen with `auth use`)
 //
 // Returns an empty string if no token is found.
 func LookupToken(flagToken, serverURL string) string {
@@ -42,9 +43,9 @@ func LookupToken(flagToken, serverURL string) string {
 	if path == "" {
 		return ""
 	}
-	creds, err := LoadCredentials(path)
+	creds, err := LoadServerCredentials(path, serverURL)
 	if err != nil {
 		return ""
 	}
-	return GetToken(creds, serverURL)
+	return GetToken(creds, serverURL, creds.ResolveProfile(serverURL, ""))
 }
@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshSkew is how long before expiry OAuth2Provider refreshes an
+// access token when neither RefreshSkew nor CLIHUB_TOKEN_REFRESH_SKEW is set.
+const DefaultRefreshSkew = 60 * time.Second
+
+// refreshRetryAfter is how long GetHeaders waits after a failed proactive
+// refresh before trying again, unless the token has expired meanwhile.
+const refreshRetryAfter = 30 * time.Second
+
+// OAuth2Provider provides OAuth2 authentication via stored tokens and
+// supports interactive browser flow for initial authentication.
+type OAuth2Provider struct {
+	// ServerURL is the MCP server URL (used for credential store lookup).
+	ServerURL string
+	// CredPath is the path to the credentials file.
+	CredPath string
+	// Profile is the credential profile to read and update (empty = default).
+	Profile string
+	// ClientID is a pre-registered OAuth client ID (optional; skips DCR if set).
+	ClientID string
+	// ClientSecret is a pre-registered OAuth client secret (optional).
+	ClientSecret string
+	// ResourceMetadataURL is a hint from the WWW-Authenticate header (auto-detection).
+	ResourceMetadataURL string
+	// Scope is a hint from the WWW-Authenticate header (auto-detection).
+	Scope string
+	// Device uses the device authorization grant instead of a local browser.
+	Device bool
+	// AuthServerURL, ClientName and AuthCommand are passed to the
+	// interactive flow; see FlowConfig.
+	AuthServerURL string
+	ClientName    string
+	AuthCommand   string
+	// RefreshSkew is how long before expiry GetHeaders refreshes the token
+	// (0 = CLIHUB_TOKEN_REFRESH_SKEW, else DefaultRefreshSkew).
+	RefreshSkew time.Duration
+	// Verbose is an optional logging function.
+	Verbose func(format string, args ...interface{})
+	// OnRefreshError, if set, is told when a proactive refresh fails.
+	OnRefreshError func(err error)
+
+	// mu guards the cached token; GetHeaders runs for every request.
+	mu sync.Mutex
+	// cachedToken is the access token from the last successful auth.
+	cachedToken string
+	// cachedExpiry is when cachedToken expires (zero = unknown).
+	cachedExpiry time.Time
+	// refreshFailedAt is when the last proactive refresh failed (zero =
+	// it did not).
+	refreshFailedAt time.Time
+}
+
+// GetHeaders returns the stored access token, refreshing it first when it
+// expires within the refresh skew. A failed proactive refresh is logged and
+// the current token is still sent, so a call never fails just because the
+// refresh did; an actual 401 is handled by OnUnauthorized. After a failure
+// the next proactive attempt waits refreshRetryAfter, or until the token
+// expires.
+func (p *OAuth2Provider) GetHeaders(ctx context.Context) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	token := p.cachedToken
+	if token == "" {
+		// Try loading from credential store
+		token = p.loadToken()
+	}
+	if token == "" {
+		return nil, nil
+	}
+	if !p.cachedExpiry.IsZero() && time.Until(p.cachedExpiry) < p.refreshSkew() && p.mayRetryRefresh() {
+		if _, err := p.refresh(ctx, token); err != nil {
+			p.refreshFailedAt = time.Now()
+			p.logf("Proactive token refresh failed: %v", err)
+			if p.OnRefreshError != nil {
+				p.OnRefreshError(err)
+			}
+		} else {
+			p.refreshFailedAt = time.Time{}
+		}
+		token = p.cachedToken
+	}
+	return map[string]string{"Authorization": "Bearer " + token}, nil
+}
+
+// mayRetryRefresh reports whether GetHeaders may try a proactive refresh:
+// none has failed yet, the last failure is refreshRetryAfter old, or the
+// token has expired since. The caller holds p.mu.
+func (p *OAuth2Provider) mayRetryRefresh() bool {
+	return p.refreshFailedAt.IsZero() ||
+		time.Since(p.refreshFailedAt) >= refreshRetryAfter ||
+		!time.Now().Before(p.cachedExpiry)
+}
+
+func (p *OAuth2Provider) OnUnauthorized(ctx context.Context, _ *http.Response) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.refresh(ctx, p.cachedToken)
+}
+
+// refresh replaces stale, the token in use, with a fresh one. Only one
+// process refreshes a server's token at a time. Whoever waited re-reads the
+// store and reuses the token the holder saved, instead of spending the
+// (possibly rotated) refresh token a second time. The caller holds p.mu.
+func (p *OAuth2Provider) refresh(ctx context.Context, stale string) (bool, error) {
+	// Try token refresh
+	if p.CredPath == "" {
+		return false, nil
+	}
+
+	unlock, err := lockFile(refreshLockPath(p.CredPath, p.ServerURL, p.Profile))
+	if err != nil {
+		return false, fmt.Errorf("cannot refresh: %w", err)
+	}
+	defer unlock()
+
+	creds, err := LoadServerCredentials(p.CredPath, p.ServerURL)
+	if err != nil {
+		return false, fmt.Errorf("cannot refresh: %w", err)
+	}
+	sc := GetOAuthCredential(creds, p.ServerURL, p.Profile)
+	if sc == nil {
+		return false, nil
+	}
+	if stale != "" && sc.AccessToken != "" && sc.AccessToken != stale && !expiresWithin(*sc, p.refreshSkew()) {
+		p.setToken(sc.AccessToken, sc.ExpiresAt)
+		return true, nil
+	}
+	if sc.RefreshToken == "" {
+		return false, fmt.Errorf("access token expired and no refresh token available")
+	}
+
+	// Discover token endpoint
+	tokenEndpoint := sc.TokenEndpoint
+	if tokenEndpoint == "" {
+		// Try to discover from auth server metadata
+		endpoint, err := discoverTokenEndpoint(ctx, p.ServerURL)
+		if err != nil {
+			return false, fmt.Errorf("cannot refresh: %w", err)
+		}
+		tokenEndpoint = endpoint
+		// Persist discovered endpoint so subsequent refreshes don't need
+		// discovery. The refresh below saves it too, so a failure here only
+		// costs a discovery next time.
+		err = UpdateCredentials(p.CredPath, func(creds *CredentialsFile) error {
+			if entry, ok := creds.Credential(p.ServerURL, p.Profile); ok {
+				entry.TokenEndpoint = tokenEndpoint
+				creds.SetCredential(p.ServerURL, p.Profile, entry)
+			}
+			return nil
+		})
+		if err != nil {
+			p.logf("Could not save token endpoint for %s: %v", p.ServerURL, err)
+		}
+	}
+
+	// Attempt refresh
+	httpClient := newHTTPClient(30 * time.Second)
+	clientID := sc.ClientID
+	if clientID == "" {
+		clientID = p.ClientID
+	}
+	clientSecret := sc.ClientSecret
+	if clientSecret == "" {
+		clientSecret = p.ClientSecret
+	}
+	tokenResp, err := RefreshAccessToken(ctx, httpClient, tokenEndpoint, clientID, clientSecret, sc.RefreshToken)
+	if err != nil {
+		return false, fmt.Errorf("token refresh failed: %w", err)
+	}
+
+	// Update credential store
+	var expiresAt time.Time
+	if tokenResp.ExpiresIn > 0 {
+		expiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+	refreshToken := tokenResp.RefreshToken
+	if refreshToken == "" {
+		refreshToken = sc.RefreshToken // keep old refresh token if not rotated
+	}
+	scope := sc.Scope
+	if tokenResp.Scope != "" {
+		scope = tokenResp.Scope
+	}
+	err = UpdateCredentials(p.CredPath, func(creds *CredentialsFile) error {
+		SetOAuthTokens(creds, p.ServerURL, p.Profile, OAuthTokens{
+			AccessToken:   tokenResp.AccessToken,
+			RefreshToken:  refreshToken,
+			ExpiresAt:     expiresAt,
+			ClientID:      clientID,
+			ClientSecret:  clientSecret,
+			TokenEndpoint: tokenEndpoint,
+			Scope:         scope,
+		})
+		return nil
+	})
+
+	// Keep using the new token in this process even if it could not be
+	// saved; the error still surfaces, since a rotated refresh token that
+	// was not saved means the next run has to sign in again.
+	p.cachedToken, p.cachedExpiry = tokenResp.AccessToken, expiresAt
+	if err != nil {
+		return true, fmt.Errorf("save refreshed token: %w", err)
+	}
+	p.logf("Refreshed access token for %s", p.ServerURL)
+	return true, nil
+}
+
+// Refresh exchanges the stored refresh token for a new access token now,
+// whether or not the current one is about to expire.
+func (p *OAuth2Provider) Refresh(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ok, err := p.refresh(ctx, "")
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("no OAuth credentials stored for %s", p.ServerURL)
+	}
+	return nil
+}
+
+func (p *OAuth2Provider) refreshSkew() time.Duration {
+	if p.RefreshSkew > 0 {
+		return p.RefreshSkew
+	}
+	return RefreshSkewFromEnv()
+}
+
+// RefreshSkewFromEnv returns CLIHUB_TOKEN_REFRESH_SKEW (a Go duration such
+// as "2m"), or DefaultRefreshSkew if it is unset or invalid.
+func RefreshSkewFromEnv() time.Duration {
+	if v := os.Getenv("CLIHUB_TOKEN_REFRESH_SKEW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			return d
+		}
+	}
+	return DefaultRefreshSkew
+}
+
+// expiresWithin reports whether sc's access token expires within d. Tokens
+// without an expiry never do.
+func expiresWithin(sc ServerCredential, d time.Duration) bool {
+	return sc.ExpiresAt != nil && time.Until(*sc.ExpiresAt) < d
+}
+
+func (p *OAuth2Provider) setToken(token string, expiresAt *time.Time) {
+	p.cachedToken, p.cachedExpiry = token, time.Time{}
+	if expiresAt != nil {
+		p.cachedExpiry = *expiresAt
+	}
+}
+
+func (p *OAuth2Provider) logf(format string, args ...interface{}) {
+	if p.Verbose != nil {
+		p.Verbose(format, args...)
+	}
+}
+
+// RunInteractiveFlow runs the interactive OAuth2 browser flow and stores the tokens.
+func (p *OAuth2Provider) RunInteractiveFlow(ctx context.Context) (string, error) {
+	tokens, err := Authenticate(ctx, p.flowConfig(p.Scope))
+	if err != nil {
+		return "", err
+	}
+
+	// Save tokens to credential store
+	if p.CredPath != "" {
+		err := UpdateCredentials(p.CredPath, func(creds *CredentialsFile) error {
+			SetOAuthTokens(creds, p.ServerURL, p.Profile, *tokens)
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("save tokens: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	p.cachedToken, p.cachedExpiry = tokens.AccessToken, tokens.ExpiresAt
+	p.mu.Unlock()
+	return tokens.AccessToken, nil
+}
+
+// flowConfig configures the interactive flow for scope.
+func (p *OAuth2Provider) flowConfig(scope string) FlowConfig {
+	return FlowConfig{
+		ServerURL:           p.ServerURL,
+		ClientID:            p.ClientID,
+		ClientSecret:        p.ClientSecret,
+		ResourceMetadataURL: p.ResourceMetadataURL,
+		Scope:               scope,
+		AuthServerURL:       p.AuthServerURL,
+		ClientName:          p.ClientName,
+		AuthCommand:         p.AuthCommand,
+		Device:              p.Device,
+		Verbose:             p.Verbose,
+	}
+}
+
+func (p *OAuth2Provider) loadToken() string {
+	if p.CredPath == "" {
+		return ""
+	}
+	creds, err := LoadServerCredentials(p.CredPath, p.ServerURL)
+	if err != nil {
+		return ""
+	}
+	sc := GetOAuthCredential(creds, p.ServerURL, p.Profile)
+	if sc == nil {
+		return GetToken(creds, p.ServerURL, p.Profile)
+	}
+	if sc.AccessToken != "" {
+		p.setToken(sc.AccessToken, sc.ExpiresAt)
+	}
+	return sc.AccessToken
+}
+
+// discoverTokenEndpoint finds the token endpoint for a server using OAuth metadata discovery.
+func discoverTokenEndpoint(ctx context.Context, serverURL string) (string, error) {
+	httpClient := newHTTPClient(30 * time.Second)
+
+	// Try protected resource metadata first to find auth server
+	resMeta, err := FetchProtectedResourceMetadata(ctx, httpClient, serverURL)
+	var authServerURL string
+	if err == nil && len(resMeta.AuthorizationServers) > 0 {
+		authServerURL = resMeta.AuthorizationServers[0]
+	} else {
+		// Fallback to server root
+		authServerURL = serverURL
+	}
+
+	// Fetch auth server metadata
+	authMeta, err := FetchAuthServerMetadata(ctx, httpClient, authServerURL)
+	if err != nil {
+		return "", fmt.Errorf("could not discover token endpoint: %w", err)
+	}
+	return authMeta.TokenEndpoint, nil
+}
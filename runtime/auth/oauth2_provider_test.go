@@ -2,8 +2,12 @@ package auth
 
 import (
 	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -110,6 +114,18 @@ func TestOAuth2Provider_OnUnauthorized_NoCreds(t *testing.T) {
 	}
 }
 
+func TestOAuth2Provider_OnUnauthorized_UnreadableCreds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := os.WriteFile(path, []byte("{not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := &OAuth2Provider{ServerURL: "https://example.com", CredPath: path}
+	retry, err := p.OnUnauthorized(context.Background(), nil)
+	if retry || err == nil {
+		t.Errorf("OnUnauthorized = %v, %v; want the credentials error", retry, err)
+	}
+}
+
 func TestOAuth2Provider_OnUnauthorized_NoCredPath(t *testing.T) {
 	p := &OAuth2Provider{}
 	retry, err := p.OnUnauthorized(context.Background(), nil)
@@ -212,3 +228,137 @@ func TestOAuth2Provider_CredPathFromEnv(t *testing.T) {
 	// Clean up env
 	os.Unsetenv("CLIHUB_CREDENTIALS_FILE")
 }
+
+func TestOAuth2Provider_GetHeaders_ProactiveRefresh(t *testing.T) {
+	var refreshes, failures int32
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			atomic.AddInt32(&failures, 1)
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		n := atomic.AddInt32(&refreshes, 1)
+		fmt.Fprintf(w, `{"access_token":"fresh-%d","token_type":"Bearer","expires_in":3600}`, n)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	save := func(expiresIn time.Duration) {
+		exp := time.Now().Add(expiresIn)
+		creds := &CredentialsFile{Version: 2, Servers: map[string]ServerCredential{
+			"https://example.com": {
+				AuthType:      "oauth2",
+				AccessToken:   "expiring",
+				RefreshToken:  "refresh",
+				ExpiresAt:     &exp,
+				TokenEndpoint: srv.URL,
+			},
+		}}
+		if err := SaveCredentials(path, creds); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Outside the skew: no refresh.
+	save(30 * time.Second)
+	p := &OAuth2Provider{ServerURL: "https://example.com", CredPath: path, RefreshSkew: 10 * time.Second}
+	headers, _ := p.GetHeaders(context.Background())
+	if got := headers["Authorization"]; got != "Bearer expiring" {
+		t.Errorf("Authorization = %q, want the stored token", got)
+	}
+
+	// Inside the default skew: refreshed once, then reused.
+	p = &OAuth2Provider{ServerURL: "https://example.com", CredPath: path}
+	for i := 0; i < 3; i++ {
+		headers, err := p.GetHeaders(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := headers["Authorization"]; got != "Bearer fresh-1" {
+			t.Errorf("call %d: Authorization = %q, want Bearer fresh-1", i, got)
+		}
+	}
+	if got := atomic.LoadInt32(&refreshes); got != 1 {
+		t.Errorf("token endpoint called %d times, want 1", got)
+	}
+
+	// A failed proactive refresh still sends the current token.
+	save(30 * time.Second)
+	fail = true
+	p = &OAuth2Provider{ServerURL: "https://example.com", CredPath: path}
+	headers, err := p.GetHeaders(context.Background())
+	if err != nil {
+		t.Fatalf("GetHeaders failed with the refresh: %v", err)
+	}
+	if got := headers["Authorization"]; got != "Bearer expiring" {
+		t.Errorf("Authorization = %q, want the current token", got)
+	}
+
+	// ... and is not retried on every request.
+	for i := 0; i < 3; i++ {
+		if _, err := p.GetHeaders(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := atomic.LoadInt32(&failures); got != 1 {
+		t.Errorf("token endpoint called %d times after a failure, want 1", got)
+	}
+
+	// Once the cooldown has passed, it is tried again.
+	fail = false
+	p.refreshFailedAt = time.Now().Add(-refreshRetryAfter)
+	headers, _ = p.GetHeaders(context.Background())
+	if got := headers["Authorization"]; got != "Bearer fresh-2" {
+		t.Errorf("Authorization = %q, want Bearer fresh-2 after the cooldown", got)
+	}
+}
+
+func TestOAuth2Provider_Refresh(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"access_token":"forced","token_type":"Bearer","expires_in":3600,"scope":"read write"}`)
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	exp := time.Now().Add(time.Hour)
+	creds := &CredentialsFile{Version: 2, Servers: map[string]ServerCredential{
+		"https://example.com": {AuthType: "oauth2", AccessToken: "valid", RefreshToken: "refresh", ExpiresAt: &exp, TokenEndpoint: srv.URL},
+	}}
+	if err := SaveCredentials(path, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	// Refresh does not wait for the token to near expiry.
+	p := &OAuth2Provider{ServerURL: "https://example.com", CredPath: path}
+	if err := p.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	creds, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc := creds.Servers["https://example.com"]; sc.AccessToken != "forced" || sc.Scope != "read write" {
+		t.Errorf("stored credential = %+v", sc)
+	}
+
+	p = &OAuth2Provider{ServerURL: "https://other.example.com", CredPath: path}
+	if err := p.Refresh(context.Background()); err == nil {
+		t.Error("expected an error without stored credentials")
+	}
+}
+
+func TestRefreshSkewFromEnv(t *testing.T) {
+	t.Setenv("CLIHUB_TOKEN_REFRESH_SKEW", "")
+	if got := RefreshSkewFromEnv(); got != DefaultRefreshSkew {
+		t.Errorf("unset: %v", got)
+	}
+	t.Setenv("CLIHUB_TOKEN_REFRESH_SKEW", "5m")
+	if got := RefreshSkewFromEnv(); got != 5*time.Minute {
+		t.Errorf("5m: %v", got)
+	}
+	t.Setenv("CLIHUB_TOKEN_REFRESH_SKEW", "soon")
+	if got := RefreshSkewFromEnv(); got != DefaultRefreshSkew {
+		t.Errorf("invalid: %v", got)
+	}
+}
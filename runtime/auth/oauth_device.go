@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DeviceCodeGrantType is the RFC 8628 grant type used when polling the token
+// endpoint.
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// ManualRedirectURI is the redirect URI used when the browser runs on
+// another machine: the redirect fails to load, and the user pastes the URL
+// from the address bar back into the terminal. Loopback redirects may use
+// any port (RFC 8252 §7.3), so this matches pre-registered apps too.
+const ManualRedirectURI = "http://127.0.0.1/callback"
+
+// devicePollUnit scales the polling interval; tests shorten it.
+var devicePollUnit = time.Second
+
+// DeviceAuthorization is the response from the device authorization
+// endpoint (RFC 8628 §3.2).
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// RequestDeviceAuthorization starts a device authorization grant.
+func RequestDeviceAuthorization(ctx context.Context, client *http.Client, endpoint, clientID, clientSecret, scope string) (*DeviceAuthorization, error) {
+	form := url.Values{"client_id": {clientID}}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		if code, desc := parseOAuthError(body); code != "" {
+			return nil, fmt.Errorf("device authorization error: %s — %s", code, desc)
+		}
+		return nil, fmt.Errorf("device authorization endpoint returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var da DeviceAuthorization
+	if err := json.NewDecoder(resp.Body).Decode(&da); err != nil {
+		return nil, fmt.Errorf("parse device authorization response: %w", err)
+	}
+	if da.DeviceCode == "" || da.UserCode == "" || da.VerificationURI == "" {
+		return nil, fmt.Errorf("device authorization response missing device_code, user_code or verification_uri")
+	}
+	return &da, nil
+}
+
+// PollDeviceToken polls the token endpoint until the user approves or
+// denies the request, the device code expires, or ctx is cancelled. It
+// honours authorization_pending and slow_down (RFC 8628 §3.5).
+func PollDeviceToken(ctx context.Context, client *http.Client, tokenEndpoint, clientID, clientSecret string, da *DeviceAuthorization) (*TokenResponse, error) {
+	interval := da.Interval
+	if interval <= 0 {
+		interval = 5
+	}
+	var deadline <-chan time.Time
+	if da.ExpiresIn > 0 {
+		timer := time.NewTimer(time.Duration(da.ExpiresIn) * devicePollUnit)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("timed out waiting for device authorization")
+		case <-deadline:
+			return nil, fmt.Errorf("device code expired before the request was approved")
+		case <-time.After(time.Duration(interval) * devicePollUnit):
+		}
+
+		form := url.Values{
+			"grant_type":  {DeviceCodeGrantType},
+			"device_code": {da.DeviceCode},
+			"client_id":   {clientID},
+		}
+		if clientSecret != "" {
+			form.Set("client_secret", clientSecret)
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", tokenEndpoint, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("token request: %w", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusOK {
+			var tokenResp TokenResponse
+			if err := json.Unmarshal(body, &tokenResp); err != nil {
+				return nil, fmt.Errorf("parse token response: %w", err)
+			}
+			if tokenResp.AccessToken == "" {
+				return nil, fmt.Errorf("token response missing access_token")
+			}
+			return &tokenResp, nil
+		}
+
+		code, desc := parseOAuthError(body)
+		switch code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5
+		case "access_denied":
+			return nil, fmt.Errorf("device authorization was denied")
+		case "expired_token":
+			return nil, fmt.Errorf("device code expired before the request was approved")
+		case "":
+			return nil, fmt.Errorf("token endpoint returned %d: %s", resp.StatusCode, string(body))
+		default:
+			return nil, fmt.Errorf("token error: %s — %s", code, desc)
+		}
+	}
+}
+
+// ReadRedirectURL reads the URL the browser was redirected to from r and
+// returns its authorization code after checking state.
+func ReadRedirectURL(r io.Reader, expectedState string) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read redirect URL: %w", err)
+	}
+	u, err := url.Parse(strings.TrimSpace(line))
+	if err != nil {
+		return "", fmt.Errorf("parse redirect URL: %w", err)
+	}
+	q := u.Query()
+	if oauthErr := q.Get("error"); oauthErr != "" {
+		return "", fmt.Errorf("authorization error: %s — %s", oauthErr, q.Get("error_description"))
+	}
+	if expectedState != "" && q.Get("state") != expectedState {
+		return "", fmt.Errorf("OAuth state mismatch (possible CSRF)")
+	}
+	code := q.Get("code")
+	if code == "" {
+		return "", fmt.Errorf("redirect URL has no authorization code")
+	}
+	return code, nil
+}
+
+func parseOAuthError(body []byte) (string, string) {
+	var errResp struct {
+		Error       string `json:"error"`
+		Description string `json:"error_description"`
+	}
+	if json.Unmarshal(body, &errResp) != nil {
+		return "", ""
+	}
+	return errResp.Error, errResp.Description
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func shortDevicePoll(t *testing.T) {
+	old := devicePollUnit
+	devicePollUnit = time.Millisecond
+	t.Cleanup(func() { devicePollUnit = old })
+}
+
+// deviceAuthServer serves OAuth metadata, registration, device
+// authorization and token endpoints. With device=false it has no device
+// authorization endpoint. tokenErrors are returned, in order, before the
+// token is issued.
+func deviceAuthServer(t *testing.T, device bool, tokenErrors ...string) (*httptest.Server, *clientRegistrationRequest) {
+	t.Helper()
+	var registered clientRegistrationRequest
+	var polls int32
+	mux := http.NewServeMux()
+	var ts *httptest.Server
+	mux.HandleFunc("/.well-known/oauth-authorization-server", func(w http.ResponseWriter, r *http.Request) {
+		meta := AuthServerMetadata{
+			Issuer:                ts.URL,
+			AuthorizationEndpoint: ts.URL + "/authorize",
+			TokenEndpoint:         ts.URL + "/token",
+			RegistrationEndpoint:  ts.URL + "/register",
+		}
+		if device {
+			meta.DeviceAuthorizationEndpoint = ts.URL + "/device"
+		}
+		json.NewEncoder(w).Encode(meta)
+	})
+	mux.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&registered)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(ClientRegistration{ClientID: "device-client"})
+	})
+	mux.HandleFunc("/device", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("client_id") != "device-client" {
+			t.Errorf("device authorization client_id = %q", r.Form.Get("client_id"))
+		}
+		json.NewEncoder(w).Encode(DeviceAuthorization{
+			DeviceCode:      "dev-code",
+			UserCode:        "ABCD-EFGH",
+			VerificationURI: ts.URL + "/activate",
+			ExpiresIn:       600,
+			Interval:        1,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != DeviceCodeGrantType || r.Form.Get("device_code") != "dev-code" {
+			t.Errorf("unexpected token request: %v", r.Form)
+		}
+		n := int(atomic.AddInt32(&polls, 1))
+		if n <= len(tokenErrors) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, `{"error":%q}`, tokenErrors[n-1])
+			return
+		}
+		json.NewEncoder(w).Encode(TokenResponse{AccessToken: "device-access", RefreshToken: "device-refresh", ExpiresIn: 3600})
+	})
+	ts = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+	return ts, &registered
+}
+
+func TestAuthenticate_DeviceFlow(t *testing.T) {
+	shortDevicePoll(t)
+	ts, registered := deviceAuthServer(t, true, "authorization_pending", "slow_down")
+
+	tokens, err := Authenticate(context.Background(), FlowConfig{
+		ServerURL:  ts.URL + "/mcp",
+		HTTPClient: ts.Client(),
+		Device:     true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tokens.AccessToken != "device-access" || tokens.RefreshToken != "device-refresh" {
+		t.Errorf("tokens = %+v", tokens)
+	}
+	if tokens.ClientID != "device-client" || tokens.TokenEndpoint != ts.URL+"/token" {
+		t.Errorf("client/endpoint not recorded: %+v", tokens)
+	}
+	if len(registered.RedirectURIs) != 0 || len(registered.GrantTypes) == 0 || registered.GrantTypes[0] != DeviceCodeGrantType {
+		t.Errorf("device client registered with %+v", registered)
+	}
+}
+
+func TestAuthenticate_DeviceFallsBackToPastedURL(t *testing.T) {
+	ts, registered := deviceAuthServer(t, false)
+
+	_, err := Authenticate(context.Background(), FlowConfig{
+		ServerURL:  ts.URL + "/mcp",
+		HTTPClient: ts.Client(),
+		Device:     true,
+		Input:      strings.NewReader("http://127.0.0.1/callback?code=abc&state=forged\n"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "state mismatch") {
+		t.Fatalf("expected state mismatch from the pasted URL, got %v", err)
+	}
+	if len(registered.RedirectURIs) != 1 || registered.RedirectURIs[0] != ManualRedirectURI {
+		t.Errorf("redirect_uris = %v, want [%s]", registered.RedirectURIs, ManualRedirectURI)
+	}
+}
+
+func TestPollDeviceToken_Errors(t *testing.T) {
+	shortDevicePoll(t)
+	for _, tc := range []struct {
+		tokenError string
+		want       string
+	}{
+		{"access_denied", "denied"},
+		{"expired_token", "expired"},
+		{"invalid_client", "invalid_client"},
+	} {
+		t.Run(tc.tokenError, func(t *testing.T) {
+			ts, _ := deviceAuthServer(t, true, tc.tokenError)
+			da := &DeviceAuthorization{DeviceCode: "dev-code", Interval: 1}
+			_, err := PollDeviceToken(context.Background(), ts.Client(), ts.URL+"/token", "device-client", "", da)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestReadRedirectURL(t *testing.T) {
+	tests := []struct {
+		input   string
+		code    string
+		wantErr string
+	}{
+		{"http://127.0.0.1/callback?code=abc&state=s1\n", "abc", ""},
+		{"  http://127.0.0.1/callback?code=abc&state=s1  ", "abc", ""},
+		{"http://127.0.0.1/callback?code=abc&state=other\n", "", "state mismatch"},
+		{"http://127.0.0.1/callback?error=access_denied&error_description=no\n", "", "access_denied"},
+		{"http://127.0.0.1/callback?state=s1\n", "", "no authorization code"},
+		{"", "", "read redirect URL"},
+	}
+	for _, tt := range tests {
+		code, err := ReadRedirectURL(strings.NewReader(tt.input), "s1")
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadRedirectURL(%q) error = %v, want %q", tt.input, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || code != tt.code {
+			t.Errorf("ReadRedirectURL(%q) = %q, %v", tt.input, code, err)
+		}
+	}
+}
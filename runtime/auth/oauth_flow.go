@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// FlowConfig configures the OAuth authentication flow.
+type FlowConfig struct {
+	ServerURL           string
+	HTTPClient          *http.Client
+	Verbose             func(format string, args ...interface{})
+	ClientID            string // Pre-registered client ID (skips dynamic registration)
+	ClientSecret        string // Pre-registered client secret
+	ResourceMetadataURL string // Hint from WWW-Authenticate header
+	Scope               string // Hint from WWW-Authenticate header
+	// AuthServerURL is used when the server publishes no protected resource
+	// metadata (default: the server's origin).
+	AuthServerURL string
+	// ClientName is sent with dynamic client registration (default "clihub").
+	ClientName string
+	// AuthCommand is the command suggested with --client-id and
+	// --client-secret when the server needs a pre-registered client
+	// (default "clihub generate --url <server-url>").
+	AuthCommand string
+	// Device uses the device authorization grant (RFC 8628) instead of a
+	// local browser and callback server. If the authorization server has
+	// no device endpoint, the user pastes the redirect URL instead.
+	Device bool
+	// Input is read for the pasted redirect URL (default os.Stdin).
+	Input io.Reader
+}
+
+// Authenticate runs the full MCP OAuth flow:
+// 1. Discover protected resource metadata (RFC 9728)
+// 2. Discover authorization server metadata (RFC 8414)
+// 3. Start local callback server
+// 4. Register client dynamically (RFC 7591) — or use pre-registered client ID
+// 5. Generate PKCE verifier/challenge
+// 6. Open browser to authorization URL
+// 7. Wait for callback with authorization code
+// 8. Exchange code for tokens
+//
+// With cfg.Device, steps 3-7 are replaced by the device authorization grant,
+// or by pasting the redirect URL when the server does not support it.
+func Authenticate(ctx context.Context, cfg FlowConfig) (*OAuthTokens, error) {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = newHTTPClient(30 * time.Second)
+	}
+	log := cfg.Verbose
+	if log == nil {
+		log = func(string, ...interface{}) {}
+	}
+
+	// Step 1: Discover protected resource metadata
+	log("Discovering OAuth endpoints...")
+	var resMeta *ProtectedResourceMetadata
+	var err error
+
+	if cfg.ResourceMetadataURL != "" {
+		// Use the URL from the WWW-Authenticate header directly
+		log("Using resource metadata URL from server: %s", cfg.ResourceMetadataURL)
+		resMeta, err = FetchProtectedResourceMetadataFromURL(ctx, cfg.HTTPClient, cfg.ResourceMetadataURL)
+	} else {
+		resMeta, err = FetchProtectedResourceMetadata(ctx, cfg.HTTPClient, cfg.ServerURL)
+	}
+
+	// Fallback: if no protected resource metadata, use server root as auth server
+	if err != nil {
+		log("Protected resource metadata not found, using server as auth server")
+		authServer := cfg.AuthServerURL
+		if authServer == "" {
+			authServer = serverRoot(cfg.ServerURL)
+		}
+		resMeta = &ProtectedResourceMetadata{
+			AuthorizationServers: []string{authServer},
+		}
+	}
+
+	// Determine scope: explicit > resource metadata > auth server metadata > default
+	scope := "mcp:tools"
+	if cfg.Scope != "" {
+		scope = cfg.Scope
+	} else if len(resMeta.ScopesSupported) > 0 {
+		scope = strings.Join(resMeta.ScopesSupported, " ")
+	}
+
+	// Step 2: Discover authorization server metadata
+	authMeta, err := FetchAuthServerMetadata(ctx, cfg.HTTPClient, resMeta.AuthorizationServers[0])
+	if err != nil {
+		return nil, fmt.Errorf("OAuth discovery failed: %w", err)
+	}
+	log("Found authorization server: %s", authMeta.Issuer)
+
+	// Override scope from auth server if nothing else specified it
+	if cfg.Scope == "" && len(resMeta.ScopesSupported) == 0 && len(authMeta.ScopesSupported) > 0 {
+		scope = strings.Join(authMeta.ScopesSupported, " ")
+	}
+
+	if cfg.Device && authMeta.DeviceAuthorizationEndpoint != "" {
+		return authenticateDevice(ctx, cfg, authMeta, scope, log)
+	}
+
+	// Step 3: Start callback server, unless the browser is elsewhere
+	redirectURI := ManualRedirectURI
+	var callback *CallbackServer
+	if cfg.Device {
+		fmt.Fprintln(os.Stderr, "The authorization server does not support the device flow; falling back to pasting the redirect URL.")
+	} else {
+		callback = &CallbackServer{}
+		if err := callback.Start(); err != nil {
+			return nil, fmt.Errorf("could not start local callback server: %w", err)
+		}
+		defer callback.Close()
+		redirectURI = callback.RedirectURI()
+	}
+
+	// Step 4: Get client credentials (pre-registered or dynamic registration)
+	clientID, clientSecret, err := flowClient(ctx, cfg, authMeta, scope, log, func() (*ClientRegistration, error) {
+		return registerClient(ctx, cfg.HTTPClient, authMeta.RegistrationEndpoint, codeClientRequest(cfg.clientName(), redirectURI, scope))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Step 5: Generate PKCE
+	verifier, err := GenerateCodeVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("generate PKCE verifier: %w", err)
+	}
+	challenge := GenerateCodeChallenge(verifier)
+
+	// Step 6: Generate state
+	state, err := GenerateState()
+	if err != nil {
+		return nil, fmt.Errorf("generate state: %w", err)
+	}
+
+	// Step 7: Build authorization URL
+	authURL, err := buildAuthorizationURL(authMeta.AuthorizationEndpoint, clientID, redirectURI, challenge, state, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var code string
+	if callback == nil {
+		// Steps 8-9: the user opens the URL elsewhere and pastes the redirect
+		fmt.Fprintf(os.Stderr, "Open this URL in a browser on any machine:\n%s\n\n", authURL)
+		fmt.Fprintln(os.Stderr, "After approving, the browser is redirected to a page that fails to load.")
+		fmt.Fprint(os.Stderr, "Paste that page's full URL here: ")
+		input := cfg.Input
+		if input == nil {
+			input = os.Stdin
+		}
+		code, err = ReadRedirectURL(input, state)
+		if err != nil {
+			return nil, fmt.Errorf("authorization failed: %w", err)
+		}
+	} else {
+		// Step 8: Open browser
+		log("Opening browser for authentication...")
+		if err := OpenBrowser(authURL); err != nil {
+			log("Could not open browser automatically")
+		}
+		fmt.Fprintf(os.Stderr, "If the browser doesn't open, visit:\n%s\n\n", authURL)
+		fmt.Fprintln(os.Stderr, "Waiting for authorization...")
+
+		// Step 9: Wait for callback
+		code, err = callback.WaitForCallback(ctx, state)
+		if err != nil {
+			return nil, fmt.Errorf("authorization failed: %w", err)
+		}
+	}
+	log("Authorization code received")
+
+	// Step 10: Exchange code for tokens
+	log("Exchanging authorization code for tokens...")
+	tokenResp, err := ExchangeCode(ctx, cfg.HTTPClient, authMeta.TokenEndpoint, TokenExchangeParams{
+		Code:         code,
+		RedirectURI:  redirectURI,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		CodeVerifier: verifier,
+		AuthMethods:  authMeta.TokenEndpointAuthMethodsSupported,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("token exchange failed: %w", err)
+	}
+
+	log("Authentication complete")
+	return flowTokens(tokenResp, clientID, clientSecret, authMeta.TokenEndpoint, scope), nil
+}
+
+// authenticateDevice runs the device authorization grant: it prints the
+// user code and verification URL, then polls the token endpoint.
+func authenticateDevice(ctx context.Context, cfg FlowConfig, authMeta *AuthServerMetadata, scope string, log func(string, ...interface{})) (*OAuthTokens, error) {
+	clientID, clientSecret, err := flowClient(ctx, cfg, authMeta, scope, log, func() (*ClientRegistration, error) {
+		return registerClient(ctx, cfg.HTTPClient, authMeta.RegistrationEndpoint, deviceClientRequest(cfg.clientName(), scope))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	da, err := RequestDeviceAuthorization(ctx, cfg.HTTPClient, authMeta.DeviceAuthorizationEndpoint, clientID, clientSecret, scope)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "To authorize, visit:\n%s\n\nand enter the code: %s\n\n", da.VerificationURI, da.UserCode)
+	if da.VerificationURIComplete != "" {
+		fmt.Fprintf(os.Stderr, "Or open this URL directly:\n%s\n\n", da.VerificationURIComplete)
+	}
+	fmt.Fprintln(os.Stderr, "Waiting for authorization...")
+
+	tokenResp, err := PollDeviceToken(ctx, cfg.HTTPClient, authMeta.TokenEndpoint, clientID, clientSecret, da)
+	if err != nil {
+		return nil, fmt.Errorf("authorization failed: %w", err)
+	}
+	log("Authentication complete")
+	return flowTokens(tokenResp, clientID, clientSecret, authMeta.TokenEndpoint, scope), nil
+}
+
+// flowClient returns the pre-registered client, or registers one with
+// register when the server supports dynamic registration.
+func flowClient(ctx context.Context, cfg FlowConfig, authMeta *AuthServerMetadata, scope string, log func(string, ...interface{}), register func() (*ClientRegistration, error)) (string, string, error) {
+	if cfg.ClientID != "" {
+		// Use pre-registered client credentials — skip dynamic registration
+		log("Using pre-registered client: %s", cfg.ClientID)
+		return cfg.ClientID, cfg.ClientSecret, nil
+	}
+	if authMeta.RegistrationEndpoint == "" {
+		return "", "", fmt.Errorf("this server requires a pre-registered OAuth app (no automatic registration available)\n\n"+
+			"  1. Register an OAuth app at the provider's developer portal (%s)\n"+
+			"  2. Set the redirect URI to: http://127.0.0.1/callback\n"+
+			"  3. Run: %s --client-id <YOUR_CLIENT_ID> --client-secret <YOUR_SECRET>",
+			authMeta.Issuer, cfg.authCommand())
+	}
+	log("Registering OAuth client...")
+	reg, err := register()
+	if err != nil {
+		return "", "", fmt.Errorf("client registration failed at %s: %w\n\n"+
+			"This server requires a pre-registered OAuth app.\n"+
+			"  1. Register an OAuth app at the provider's developer portal (%s)\n"+
+			"  2. Set the redirect URI to: http://127.0.0.1/callback\n"+
+			"  3. Run: %s --client-id <YOUR_CLIENT_ID> --client-secret <YOUR_SECRET>",
+			authMeta.RegistrationEndpoint, err, authMeta.Issuer, cfg.authCommand())
+	}
+	return reg.ClientID, reg.ClientSecret, nil
+}
+
+func (cfg FlowConfig) clientName() string {
+	if cfg.ClientName != "" {
+		return cfg.ClientName
+	}
+	return "clihub"
+}
+
+func (cfg FlowConfig) authCommand() string {
+	if cfg.AuthCommand != "" {
+		return cfg.AuthCommand
+	}
+	return "clihub generate --url <server-url>"
+}
+
+// flowTokens converts a token response. A response without a scope was
+// granted the requested one (RFC 6749 §5.1).
+func flowTokens(tokenResp *TokenResponse, clientID, clientSecret, tokenEndpoint, requested string) *OAuthTokens {
+	tokens := &OAuthTokens{
+		AccessToken:   tokenResp.AccessToken,
+		RefreshToken:  tokenResp.RefreshToken,
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		TokenEndpoint: tokenEndpoint,
+		Scope:         tokenResp.Scope,
+	}
+	if tokens.Scope == "" {
+		tokens.Scope = requested
+	}
+	if tokenResp.ExpiresIn > 0 {
+		tokens.ExpiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+	return tokens
+}
+
+// serverRoot extracts the origin (scheme + host) from a URL.
+// e.g. "https://mcp.linear.app/sse" → "https://mcp.linear.app"
+func serverRoot(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return fmt.Sprintf("%s://%s", u.Scheme, u.Host)
+}
+
+func buildAuthorizationURL(endpoint, clientID, redirectURI, codeChallenge, state, scope string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse authorization endpoint: %w", err)
+	}
+
+	q := u.Query()
+	q.Set("client_id", clientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("response_type", "code")
+	q.Set("code_challenge", codeChallenge)
+	q.Set("code_challenge_method", "S256")
+	q.Set("state", state)
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
@@ -19,9 +19,16 @@ func FetchProtectedResourceMetadataFromURL(ctx context.Context, client *http.Cli
 // FetchProtectedResourceMetadata fetches the RFC 9728 protected resource metadata.
 // It tries the RFC-compliant URL with path appended first, then falls back to path-less.
 func FetchProtectedResourceMetadata(ctx context.Context, client *http.Client, serverURL string) (*ProtectedResourceMetadata, error) {
+	meta, _, err := discoverResourceMeta(ctx, client, serverURL)
+	return meta, err
+}
+
+// discoverResourceMeta is FetchProtectedResourceMetadata that also returns
+// the well-known URL the metadata was found at.
+func discoverResourceMeta(ctx context.Context, client *http.Client, serverURL string) (*ProtectedResourceMetadata, string, error) {
 	urls, err := wellKnownURLs(serverURL, "oauth-protected-resource")
 	if err != nil {
-		return nil, fmt.Errorf("build discovery URL: %w", err)
+		return nil, "", fmt.Errorf("build discovery URL: %w", err)
 	}
 
 	var lastErr error
@@ -31,10 +38,45 @@ func FetchProtectedResourceMetadata(ctx context.Context, client *http.Client, se
 			lastErr = err
 			continue
 		}
-		return meta, nil
+		return meta, wellKnown, nil
 	}
 
-	return nil, lastErr
+	return nil, "", lastErr
+}
+
+// OAuthDiscovery is what discovery found for an OAuth-protected server.
+type OAuthDiscovery struct {
+	ResourceMetadataURL string // where the protected resource metadata was found
+	AuthServerURL       string // the authorization server's issuer
+	Resource            *ProtectedResourceMetadata
+	AuthServer          *AuthServerMetadata // nil if its metadata could not be fetched
+}
+
+// DiscoverOAuth fetches the protected resource metadata for serverURL, from
+// resourceMetadataURL when the server named one, and then the metadata of
+// its first authorization server. Only a missing protected resource
+// metadata is an error; without authorization server metadata,
+// AuthServerURL is the server the resource metadata lists.
+func DiscoverOAuth(ctx context.Context, client *http.Client, serverURL, resourceMetadataURL string) (*OAuthDiscovery, error) {
+	d := &OAuthDiscovery{ResourceMetadataURL: resourceMetadataURL}
+	var err error
+	if resourceMetadataURL != "" {
+		d.Resource, err = FetchProtectedResourceMetadataFromURL(ctx, client, resourceMetadataURL)
+	} else {
+		d.Resource, d.ResourceMetadataURL, err = discoverResourceMeta(ctx, client, serverURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	d.AuthServerURL = d.Resource.AuthorizationServers[0]
+	if meta, err := FetchAuthServerMetadata(ctx, client, d.AuthServerURL); err == nil {
+		d.AuthServer = meta
+		if meta.Issuer != "" {
+			d.AuthServerURL = meta.Issuer
+		}
+	}
+	return d, nil
 }
 
 func fetchResourceMeta(ctx context.Context, client *http.Client, wellKnown string) (*ProtectedResourceMetadata, error) {
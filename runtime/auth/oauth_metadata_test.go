@@ -204,3 +204,62 @@ func TestWellKnownURLs_Construction(t *testing.T) {
 		}
 	}
 }
+
+func TestDiscoverOAuth(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/oauth-protected-resource":
+			json.NewEncoder(w).Encode(ProtectedResourceMetadata{AuthorizationServers: []string{ts.URL + "/as"}})
+		case "/.well-known/oauth-authorization-server/as":
+			json.NewEncoder(w).Encode(AuthServerMetadata{
+				Issuer:                "https://issuer.example.com",
+				AuthorizationEndpoint: ts.URL + "/authorize",
+				TokenEndpoint:         ts.URL + "/token",
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	// Found by well-known discovery: the URL that answered is reported.
+	d, err := DiscoverOAuth(context.Background(), ts.Client(), ts.URL+"/mcp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ResourceMetadataURL != ts.URL+"/.well-known/oauth-protected-resource" {
+		t.Errorf("ResourceMetadataURL = %q", d.ResourceMetadataURL)
+	}
+	if d.AuthServerURL != "https://issuer.example.com" || d.AuthServer == nil {
+		t.Errorf("AuthServerURL = %q, AuthServer = %v", d.AuthServerURL, d.AuthServer)
+	}
+
+	// A hint from WWW-Authenticate is used as is.
+	hint := ts.URL + "/.well-known/oauth-protected-resource"
+	if d, err := DiscoverOAuth(context.Background(), ts.Client(), ts.URL+"/other", hint); err != nil || d.ResourceMetadataURL != hint {
+		t.Errorf("with hint: %+v, %v", d, err)
+	}
+
+	// Without authorization server metadata, the listed server is used.
+	var bare *httptest.Server
+	bare = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/oauth-protected-resource" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(ProtectedResourceMetadata{AuthorizationServers: []string{bare.URL + "/as"}})
+	}))
+	defer bare.Close()
+	d, err = DiscoverOAuth(context.Background(), bare.Client(), bare.URL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.AuthServerURL != bare.URL+"/as" || d.AuthServer != nil {
+		t.Errorf("AuthServerURL = %q, AuthServer = %v", d.AuthServerURL, d.AuthServer)
+	}
+
+	if _, err := DiscoverOAuth(context.Background(), bare.Client(), bare.URL, bare.URL+"/missing"); err == nil {
+		t.Error("expected error for missing resource metadata")
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+type clientRegistrationRequest struct {
+	ClientName              string   `json:"client_name"`
+	RedirectURIs            []string `json:"redirect_uris,omitempty"`
+	GrantTypes              []string `json:"grant_types"`
+	ResponseTypes           []string `json:"response_types,omitempty"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method"`
+	Scope                   string   `json:"scope"`
+}
+
+// RegisterClient performs RFC 7591 dynamic client registration.
+func RegisterClient(ctx context.Context, client *http.Client, registrationEndpoint, redirectURI, scope string) (*ClientRegistration, error) {
+	return registerClient(ctx, client, registrationEndpoint, codeClientRequest("clihub", redirectURI, scope))
+}
+
+// RegisterDeviceClient registers a client for the device authorization
+// grant, which has no redirect URI.
+func RegisterDeviceClient(ctx context.Context, client *http.Client, registrationEndpoint, scope string) (*ClientRegistration, error) {
+	return registerClient(ctx, client, registrationEndpoint, deviceClientRequest("clihub", scope))
+}
+
+// codeClientRequest registers a public client for the authorization code
+// flow with a loopback redirect.
+func codeClientRequest(name, redirectURI, scope string) clientRegistrationRequest {
+	return clientRegistrationRequest{
+		ClientName:              name,
+		RedirectURIs:            []string{redirectURI},
+		GrantTypes:              []string{"authorization_code", "refresh_token"},
+		ResponseTypes:           []string{"code"},
+		TokenEndpointAuthMethod: "none",
+		Scope:                   scope,
+	}
+}
+
+// deviceClientRequest registers a public client for the device grant.
+func deviceClientRequest(name, scope string) clientRegistrationRequest {
+	return clientRegistrationRequest{
+		ClientName:              name,
+		GrantTypes:              []string{DeviceCodeGrantType, "refresh_token"},
+		TokenEndpointAuthMethod: "none",
+		Scope:                   scope,
+	}
+}
+
+func registerClient(ctx context.Context, client *http.Client, registrationEndpoint string, body clientRegistrationRequest) (*ClientRegistration, error) {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", registrationEndpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("client registration request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("client registration failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	var reg ClientRegistration
+	if err := json.NewDecoder(resp.Body).Decode(&reg); err != nil {
+		return nil, fmt.Errorf("parse registration response: %w", err)
+	}
+
+	if reg.ClientID == "" {
+		return nil, fmt.Errorf("server returned empty client_id")
+	}
+
+	return &reg, nil
+}
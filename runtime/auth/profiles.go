@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// DefaultProfile is the profile stored in CredentialsFile.Servers. An empty
+// profile name means DefaultProfile everywhere in this package.
+const DefaultProfile = "default"
+
+// ValidateProfileName checks that a profile name is usable as a flag value
+// and a JSON key: lowercase letters, digits, '-' and '_', starting with a
+// letter or digit.
+func ValidateProfileName(name string) error {
+	if name == "" {
+		return fmt.Errorf("profile name is empty")
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+		case (r == '-' || r == '_') && i > 0:
+		default:
+			return fmt.Errorf("invalid profile name %q: use lowercase letters, digits, '-' and '_'", name)
+		}
+	}
+	return nil
+}
+
+func normalizeProfile(profile string) string {
+	if profile == "" {
+		return DefaultProfile
+	}
+	return profile
+}
+
+// ResolveProfile returns the profile to use for serverURL: requested if set
+// (typically from --profile), then CLIHUB_PROFILE, then the profile chosen
+// with `auth use`, then DefaultProfile.
+func (c *CredentialsFile) ResolveProfile(serverURL, requested string) string {
+	if requested != "" {
+		return requested
+	}
+	if env := os.Getenv("CLIHUB_PROFILE"); env != "" {
+		return env
+	}
+	if p := c.DefaultProfiles[serverURL]; p != "" {
+		return p
+	}
+	return DefaultProfile
+}
+
+// Credential returns the credential stored for serverURL under profile.
+func (c *CredentialsFile) Credential(serverURL, profile string) (ServerCredential, bool) {
+	profile = normalizeProfile(profile)
+	if profile == DefaultProfile {
+		sc, ok := c.Servers[serverURL]
+		return sc, ok
+	}
+	sc, ok := c.Profiles[profile][serverURL]
+	return sc, ok
+}
+
+// SetCredential stores sc for serverURL under profile.
+func (c *CredentialsFile) SetCredential(serverURL, profile string, sc ServerCredential) {
+	if c.Version < 2 {
+		c.Version = 2
+	}
+	profile = normalizeProfile(profile)
+	if profile == DefaultProfile {
+		if c.Servers == nil {
+			c.Servers = make(map[string]ServerCredential)
+		}
+		c.Servers[serverURL] = sc
+		return
+	}
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]map[string]ServerCredential)
+	}
+	if c.Profiles[profile] == nil {
+		c.Profiles[profile] = make(map[string]ServerCredential)
+	}
+	c.Profiles[profile][serverURL] = sc
+}
+
+// DeleteCredential removes the credential for serverURL under profile. If
+// that profile was the server's default, the default is reset.
+func (c *CredentialsFile) DeleteCredential(serverURL, profile string) {
+	profile = normalizeProfile(profile)
+	if profile == DefaultProfile {
+		delete(c.Servers, serverURL)
+	} else if servers := c.Profiles[profile]; servers != nil {
+		delete(servers, serverURL)
+		if len(servers) == 0 {
+			delete(c.Profiles, profile)
+		}
+	}
+	if c.DefaultProfiles[serverURL] == profile {
+		delete(c.DefaultProfiles, serverURL)
+	}
+}
+
+// ServerProfiles returns the profiles holding a credential for serverURL,
+// DefaultProfile first and the rest sorted.
+func (c *CredentialsFile) ServerProfiles(serverURL string) []string {
+	var names []string
+	for name, servers := range c.Profiles {
+		if _, ok := servers[serverURL]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := c.Servers[serverURL]; ok {
+		names = append([]string{DefaultProfile}, names...)
+	}
+	return names
+}
+
+// CredentialEntry is one stored credential.
+type CredentialEntry struct {
+	ServerURL  string
+	Profile    string
+	Credential ServerCredential
+}
+
+// Entries returns every stored credential, sorted by server URL with the
+// default profile first.
+func (c *CredentialsFile) Entries() []CredentialEntry {
+	var entries []CredentialEntry
+	for url, sc := range c.Servers {
+		entries = append(entries, CredentialEntry{ServerURL: url, Profile: DefaultProfile, Credential: sc})
+	}
+	for profile, servers := range c.Profiles {
+		for url, sc := range servers {
+			entries = append(entries, CredentialEntry{ServerURL: url, Profile: profile, Credential: sc})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.ServerURL != b.ServerURL {
+			return a.ServerURL < b.ServerURL
+		}
+		if (a.Profile == DefaultProfile) != (b.Profile == DefaultProfile) {
+			return a.Profile == DefaultProfile
+		}
+		return a.Profile < b.Profile
+	})
+	return entries
+}
+
+// UseProfile makes profile the default for serverURL. The profile must
+// already hold a credential for the server.
+func (c *CredentialsFile) UseProfile(serverURL, profile string) error {
+	profile = normalizeProfile(profile)
+	if _, ok := c.Credential(serverURL, profile); !ok {
+		return fmt.Errorf("profile %q has no credentials for %s", profile, serverURL)
+	}
+	if profile == DefaultProfile {
+		delete(c.DefaultProfiles, serverURL)
+		return nil
+	}
+	if c.DefaultProfiles == nil {
+		c.DefaultProfiles = make(map[string]string)
+	}
+	c.DefaultProfiles[serverURL] = profile
+	return nil
+}
+
+// clone returns a copy of c whose maps can be modified independently.
+func (c *CredentialsFile) clone() *CredentialsFile {
+	out := &CredentialsFile{Version: c.Version, Servers: make(map[string]ServerCredential, len(c.Servers))}
+	for url, sc := range c.Servers {
+		out.Servers[url] = sc
+	}
+	for profile, servers := range c.Profiles {
+		for url, sc := range servers {
+			out.SetCredential(url, profile, sc)
+		}
+	}
+	for url, profile := range c.DefaultProfiles {
+		if out.DefaultProfiles == nil {
+			out.DefaultProfiles = make(map[string]string)
+		}
+		out.DefaultProfiles[url] = profile
+	}
+	return out
+}
+
+// secretKey identifies a credential in a SecretStore. The default profile
+// uses the bare server URL.
+func secretKey(serverURL, profile string) string {
+	profile = normalizeProfile(profile)
+	if profile == DefaultProfile {
+		return serverURL
+	}
+	return profile + " " + serverURL
+}
+
+// forEachCredential calls fn for every stored credential and stores the
+// credential it returns.
+func (c *CredentialsFile) forEachCredential(fn func(profile, serverURL string, sc ServerCredential) (ServerCredential, error)) error {
+	for url, sc := range c.Servers {
+		updated, err := fn(DefaultProfile, url, sc)
+		if err != nil {
+			return err
+		}
+		c.Servers[url] = updated
+	}
+	for profile, servers := range c.Profiles {
+		for url, sc := range servers {
+			updated, err := fn(profile, url, sc)
+			if err != nil {
+				return err
+			}
+			servers[url] = updated
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveProfile(t *testing.T) {
+	creds := &CredentialsFile{Version: 2, Servers: map[string]ServerCredential{}}
+	const url = "https://example.com/mcp"
+
+	t.Setenv("CLIHUB_PROFILE", "")
+	if got := creds.ResolveProfile(url, ""); got != DefaultProfile {
+		t.Errorf("ResolveProfile() = %q, want %q", got, DefaultProfile)
+	}
+
+	SetToken(creds, url, "bot", "b")
+	if err := creds.UseProfile(url, "bot"); err != nil {
+		t.Fatal(err)
+	}
+	if got := creds.ResolveProfile(url, ""); got != "bot" {
+		t.Errorf("after UseProfile: ResolveProfile() = %q, want bot", got)
+	}
+
+	t.Setenv("CLIHUB_PROFILE", "ci")
+	if got := creds.ResolveProfile(url, ""); got != "ci" {
+		t.Errorf("with CLIHUB_PROFILE: ResolveProfile() = %q, want ci", got)
+	}
+	if got := creds.ResolveProfile(url, "work"); got != "work" {
+		t.Errorf("with --profile: ResolveProfile() = %q, want work", got)
+	}
+}
+
+func TestProfileCredentials(t *testing.T) {
+	const url = "https://example.com/mcp"
+	creds := &CredentialsFile{Version: 2, Servers: map[string]ServerCredential{}}
+	SetToken(creds, url, "", "default-token")
+	SetToken(creds, url, "bot", "bot-token")
+
+	if got := GetToken(creds, url, DefaultProfile); got != "default-token" {
+		t.Errorf("default token = %q", got)
+	}
+	if got := GetToken(creds, url, "bot"); got != "bot-token" {
+		t.Errorf("bot token = %q", got)
+	}
+	if got := creds.Servers[url].Token; got != "default-token" {
+		t.Errorf("default profile not kept in Servers: %q", got)
+	}
+	if got := creds.ServerProfiles(url); len(got) != 2 || got[0] != DefaultProfile || got[1] != "bot" {
+		t.Errorf("ServerProfiles() = %v", got)
+	}
+
+	if err := creds.UseProfile(url, "missing"); err == nil {
+		t.Error("UseProfile accepted a profile without credentials")
+	}
+	if err := creds.UseProfile(url, "bot"); err != nil {
+		t.Fatal(err)
+	}
+	creds.DeleteCredential(url, "bot")
+	if _, ok := creds.Credential(url, "bot"); ok {
+		t.Error("bot credential still present after delete")
+	}
+	if creds.DefaultProfiles[url] != "" {
+		t.Error("deleting the default profile did not reset it")
+	}
+	if len(creds.Profiles) != 0 {
+		t.Errorf("empty profile not removed: %v", creds.Profiles)
+	}
+}
+
+func TestProfileSecretsRoundTrip(t *testing.T) {
+	const url = "https://example.com/mcp"
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	t.Setenv("CLIHUB_SECRET_STORE", SecretStoreFile)
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "profiles")
+
+	creds := &CredentialsFile{Version: 2, Servers: map[string]ServerCredential{}}
+	SetToken(creds, url, "", "default-token")
+	SetToken(creds, url, "bot", "bot-token")
+	if err := SaveCredentials(path, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := OpenSecretStore(SecretStoreFile, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("bot " + url); err != nil {
+		t.Errorf("named profile secret not stored under its own key: %v", err)
+	}
+
+	loaded, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := GetToken(loaded, url, "bot"); got != "bot-token" {
+		t.Errorf("bot token = %q", got)
+	}
+	if got := GetToken(loaded, url, ""); got != "default-token" {
+		t.Errorf("default token = %q", got)
+	}
+}
+
+func TestValidateProfileName(t *testing.T) {
+	for _, name := range []string{"default", "bot", "ci-2", "work_admin"} {
+		if err := ValidateProfileName(name); err != nil {
+			t.Errorf("ValidateProfileName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "Bot", "-x", "a b", "a/b"} {
+		if err := ValidateProfileName(name); err == nil {
+			t.Errorf("ValidateProfileName(%q) accepted", name)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -26,10 +27,22 @@ type S2SOAuth2Provider struct {
 	// Scope is the requested scope (optional).
 	Scope string
 
+	mu          sync.Mutex
 	cachedToken string
+	expiresAt   time.Time
 }
 
-func (p *S2SOAuth2Provider) GetHeaders(_ context.Context) (map[string]string, error) {
+// GetHeaders returns the cached token, first requesting one when there is
+// none or it is about to expire and client credentials are configured.
+func (p *S2SOAuth2Provider) GetHeaders(ctx context.Context) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	stale := p.cachedToken == "" || (!p.expiresAt.IsZero() && time.Until(p.expiresAt) < RefreshSkewFromEnv())
+	if stale && p.ClientID != "" {
+		if _, err := p.Authenticate(ctx); err != nil {
+			return nil, err
+		}
+	}
 	if p.cachedToken == "" {
 		return nil, nil
 	}
@@ -74,7 +87,7 @@ func (p *S2SOAuth2Provider) Authenticate(ctx context.Context) (string, error) {
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	client := &http.Client{Timeout: 30 * time.Second}
+	client := newHTTPClient(30 * time.Second)
 	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("S2S OAuth2 token request: %w", err)
@@ -105,6 +118,9 @@ func (p *S2SOAuth2Provider) Authenticate(ctx context.Context) (string, error) {
 		return "", fmt.Errorf("S2S OAuth2 response missing access_token")
 	}
 
-	p.cachedToken = tokenResp.AccessToken
+	p.cachedToken, p.expiresAt = tokenResp.AccessToken, time.Time{}
+	if tokenResp.ExpiresIn > 0 {
+		p.expiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
 	return tokenResp.AccessToken, nil
 }
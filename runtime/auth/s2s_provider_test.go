@@ -34,6 +34,38 @@ func TestS2SOAuth2Provider_GetHeaders_CachedToken(t *testing.T) {
 	}
 }
 
+func TestS2SOAuth2Provider_GetHeaders_FetchesToken(t *testing.T) {
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "fetched-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer server.Close()
+
+	p := &S2SOAuth2Provider{
+		ClientID:      "test-client",
+		ClientSecret:  "test-secret",
+		TokenEndpoint: server.URL,
+	}
+	for i := 0; i < 2; i++ {
+		headers, err := p.GetHeaders(context.Background())
+		if err != nil {
+			t.Fatalf("GetHeaders error: %v", err)
+		}
+		if got := headers["Authorization"]; got != "Bearer fetched-token" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer fetched-token")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("token endpoint called %d times, want 1", calls)
+	}
+}
+
 func TestS2SOAuth2Provider_Authenticate(t *testing.T) {
 	// Mock token endpoint
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
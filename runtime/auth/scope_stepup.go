@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ErrStepUpDeclined is returned when the user does not consent to
+// re-authorizing with a broader scope.
+var ErrStepUpDeclined = errors.New("scope step-up declined")
+
+// InsufficientScope reports the scope a response says the request needs:
+// a 403 with a Bearer insufficient_scope challenge (RFC 6750 §3.1). ok is
+// false for any other response.
+func InsufficientScope(resp *http.Response) (scope string, ok bool) {
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		return "", false
+	}
+	bearer := FindBearerChallenge(ParseWWWAuthenticate(resp.Header.Get("WWW-Authenticate")))
+	if bearer == nil || bearer.Error != "insufficient_scope" {
+		return "", false
+	}
+	return bearer.Scope, true
+}
+
+// MergeScopes returns the union of space-separated scope lists, keeping
+// the order in which scopes first appear.
+func MergeScopes(scopes ...string) string {
+	seen := map[string]bool{}
+	var out []string
+	for _, list := range scopes {
+		for _, s := range strings.Fields(list) {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+	return strings.Join(out, " ")
+}
+
+// StepUp re-runs the interactive flow for the union of the stored scope and
+// required, after confirm (when set) approves the new scope. The broader
+// scope is saved with the new tokens. It returns false when the stored
+// scope already covers required, since authorizing again would not help.
+func (p *OAuth2Provider) StepUp(ctx context.Context, required string, confirm func(scope string) bool) (bool, error) {
+	current := p.Scope
+	if p.CredPath != "" {
+		if creds, err := LoadServerCredentials(p.CredPath, p.ServerURL); err == nil {
+			if sc := GetOAuthCredential(creds, p.ServerURL, p.Profile); sc != nil && sc.Scope != "" {
+				current = sc.Scope
+			}
+		}
+	}
+	scope := MergeScopes(current, required)
+	if required == "" || scope == MergeScopes(current) {
+		return false, nil
+	}
+	if confirm != nil && !confirm(scope) {
+		return false, ErrStepUpDeclined
+	}
+
+	// The user may need longer than ctx allows, if the caller passes the
+	// context of the request that hit the 403.
+	timeout := 5 * time.Minute
+	if p.Device {
+		timeout = 15 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	tokens, err := Authenticate(ctx, p.flowConfig(scope))
+	if err != nil {
+		return false, fmt.Errorf("re-authorize with scope %q: %w", scope, err)
+	}
+	if p.CredPath != "" {
+		err := UpdateCredentials(p.CredPath, func(creds *CredentialsFile) error {
+			SetOAuthTokens(creds, p.ServerURL, p.Profile, *tokens)
+			return nil
+		})
+		if err != nil {
+			return false, fmt.Errorf("save credentials: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	p.cachedToken, p.cachedExpiry = tokens.AccessToken, tokens.ExpiresAt
+	p.Scope = scope
+	p.mu.Unlock()
+	return true, nil
+}
+
+// InsufficientScopeError is returned by ScopeStepUpTransport for a 403
+// insufficient_scope response.
+type InsufficientScopeError struct {
+	// Scope is the scope the server asked for.
+	Scope string
+}
+
+func (e *InsufficientScopeError) Error() string {
+	return fmt.Sprintf("server requires scope %q", e.Scope)
+}
+
+// ScopeStepUpTransport turns 403 insufficient_scope responses into an
+// *InsufficientScopeError. The caller then runs OAuth2Provider.StepUp, which
+// may wait minutes for the user, outside the deadline of the request that
+// failed, and sends the request again with a fresh one.
+type ScopeStepUpTransport struct {
+	Base http.RoundTripper
+}
+
+func (t *ScopeStepUpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	required, ok := InsufficientScope(resp)
+	if !ok {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return nil, &InsufficientScopeError{Scope: required}
+}
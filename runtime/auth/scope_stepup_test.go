@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInsufficientScope(t *testing.T) {
+	resp := func(status int, challenge string) *http.Response {
+		r := &http.Response{StatusCode: status, Header: http.Header{}}
+		r.Header.Set("WWW-Authenticate", challenge)
+		return r
+	}
+	if scope, ok := InsufficientScope(resp(403, `Bearer error="insufficient_scope", scope="files:write"`)); !ok || scope != "files:write" {
+		t.Errorf("InsufficientScope = %q, %v", scope, ok)
+	}
+	for _, r := range []*http.Response{
+		resp(401, `Bearer error="insufficient_scope", scope="x"`),
+		resp(403, `Bearer error="invalid_token"`),
+		resp(403, `Basic realm="x"`),
+		nil,
+	} {
+		if _, ok := InsufficientScope(r); ok {
+			t.Errorf("InsufficientScope(%v) = true", r)
+		}
+	}
+}
+
+func TestMergeScopes(t *testing.T) {
+	if got := MergeScopes("read write", " write  admin", "", "read"); got != "read write admin" {
+		t.Errorf("MergeScopes = %q", got)
+	}
+}
+
+func TestScopeStepUpTransport(t *testing.T) {
+	mcp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer broad" {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="write"`)
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer mcp.Close()
+
+	send := func(token string) (*http.Response, error) {
+		req, _ := http.NewRequest("POST", mcp.URL, strings.NewReader("call"))
+		req.Header.Set("Authorization", "Bearer "+token)
+		return (&ScopeStepUpTransport{}).RoundTrip(req)
+	}
+	var scopeErr *InsufficientScopeError
+	if _, err := send("narrow"); !errors.As(err, &scopeErr) || scopeErr.Scope != "write" {
+		t.Fatalf("error = %v, want an InsufficientScopeError for scope write", err)
+	}
+	resp, err := send("broad")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("authorized request = %v, %v", resp, err)
+	}
+	resp.Body.Close()
+}
+
+func TestOAuth2Provider_StepUp(t *testing.T) {
+	shortDevicePoll(t)
+	as, _ := deviceAuthServer(t, true)
+	serverURL := as.URL + "/mcp"
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	creds := &CredentialsFile{Version: 2, Servers: map[string]ServerCredential{
+		serverURL: {AuthType: "oauth2", AccessToken: "narrow", Scope: "read"},
+	}}
+	if err := SaveCredentials(path, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	// Declining keeps the call failing.
+	declined := &OAuth2Provider{ServerURL: serverURL, CredPath: path, Device: true}
+	if _, err := declined.StepUp(context.Background(), "write", func(string) bool { return false }); !errors.Is(err, ErrStepUpDeclined) {
+		t.Fatalf("declined step-up error = %v", err)
+	}
+
+	// A scope the stored one already covers is not asked for again.
+	p := &OAuth2Provider{ServerURL: serverURL, CredPath: path, Device: true}
+	if stepped, err := p.StepUp(context.Background(), "read", nil); stepped || err != nil {
+		t.Fatalf("StepUp(read) = %v, %v; want false, nil", stepped, err)
+	}
+
+	// A cancelled context does not cut the flow short.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var asked string
+	stepped, err := p.StepUp(ctx, "write", func(scope string) bool { asked = scope; return true })
+	if err != nil || !stepped {
+		t.Fatalf("StepUp = %v, %v", stepped, err)
+	}
+	if asked != "read write" {
+		t.Errorf("asked to consent to %q, want the union of scopes", asked)
+	}
+	headers, _ := p.GetHeaders(context.Background())
+	if headers["Authorization"] != "Bearer device-access" {
+		t.Errorf("Authorization after step-up = %q", headers["Authorization"])
+	}
+
+	creds, err = LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc := creds.Servers[serverURL]; sc.AccessToken != "device-access" || sc.Scope != "read write" {
+		t.Errorf("stored credential = %+v", sc)
+	}
+}
@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// Secret store names accepted by CLIHUB_SECRET_STORE and recorded in
+// ServerCredential.SecretStore.
+const (
+	SecretStoreAuto          = "auto"
+	SecretStoreJSON          = "json"
+	SecretStoreSecretService = "secret-service"
+	SecretStoreFile          = "file"
+)
+
+// ErrSecretNotFound is returned by SecretStore.Get when no secret is stored
+// for a server.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore keeps the secret fields of a ServerCredential (tokens,
+// passwords, client secrets) outside credentials.json. Each credential's
+// secrets are stored as one opaque string keyed by server URL (prefixed with
+// the profile name for named profiles). Stores are scoped to a credentials
+// file, so different CLIHUB_CREDENTIALS_FILE values do not share secrets.
+type SecretStore interface {
+	Name() string
+	Get(key string) (string, error)
+	Set(key, secret string) error
+	Delete(key string) error
+}
+
+// credentialSecrets are the ServerCredential fields moved into a SecretStore.
+type credentialSecrets struct {
+	Token        string `json:"token,omitempty"`
+	Password     string `json:"password,omitempty"`
+	AccessToken  string `json:"access_token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+func (s credentialSecrets) empty() bool {
+	return s == credentialSecrets{}
+}
+
+// splitSecrets returns a copy of sc with its secret fields cleared, and the
+// cleared fields.
+func splitSecrets(sc ServerCredential) (ServerCredential, credentialSecrets) {
+	secrets := credentialSecrets{
+		Token:        sc.Token,
+		Password:     sc.Password,
+		AccessToken:  sc.AccessToken,
+		RefreshToken: sc.RefreshToken,
+		ClientSecret: sc.ClientSecret,
+	}
+	sc.Token, sc.Password, sc.AccessToken, sc.RefreshToken, sc.ClientSecret = "", "", "", "", ""
+	return sc, secrets
+}
+
+// mergeSecrets fills the secret fields of sc from secrets.
+func mergeSecrets(sc *ServerCredential, secrets credentialSecrets) {
+	sc.Token = secrets.Token
+	sc.Password = secrets.Password
+	sc.AccessToken = secrets.AccessToken
+	sc.RefreshToken = secrets.RefreshToken
+	sc.ClientSecret = secrets.ClientSecret
+}
+
+// Redacted returns a copy of sc with every secret field that is set replaced
+// by "REDACTED", so it can be shown without leaking tokens.
+func (sc ServerCredential) Redacted() ServerCredential {
+	out, secrets := splitSecrets(sc)
+	redact := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		return "REDACTED"
+	}
+	mergeSecrets(&out, credentialSecrets{
+		Token:        redact(secrets.Token),
+		Password:     redact(secrets.Password),
+		AccessToken:  redact(secrets.AccessToken),
+		RefreshToken: redact(secrets.RefreshToken),
+		ClientSecret: redact(secrets.ClientSecret),
+	})
+	return out
+}
+
+// DefaultSecretStore returns the store new secrets are written to for the
+// credentials file at credPath, chosen by CLIHUB_SECRET_STORE:
+//
+//   - "json": secrets stay in credentials.json (returns nil)
+//   - "secret-service": the desktop keyring, by running secret-tool
+//   - "file": an AES-GCM encrypted file next to credentials.json, keyed by
+//     CLIHUB_SECRETS_PASSPHRASE or CLIHUB_SECRETS_KEY_FILE
+//   - "auto" or unset: "file" when a passphrase or key file is configured,
+//     otherwise "secret-service" when a session bus and secret-tool are
+//     available, otherwise "json"
+func DefaultSecretStore(credPath string) (SecretStore, error) {
+	name := os.Getenv("CLIHUB_SECRET_STORE")
+	if name == "" || name == SecretStoreAuto {
+		name = autoSecretStore()
+	}
+	return OpenSecretStore(name, credPath)
+}
+
+func autoSecretStore() string {
+	if os.Getenv("CLIHUB_SECRETS_PASSPHRASE") != "" || os.Getenv("CLIHUB_SECRETS_KEY_FILE") != "" {
+		return SecretStoreFile
+	}
+	if secretServiceAvailable() {
+		return SecretStoreSecretService
+	}
+	return SecretStoreJSON
+}
+
+func secretServiceAvailable() bool {
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		return false
+	}
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+// OpenSecretStore opens the named store for the credentials file at
+// credPath. The "json" store is represented by a nil SecretStore.
+func OpenSecretStore(name, credPath string) (SecretStore, error) {
+	switch name {
+	case SecretStoreJSON:
+		return nil, nil
+	case SecretStoreSecretService:
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return nil, fmt.Errorf("secret store %q needs the secret-tool command on PATH (package libsecret-tools)", name)
+		}
+		return &secretServiceStore{credPath: credPath}, nil
+	case SecretStoreFile:
+		return newEncryptedFileStore(credPath)
+	default:
+		return nil, fmt.Errorf("unknown secret store %q (want %s, %s, %s or %s)",
+			name, SecretStoreAuto, SecretStoreJSON, SecretStoreSecretService, SecretStoreFile)
+	}
+}
+
+// secretBatcher is implemented by stores that keep every secret in one
+// place, so a load reads them all at once and a save rewrites them once.
+type secretBatcher interface {
+	GetAll() (map[string]string, error)
+	// SetAll stores set and deletes del in one write, skipping the write
+	// when nothing changes.
+	SetAll(set map[string]string, del []string) error
+}
+
+// loadSecrets fills in the secret fields of every credential that keeps
+// them in a secret store, or only of serverURL's credentials when it is not
+// empty. Each store is opened once; stores that implement secretBatcher are
+// read once. The values read are remembered in creds so saving it again
+// only writes the secrets that changed.
+func loadSecrets(path string, creds *CredentialsFile, serverURL string) error {
+	stores := map[string]SecretStore{}
+	all := map[string]map[string]string{}
+	creds.loaded = map[string]map[string]string{}
+	return creds.forEachCredential(func(profile, url string, sc ServerCredential) (ServerCredential, error) {
+		if sc.SecretStore == "" || (serverURL != "" && url != serverURL) {
+			return sc, nil
+		}
+		store, ok := stores[sc.SecretStore]
+		if !ok {
+			var err error
+			if store, err = OpenSecretStore(sc.SecretStore, path); err != nil {
+				return sc, fmt.Errorf("credentials for %s: %w", url, err)
+			}
+			stores[sc.SecretStore] = store
+			if b, ok := store.(secretBatcher); ok {
+				if all[sc.SecretStore], err = b.GetAll(); err != nil {
+					return sc, fmt.Errorf("credentials for %s: read %s store: %w", url, sc.SecretStore, err)
+				}
+			}
+		}
+		if store == nil {
+			return sc, nil
+		}
+		key := secretKey(url, profile)
+		var raw string
+		var err error
+		if secrets, ok := all[sc.SecretStore]; ok {
+			if raw, ok = secrets[key]; !ok {
+				err = ErrSecretNotFound
+			}
+		} else {
+			raw, err = store.Get(key)
+		}
+		if errors.Is(err, ErrSecretNotFound) {
+			// The store lost the entry (e.g. a wiped keyring); keep the
+			// metadata so the user is asked to re-authenticate.
+			return sc, nil
+		}
+		if err != nil {
+			return sc, fmt.Errorf("credentials for %s: read %s store: %w", url, sc.SecretStore, err)
+		}
+		var secrets credentialSecrets
+		if err := json.Unmarshal([]byte(raw), &secrets); err != nil {
+			return sc, fmt.Errorf("credentials for %s: parse secrets: %w", url, err)
+		}
+		if creds.loaded[sc.SecretStore] == nil {
+			creds.loaded[sc.SecretStore] = map[string]string{}
+		}
+		creds.loaded[sc.SecretStore][key] = raw
+		mergeSecrets(&sc, secrets)
+		return sc, nil
+	})
+}
+
+// storeSecrets returns a copy of creds with secret fields moved into store
+// (when non-nil), and removes secrets that the previous file kept in a store
+// but the new one no longer does. Secrets that are unchanged since creds was
+// loaded are not written again.
+func storeSecrets(path string, creds *CredentialsFile, store SecretStore) (*CredentialsFile, error) {
+	out := creds.clone()
+	set := map[string]string{}
+	err := out.forEachCredential(func(profile, url string, sc ServerCredential) (ServerCredential, error) {
+		sc.SecretStore = ""
+		if store == nil {
+			return sc, nil
+		}
+		stripped, secrets := splitSecrets(sc)
+		if secrets.empty() {
+			return sc, nil
+		}
+		data, err := json.Marshal(secrets)
+		if err != nil {
+			return sc, err
+		}
+		set[secretKey(url, profile)] = string(data)
+		stripped.SecretStore = store.Name()
+		return stripped, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Secrets the previous file kept in a store the new entry no longer
+	// uses, grouped by store.
+	del := map[string][]string{}
+	if previous, err := readCredentialsFile(path); err == nil && previous != nil {
+		_ = previous.forEachCredential(func(profile, url string, old ServerCredential) (ServerCredential, error) {
+			current, _ := out.Credential(url, profile)
+			if old.SecretStore != "" && current.SecretStore != old.SecretStore {
+				del[old.SecretStore] = append(del[old.SecretStore], secretKey(url, profile))
+			}
+			return old, nil
+		})
+	}
+
+	if store != nil {
+		if err := applySecrets(store, set, del[store.Name()], creds.loaded[store.Name()]); err != nil {
+			return nil, fmt.Errorf("write %s store: %w", store.Name(), err)
+		}
+		delete(del, store.Name())
+	}
+	for name, keys := range del {
+		if oldStore, err := OpenSecretStore(name, path); err == nil && oldStore != nil {
+			_ = applySecrets(oldStore, nil, keys, nil)
+		}
+	}
+	return out, nil
+}
+
+// applySecrets stores set and deletes del in store: in one write for a
+// secretBatcher, otherwise one call per entry, skipping entries whose value
+// matches loaded.
+func applySecrets(store SecretStore, set map[string]string, del []string, loaded map[string]string) error {
+	if b, ok := store.(secretBatcher); ok {
+		return b.SetAll(set, del)
+	}
+	for key, secret := range set {
+		if old, ok := loaded[key]; ok && old == secret {
+			continue
+		}
+		if err := store.Set(key, secret); err != nil {
+			return err
+		}
+	}
+	for _, key := range del {
+		if err := store.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	kdfPBKDF2 = "pbkdf2-sha256" // passphrase
+	kdfHKDF   = "hkdf-sha256"   // key file
+
+	pbkdf2Iterations = 600000
+	secretsFileAAD   = "clihub-secrets-v1"
+)
+
+// derivedKeys caches derived AES keys so a process pays for PBKDF2 once per
+// salt rather than on every credentials read.
+var derivedKeys sync.Map
+
+// encryptedFileStore keeps every server's secrets in one AES-256-GCM
+// encrypted file next to the credentials file. The key is derived from
+// CLIHUB_SECRETS_PASSPHRASE with PBKDF2, or from the raw contents of
+// CLIHUB_SECRETS_KEY_FILE (at least 32 random bytes) with HKDF.
+type encryptedFileStore struct {
+	path   string
+	kdf    string
+	secret []byte
+}
+
+// encryptedSecrets is the on-disk format of the encrypted secrets file.
+type encryptedSecrets struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// SecretsFilePath returns the encrypted secrets file used for the
+// credentials file at credPath: credentials.json → credentials.secrets.
+func SecretsFilePath(credPath string) string {
+	return strings.TrimSuffix(credPath, filepath.Ext(credPath)) + ".secrets"
+}
+
+func newEncryptedFileStore(credPath string) (*encryptedFileStore, error) {
+	s := &encryptedFileStore{path: SecretsFilePath(credPath)}
+	if p := os.Getenv("CLIHUB_SECRETS_PASSPHRASE"); p != "" {
+		s.kdf, s.secret = kdfPBKDF2, []byte(p)
+		return s, nil
+	}
+	if keyFile := os.Getenv("CLIHUB_SECRETS_KEY_FILE"); keyFile != "" {
+		data, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("read secrets key file: %w", err)
+		}
+		data = bytes.TrimSpace(data)
+		if len(data) < 32 {
+			return nil, fmt.Errorf("secrets key file %s is too short (need at least 32 bytes)", keyFile)
+		}
+		s.kdf, s.secret = kdfHKDF, data
+		return s, nil
+	}
+	return nil, fmt.Errorf("secret store %q needs CLIHUB_SECRETS_PASSPHRASE or CLIHUB_SECRETS_KEY_FILE", SecretStoreFile)
+}
+
+func (s *encryptedFileStore) Name() string { return SecretStoreFile }
+
+func (s *encryptedFileStore) Get(key string) (string, error) {
+	secrets, err := s.GetAll()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[key]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+func (s *encryptedFileStore) Set(key, secret string) error {
+	return s.SetAll(map[string]string{key: secret}, nil)
+}
+
+func (s *encryptedFileStore) Delete(key string) error {
+	return s.SetAll(nil, []string{key})
+}
+
+// GetAll decrypts the secrets file once and returns every secret in it.
+func (s *encryptedFileStore) GetAll() (map[string]string, error) {
+	secrets, _, err := s.read()
+	return secrets, err
+}
+
+// SetAll applies every change with one decrypt and at most one write.
+func (s *encryptedFileStore) SetAll(set map[string]string, del []string) error {
+	secrets, salt, err := s.read()
+	if err != nil {
+		return err
+	}
+	changed := false
+	for key, secret := range set {
+		if old, ok := secrets[key]; !ok || old != secret {
+			secrets[key] = secret
+			changed = true
+		}
+	}
+	for _, key := range del {
+		if _, ok := secrets[key]; ok {
+			delete(secrets, key)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.write(secrets, salt)
+}
+
+// read decrypts the secrets file. A missing file is an empty store.
+func (s *encryptedFileStore) read() (map[string]string, []byte, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return map[string]string{}, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var file encryptedSecrets
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, nil, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	if file.KDF != s.kdf {
+		return nil, nil, fmt.Errorf("%s was encrypted with %s; configure the matching passphrase or key file", s.path, describeKDF(file.KDF))
+	}
+	aead, err := s.aead(file.Salt, file.Iterations)
+	if err != nil {
+		return nil, nil, err
+	}
+	plain, err := aead.Open(nil, file.Nonce, file.Ciphertext, []byte(secretsFileAAD))
+	if err != nil {
+		return nil, nil, fmt.Errorf("decrypt %s: wrong passphrase or key file", s.path)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, nil, fmt.Errorf("parse decrypted %s: %w", s.path, err)
+	}
+	return secrets, file.Salt, nil
+}
+
+// write encrypts secrets with a fresh nonce and atomically replaces the
+// secrets file. The salt is kept across writes so the derived key stays
+// cached.
+func (s *encryptedFileStore) write(secrets map[string]string, salt []byte) error {
+	if salt == nil {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+	aead, err := s.aead(salt, pbkdf2Iterations)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	file := encryptedSecrets{
+		Version:    1,
+		KDF:        s.kdf,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plain, []byte(secretsFileAAD)),
+	}
+	if s.kdf == kdfPBKDF2 {
+		file.Iterations = pbkdf2Iterations
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data, 0600)
+}
+
+func (s *encryptedFileStore) aead(salt []byte, iterations int) (cipher.AEAD, error) {
+	fingerprint := sha256.Sum256(s.secret)
+	cacheKey := fmt.Sprintf("%s\x00%d\x00%s\x00%s", s.kdf, iterations, salt, fingerprint[:])
+
+	var key []byte
+	if cached, ok := derivedKeys.Load(cacheKey); ok {
+		key = cached.([]byte)
+	} else {
+		var err error
+		switch s.kdf {
+		case kdfPBKDF2:
+			key, err = pbkdf2.Key(sha256.New, string(s.secret), salt, iterations, 32)
+		default:
+			key, err = hkdf.Key(sha256.New, s.secret, salt, secretsFileAAD, 32)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("derive secrets key: %w", err)
+		}
+		derivedKeys.Store(cacheKey, key)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func describeKDF(kdf string) string {
+	switch kdf {
+	case kdfPBKDF2:
+		return "a passphrase (CLIHUB_SECRETS_PASSPHRASE)"
+	case kdfHKDF:
+		return "a key file (CLIHUB_SECRETS_KEY_FILE)"
+	default:
+		return fmt.Sprintf("unknown key derivation %q", kdf)
+	}
+}
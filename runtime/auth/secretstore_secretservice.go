@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// secretServiceStore keeps secrets in the freedesktop Secret Service (GNOME
+// Keyring, KWallet) by running libsecret's secret-tool command, once per
+// Get, Set or Delete. Items are identified by the credentials file path and
+// the secret key (server URL, prefixed by the profile name for named
+// profiles).
+type secretServiceStore struct {
+	credPath string
+}
+
+func (s *secretServiceStore) Name() string { return SecretStoreSecretService }
+
+func (s *secretServiceStore) attributes(key string) []string {
+	return []string{"service", "clihub", "credentials", s.credPath, "server", key}
+}
+
+func (s *secretServiceStore) Get(key string) (string, error) {
+	out, err := s.run(nil, append([]string{"lookup"}, s.attributes(key)...)...)
+	if err != nil {
+		// secret-tool exits 1 with no output when nothing matches.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 && out == "" {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	return out, nil
+}
+
+func (s *secretServiceStore) Set(key, secret string) error {
+	args := append([]string{"store", "--label=clihub credentials for " + key}, s.attributes(key)...)
+	_, err := s.run(strings.NewReader(secret), args...)
+	return err
+}
+
+func (s *secretServiceStore) Delete(key string) error {
+	_, err := s.run(nil, append([]string{"clear"}, s.attributes(key)...)...)
+	return err
+}
+
+func (s *secretServiceStore) run(stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.Command("secret-tool", args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return stdout.String(), fmt.Errorf("secret-tool %s: %s: %w", args[0], msg, err)
+		}
+		return stdout.String(), err
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// Keep tests away from the developer's real keyring.
+	if os.Getenv("CLIHUB_SECRET_STORE") == "" {
+		os.Setenv("CLIHUB_SECRET_STORE", SecretStoreJSON)
+	}
+	os.Exit(m.Run())
+}
+
+func sampleCredentials() *CredentialsFile {
+	return &CredentialsFile{
+		Version: 2,
+		Servers: map[string]ServerCredential{
+			"https://a.example.com": {AuthType: "oauth2", AccessToken: "access-a", RefreshToken: "refresh-a", ClientID: "client-a"},
+			"https://b.example.com": {AuthType: "basic_auth", Username: "bob", Password: "hunter2"},
+			"https://c.example.com": {AuthType: "google_sa", KeyFile: "/keys/sa.json"},
+		},
+	}
+}
+
+// assertRoundTrip saves sample credentials, checks that no secret reached
+// credentials.json, and loads them back.
+func assertRoundTrip(t *testing.T, path, store string) {
+	t.Helper()
+	if err := SaveCredentials(path, sampleCredentials()); err != nil {
+		t.Fatalf("SaveCredentials: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"access-a", "refresh-a", "hunter2"} {
+		if strings.Contains(string(raw), secret) {
+			t.Errorf("credentials.json contains secret %q:\n%s", secret, raw)
+		}
+	}
+
+	stored, err := readCredentialsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stored.Servers["https://a.example.com"].SecretStore; got != store {
+		t.Errorf("SecretStore = %q, want %q", got, store)
+	}
+	if got := stored.Servers["https://c.example.com"].SecretStore; got != "" {
+		t.Errorf("entry without secrets has SecretStore %q, want empty", got)
+	}
+
+	loaded, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatalf("LoadCredentials: %v", err)
+	}
+	a := loaded.Servers["https://a.example.com"]
+	if a.AccessToken != "access-a" || a.RefreshToken != "refresh-a" || a.ClientID != "client-a" {
+		t.Errorf("oauth2 entry = %+v", a)
+	}
+	if b := loaded.Servers["https://b.example.com"]; b.Username != "bob" || b.Password != "hunter2" {
+		t.Errorf("basic_auth entry = %+v", b)
+	}
+}
+
+func TestEncryptedFileStorePassphrase(t *testing.T) {
+	t.Setenv("CLIHUB_SECRET_STORE", SecretStoreFile)
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "correct horse")
+	path := filepath.Join(t.TempDir(), "credentials.json")
+
+	assertRoundTrip(t, path, SecretStoreFile)
+
+	secrets, err := os.ReadFile(SecretsFilePath(path))
+	if err != nil {
+		t.Fatalf("secrets file not written: %v", err)
+	}
+	if strings.Contains(string(secrets), "hunter2") {
+		t.Error("secrets file contains plaintext secret")
+	}
+	info, _ := os.Stat(SecretsFilePath(path))
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("secrets file permissions = %04o, want 0600", perm)
+	}
+
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "wrong")
+	if _, err := LoadCredentials(path); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("expected wrong passphrase error, got %v", err)
+	}
+}
+
+func TestEncryptedFileStoreKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key.txt")
+	if err := os.WriteFile(keyFile, []byte("AGE-SECRET-KEY-1QQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQQ\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLIHUB_SECRETS_KEY_FILE", keyFile)
+	path := filepath.Join(dir, "credentials.json")
+
+	// auto picks the file store once a key is configured.
+	t.Setenv("CLIHUB_SECRET_STORE", SecretStoreAuto)
+	assertRoundTrip(t, path, SecretStoreFile)
+
+	t.Setenv("CLIHUB_SECRETS_KEY_FILE", "")
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "some passphrase")
+	if _, err := LoadCredentials(path); err == nil || !strings.Contains(err.Error(), "key file") {
+		t.Fatalf("expected key file mismatch error, got %v", err)
+	}
+}
+
+func TestSecretStoreSwitchBackToJSON(t *testing.T) {
+	t.Setenv("CLIHUB_SECRET_STORE", SecretStoreFile)
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "correct horse")
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	if err := SaveCredentials(path, sampleCredentials()); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CLIHUB_SECRET_STORE", SecretStoreJSON)
+	if err := SaveCredentials(path, creds); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, _ := os.ReadFile(path)
+	if !strings.Contains(string(raw), "hunter2") || strings.Contains(string(raw), "secret_store") {
+		t.Errorf("expected inline secrets after switching to json:\n%s", raw)
+	}
+	store, err := newEncryptedFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get("https://b.example.com"); err != ErrSecretNotFound {
+		t.Errorf("stale secret left in file store: %v", err)
+	}
+}
+
+func TestSecretServiceStore(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake secret-tool is a shell script")
+	}
+	bin := t.TempDir()
+	keyring := t.TempDir()
+	script := `#!/bin/sh
+cmd=$1; shift
+echo "$cmd" >> "$FAKE_KEYRING.log"
+[ "$cmd" = store ] && shift
+key=$(echo "$*" | cksum | cut -d' ' -f1)
+case $cmd in
+store) cat > "$FAKE_KEYRING/$key" ;;
+lookup) [ -f "$FAKE_KEYRING/$key" ] || exit 1; cat "$FAKE_KEYRING/$key" ;;
+clear) rm -f "$FAKE_KEYRING/$key" ;;
+esac
+`
+	if err := os.WriteFile(filepath.Join(bin, "secret-tool"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_KEYRING", keyring)
+	t.Setenv("CLIHUB_SECRET_STORE", SecretStoreSecretService)
+
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	assertRoundTrip(t, path, SecretStoreSecretService)
+
+	// Loading one server's credentials looks up only that server's secret.
+	os.Remove(keyring + ".log")
+	one, err := LoadServerCredentials(path, "https://b.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := one.Servers["https://b.example.com"]; b.Password != "hunter2" {
+		t.Errorf("basic_auth entry = %+v", b)
+	}
+	if a := one.Servers["https://a.example.com"]; a.AccessToken != "" {
+		t.Errorf("unrequested server's secret was loaded: %+v", a)
+	}
+	if log, _ := os.ReadFile(keyring + ".log"); strings.Count(string(log), "lookup") != 1 {
+		t.Errorf("secret-tool calls = %q, want one lookup", log)
+	}
+	if err := SaveCredentials(path, one); err == nil {
+		t.Error("SaveCredentials accepted credentials loaded for one server")
+	}
+
+	// Removing a server clears its keyring item.
+	creds, err := LoadCredentials(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(creds.Servers, "https://b.example.com")
+	if err := SaveCredentials(path, creds); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(keyring)
+	if len(entries) != 1 {
+		t.Errorf("keyring has %d items, want 1", len(entries))
+	}
+
+	// Saving unchanged credentials does not rewrite the keyring.
+	os.Remove(keyring + ".log")
+	if err := UpdateCredentials(path, func(*CredentialsFile) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	log, _ := os.ReadFile(keyring + ".log")
+	if strings.Contains(string(log), "store") || strings.Contains(string(log), "clear") {
+		t.Errorf("unchanged save called secret-tool:\n%s", log)
+	}
+}
+
+func TestOpenSecretStoreErrors(t *testing.T) {
+	t.Setenv("CLIHUB_SECRETS_PASSPHRASE", "")
+	t.Setenv("CLIHUB_SECRETS_KEY_FILE", "")
+	if _, err := OpenSecretStore("vault", "creds.json"); err == nil || !strings.Contains(err.Error(), "unknown secret store") {
+		t.Errorf("expected unknown store error, got %v", err)
+	}
+	if _, err := OpenSecretStore(SecretStoreFile, "creds.json"); err == nil || !strings.Contains(err.Error(), "CLIHUB_SECRETS_PASSPHRASE") {
+		t.Errorf("expected missing passphrase error, got %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// TLSOptions configures client certificates (mTLS) and extra trusted CAs
+// for MCP and OAuth requests.
+type TLSOptions struct {
+	// ClientCert and ClientKey are PEM files for a client certificate.
+	ClientCert string
+	ClientKey  string
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string
+	// CAPEM is an in-memory PEM bundle, such as one embedded in a generated
+	// binary, trusted in addition to the system roots.
+	CAPEM []byte
+}
+
+// TLSOptionsFromEnv reads CLIHUB_CLIENT_CERT, CLIHUB_CLIENT_KEY and
+// CLIHUB_CA_FILE.
+func TLSOptionsFromEnv() TLSOptions {
+	return TLSOptions{
+		ClientCert: os.Getenv("CLIHUB_CLIENT_CERT"),
+		ClientKey:  os.Getenv("CLIHUB_CLIENT_KEY"),
+		CAFile:     os.Getenv("CLIHUB_CA_FILE"),
+	}
+}
+
+// IsZero reports whether no TLS customization is configured.
+func (o TLSOptions) IsZero() bool {
+	return o.ClientCert == "" && o.ClientKey == "" && o.CAFile == "" && len(o.CAPEM) == 0
+}
+
+// Config builds a tls.Config from the options.
+func (o TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if o.ClientCert != "" || o.ClientKey != "" {
+		if o.ClientCert == "" || o.ClientKey == "" {
+			return nil, fmt.Errorf("a client certificate needs both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if o.CAFile != "" || len(o.CAPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if len(o.CAPEM) > 0 && !pool.AppendCertsFromPEM(o.CAPEM) {
+			return nil, fmt.Errorf("embedded CA bundle contains no certificates")
+		}
+		if o.CAFile != "" {
+			data, err := os.ReadFile(o.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("read CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("CA file %s contains no PEM certificates", o.CAFile)
+			}
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+// Transport returns an http.Transport using the options, or nil when none
+// are set so callers keep http.DefaultTransport.
+func (o TLSOptions) Transport() (*http.Transport, error) {
+	if o.IsZero() {
+		return nil, nil
+	}
+	cfg, err := o.Config()
+	if err != nil {
+		return nil, err
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = cfg
+	return t, nil
+}
+
+// Transport is used for OAuth discovery, registration and token requests
+// made by this package. Nil means http.DefaultTransport.
+var Transport http.RoundTripper
+
+// newHTTPClient returns a client with the package Transport.
+func newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Timeout: timeout, Transport: Transport}
+}
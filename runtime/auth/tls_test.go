@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCert creates a self-signed client certificate and returns its
+// certificate and key files plus a pool trusting it.
+func writeClientCert(t *testing.T) (certFile, keyFile string, pool *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "clihub-test-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	cert, _ := x509.ParseCertificate(der)
+	pool = x509.NewCertPool()
+	pool.AddCert(cert)
+	return certFile, keyFile, pool
+}
+
+func TestTLSOptions_MutualTLS(t *testing.T) {
+	certFile, keyFile, clientCAs := writeClientCert(t)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+
+	// Without the CA the server certificate is untrusted
+	if _, err := (&http.Client{}).Get(srv.URL); err == nil {
+		t.Fatal("expected certificate verification error without --ca-file")
+	}
+
+	// The CA alone is not enough: the server wants a client certificate
+	tr, err := TLSOptions{CAFile: caFile}.Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&http.Client{Transport: tr}).Get(srv.URL); err == nil {
+		t.Fatal("expected handshake failure without a client certificate")
+	}
+
+	tr, err = TLSOptions{ClientCert: certFile, ClientKey: keyFile, CAFile: caFile}.Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("mTLS request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+
+	// Embedded PEM works like a CA file
+	tr, err = TLSOptions{ClientCert: certFile, ClientKey: keyFile, CAPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})}.Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&http.Client{Transport: tr}).Get(srv.URL); err != nil {
+		t.Errorf("request with embedded CA failed: %v", err)
+	}
+}
+
+func TestTLSOptions_Errors(t *testing.T) {
+	if tr, err := (TLSOptions{}).Transport(); tr != nil || err != nil {
+		t.Errorf("empty options = %v, %v; want nil, nil", tr, err)
+	}
+	certFile, _, _ := writeClientCert(t)
+	if _, err := (TLSOptions{ClientCert: certFile}).Config(); err == nil || !strings.Contains(err.Error(), "key") {
+		t.Errorf("cert without key: err = %v", err)
+	}
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0600)
+	if _, err := (TLSOptions{CAFile: notPEM}).Config(); err == nil {
+		t.Error("expected error for CA file without certificates")
+	}
+	if _, err := (TLSOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).Config(); err == nil {
+		t.Error("expected error for missing CA file")
+	}
+}
+
+func TestTLSOptionsFromEnv(t *testing.T) {
+	t.Setenv("CLIHUB_CLIENT_CERT", "c.pem")
+	t.Setenv("CLIHUB_CLIENT_KEY", "k.pem")
+	t.Setenv("CLIHUB_CA_FILE", "ca.pem")
+	if got := TLSOptionsFromEnv(); got.ClientCert != "c.pem" || got.ClientKey != "k.pem" || got.CAFile != "ca.pem" {
+		t.Errorf("TLSOptionsFromEnv() = %+v", got)
+	}
+}
+
+func TestS2SOAuth2Provider_UsesTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"access_token":"tls-token"}`))
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600)
+	tr, err := TLSOptions{CAFile: caFile}.Transport()
+	if err != nil {
+		t.Fatal(err)
+	}
+	Transport = tr
+	defer func() { Transport = nil }()
+
+	p := &S2SOAuth2Provider{ClientID: "id", ClientSecret: "secret", TokenEndpoint: srv.URL}
+	token, err := p.Authenticate(t.Context())
+	if err != nil || token != "tls-token" {
+		t.Errorf("Authenticate = %q, %v; want token over the custom CA", token, err)
+	}
+}
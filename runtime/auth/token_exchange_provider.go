@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RFC 8693 identifiers.
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeJWT           = "urn:ietf:params:oauth:token-type:jwt"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// TokenExchangeProvider exchanges a workload identity token, such as a CI
+// OIDC ID token, for an access token (RFC 8693). The subject token is
+// re-read before every exchange because CI systems rotate it.
+type TokenExchangeProvider struct {
+	// TokenEndpoint is the authorization server's token endpoint.
+	TokenEndpoint string
+	// SubjectTokenFile is a file holding the subject token.
+	SubjectTokenFile string
+	// SubjectTokenEnv names an environment variable holding the subject
+	// token; used when SubjectTokenFile is empty.
+	SubjectTokenEnv string
+	// SubjectTokenType defaults to TokenTypeJWT.
+	SubjectTokenType string
+	// Audience and Resource identify the target service (optional).
+	Audience string
+	Resource string
+	// Scope is the requested scope (optional).
+	Scope string
+	// ClientID and ClientSecret authenticate the client (optional).
+	ClientID     string
+	ClientSecret string
+
+	mu          sync.Mutex
+	cachedToken string
+	expiresAt   time.Time
+}
+
+func (p *TokenExchangeProvider) GetHeaders(ctx context.Context) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.cachedToken == "" || (!p.expiresAt.IsZero() && time.Until(p.expiresAt) < RefreshSkewFromEnv()) {
+		if err := p.exchange(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]string{"Authorization": "Bearer " + p.cachedToken}, nil
+}
+
+func (p *TokenExchangeProvider) OnUnauthorized(ctx context.Context, _ *http.Response) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.exchange(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Credential returns the configuration to persist with --save-credentials.
+func (p *TokenExchangeProvider) Credential() ServerCredential {
+	return ServerCredential{
+		AuthType:         "token_exchange",
+		TokenEndpoint:    p.TokenEndpoint,
+		SubjectTokenFile: p.SubjectTokenFile,
+		SubjectTokenEnv:  p.SubjectTokenEnv,
+		SubjectTokenType: p.SubjectTokenType,
+		Audience:         p.Audience,
+		Resource:         p.Resource,
+		Scope:            p.Scope,
+		ClientID:         p.ClientID,
+		ClientSecret:     p.ClientSecret,
+	}
+}
+
+// subjectToken reads the subject token from the file or environment.
+func (p *TokenExchangeProvider) subjectToken() (string, error) {
+	if p.SubjectTokenFile != "" {
+		data, err := os.ReadFile(p.SubjectTokenFile)
+		if err != nil {
+			return "", fmt.Errorf("read subject token: %w", err)
+		}
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("subject token file %s is empty", p.SubjectTokenFile)
+	}
+	if p.SubjectTokenEnv != "" {
+		if token := strings.TrimSpace(os.Getenv(p.SubjectTokenEnv)); token != "" {
+			return token, nil
+		}
+		return "", fmt.Errorf("subject token environment variable %s is not set", p.SubjectTokenEnv)
+	}
+	return "", fmt.Errorf("token exchange needs a subject token file or environment variable")
+}
+
+// exchange performs the token exchange; the caller holds p.mu.
+func (p *TokenExchangeProvider) exchange(ctx context.Context) error {
+	subject, err := p.subjectToken()
+	if err != nil {
+		return err
+	}
+	subjectType := p.SubjectTokenType
+	if subjectType == "" {
+		subjectType = TokenTypeJWT
+	}
+	form := url.Values{
+		"grant_type":           {TokenExchangeGrantType},
+		"subject_token":        {subject},
+		"subject_token_type":   {subjectType},
+		"requested_token_type": {TokenTypeAccessToken},
+	}
+	for key, value := range map[string]string{"audience": p.Audience, "resource": p.Resource, "scope": p.Scope} {
+		if value != "" {
+			form.Set(key, value)
+		}
+	}
+	if p.ClientID != "" {
+		form.Set("client_id", p.ClientID)
+	}
+	if p.ClientSecret != "" {
+		form.Set("client_secret", p.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := newHTTPClient(30 * time.Second)
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("token exchange request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		if code, desc := parseOAuthError(body); code != "" {
+			return fmt.Errorf("token exchange: %s — %s", code, desc)
+		}
+		return fmt.Errorf("token exchange endpoint returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in,omitempty"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return fmt.Errorf("parse token exchange response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return fmt.Errorf("token exchange response missing access_token")
+	}
+
+	p.cachedToken, p.expiresAt = tokenResp.AccessToken, time.Time{}
+	if tokenResp.ExpiresIn > 0 {
+		p.expiresAt = time.Now().Add(time.Duration(tokenResp.ExpiresIn) * time.Second)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTokenExchangeProvider_ImplementsInterface(t *testing.T) {
+	var _ AuthProvider = &TokenExchangeProvider{}
+}
+
+func TestTokenExchangeProvider_ExchangesSubjectTokenFile(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]string{
+			"grant_type":         TokenExchangeGrantType,
+			"subject_token":      "oidc-id-token",
+			"subject_token_type": TokenTypeJWT,
+			"audience":           "mcp.example.com",
+			"client_id":          "ci",
+		}
+		for key, value := range want {
+			if got := r.FormValue(key); got != value {
+				t.Errorf("%s = %q, want %q", key, got, value)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":      "exchanged-token",
+			"issued_token_type": TokenTypeAccessToken,
+			"token_type":        "Bearer",
+			"expires_in":        3600,
+		})
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("oidc-id-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	p := &TokenExchangeProvider{
+		TokenEndpoint:    server.URL,
+		SubjectTokenFile: tokenFile,
+		Audience:         "mcp.example.com",
+		ClientID:         "ci",
+	}
+	for i := 0; i < 2; i++ {
+		headers, err := p.GetHeaders(context.Background())
+		if err != nil {
+			t.Fatalf("GetHeaders: %v", err)
+		}
+		if got := headers["Authorization"]; got != "Bearer exchanged-token" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer exchanged-token")
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("exchanges = %d, want 1 (token cached)", got)
+	}
+
+	retry, err := p.OnUnauthorized(context.Background(), nil)
+	if err != nil || !retry {
+		t.Fatalf("OnUnauthorized = %v, %v; want true, nil", retry, err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("exchanges = %d, want 2 after 401", got)
+	}
+}
+
+func TestTokenExchangeProvider_ReexchangesExpiringToken(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "short", "expires_in": 5})
+	}))
+	defer server.Close()
+
+	t.Setenv("TEST_SUBJECT_TOKEN", "subject")
+	p := &TokenExchangeProvider{TokenEndpoint: server.URL, SubjectTokenEnv: "TEST_SUBJECT_TOKEN"}
+	for i := 0; i < 2; i++ {
+		if _, err := p.GetHeaders(context.Background()); err != nil {
+			t.Fatalf("GetHeaders: %v", err)
+		}
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("exchanges = %d, want 2 (token expires within the refresh skew)", got)
+	}
+}
+
+func TestTokenExchangeProvider_Errors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant","error_description":"subject token expired"}`))
+	}))
+	defer server.Close()
+
+	p := &TokenExchangeProvider{TokenEndpoint: server.URL, SubjectTokenEnv: "TEST_SUBJECT_TOKEN_UNSET"}
+	if _, err := p.GetHeaders(context.Background()); err == nil {
+		t.Error("expected error for unset subject token variable")
+	}
+
+	t.Setenv("TEST_SUBJECT_TOKEN", "subject")
+	p.SubjectTokenEnv = "TEST_SUBJECT_TOKEN"
+	_, err := p.GetHeaders(context.Background())
+	if err == nil || err.Error() != "token exchange: invalid_grant — subject token expired" {
+		t.Errorf("error = %v, want OAuth error", err)
+	}
+}
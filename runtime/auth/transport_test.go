@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders([]string{"x-tenant=acme", " X-Empty =", "X-Eq=a=b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"X-Tenant": "acme", "X-Empty": "", "X-Eq": "a=b"}
+	for k, v := range want {
+		if headers[k] != v {
+			t.Errorf("%s = %q, want %q", k, headers[k], v)
+		}
+	}
+	for _, bad := range []string{"no-equals", "=value", "Bad Key=x", "X-A=line\nbreak"} {
+		if _, err := ParseHeaders([]string{bad}); err == nil || !strings.Contains(err.Error(), "expected KEY=VALUE") {
+			t.Errorf("ParseHeaders(%q) error = %v", bad, err)
+		}
+	}
+}
+
+func TestParseProxy(t *testing.T) {
+	for _, good := range []string{"http://proxy:3128", "https://user:pw@proxy.example", "socks5://127.0.0.1:1080"} {
+		if _, err := ParseProxy(good); err != nil {
+			t.Errorf("ParseProxy(%q): %v", good, err)
+		}
+	}
+	for _, bad := range []string{"proxy:3128", "ftp://proxy", "://"} {
+		if _, err := ParseProxy(bad); err == nil {
+			t.Errorf("ParseProxy(%q) accepted", bad)
+		}
+	}
+}
+
+func TestBypassProxy(t *testing.T) {
+	tests := []struct {
+		url, noProxy string
+		want         bool
+	}{
+		{"http://mcp.example.com/mcp", "", false},
+		{"http://localhost:8080/", "", true},
+		{"http://127.0.0.1/", "", true},
+		{"http://mcp.example.com/", "example.com", true},
+		{"http://mcp.example.com/", ".example.com", true},
+		{"http://notexample.com/", "example.com", false},
+		{"http://mcp.example.com:8443/", "mcp.example.com:8443", true},
+		{"http://mcp.example.com:9000/", "mcp.example.com:8443", false},
+		{"http://10.1.2.3/", "10.0.0.0/8", true},
+		{"http://other.test/", "a.test, *", true},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := bypassProxy(u, tt.noProxy); got != tt.want {
+			t.Errorf("bypassProxy(%s, %q) = %v, want %v", tt.url, tt.noProxy, got, tt.want)
+		}
+	}
+}
+
+func TestTransportOptions_RoundTripper(t *testing.T) {
+	var got *http.Request
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+	}))
+	defer proxy.Close()
+	t.Setenv("HTTP_PROXY", "")
+	t.Setenv("NO_PROXY", "")
+
+	rt, err := TransportOptions{Proxy: proxy.URL, UserAgent: "test/1.0"}.RoundTripper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: rt}).Get("http://mcp.clihub.test/mcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got == nil || got.URL.Host != "mcp.clihub.test" {
+		t.Fatalf("request did not go through the proxy: %+v", got)
+	}
+	if ua := got.Header.Get("User-Agent"); ua != "test/1.0" {
+		t.Errorf("User-Agent = %q", ua)
+	}
+
+	if _, err := (TransportOptions{Proxy: "not a url"}).RoundTripper(); err == nil {
+		t.Error("expected an invalid proxy error")
+	}
+}
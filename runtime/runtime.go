@@ -0,0 +1,17 @@
+// Package runtime holds the code that generated CLIs share with clihub.
+//
+// Generated projects import github.com/thellimist/clihub/runtime/auth and,
+// with self-update enabled, runtime/update. clihub copies the package source
+// from Source into the project and points the module at it with a replace
+// directive, so a project builds with the runtime of the clihub that
+// generated it and no published clihub version is needed. The runtime's own
+// dependencies (golang.org/x/crypto and x/oauth2) are still downloaded by
+// go mod tidy like any other.
+package runtime
+
+import "embed"
+
+// Source is the source of the runtime packages, tests included.
+//
+//go:embed auth/*.go update/*.go
+var Source embed.FS
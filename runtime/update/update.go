@@ -0,0 +1,440 @@
+// Package update lets a generated CLI replace itself with a newer release.
+//
+// A release is what `clihub generate` writes to its output directory: the
+// binaries, an SBOM per binary, SHA256SUMS and, when signed, SHA256SUMS.minisig
+// or SHA256SUMS.sig. Publish that directory as is, on a file share or under
+// an HTTP base URL, and point the CLI at it.
+package update
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// ChecksumsFile is the name of the release's checksum file.
+const ChecksumsFile = "SHA256SUMS"
+
+// maxMetadataSize caps SHA256SUMS, signatures and SBOMs read from a release.
+const maxMetadataSize = 4 << 20
+
+// Config describes the running CLI and where its releases are published.
+type Config struct {
+	Name      string       // CLI name, the prefix of the release's binary names
+	Version   string       // version of the running binary
+	URL       string       // release location: a directory, a file:// URL or an http(s) base URL
+	PublicKey string       // minisign or PEM public key SHA256SUMS must be signed with; empty checks checksums only, and needs a local or https URL
+	Client    *http.Client // used for http(s) URLs; nil means http.DefaultClient
+}
+
+// Release is the binary a release offers for this platform.
+type Release struct {
+	Binary    string // file name in the release
+	SHA256    string // hex SHA-256 of the binary, from SHA256SUMS
+	Version   string // release version from the binary's SBOM; empty if it has none
+	Signed    bool   // whether SHA256SUMS was verified against Config.PublicKey
+	Available bool   // whether the binary differs from the running one and is newer; never true when Version is empty
+}
+
+// Check reads the release at cfg.URL, verifies the signature of SHA256SUMS
+// when cfg.PublicKey is set, and finds the binary for this platform.
+//
+// Without a public key nothing vouches for SHA256SUMS but the transport, so
+// Check refuses plain http URLs other than loopback ones. A release without
+// an SBOM has no known version and is never offered as an update, since it
+// could be older than the running binary.
+func Check(ctx context.Context, cfg Config) (*Release, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("no release location configured")
+	}
+	if err := CheckURL(cfg.URL, cfg.PublicKey); err != nil {
+		return nil, err
+	}
+	sums, err := cfg.fetch(ctx, ChecksumsFile)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.PublicKey != "" {
+		sigName := ChecksumsFile + ".sig"
+		if isMinisignKey(cfg.PublicKey) {
+			sigName = ChecksumsFile + ".minisig"
+		}
+		sig, err := cfg.fetch(ctx, sigName)
+		if err != nil {
+			return nil, err
+		}
+		if err := VerifySignature(sums, sig, cfg.PublicKey); err != nil {
+			return nil, fmt.Errorf("%s: %w", ChecksumsFile, err)
+		}
+	}
+	checksums := ParseChecksums(sums)
+
+	rel := &Release{Signed: cfg.PublicKey != ""}
+	for _, name := range releaseNames(cfg.Name, runtime.GOOS, runtime.GOARCH, HostGOARM()) {
+		if sum, ok := checksums[name]; ok {
+			rel.Binary, rel.SHA256 = name, sum
+			break
+		}
+	}
+	if rel.Binary == "" {
+		return nil, fmt.Errorf("release has no %s binary for %s/%s", cfg.Name, runtime.GOOS, runtime.GOARCH)
+	}
+
+	// The SBOM names the version, and the platform of a single-platform
+	// release whose binary name doesn't.
+	sbomName := strings.TrimSuffix(strings.TrimSuffix(rel.Binary, ".exe"), ".wasm") + ".sbom.json"
+	if sum, ok := checksums[sbomName]; ok {
+		data, err := cfg.fetch(ctx, sbomName)
+		if err != nil {
+			return nil, err
+		}
+		if sha256Hex(data) != sum {
+			return nil, fmt.Errorf("%s does not match %s", sbomName, ChecksumsFile)
+		}
+		info, err := readSBOM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sbomName, err)
+		}
+		if info.goos != runtime.GOOS || info.goarch != runtime.GOARCH {
+			return nil, fmt.Errorf("release binary %s is built for %s/%s, not %s/%s", rel.Binary, info.goos, info.goarch, runtime.GOOS, runtime.GOARCH)
+		}
+		rel.Version = info.version
+	}
+
+	exe, err := executable()
+	if err != nil {
+		return nil, err
+	}
+	current, err := fileSHA256(exe)
+	if err != nil {
+		return nil, err
+	}
+	rel.Available = current != rel.SHA256 && rel.Version != ""
+	if c, ok := compareVersions(rel.Version, cfg.Version); ok && c < 0 {
+		rel.Available = false
+	}
+	return rel, nil
+}
+
+// Apply downloads rel's binary, checks it against SHA256SUMS and atomically
+// replaces the running executable with it. Returns the replaced path. A
+// release of unknown version is refused, as in Check.
+func Apply(ctx context.Context, cfg Config, rel *Release) (string, error) {
+	if rel.Version == "" {
+		return "", fmt.Errorf("release %s has no SBOM, so its version is unknown; refusing to update", rel.Binary)
+	}
+	exe, err := executable()
+	if err != nil {
+		return "", err
+	}
+	st, err := os.Stat(exe)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := cfg.open(ctx, rel.Binary)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	// The new binary is written next to the old one so the final rename
+	// stays on one file system.
+	tmp, err := os.CreateTemp(filepath.Dir(exe), "."+filepath.Base(exe)+".new-*")
+	if err != nil {
+		return "", fmt.Errorf("cannot write next to %s: %w", exe, err)
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("download %s: %w", rel.Binary, err)
+	}
+	if got := hex.EncodeToString(h.Sum(nil)); got != rel.SHA256 {
+		return "", fmt.Errorf("%s does not match %s (got %s, want %s)", rel.Binary, ChecksumsFile, got, rel.SHA256)
+	}
+	if err := os.Chmod(tmp.Name(), st.Mode().Perm()|0111); err != nil {
+		return "", err
+	}
+
+	if runtime.GOOS == "windows" {
+		// A running executable can't be overwritten on windows, but it can
+		// be renamed out of the way.
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			return "", fmt.Errorf("replace %s: %w", exe, err)
+		}
+		if err := os.Rename(tmp.Name(), exe); err != nil {
+			os.Rename(old, exe)
+			return "", fmt.Errorf("replace %s: %w", exe, err)
+		}
+		return exe, nil
+	}
+	if err := os.Rename(tmp.Name(), exe); err != nil {
+		return "", fmt.Errorf("replace %s: %w", exe, err)
+	}
+	return exe, nil
+}
+
+// BinaryName returns the name clihub gives a binary: <name>-<os>-<arch>[v<arm>]
+// in a multi-platform build and <name> otherwise, with .exe on windows and
+// .wasm on wasip1.
+func BinaryName(name, goos, goarch, goarm string, multiPlatform bool) string {
+	result := name
+	if multiPlatform {
+		result = fmt.Sprintf("%s-%s-%s", name, goos, goarch)
+		if goarm != "" {
+			result += "v" + goarm
+		}
+	}
+	switch goos {
+	case "windows":
+		result += ".exe"
+	case "wasip1":
+		result += ".wasm"
+	}
+	return result
+}
+
+// ParseChecksums parses a SHA256SUMS file into a file name → hex digest map.
+func ParseChecksums(data []byte) map[string]string {
+	sums := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		sum, name, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok {
+			continue
+		}
+		// sha256sum marks binary-mode entries with a leading '*'.
+		name = strings.TrimPrefix(strings.TrimSpace(name), "*")
+		sums[name] = strings.ToLower(sum)
+	}
+	return sums
+}
+
+// releaseNames returns the names this platform's binary may have in a
+// release, most specific first. The build info records GOARM even when the
+// build left it at Go's default, and clihub only puts pinned versions in the
+// name, so an ARM binary is looked for both with and without its version.
+func releaseNames(name, goos, goarch, goarm string) []string {
+	names := []string{BinaryName(name, goos, goarch, goarm, true)}
+	if goarm != "" {
+		names = append(names, BinaryName(name, goos, goarch, "", true))
+	}
+	return append(names, BinaryName(name, goos, goarch, goarm, false))
+}
+
+// HostGOARM returns the GOARM the running binary was built with, or "" off
+// ARM or without build info.
+func HostGOARM() string {
+	if runtime.GOARCH != "arm" {
+		return ""
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return buildGOARM(info)
+	}
+	return ""
+}
+
+// buildGOARM returns the ARM version in a binary's build settings.
+func buildGOARM(info *debug.BuildInfo) string {
+	for _, s := range info.Settings {
+		if s.Key == "GOARM" {
+			return strings.SplitN(s.Value, ",", 2)[0]
+		}
+	}
+	return ""
+}
+
+// executable returns the path of the running binary, with symlinks
+// resolved so the update replaces the real file. Tests replace it.
+var executable = func() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("locate running binary: %w", err)
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+func isRemote(u string) bool {
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
+}
+
+// CheckURL reports whether releases can be fetched from rawURL safely: a
+// plain http URL, other than a loopback one, needs publicKey to vouch for
+// SHA256SUMS.
+func CheckURL(rawURL, publicKey string) error {
+	if publicKey == "" && strings.HasPrefix(rawURL, "http://") && !isLoopback(rawURL) {
+		return fmt.Errorf("release at %s is neither signed nor served over https: use an https URL, or sign releases (clihub generate --sign-key with --update-key)", rawURL)
+	}
+	return nil
+}
+
+// isLoopback reports whether the host of rawURL is localhost or a loopback
+// address, which plain http can reach without crossing the network.
+func isLoopback(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	if u.Hostname() == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(u.Hostname())
+	return ip != nil && ip.IsLoopback()
+}
+
+// open opens a file of the release.
+func (c Config) open(ctx context.Context, name string) (io.ReadCloser, error) {
+	if !isRemote(c.URL) {
+		return os.Open(filepath.Join(strings.TrimPrefix(c.URL, "file://"), name))
+	}
+	url := strings.TrimSuffix(c.URL, "/") + "/" + name
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// fetch reads a small file of the release.
+func (c Config) fetch(ctx context.Context, name string) ([]byte, error) {
+	body, err := c.open(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("read release %s: %w", name, err)
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, maxMetadataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read release %s: %w", name, err)
+	}
+	if len(data) > maxMetadataSize {
+		return nil, fmt.Errorf("read release %s: file too large", name)
+	}
+	return data, nil
+}
+
+type sbomInfo struct{ version, goos, goarch string }
+
+// readSBOM reads the version and platform from a clihub CycloneDX SBOM.
+func readSBOM(data []byte) (*sbomInfo, error) {
+	var bom struct {
+		Metadata struct {
+			Component struct {
+				Version    string `json:"version"`
+				Properties []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"properties"`
+			} `json:"component"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &bom); err != nil {
+		return nil, fmt.Errorf("invalid SBOM: %w", err)
+	}
+	info := &sbomInfo{version: bom.Metadata.Component.Version}
+	for _, p := range bom.Metadata.Component.Properties {
+		switch p.Name {
+		case "go:GOOS":
+			info.goos = p.Value
+		case "go:GOARCH":
+			info.goarch = p.Value
+		}
+	}
+	if info.goos == "" || info.goarch == "" {
+		return nil, fmt.Errorf("SBOM does not name a platform")
+	}
+	return info, nil
+}
+
+// compareVersions compares two MAJOR.MINOR.PATCH versions, with an optional
+// "v" prefix and pre-release suffix. ok is false if either doesn't parse.
+func compareVersions(a, b string) (c int, ok bool) {
+	pa, prea, ok1 := parseVersion(a)
+	pb, preb, ok2 := parseVersion(b)
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	for i := range pa {
+		if pa[i] != pb[i] {
+			if pa[i] < pb[i] {
+				return -1, true
+			}
+			return 1, true
+		}
+	}
+	switch {
+	case prea == preb:
+		return 0, true
+	case prea == "":
+		return 1, true
+	case preb == "":
+		return -1, true
+	case prea < preb:
+		return -1, true
+	}
+	return 1, true
+}
+
+func parseVersion(v string) ([3]int, string, bool) {
+	var parts [3]int
+	v, _, _ = strings.Cut(strings.TrimPrefix(v, "v"), "+")
+	v, pre, _ := strings.Cut(v, "-")
+	fields := strings.Split(v, ".")
+	if len(fields) != 3 {
+		return parts, "", false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return parts, "", false
+		}
+		parts[i] = n
+	}
+	return parts, pre, true
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
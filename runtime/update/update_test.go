@@ -0,0 +1,387 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"debug/buildinfo"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/thellimist/clihub/internal/release"
+	"golang.org/x/crypto/blake2b"
+)
+
+// writeMinisignKeys writes an unencrypted minisign secret key and returns its
+// path with the matching public key file contents.
+func writeMinisignKeys(t *testing.T) (string, string) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := make([]byte, 8)
+	rand.Read(keyID)
+	checksum := blake2b.Sum256(append(append([]byte("Ed"), keyID...), priv...))
+	raw := append([]byte("Ed\x00\x00B2"), make([]byte, 48)...)
+	raw = append(append(append(raw, keyID...), priv...), checksum[:]...)
+
+	path := filepath.Join(t.TempDir(), "minisign.key")
+	secret := "untrusted comment: minisign secret key\n" + base64.StdEncoding.EncodeToString(raw) + "\n"
+	if err := os.WriteFile(path, []byte(secret), 0600); err != nil {
+		t.Fatal(err)
+	}
+	public := "untrusted comment: minisign public key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), keyID...), pub...)) + "\n"
+	return path, public
+}
+
+// writePEMKeys writes an Ed25519 PEM private key and returns its path with
+// the PEM public key.
+func writePEMKeys(t *testing.T) (string, string) {
+	t.Helper()
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(priv)
+	path := filepath.Join(t.TempDir(), "cosign.key")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	pubDER, _ := x509.MarshalPKIXPublicKey(pub)
+	return path, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}))
+}
+
+func TestVerifySignature(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		keys func(*testing.T) (string, string)
+	}{
+		{"minisign", writeMinisignKeys},
+		{"pem", writePEMKeys},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			keyPath, pub := tc.keys(t)
+			if err := CheckPublicKey(pub); err != nil {
+				t.Fatalf("CheckPublicKey: %v", err)
+			}
+			msg := []byte("abc  mycli\n")
+			path := filepath.Join(t.TempDir(), ChecksumsFile)
+			os.WriteFile(path, msg, 0644)
+			sigPath, err := release.Sign(path, keyPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sig, _ := os.ReadFile(sigPath)
+
+			if err := VerifySignature(msg, sig, pub); err != nil {
+				t.Errorf("VerifySignature: %v", err)
+			}
+			if err := VerifySignature([]byte("abd  mycli\n"), sig, pub); err == nil {
+				t.Error("tampered message verified")
+			}
+			_, other := tc.keys(t)
+			if err := VerifySignature(msg, sig, other); err == nil {
+				t.Error("signature verified with another key")
+			}
+		})
+	}
+}
+
+func TestCheckPublicKey_Invalid(t *testing.T) {
+	for _, key := range []string{"", "not a key", "-----BEGIN PUBLIC KEY-----\nAAAA\n-----END PUBLIC KEY-----\n"} {
+		if err := CheckPublicKey(key); err == nil {
+			t.Errorf("CheckPublicKey(%q) succeeded", key)
+		}
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	sums := ParseChecksums([]byte("ABC  mycli-linux-amd64\ndef *mycli.exe\n\nbogus\n"))
+	if len(sums) != 2 || sums["mycli-linux-amd64"] != "abc" || sums["mycli.exe"] != "def" {
+		t.Errorf("ParseChecksums = %v", sums)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+		ok   bool
+	}{
+		{"1.2.0", "1.10.0", -1, true},
+		{"v2.0.0", "1.9.9", 1, true},
+		{"1.0.0", "1.0.0", 0, true},
+		{"1.0.0-rc.1", "1.0.0", -1, true},
+		{"1.0.0", "1.0.0-rc.1", 1, true},
+		{"", "1.0.0", 0, false},
+		{"1.0", "1.0.0", 0, false},
+	} {
+		got, ok := compareVersions(tc.a, tc.b)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("compareVersions(%q, %q) = %d, %v; want %d, %v", tc.a, tc.b, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+// writeRelease publishes binary as a release of mycli in dir, in the
+// multi-platform layout, with an SBOM naming version and a signed
+// SHA256SUMS.
+func writeRelease(t *testing.T, dir, binary, version, keyPath string) {
+	t.Helper()
+	name := BinaryName("mycli", runtime.GOOS, runtime.GOARCH, HostGOARM(), true)
+	sbomName := strings.TrimSuffix(strings.TrimSuffix(name, ".exe"), ".wasm") + ".sbom.json"
+	sbom := fmt.Sprintf(`{"bomFormat":"CycloneDX","metadata":{"component":{"name":"mycli","version":%q,"properties":[{"name":"go:GOOS","value":%q},{"name":"go:GOARCH","value":%q}]}}}`, version, runtime.GOOS, runtime.GOARCH)
+	files := map[string]string{name: binary, sbomName: sbom}
+	var paths []string
+	for n, content := range files {
+		p := filepath.Join(dir, n)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, p)
+	}
+	sums, err := release.WriteChecksums(dir, paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := release.Sign(sums, keyPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeExecutable makes Check and Apply treat a file holding content as the
+// running binary.
+func fakeExecutable(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mycli")
+	if err := os.WriteFile(path, []byte(content), 0755); err != nil {
+		t.Fatal(err)
+	}
+	orig := executable
+	executable = func() (string, error) { return path, nil }
+	t.Cleanup(func() { executable = orig })
+	return path
+}
+
+func TestReleaseNames_BareARM(t *testing.T) {
+	// A linux/arm build without GOARM is published as <name>-linux-arm, but
+	// its build info still records Go's default ARM version.
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module armtest\n\ngo 1.24\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	bin := filepath.Join(dir, "mycli-linux-arm")
+	cmd := exec.Command("go", "build", "-o", bin, ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH=arm", "GOARM=", "CGO_ENABLED=0")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go build: %v\n%s", err, out)
+	}
+	info, err := buildinfo.ReadFile(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goarm := buildGOARM(info)
+	if goarm == "" {
+		t.Fatal("build info records no GOARM")
+	}
+
+	names := releaseNames("mycli", "linux", "arm", goarm)
+	want := []string{"mycli-linux-armv" + goarm, "mycli-linux-arm", "mycli"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("releaseNames = %q, want %q", names, want)
+	}
+	if got := releaseNames("mycli", "linux", "amd64", ""); strings.Join(got, " ") != "mycli-linux-amd64 mycli" {
+		t.Errorf("releaseNames(amd64) = %q", got)
+	}
+}
+
+func TestCheckAndApply(t *testing.T) {
+	keyPath, pub := writeMinisignKeys(t)
+	dir := t.TempDir()
+	writeRelease(t, dir, "new binary", "1.1.0", keyPath)
+	exe := fakeExecutable(t, "old binary")
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+
+	for _, url := range []string{dir, "file://" + dir, server.URL + "/"} {
+		cfg := Config{Name: "mycli", Version: "1.0.0", URL: url, PublicKey: pub}
+		rel, err := Check(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("Check(%s): %v", url, err)
+		}
+		if !rel.Available || !rel.Signed || rel.Version != "1.1.0" {
+			t.Errorf("Check(%s) = %+v, want 1.1.0 available", url, rel)
+		}
+	}
+
+	cfg := Config{Name: "mycli", Version: "1.0.0", URL: server.URL, PublicKey: pub}
+	rel, err := Check(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := Apply(context.Background(), cfg, rel)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	data, _ := os.ReadFile(exe)
+	if path != exe || string(data) != "new binary" {
+		t.Errorf("Apply replaced %s with %q", path, data)
+	}
+	if st, _ := os.Stat(exe); st.Mode().Perm()&0100 == 0 {
+		t.Errorf("updated binary mode = %v, want executable", st.Mode())
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(filepath.Dir(exe), ".mycli.new-*"))
+	if len(leftovers) != 0 {
+		t.Errorf("temporary files left behind: %v", leftovers)
+	}
+
+	cfg.Version = "1.1.0"
+	if rel, err = Check(context.Background(), cfg); err != nil || rel.Available {
+		t.Errorf("Check after update = %+v, %v; want up to date", rel, err)
+	}
+}
+
+func TestCheck_OlderRelease(t *testing.T) {
+	keyPath, pub := writeMinisignKeys(t)
+	dir := t.TempDir()
+	writeRelease(t, dir, "old binary", "0.9.0", keyPath)
+	fakeExecutable(t, "newer binary")
+
+	rel, err := Check(context.Background(), Config{Name: "mycli", Version: "1.0.0", URL: dir, PublicKey: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Available {
+		t.Error("an older release should not be offered as an update")
+	}
+}
+
+func TestCheck_Rejects(t *testing.T) {
+	keyPath, pub := writeMinisignKeys(t)
+	_, otherPub := writeMinisignKeys(t)
+	fakeExecutable(t, "old binary")
+
+	tampered := t.TempDir()
+	writeRelease(t, tampered, "new binary", "1.1.0", keyPath)
+	sums, _ := os.ReadFile(filepath.Join(tampered, ChecksumsFile))
+	os.WriteFile(filepath.Join(tampered, ChecksumsFile), append(sums, "abc  extra\n"...), 0644)
+
+	good := t.TempDir()
+	writeRelease(t, good, "new binary", "1.1.0", keyPath)
+
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"tampered sums", Config{Name: "mycli", URL: tampered, PublicKey: pub}, "signature verification failed"},
+		{"wrong key", Config{Name: "mycli", URL: good, PublicKey: otherPub}, "different key"},
+		{"other cli", Config{Name: "othercli", URL: good, PublicKey: pub}, "no othercli binary"},
+		{"missing", Config{Name: "mycli", URL: t.TempDir()}, "read release SHA256SUMS"},
+	} {
+		_, err := Check(context.Background(), tc.cfg)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	_, pub := writeMinisignKeys(t)
+	for _, tc := range []struct {
+		url, key string
+		ok       bool
+	}{
+		{"http://releases.example.com/mycli", "", false},
+		{"http://releases.example.com/mycli", pub, true},
+		{"https://releases.example.com/mycli", "", true},
+		{"http://127.0.0.1:8080/", "", true},
+		{"http://localhost/", "", true},
+		{"/srv/releases/mycli", "", true},
+	} {
+		if err := CheckURL(tc.url, tc.key); (err == nil) != tc.ok {
+			t.Errorf("CheckURL(%s, key=%v) = %v", tc.url, tc.key != "", err)
+		}
+	}
+}
+
+func TestCheck_UnsignedUnknownVersion(t *testing.T) {
+	dir := t.TempDir()
+	name := BinaryName("mycli", runtime.GOOS, runtime.GOARCH, HostGOARM(), true)
+	path := filepath.Join(dir, name)
+	os.WriteFile(path, []byte("new binary"), 0644)
+	if _, err := release.WriteChecksums(dir, []string{path}); err != nil {
+		t.Fatal(err)
+	}
+	exe := fakeExecutable(t, "old binary")
+
+	cfg := Config{Name: "mycli", Version: "1.0.0", URL: dir}
+	rel, err := Check(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Signed || rel.Version != "" || rel.Available {
+		t.Errorf("Check = %+v, want unsigned, unknown version, not available", rel)
+	}
+	if _, err := Apply(context.Background(), cfg, rel); err == nil || !strings.Contains(err.Error(), "version is unknown") {
+		t.Errorf("Apply err = %v, want unknown version refusal", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old binary" {
+		t.Errorf("binary replaced: %q", data)
+	}
+}
+
+func TestApply_ChecksumMismatch(t *testing.T) {
+	keyPath, pub := writeMinisignKeys(t)
+	dir := t.TempDir()
+	writeRelease(t, dir, "new binary", "1.1.0", keyPath)
+	exe := fakeExecutable(t, "old binary")
+
+	cfg := Config{Name: "mycli", Version: "1.0.0", URL: dir, PublicKey: pub}
+	rel, err := Check(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, rel.Binary), []byte("evil binary"), 0644)
+	if _, err := Apply(context.Background(), cfg, rel); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Errorf("Apply err = %v, want checksum mismatch", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old binary" {
+		t.Errorf("binary replaced despite mismatch: %q", data)
+	}
+}
+
+func TestVerifySignature_ToolFixtures(t *testing.T) {
+	msg, err := os.ReadFile(filepath.Join("testdata", "message.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct{ key, sig string }{
+		{"minisign.pub", "message.txt.minisig"},
+		{"cosign.pub", "message.txt.sig"},
+	} {
+		t.Run(tc.key, func(t *testing.T) {
+			pub, _ := os.ReadFile(filepath.Join("testdata", tc.key))
+			sig, _ := os.ReadFile(filepath.Join("testdata", tc.sig))
+			if err := CheckPublicKey(string(pub)); err != nil {
+				t.Fatalf("CheckPublicKey: %v", err)
+			}
+			if err := VerifySignature(msg, sig, string(pub)); err != nil {
+				t.Errorf("VerifySignature: %v", err)
+			}
+			if err := VerifySignature(append(msg, '!'), sig, string(pub)); err == nil {
+				t.Error("tampered message verified")
+			}
+		})
+	}
+}
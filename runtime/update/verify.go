@@ -0,0 +1,149 @@
+package update
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// VerifySignature checks sig, the contents of a signature file, against msg
+// with publicKey, which is either
+//
+//   - a minisign public key (the .pub file, or just its base64 line), for a
+//     .minisig signature, or
+//   - a PEM ECDSA or Ed25519 public key, such as cosign.pub, for a .sig
+//     signature holding the base64 signature `cosign sign-blob` writes.
+func VerifySignature(msg, sig []byte, publicKey string) error {
+	if isMinisignKey(publicKey) {
+		return verifyMinisign(msg, sig, publicKey)
+	}
+	return verifyPEM(msg, sig, publicKey)
+}
+
+// CheckPublicKey reports whether publicKey is a key VerifySignature accepts.
+func CheckPublicKey(publicKey string) error {
+	if isMinisignKey(publicKey) {
+		_, _, err := parseMinisignKey(publicKey)
+		return err
+	}
+	_, err := parsePEMKey(publicKey)
+	return err
+}
+
+// isMinisignKey reports whether key is a minisign public key rather than PEM.
+func isMinisignKey(key string) bool {
+	return !strings.Contains(key, "-----BEGIN")
+}
+
+// verifyMinisign checks a minisign signature file: untrusted comment, base64
+// of sig_alg[2] | key_id[8] | signature[64], trusted comment, and base64 of
+// the global signature over signature || trusted comment. sig_alg "ED"
+// signs the BLAKE2b-512 hash of msg, legacy "Ed" signs msg itself.
+func verifyMinisign(msg, sigFile []byte, publicKey string) error {
+	keyID, key, err := parseMinisignKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	sigLines := strings.Split(strings.TrimSpace(string(sigFile)), "\n")
+	if len(sigLines) != 4 || !strings.HasPrefix(sigLines[2], "trusted comment: ") {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sigLines[1]))
+	if err != nil || len(raw) != 74 {
+		return fmt.Errorf("invalid minisign signature")
+	}
+	if !bytes.Equal(raw[2:10], keyID) {
+		return fmt.Errorf("signed with a different key (key ID %X, want %X)", reverse(raw[2:10]), reverse(keyID))
+	}
+	signed := msg
+	switch string(raw[:2]) {
+	case "ED":
+		digest := blake2b.Sum512(msg)
+		signed = digest[:]
+	case "Ed":
+	default:
+		return fmt.Errorf("unsupported minisign signature algorithm")
+	}
+	if !ed25519.Verify(key, signed, raw[10:74]) {
+		return fmt.Errorf("signature verification failed")
+	}
+
+	global, err := base64.StdEncoding.DecodeString(strings.TrimSpace(sigLines[3]))
+	trusted := strings.TrimPrefix(strings.TrimRight(sigLines[2], "\r"), "trusted comment: ")
+	if err != nil || !ed25519.Verify(key, append(append([]byte(nil), raw[10:74]...), trusted...), global) {
+		return fmt.Errorf("trusted comment signature verification failed")
+	}
+	return nil
+}
+
+// parseMinisignKey decodes a minisign public key, the base64 of
+// sig_alg "Ed" | key_id[8] | public_key[32] on the last line of a .pub file.
+func parseMinisignKey(publicKey string) ([]byte, ed25519.PublicKey, error) {
+	lines := strings.Split(strings.TrimSpace(publicKey), "\n")
+	pub, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil || len(pub) != 42 || string(pub[:2]) != "Ed" {
+		return nil, nil, fmt.Errorf("invalid minisign public key")
+	}
+	return pub[2:10], ed25519.PublicKey(pub[10:42]), nil
+}
+
+// reverse returns b reversed; minisign prints key IDs as little-endian hex.
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+// verifyPEM checks a base64 signature made the way `cosign sign-blob` does:
+// ASN.1 ECDSA over SHA-256(msg), or Ed25519 over msg.
+func verifyPEM(msg, sigFile []byte, publicKey string) error {
+	key, err := parsePEMKey(publicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sigFile)))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+
+	var ok bool
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(msg)
+		ok = ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, msg, sig)
+	}
+	if !ok {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+// parsePEMKey parses a PEM ECDSA or Ed25519 public key.
+func parsePEMKey(publicKey string) (any, error) {
+	block, _ := pem.Decode([]byte(publicKey))
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key: %w", err)
+	}
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", key)
+}